@@ -13,10 +13,20 @@ type MetricMaker interface {
 	Log() telegraf.Logger
 }
 
+// errorRecorder is implemented by makers keeping track of the errors reported
+// via the accumulator, e.g. to show them in the admin API.
+type errorRecorder interface {
+	SetLastError(err error)
+}
+
 type accumulator struct {
 	maker     MetricMaker
 	metrics   chan<- telegraf.Metric
 	precision time.Duration
+
+	// now returns the time of metrics added without timestamp, the current
+	// time is used if unset
+	now func() time.Time
 }
 
 func NewAccumulator(
@@ -103,6 +113,9 @@ func (ac *accumulator) AddError(err error) {
 		return
 	}
 	ac.maker.Log().Errorf("Error in plugin: %v", err)
+	if r, ok := ac.maker.(errorRecorder); ok {
+		r.SetLastError(err)
+	}
 }
 
 func (ac *accumulator) SetPrecision(precision time.Duration) {
@@ -113,6 +126,8 @@ func (ac *accumulator) getTime(t []time.Time) time.Time {
 	var timestamp time.Time
 	if len(t) > 0 {
 		timestamp = t[0]
+	} else if ac.now != nil {
+		timestamp = ac.now()
 	} else {
 		timestamp = time.Now()
 	}
@@ -0,0 +1,370 @@
+package agent
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/models"
+	"github.com/influxdata/telegraf/plugins/common/auth"
+)
+
+// adminServer serves the HTTP admin API allowing to inspect and control the
+// running agent.
+type adminServer struct {
+	agent  *Agent
+	auth   auth.BasicAuth
+	addr   net.Addr
+	server *http.Server
+	wg     sync.WaitGroup
+}
+
+type adminPlugins struct {
+	Inputs      []adminPlugin `json:"inputs"`
+	Processors  []adminPlugin `json:"processors"`
+	Aggregators []adminPlugin `json:"aggregators"`
+	Outputs     []adminPlugin `json:"outputs"`
+}
+
+type adminPlugin struct {
+	ID        string       `json:"id"`
+	Name      string       `json:"name"`
+	Alias     string       `json:"alias,omitempty"`
+	Filter    adminFilter  `json:"filter"`
+	Paused    bool         `json:"paused,omitempty"`
+	Buffer    *adminBuffer `json:"buffer,omitempty"`
+	LastError *adminError  `json:"last_error,omitempty"`
+}
+
+type adminFilter struct {
+	NamePass     []string            `json:"namepass,omitempty"`
+	NameDrop     []string            `json:"namedrop,omitempty"`
+	FieldInclude []string            `json:"fieldinclude,omitempty"`
+	FieldExclude []string            `json:"fieldexclude,omitempty"`
+	TagPass      map[string][]string `json:"tagpass,omitempty"`
+	TagDrop      map[string][]string `json:"tagdrop,omitempty"`
+	TagInclude   []string            `json:"taginclude,omitempty"`
+	TagExclude   []string            `json:"tagexclude,omitempty"`
+	MetricPass   string              `json:"metricpass,omitempty"`
+}
+
+type adminBuffer struct {
+	Strategy        string `json:"strategy"`
+	Length          int    `json:"length"`
+	Limit           int    `json:"limit"`
+	DiskUsage       int64  `json:"disk_usage,omitempty"`
+	MetricsAdded    int64  `json:"metrics_added"`
+	MetricsWritten  int64  `json:"metrics_written"`
+	MetricsRejected int64  `json:"metrics_rejected"`
+	MetricsDropped  int64  `json:"metrics_dropped"`
+}
+
+type adminError struct {
+	Message string    `json:"message"`
+	Time    time.Time `json:"time"`
+}
+
+// SetReloadHandler sets the function called when a configuration reload is
+// requested via the admin API. Reloading is not available if unset.
+func (a *Agent) SetReloadHandler(f func() error) {
+	a.Lock()
+	defer a.Unlock()
+	a.reloadHandler = f
+}
+
+// startAdminServer starts serving the admin API on the configured address.
+func (a *Agent) startAdminServer(cfg *config.AdminConfig) (*adminServer, error) {
+	tlsConfig, err := cfg.TLSConfig()
+	if err != nil {
+		return nil, fmt.Errorf("creating TLS config failed: %w", err)
+	}
+
+	var listener net.Listener
+	scheme := "http"
+	if tlsConfig != nil {
+		listener, err = tls.Listen("tcp", cfg.Address, tlsConfig)
+		scheme = "https"
+	} else {
+		listener, err = net.Listen("tcp", cfg.Address)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("listening on %q failed: %w", cfg.Address, err)
+	}
+
+	// The API allows to control the agent so only serve it without
+	// authentication if it is not reachable from other hosts
+	authenticated := cfg.Username != "" || cfg.Password != "" || len(cfg.TLSAllowedCACerts) > 0
+	if !authenticated && !isLoopback(listener.Addr()) {
+		listener.Close()
+		return nil, fmt.Errorf("serving on non-loopback address %q requires credentials or client certificates", listener.Addr())
+	}
+
+	s := &adminServer{
+		agent: a,
+		auth:  cfg.BasicAuth,
+		addr:  listener.Addr(),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/v1/plugins", s.listPlugins)
+	mux.HandleFunc("POST /api/v1/flush", s.flush)
+	mux.HandleFunc("POST /api/v1/outputs/{id}/flush", s.flush)
+	mux.HandleFunc("POST /api/v1/inputs/{id}/pause", s.pause)
+	mux.HandleFunc("POST /api/v1/inputs/{id}/resume", s.resume)
+	mux.HandleFunc("POST /api/v1/reload", s.reload)
+
+	s.server = &http.Server{
+		Handler:           s.authenticate(mux),
+		TLSConfig:         tlsConfig,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	log.Printf("I! [agent] Serving admin API on %s://%s", scheme, s.addr)
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		if err := s.server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Printf("E! [agent] Serving admin API failed: %v", err)
+		}
+	}()
+
+	return s, nil
+}
+
+// isLoopback returns true if the given address is only reachable from the
+// local host.
+func isLoopback(addr net.Addr) bool {
+	tcpAddr, ok := addr.(*net.TCPAddr)
+	return ok && tcpAddr.IP.IsLoopback()
+}
+
+func (s *adminServer) stop() {
+	if err := s.server.Close(); err != nil {
+		log.Printf("E! [agent] Stopping admin API failed: %v", err)
+	}
+	s.wg.Wait()
+}
+
+func (s *adminServer) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !s.auth.Verify(r) {
+			w.Header().Set("WWW-Authenticate", `Basic realm="telegraf"`)
+			writeAdminError(w, http.StatusUnauthorized, errors.New("unauthorized"))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (s *adminServer) listPlugins(w http.ResponseWriter, _ *http.Request) {
+	a := s.agent
+	a.Lock()
+	defer a.Unlock()
+
+	response := adminPlugins{
+		Inputs:      make([]adminPlugin, 0, len(a.Config.Inputs)),
+		Processors:  make([]adminPlugin, 0, len(a.Config.Processors)),
+		Aggregators: make([]adminPlugin, 0, len(a.Config.Aggregators)),
+		Outputs:     make([]adminPlugin, 0, len(a.Config.Outputs)),
+	}
+	for _, input := range a.Config.Inputs {
+		p := adminPlugin{
+			ID:     input.ID(),
+			Name:   input.Config.Name,
+			Alias:  input.Config.Alias,
+			Filter: newAdminFilter(&input.Config.Filter),
+			Paused: input.Paused(),
+		}
+		p.LastError = newAdminError(input.LastError())
+		response.Inputs = append(response.Inputs, p)
+	}
+	for _, processor := range a.Config.Processors {
+		response.Processors = append(response.Processors, adminPlugin{
+			ID:     processor.ID(),
+			Name:   processor.Config.Name,
+			Alias:  processor.Config.Alias,
+			Filter: newAdminFilter(&processor.Config.Filter),
+		})
+	}
+	for _, aggregator := range a.Config.Aggregators {
+		response.Aggregators = append(response.Aggregators, adminPlugin{
+			ID:     aggregator.ID(),
+			Name:   aggregator.Config.Name,
+			Alias:  aggregator.Config.Alias,
+			Filter: newAdminFilter(&aggregator.Config.Filter),
+		})
+	}
+	for _, output := range a.Config.Outputs {
+		strategy := output.Config.BufferStrategy
+		if strategy == "" {
+			strategy = "memory"
+		}
+		stats := output.BufferStats()
+
+		p := adminPlugin{
+			ID:     output.ID(),
+			Name:   output.Config.Name,
+			Alias:  output.Config.Alias,
+			Filter: newAdminFilter(&output.Config.Filter),
+			Buffer: &adminBuffer{
+				Strategy:        strategy,
+				Length:          output.BufferLength(),
+				Limit:           output.MetricBufferLimit,
+				DiskUsage:       output.BufferDiskUsage(),
+				MetricsAdded:    stats.MetricsAdded.Get(),
+				MetricsWritten:  stats.MetricsWritten.Get(),
+				MetricsRejected: stats.MetricsRejected.Get(),
+				MetricsDropped:  stats.MetricsDropped.Get(),
+			},
+		}
+		p.LastError = newAdminError(output.LastError())
+		response.Outputs = append(response.Outputs, p)
+	}
+
+	writeAdminResponse(w, http.StatusOK, response)
+}
+
+// flush requests an immediate flush of the output with the given ID or of all
+// outputs if no ID is given.
+func (s *adminServer) flush(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+
+	a := s.agent
+	a.Lock()
+	p := a.pipeline
+	a.Unlock()
+	if p == nil {
+		writeAdminError(w, http.StatusServiceUnavailable, errors.New("agent is not running"))
+		return
+	}
+
+	flushed := make([]string, 0)
+	p.outputs.RLock()
+	for _, output := range p.outputs.outputs {
+		if id != "" && output.ID() != id {
+			continue
+		}
+		flush, found := p.outputs.flushes[output]
+		if !found {
+			continue
+		}
+		select {
+		case flush <- struct{}{}:
+		default:
+			// A flush is already pending
+		}
+		flushed = append(flushed, output.LogName())
+	}
+	p.outputs.RUnlock()
+
+	if id != "" && len(flushed) == 0 {
+		writeAdminError(w, http.StatusNotFound, fmt.Errorf("no output with ID %q", id))
+		return
+	}
+	writeAdminResponse(w, http.StatusAccepted, map[string][]string{"plugins": flushed})
+}
+
+func (s *adminServer) pause(w http.ResponseWriter, r *http.Request) {
+	s.setPaused(w, r.PathValue("id"), true)
+}
+
+func (s *adminServer) resume(w http.ResponseWriter, r *http.Request) {
+	s.setPaused(w, r.PathValue("id"), false)
+}
+
+// setPaused pauses or resumes all inputs with the given ID. Inputs with an
+// identical configuration share their ID and are thus affected together.
+func (s *adminServer) setPaused(w http.ResponseWriter, id string, paused bool) {
+	a := s.agent
+	a.Lock()
+	affected := make([]string, 0)
+	for _, input := range a.Config.Inputs {
+		if input.ID() != id {
+			continue
+		}
+		if paused {
+			input.Pause()
+			log.Printf("I! [agent] Paused %s via admin API", input.LogName())
+		} else {
+			input.Resume()
+			log.Printf("I! [agent] Resumed %s via admin API", input.LogName())
+		}
+		affected = append(affected, input.LogName())
+	}
+	a.Unlock()
+
+	if len(affected) == 0 {
+		writeAdminError(w, http.StatusNotFound, fmt.Errorf("no input with ID %q", id))
+		return
+	}
+	writeAdminResponse(w, http.StatusOK, map[string][]string{"plugins": affected})
+}
+
+func (s *adminServer) reload(w http.ResponseWriter, _ *http.Request) {
+	a := s.agent
+	a.Lock()
+	handler := a.reloadHandler
+	a.Unlock()
+
+	if handler == nil {
+		writeAdminError(w, http.StatusNotImplemented, errors.New("reloading is not supported"))
+		return
+	}
+	if err := handler(); err != nil {
+		writeAdminError(w, http.StatusInternalServerError, err)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func newAdminFilter(f *models.Filter) adminFilter {
+	tagFilters := func(filters []models.TagFilter) map[string][]string {
+		if len(filters) == 0 {
+			return nil
+		}
+		m := make(map[string][]string, len(filters))
+		for _, tf := range filters {
+			m[tf.Name] = tf.Values
+		}
+		return m
+	}
+
+	return adminFilter{
+		NamePass:     f.NamePass,
+		NameDrop:     f.NameDrop,
+		FieldInclude: f.FieldInclude,
+		FieldExclude: f.FieldExclude,
+		TagPass:      tagFilters(f.TagPassFilters),
+		TagDrop:      tagFilters(f.TagDropFilters),
+		TagInclude:   f.TagInclude,
+		TagExclude:   f.TagExclude,
+		MetricPass:   f.MetricPass,
+	}
+}
+
+func newAdminError(ts time.Time, err error) *adminError {
+	if err == nil {
+		return nil
+	}
+	return &adminError{Message: err.Error(), Time: ts}
+}
+
+func writeAdminResponse(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("E! [agent] Writing admin API response failed: %v", err)
+	}
+}
+
+func writeAdminError(w http.ResponseWriter, status int, err error) {
+	writeAdminResponse(w, status, map[string]string{"error": err.Error()})
+}
@@ -0,0 +1,179 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/plugins/common/auth"
+	"github.com/influxdata/telegraf/plugins/inputs"
+	"github.com/influxdata/telegraf/plugins/outputs"
+)
+
+func TestAdminConfig(t *testing.T) {
+	c := config.NewConfig()
+	cfg := `
+[agent]
+  omit_hostname = true
+
+  [agent.admin]
+    address = "localhost:8089"
+    username = "admin"
+    password = "secret"
+`
+	require.NoError(t, c.LoadConfigData([]byte(cfg), config.EmptySourcePath))
+	require.NotNil(t, c.Agent.Admin)
+	require.Equal(t, "localhost:8089", c.Agent.Admin.Address)
+	require.Equal(t, "admin", c.Agent.Admin.Username)
+	require.Equal(t, "secret", c.Agent.Admin.Password)
+}
+
+func TestAdminAPI(t *testing.T) {
+	inputs.Add("reload_test", func() telegraf.Input { return &reloadInput{} })
+	outputs.Add("reload_test", func() telegraf.Output { return &reloadOutput{} })
+
+	cfg := `
+[agent]
+  interval = "50ms"
+  flush_interval = "1h"
+  omit_hostname = true
+  skip_processors_after_aggregators = true
+
+[[inputs.reload_test]]
+  name = "a"
+  namepass = ["a"]
+
+[[outputs.reload_test]]
+  alias = "admin"
+`
+	c := config.NewConfig()
+	require.NoError(t, c.LoadConfigData([]byte(cfg), config.EmptySourcePath))
+	a := NewAgent(c)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	errC := make(chan error, 1)
+	go func() {
+		errC <- a.Run(ctx)
+	}()
+
+	admin, err := a.startAdminServer(&config.AdminConfig{
+		Address:   "127.0.0.1:0",
+		BasicAuth: auth.BasicAuth{Username: "admin", Password: "secret"},
+	})
+	require.NoError(t, err)
+	defer admin.stop()
+	baseURL := "http://" + admin.addr.String() + "/api/v1"
+
+	request := func(method, path string) *http.Response {
+		req, err := http.NewRequest(method, baseURL+path, nil)
+		require.NoError(t, err)
+		req.SetBasicAuth("admin", "secret")
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		return resp
+	}
+
+	// Requests without credentials must be rejected
+	resp, err := http.Get(baseURL + "/plugins")
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	// Wait for metrics to be buffered in the output
+	output := c.Outputs[0]
+	require.Eventually(t, func() bool {
+		return output.BufferLength() > 0
+	}, 5*time.Second, 10*time.Millisecond)
+
+	resp = request(http.MethodGet, "/plugins")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var plugins adminPlugins
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&plugins))
+	resp.Body.Close()
+
+	require.Len(t, plugins.Inputs, 1)
+	require.Equal(t, c.Inputs[0].ID(), plugins.Inputs[0].ID)
+	require.Equal(t, "reload_test", plugins.Inputs[0].Name)
+	require.Equal(t, []string{"a"}, plugins.Inputs[0].Filter.NamePass)
+	require.False(t, plugins.Inputs[0].Paused)
+	require.Len(t, plugins.Outputs, 1)
+	require.Equal(t, "admin", plugins.Outputs[0].Alias)
+	require.NotNil(t, plugins.Outputs[0].Buffer)
+	require.Equal(t, "memory", plugins.Outputs[0].Buffer.Strategy)
+	require.Positive(t, plugins.Outputs[0].Buffer.Length)
+	require.Nil(t, plugins.Outputs[0].LastError)
+
+	// Trigger an immediate flush of the output
+	resp = request(http.MethodPost, "/outputs/"+output.ID()+"/flush")
+	resp.Body.Close()
+	require.Equal(t, http.StatusAccepted, resp.StatusCode)
+	plugin := output.Output.(*reloadOutput)
+	require.Eventually(t, func() bool {
+		return plugin.seen("a")
+	}, 5*time.Second, 10*time.Millisecond)
+
+	resp = request(http.MethodPost, "/outputs/unknown/flush")
+	resp.Body.Close()
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	// Pause and resume the input
+	input := c.Inputs[0]
+	resp = request(http.MethodPost, "/inputs/"+input.ID()+"/pause")
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.True(t, input.Paused())
+
+	resp = request(http.MethodPost, "/inputs/"+input.ID()+"/resume")
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.False(t, input.Paused())
+
+	resp = request(http.MethodPost, "/inputs/unknown/pause")
+	resp.Body.Close()
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	// Reloading requires a handler
+	resp = request(http.MethodPost, "/reload")
+	resp.Body.Close()
+	require.Equal(t, http.StatusNotImplemented, resp.StatusCode)
+
+	reloaded := make(chan bool, 1)
+	a.SetReloadHandler(func() error {
+		reloaded <- true
+		return nil
+	})
+	resp = request(http.MethodPost, "/reload")
+	resp.Body.Close()
+	require.Equal(t, http.StatusAccepted, resp.StatusCode)
+	require.True(t, <-reloaded)
+
+	cancel()
+	require.NoError(t, <-errC)
+}
+
+func TestAdminAPIRequiresAuthentication(t *testing.T) {
+	a := NewAgent(config.NewConfig())
+
+	// Serving without credentials is only allowed on loopback addresses
+	_, err := a.startAdminServer(&config.AdminConfig{Address: ":0"})
+	require.ErrorContains(t, err, "requires credentials or client certificates")
+
+	admin, err := a.startAdminServer(&config.AdminConfig{Address: "127.0.0.1:0"})
+	require.NoError(t, err)
+	admin.stop()
+
+	admin, err = a.startAdminServer(&config.AdminConfig{
+		Address:   ":0",
+		BasicAuth: auth.BasicAuth{Username: "admin", Password: "secret"},
+	})
+	require.NoError(t, err)
+	admin.stop()
+}
@@ -23,6 +23,17 @@ import (
 // Agent runs a set of plugins.
 type Agent struct {
 	Config *config.Config
+
+	// Running pipeline, used for reloading the configuration
+	pipeline *pipeline
+
+	// Function to request a configuration reload via the admin API
+	reloadHandler func() error
+
+	// Serializes reloads as the agent lock is released while connecting
+	// outputs added by a reload
+	reloadLock sync.Mutex
+	sync.Mutex
 }
 
 // NewAgent returns an Agent for the given Config.
@@ -33,7 +44,8 @@ func NewAgent(cfg *config.Config) *Agent {
 	return a
 }
 
-// inputUnit is a group of input plugins and the shared channel they write to.
+// inputUnit is a group of input plugins and the shared channels they write
+// to. Each input writes to the channel of the pipeline it feeds.
 //
 // ┌───────┐
 // │ Input │───┐
@@ -45,8 +57,12 @@ func NewAgent(cfg *config.Config) *Agent {
 // │ Input │───┘
 // └───────┘
 type inputUnit struct {
-	dst    chan<- telegraf.Metric
+	dsts   map[string]chan<- telegraf.Metric
 	inputs []*models.RunningInput
+
+	// Functions to stop the gather loop of the individual inputs
+	stops map[*models.RunningInput]func()
+	sync.Mutex
 }
 
 //  ______     ┌───────────┐     ______
@@ -80,10 +96,18 @@ type aggregatorUnit struct {
 	aggC        chan<- telegraf.Metric
 	outputC     chan<- telegraf.Metric
 	aggregators []*models.RunningAggregator
+
+	// Aggregators not pushing their current window on stop, as they
+	// continue to run after a configuration reload
+	retain map[*models.RunningAggregator]bool
+
+	// Use the metric timestamps instead of the wall-clock for the
+	// aggregation windows when replaying metrics
+	replay bool
 }
 
-// outputUnit is a group of Outputs and their source channel.  Metrics on the
-// channel are written to all outputs.
+// outputUnit is a group of Outputs and their source channels, one for each
+// pipeline. Metrics on a channel are written to all outputs of the pipeline.
 
 //                            ┌────────┐
 //                       ┌──▶ │ Output │
@@ -96,8 +120,37 @@ type aggregatorUnit struct {
 //                            └────────┘
 
 type outputUnit struct {
-	src     <-chan telegraf.Metric
+	srcs    map[string]<-chan telegraf.Metric
 	outputs []*models.RunningOutput
+
+	// Context of the flush loops and functions to stop the flush loop of
+	// the individual outputs
+	ctx    context.Context
+	cancel context.CancelFunc
+	stops  map[*models.RunningOutput]func()
+
+	// Channels to request an immediate flush of the individual outputs
+	flushes map[*models.RunningOutput]chan struct{}
+	sync.RWMutex
+}
+
+// middleUnit is the chain of processors and aggregators of a pipeline between
+// the inputs and the outputs. The chain is fed via the source channel and the
+// resulting metrics are drained from the sink channel. The sink and source
+// channels are identical if no processors and aggregators are configured.
+//
+//  ______     ┌────────────┐     ┌─────────────┐     ┌────────────┐     ______
+// ()_____)──▶ │ Processors │──▶ │ Aggregators │──▶ │ Processors │──▶ ()_____)
+//             └────────────┘     └─────────────┘     └────────────┘
+
+type middleUnit struct {
+	src           chan<- telegraf.Metric
+	dst           <-chan telegraf.Metric
+	processors    []*processorUnit
+	aggProcessors []*processorUnit
+	aggregators   *aggregatorUnit
+
+	wg sync.WaitGroup
 }
 
 // Run starts and runs the Agent until the context is done.
@@ -121,53 +174,93 @@ func (a *Agent) Run(ctx context.Context) error {
 		return err
 	}
 
+	backpressure, err := newBackpressureMonitor(a.Config.Agent)
+	if err != nil {
+		return err
+	}
+
 	if a.Config.Persister != nil {
 		log.Printf("D! [agent] Initializing plugin states")
 		if err := a.initPersister(); err != nil {
 			return err
 		}
-		if err := a.Config.Persister.Load(); err != nil {
-			if !errors.Is(err, os.ErrNotExist) {
-				return err
-			}
-			log.Print("I! [agent] State file does not exist... Skip restoring states...")
+		if err := a.loadStates(); err != nil {
+			return err
+		}
+	}
+
+	var checkpointWg sync.WaitGroup
+	if a.Config.Persister != nil && a.Config.Agent.StatefileCheckpointInterval > 0 {
+		checkpointWg.Add(1)
+		go func() {
+			defer checkpointWg.Done()
+			a.checkpointLoop(ctx, time.Duration(a.Config.Agent.StatefileCheckpointInterval))
+		}()
+	}
+
+	if a.Config.Agent.Trace != nil && a.Config.Agent.Trace.Selector != "" {
+		if err := a.startTracer(a.Config.Agent.Trace); err != nil {
+			return fmt.Errorf("starting tracer failed: %w", err)
 		}
+		defer models.SetTracer(nil)
+	}
+
+	if a.Config.Agent.Admin != nil && a.Config.Agent.Admin.Address != "" {
+		log.Printf("D! [agent] Starting admin API")
+		admin, err := a.startAdminServer(a.Config.Agent.Admin)
+		if err != nil {
+			return fmt.Errorf("starting admin API failed: %w", err)
+		}
+		defer admin.stop()
 	}
 
 	startTime := time.Now()
 
+	names := a.Config.Pipelines()
+
 	log.Printf("D! [agent] Connecting outputs")
-	next, ou, err := a.startOutputs(ctx, a.Config.Outputs)
+	outputCs, ou, err := a.startOutputs(ctx, a.Config.Outputs, names)
 	if err != nil {
 		return err
 	}
 
-	var apu []*processorUnit
-	var au *aggregatorUnit
-	if len(a.Config.Aggregators) != 0 {
-		aggC := next
-		if len(a.Config.AggProcessors) != 0 && !*a.Config.Agent.SkipProcessorsAfterAggregators {
-			aggC, apu, err = a.startProcessors(next, a.Config.AggProcessors)
-			if err != nil {
-				return err
-			}
-		}
-
-		next, au = a.startAggregators(aggC, next, a.Config.Aggregators)
+	p := &pipeline{
+		ctx:       ctx,
+		startTime: startTime,
+		middles:   make(map[string]*middleUnit, len(names)),
+		outputs:   ou,
+		swaps:     make(map[string]chan *middleSwap, len(names)),
 	}
-
-	var pu []*processorUnit
-	if len(a.Config.Processors) != 0 {
-		next, pu, err = a.startProcessors(next, a.Config.Processors)
+	for _, name := range names {
+		mu, err := a.startMiddle(name)
 		if err != nil {
 			return err
 		}
+		p.middles[name] = mu
+		p.swaps[name] = make(chan *middleSwap)
 	}
 
-	iu, err := a.startInputs(next, a.Config.Inputs)
+	inputCs := make(map[string]chan telegraf.Metric, len(names))
+	dsts := make(map[string]chan<- telegraf.Metric, len(names))
+	for _, name := range names {
+		inputCs[name] = make(chan telegraf.Metric, 100)
+		dsts[name] = inputCs[name]
+	}
+	iu, err := a.startInputs(dsts, a.Config.Inputs)
 	if err != nil {
 		return err
 	}
+	p.inputs = iu
+
+	a.Lock()
+	a.pipeline = p
+	a.Unlock()
+
+	stopWatching := a.watchSecretStores(ctx)
+	stopBackpressure := func() {}
+	if backpressure != nil {
+		stopBackpressure = backpressure.run(ctx, p)
+	}
 
 	var wg sync.WaitGroup
 	wg.Add(1)
@@ -176,26 +269,12 @@ func (a *Agent) Run(ctx context.Context) error {
 		a.runOutputs(ou)
 	}()
 
-	if au != nil {
+	for _, name := range names {
 		wg.Add(1)
-		go func() {
+		go func(name string) {
 			defer wg.Done()
-			a.runProcessors(apu)
-		}()
-
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-			a.runAggregators(startTime, au)
-		}()
-	}
-
-	if pu != nil {
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-			a.runProcessors(pu)
-		}()
+			a.runPipeline(p, name, inputCs[name], outputCs[name])
+		}(name)
 	}
 
 	wg.Add(1)
@@ -205,12 +284,22 @@ func (a *Agent) Run(ctx context.Context) error {
 	}()
 
 	wg.Wait()
+	checkpointWg.Wait()
+	stopWatching()
+	stopBackpressure()
+
+	a.Lock()
+	a.pipeline = nil
+	a.Unlock()
 
 	if a.Config.Persister != nil {
 		log.Printf("D! [agent] Persisting plugin states")
 		if err := a.Config.Persister.Store(); err != nil {
 			return err
 		}
+		if err := a.Config.Persister.Close(); err != nil {
+			return err
+		}
 	}
 
 	log.Printf("D! [agent] Stopped Successfully")
@@ -255,9 +344,63 @@ func (a *Agent) InitPlugins() error {
 			return fmt.Errorf("could not initialize output %s: %w", output.LogName(), err)
 		}
 	}
+	return a.linkDeadLetterOutputs()
+}
+
+// linkDeadLetterOutputs resolves the 'dead_letter_output' setting of the
+// outputs to the output with the referenced alias.
+func (a *Agent) linkDeadLetterOutputs() error {
+	for _, output := range a.Config.Outputs {
+		if err := linkDeadLetterOutput(output, a.Config.Outputs); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func linkDeadLetterOutput(output *models.RunningOutput, candidates []*models.RunningOutput) error {
+	ref := output.Config.DeadLetterOutput
+	if ref == "" {
+		return nil
+	}
+
+	var target *models.RunningOutput
+	for _, candidate := range candidates {
+		if candidate.Config.Alias == ref {
+			target = candidate
+			break
+		}
+	}
+	if target == nil {
+		return fmt.Errorf("dead-letter output %q of output %s not found", ref, output.LogName())
+	}
+	if err := output.SetDeadLetterOutput(target); err != nil {
+		return fmt.Errorf("could not set dead-letter output of %s: %w", output.LogName(), err)
+	}
 	return nil
 }
 
+// checkpointLoop periodically stores the plugin states until the context is
+// done, limiting the loss of states in case Telegraf does not terminate
+// gracefully.
+func (a *Agent) checkpointLoop(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := a.Config.Persister.Store(); err != nil {
+				log.Printf("E! [agent] Checkpointing plugin states failed: %v", err)
+				continue
+			}
+			log.Printf("D! [agent] Checkpointed plugin states")
+		}
+	}
+}
+
 // initPersister initializes the persister and registers the plugins.
 func (a *Agent) initPersister() error {
 	if err := a.Config.Persister.Init(); err != nil {
@@ -337,56 +480,112 @@ func (a *Agent) initPersister() error {
 		}
 	}
 
+	if a.Config.Agent.ShutdownTimeout > 0 {
+		for _, output := range a.Config.Outputs {
+			a.registerBufferState(output)
+		}
+	}
+
 	return nil
 }
 
-func (*Agent) startInputs(dst chan<- telegraf.Metric, inputs []*models.RunningInput) (*inputUnit, error) {
-	log.Printf("D! [agent] Starting service inputs")
-
-	unit := &inputUnit{
-		dst: dst,
+// loadStates restores the plugin states from the statefile. Persisted output
+// buffers are cleared right after restoring them to not write the metrics
+// again after a restart if Telegraf does not shut down gracefully.
+func (a *Agent) loadStates() error {
+	if err := a.Config.Persister.Load(); err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		log.Print("I! [agent] State file does not exist... Skip restoring states...")
+		return nil
 	}
 
-	for _, input := range inputs {
-		// Service input plugins are not normally subject to timestamp
-		// rounding except for when precision is set on the input plugin.
-		//
-		// This only applies to the accumulator passed to Start(), the
-		// Gather() accumulator does apply rounding according to the
-		// precision and interval agent/plugin settings.
-		var interval time.Duration
-		var precision time.Duration
-		if input.Config.Precision != 0 {
-			precision = input.Config.Precision
+	if a.Config.Agent.ShutdownTimeout > 0 {
+		if err := a.Config.Persister.Store(); err != nil {
+			return fmt.Errorf("clearing restored output buffers failed: %w", err)
 		}
+	}
+	return nil
+}
 
-		acc := NewAccumulator(input, dst)
-		acc.SetPrecision(getPrecision(precision, interval))
+// registerBufferState registers the output's buffer with the persister to
+// keep the metrics remaining in memory on shutdown. Failing to do so is not
+// fatal, the metrics are dropped on shutdown as without persistence.
+func (a *Agent) registerBufferState(output *models.RunningOutput) {
+	state := output.BufferState()
+	if state == nil {
+		return
+	}
+	if err := a.Config.Persister.Register(bufferStateID(output), state); err != nil {
+		log.Printf("W! [agent] Could not register buffer of %s for persisting: %v", output.LogName(), err)
+	}
+}
 
-		if err := input.Start(acc); err != nil {
-			// If the model tells us to remove the plugin we do so without error
-			var fatalErr *internal.FatalError
-			if errors.As(err, &fatalErr) {
-				log.Printf("I! [agent] Failed to start %s, shutting down plugin: %s", input.LogName(), err)
-				continue
-			}
+// bufferStateID returns the ID for persisting the buffer of the given output.
+func bufferStateID(output *models.RunningOutput) string {
+	return output.ID() + "/buffer"
+}
 
-			stopRunningInputs(unit.inputs)
+func (a *Agent) startInputs(dsts map[string]chan<- telegraf.Metric, inputs []*models.RunningInput) (*inputUnit, error) {
+	log.Printf("D! [agent] Starting service inputs")
+
+	unit := &inputUnit{
+		dsts:  dsts,
+		stops: make(map[*models.RunningInput]func()),
+	}
 
-			return nil, fmt.Errorf("starting input %s: %w", input.LogName(), err)
+	for _, input := range inputs {
+		started, err := a.startInput(unit.dst(input), input)
+		if err != nil {
+			stopRunningInputs(unit.inputs)
+			return nil, err
 		}
-		if err := input.Probe(); err != nil {
-			// Probe failures are non-fatal to the agent but should only remove the plugin
-			log.Printf("I! [agent] Failed to probe %s, shutting down plugin: %s", input.LogName(), err)
-			input.Stop()
-			continue
+		if started {
+			unit.inputs = append(unit.inputs, input)
 		}
-		unit.inputs = append(unit.inputs, input)
 	}
 
 	return unit, nil
 }
 
+// startInput starts the given input if it is a service input and probes
+// the plugin. The function returns false if the input should be removed.
+func (*Agent) startInput(dst chan<- telegraf.Metric, input *models.RunningInput) (bool, error) {
+	// Service input plugins are not normally subject to timestamp
+	// rounding except for when precision is set on the input plugin.
+	//
+	// This only applies to the accumulator passed to Start(), the
+	// Gather() accumulator does apply rounding according to the
+	// precision and interval agent/plugin settings.
+	var interval time.Duration
+	var precision time.Duration
+	if input.Config.Precision != 0 {
+		precision = input.Config.Precision
+	}
+
+	acc := NewAccumulator(input, dst)
+	acc.SetPrecision(getPrecision(precision, interval))
+
+	if err := input.Start(acc); err != nil {
+		// If the model tells us to remove the plugin we do so without error
+		var fatalErr *internal.FatalError
+		if errors.As(err, &fatalErr) {
+			log.Printf("I! [agent] Failed to start %s, shutting down plugin: %s", input.LogName(), err)
+			return false, nil
+		}
+
+		return false, fmt.Errorf("starting input %s: %w", input.LogName(), err)
+	}
+	if err := input.Probe(); err != nil {
+		// Probe failures are non-fatal to the agent but should only remove the plugin
+		log.Printf("I! [agent] Failed to probe %s, shutting down plugin: %s", input.LogName(), err)
+		input.Stop()
+		return false, nil
+	}
+	return true, nil
+}
+
 // runInputs starts and triggers the periodic gather for Inputs.
 //
 // When the context is done the timers are stopped and this function returns
@@ -396,67 +595,103 @@ func (a *Agent) runInputs(
 	startTime time.Time,
 	unit *inputUnit,
 ) {
-	var wg sync.WaitGroup
-	tickers := make([]Ticker, 0, len(unit.inputs))
+	unit.Lock()
 	for _, input := range unit.inputs {
-		// Overwrite agent interval if this plugin has its own.
-		interval := time.Duration(a.Config.Agent.Interval)
-		if input.Config.Interval != 0 {
-			interval = input.Config.Interval
-		}
+		a.runInput(ctx, startTime, unit, input)
+	}
+	unit.Unlock()
 
-		// Overwrite agent precision if this plugin has its own.
-		precision := time.Duration(a.Config.Agent.Precision)
-		if input.Config.Precision != 0 {
-			precision = input.Config.Precision
-		}
+	<-ctx.Done()
 
-		// Overwrite agent collection_jitter if this plugin has its own.
-		jitter := time.Duration(a.Config.Agent.CollectionJitter)
-		if input.Config.CollectionJitter != 0 {
-			jitter = input.Config.CollectionJitter
-		}
+	unit.Lock()
+	defer unit.Unlock()
+	for _, stop := range unit.stops {
+		stop()
+	}
 
-		// Overwrite agent collection_offset if this plugin has its own.
-		offset := time.Duration(a.Config.Agent.CollectionOffset)
-		if input.Config.CollectionOffset != 0 {
-			offset = input.Config.CollectionOffset
-		}
+	log.Printf("D! [agent] Stopping service inputs")
+	stopRunningInputs(unit.inputs)
 
-		var ticker Ticker
-		if a.Config.Agent.RoundInterval {
-			ticker = NewAlignedTicker(startTime, interval, jitter, offset)
-		} else {
-			ticker = NewUnalignedTicker(interval, jitter, offset)
-		}
-		tickers = append(tickers, ticker)
+	unit.close()
+}
 
-		acc := NewAccumulator(input, unit.dst)
-		acc.SetPrecision(getPrecision(precision, interval))
+// dst returns the channel of the pipeline fed by the given input.
+func (unit *inputUnit) dst(input *models.RunningInput) chan<- telegraf.Metric {
+	return unit.dsts[input.Pipeline()]
+}
 
-		wg.Add(1)
-		go func(input *models.RunningInput) {
-			defer wg.Done()
-			a.gatherLoop(ctx, acc, input, ticker, interval)
-		}(input)
+// close closes the channels of all pipelines.
+func (unit *inputUnit) close() {
+	for _, dst := range unit.dsts {
+		close(dst)
 	}
-	defer stopTickers(tickers)
-	wg.Wait()
+	log.Printf("D! [agent] Input channel closed")
+}
 
-	log.Printf("D! [agent] Stopping service inputs")
-	stopRunningInputs(unit.inputs)
+// runInput starts the periodic gather of the given input in the background
+// and registers the function to stop the gather loop in the unit. The caller
+// must hold the unit's lock.
+func (a *Agent) runInput(
+	ctx context.Context,
+	startTime time.Time,
+	unit *inputUnit,
+	input *models.RunningInput,
+) {
+	// Overwrite agent interval if this plugin has its own.
+	interval := time.Duration(a.Config.Agent.Interval)
+	if input.Config.Interval != 0 {
+		interval = input.Config.Interval
+	}
 
-	close(unit.dst)
-	log.Printf("D! [agent] Input channel closed")
+	// Overwrite agent precision if this plugin has its own.
+	precision := time.Duration(a.Config.Agent.Precision)
+	if input.Config.Precision != 0 {
+		precision = input.Config.Precision
+	}
+
+	// Overwrite agent collection_jitter if this plugin has its own.
+	jitter := time.Duration(a.Config.Agent.CollectionJitter)
+	if input.Config.CollectionJitter != 0 {
+		jitter = input.Config.CollectionJitter
+	}
+
+	// Overwrite agent collection_offset if this plugin has its own.
+	offset := time.Duration(a.Config.Agent.CollectionOffset)
+	if input.Config.CollectionOffset != 0 {
+		offset = input.Config.CollectionOffset
+	}
+
+	var ticker Ticker
+	if a.Config.Agent.RoundInterval {
+		ticker = NewAlignedTicker(startTime, interval, jitter, offset)
+	} else {
+		ticker = NewUnalignedTicker(interval, jitter, offset)
+	}
+
+	acc := NewAccumulator(input, unit.dst(input))
+	acc.SetPrecision(getPrecision(precision, interval))
+
+	inputCtx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		a.gatherLoop(inputCtx, acc, input, ticker, interval)
+	}()
+
+	unit.stops[input] = func() {
+		cancel()
+		<-done
+		ticker.Stop()
+	}
 }
 
 // testStartInputs is a variation of startInputs for use in --test and --once mode.
 // It differs by logging Start errors and returning only plugins successfully started.
-func (*Agent) testStartInputs(dst chan<- telegraf.Metric, inputs []*models.RunningInput) *inputUnit {
+func (*Agent) testStartInputs(dsts map[string]chan<- telegraf.Metric, inputs []*models.RunningInput) *inputUnit {
 	log.Printf("D! [agent] Starting service inputs")
 
 	unit := &inputUnit{
-		dst: dst,
+		dsts: dsts,
 	}
 
 	for _, input := range inputs {
@@ -464,7 +699,7 @@ func (*Agent) testStartInputs(dst chan<- telegraf.Metric, inputs []*models.Runni
 		// This only applies to the accumulator passed to Start(), the
 		// Gather() accumulator does apply rounding according to the
 		// precision agent setting.
-		acc := NewAccumulator(input, dst)
+		acc := NewAccumulator(input, unit.dst(input))
 		acc.SetPrecision(time.Nanosecond)
 
 		if err := input.Start(acc); err != nil {
@@ -524,7 +759,7 @@ func (a *Agent) testRunInputs(
 				time.Sleep(500 * time.Millisecond)
 			}
 
-			acc := NewAccumulator(input, unit.dst)
+			acc := NewAccumulator(input, unit.dst(input))
 			acc.SetPrecision(getPrecision(precision, interval))
 
 			if err := input.Input.Gather(acc); err != nil {
@@ -541,8 +776,7 @@ func (a *Agent) testRunInputs(
 	log.Printf("D! [agent] Stopping service inputs")
 	stopRunningInputs(unit.inputs)
 
-	close(unit.dst)
-	log.Printf("D! [agent] Input channel closed")
+	unit.close()
 }
 
 // stopRunningInputs stops all service inputs.
@@ -571,6 +805,9 @@ func (a *Agent) gatherLoop(
 	for {
 		select {
 		case <-ticker.Elapsed():
+			if input.Paused() {
+				continue
+			}
 			err := a.gatherOnce(acc, input, ticker, interval)
 			if err != nil {
 				acc.AddError(err)
@@ -687,11 +924,22 @@ func (a *Agent) runAggregators(
 	startTime time.Time,
 	unit *aggregatorUnit,
 ) {
+	if unit.replay {
+		a.replayAggregators(unit)
+		close(unit.aggC)
+		log.Printf("D! [agent] Aggregator channel closed")
+		return
+	}
+
 	ctx, cancel := context.WithCancel(context.Background())
 
 	// Before calling Add, initialize the aggregation window.  This ensures
-	// that any metric created after start time will be aggregated.
-	for _, agg := range a.Config.Aggregators {
+	// that any metric created after start time will be aggregated. Windows
+	// of aggregators retained during a configuration reload are kept.
+	for _, agg := range unit.aggregators {
+		if agg.EndPeriod().After(startTime) {
+			continue
+		}
 		since, until := updateWindow(startTime, a.Config.Agent.RoundInterval, agg.Period())
 		agg.UpdateWindow(since, until)
 	}
@@ -701,23 +949,12 @@ func (a *Agent) runAggregators(
 	go func() {
 		defer wg.Done()
 		for metric := range unit.src {
-			var dropOriginal bool
-			for _, agg := range a.Config.Aggregators {
-				if ok := agg.Add(metric); ok {
-					dropOriginal = true
-				}
-			}
-
-			if !dropOriginal {
-				unit.outputC <- metric // keep original.
-			} else {
-				metric.Drop()
-			}
+			unit.add(metric)
 		}
 		cancel()
 	}()
 
-	for _, agg := range a.Config.Aggregators {
+	for _, agg := range unit.aggregators {
 		wg.Add(1)
 		go func(agg *models.RunningAggregator) {
 			defer wg.Done()
@@ -727,7 +964,7 @@ func (a *Agent) runAggregators(
 
 			acc := NewAccumulator(agg, unit.aggC)
 			acc.SetPrecision(getPrecision(precision, interval))
-			a.push(ctx, agg, acc)
+			a.push(ctx, agg, acc, unit.retain[agg])
 		}(agg)
 	}
 
@@ -740,6 +977,23 @@ func (a *Agent) runAggregators(
 	log.Printf("D! [agent] Aggregator channel closed")
 }
 
+// add passes the metric to all aggregators and forwards the original metric
+// unless an aggregator drops it.
+func (unit *aggregatorUnit) add(metric telegraf.Metric) {
+	var dropOriginal bool
+	for _, agg := range unit.aggregators {
+		if ok := agg.Add(metric); ok {
+			dropOriginal = true
+		}
+	}
+
+	if !dropOriginal {
+		unit.outputC <- metric // keep original.
+	} else {
+		metric.Drop()
+	}
+}
+
 func updateWindow(start time.Time, roundInterval bool, period time.Duration) (time.Time, time.Time) {
 	var until time.Time
 	if roundInterval {
@@ -756,8 +1010,9 @@ func updateWindow(start time.Time, roundInterval bool, period time.Duration) (ti
 	return since, until
 }
 
-// push runs the push for a single aggregator every period.
-func (*Agent) push(ctx context.Context, aggregator *models.RunningAggregator, acc telegraf.Accumulator) {
+// push runs the push for a single aggregator every period. If retain is set,
+// the current window is not pushed when the context is done.
+func (*Agent) push(ctx context.Context, aggregator *models.RunningAggregator, acc telegraf.Accumulator, retain bool) {
 	for {
 		// Ensures that Push will be called for each period, even if it has
 		// already elapsed before this function is called.  This is guaranteed
@@ -769,20 +1024,199 @@ func (*Agent) push(ctx context.Context, aggregator *models.RunningAggregator, ac
 		case <-time.After(until):
 			aggregator.Push(acc)
 		case <-ctx.Done():
-			aggregator.Push(acc)
+			if !retain {
+				aggregator.Push(acc)
+			}
 			return
 		}
 	}
 }
 
-// startOutputs calls Connect on all outputs and returns the source channel.
-// If an error occurs calling Connect, all started plugins have Close called.
+// startMiddle sets up and starts the processor and aggregator chain of the
+// given pipeline in the current configuration.
+func (a *Agent) startMiddle(name string) (*middleUnit, error) {
+	dst := make(chan telegraf.Metric, 100)
+	unit := &middleUnit{dst: dst}
+
+	processors := inPipeline(a.Config.Processors, name)
+	aggProcessors := inPipeline(a.Config.AggProcessors, name)
+	aggregators := inPipeline(a.Config.Aggregators, name)
+
+	var err error
+	next := chan<- telegraf.Metric(dst)
+	if len(aggregators) != 0 {
+		aggC := next
+		if len(aggProcessors) != 0 && !*a.Config.Agent.SkipProcessorsAfterAggregators {
+			aggC, unit.aggProcessors, err = a.startProcessors(next, aggProcessors)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		next, unit.aggregators = a.startAggregators(aggC, next, aggregators)
+	}
+
+	if len(processors) != 0 {
+		next, unit.processors, err = a.startProcessors(next, processors)
+		if err != nil {
+			stopProcessorUnits(unit.aggProcessors)
+			return nil, err
+		}
+	}
+	unit.src = next
+
+	return unit, nil
+}
+
+// runMiddle runs the processor and aggregator chain in the background and
+// forwards the resulting metrics to the given output channel until the
+// source channel of the unit is closed and all metrics are processed.
+func (a *Agent) runMiddle(startTime time.Time, unit *middleUnit, outputC chan<- telegraf.Metric) {
+	if unit.aggregators != nil {
+		unit.wg.Add(1)
+		go func() {
+			defer unit.wg.Done()
+			a.runProcessors(unit.aggProcessors)
+		}()
+
+		unit.wg.Add(1)
+		go func() {
+			defer unit.wg.Done()
+			a.runAggregators(startTime, unit.aggregators)
+		}()
+	}
+
+	if unit.processors != nil {
+		unit.wg.Add(1)
+		go func() {
+			defer unit.wg.Done()
+			a.runProcessors(unit.processors)
+		}()
+	}
+
+	unit.wg.Add(1)
+	go func() {
+		defer unit.wg.Done()
+		for m := range unit.dst {
+			outputC <- m
+		}
+	}()
+}
+
+// inPipeline returns the plugins being part of the given pipeline.
+func inPipeline[T interface{ Pipeline() string }](plugins []T, name string) []T {
+	selected := make([]T, 0, len(plugins))
+	for _, p := range plugins {
+		if p.Pipeline() == name {
+			selected = append(selected, p)
+		}
+	}
+	return selected
+}
+
+// runPipeline forwards the metrics of the inputs through the processor and
+// aggregator chain of the given pipeline to the outputs until the input
+// channel is closed. The chain is replaced on request while no metrics are
+// in flight.
+func (a *Agent) runPipeline(p *pipeline, name string, inputC <-chan telegraf.Metric, outputC chan<- telegraf.Metric) {
+	unit := p.middles[name]
+	a.runMiddle(p.startTime, unit, outputC)
+
+	for {
+		select {
+		case m, ok := <-inputC:
+			if !ok {
+				close(unit.src)
+				unit.wg.Wait()
+				close(outputC)
+				log.Printf("D! [agent] Output channel closed")
+				return
+			}
+			unit.src <- m
+		case req := <-p.swaps[name]:
+			// Drain the current chain before starting the new one to
+			// allow reusing plugin instances in the new chain.
+			if unit.aggregators != nil {
+				unit.aggregators.retain = req.retain
+			}
+			close(unit.src)
+			unit.wg.Wait()
+
+			next, err := req.start(name)
+			if err != nil {
+				// Fall back to directly passing metrics to the outputs
+				log.Printf("E! [agent] Starting processors and aggregators failed: %v", err)
+				passthrough := make(chan telegraf.Metric, 100)
+				next = &middleUnit{src: passthrough, dst: passthrough}
+			}
+			unit = next
+			a.runMiddle(time.Now(), unit, outputC)
+			req.done <- err
+		}
+	}
+}
+
+// testStartMiddles is a variation of startMiddle for use in --test and --once
+// mode. It starts and runs the processor and aggregator chains of all
+// pipelines forwarding the resulting metrics to the given channels and
+// returns the source channels of the chains.
+func (a *Agent) testStartMiddles(
+	startTime time.Time,
+	outputCs map[string]chan<- telegraf.Metric,
+) (map[string]chan<- telegraf.Metric, map[string]*middleUnit, error) {
+	units := make(map[string]*middleUnit, len(outputCs))
+	for _, name := range a.Config.Pipelines() {
+		unit, err := a.startMiddle(name)
+		if err != nil {
+			for _, u := range units {
+				stopProcessorUnits(u.processors)
+				stopProcessorUnits(u.aggProcessors)
+			}
+			return nil, nil, err
+		}
+		units[name] = unit
+	}
+
+	dsts := make(map[string]chan<- telegraf.Metric, len(units))
+	for name, unit := range units {
+		a.runMiddle(startTime, unit, outputCs[name])
+		dsts[name] = unit.src
+	}
+	return dsts, units, nil
+}
+
+// stopProcessorUnits stops the processors of a not yet running chain.
+func stopProcessorUnits(units []*processorUnit) {
+	for _, u := range units {
+		u.processor.Stop()
+		close(u.dst)
+	}
+}
+
+// startOutputs calls Connect on all outputs and returns the source channels of
+// the given pipelines. If an error occurs calling Connect, all started plugins
+// have Close called.
 func (a *Agent) startOutputs(
 	ctx context.Context,
 	outputs []*models.RunningOutput,
-) (chan<- telegraf.Metric, *outputUnit, error) {
-	src := make(chan telegraf.Metric, 100)
-	unit := &outputUnit{src: src}
+	pipelines []string,
+) (map[string]chan<- telegraf.Metric, *outputUnit, error) {
+	dsts := make(map[string]chan<- telegraf.Metric, len(pipelines))
+	srcs := make(map[string]<-chan telegraf.Metric, len(pipelines))
+	for _, name := range pipelines {
+		src := make(chan telegraf.Metric, 100)
+		dsts[name] = src
+		srcs[name] = src
+	}
+
+	flushCtx, cancel := context.WithCancel(context.Background())
+	unit := &outputUnit{
+		srcs:    srcs,
+		ctx:     flushCtx,
+		cancel:  cancel,
+		stops:   make(map[*models.RunningOutput]func()),
+		flushes: make(map[*models.RunningOutput]chan struct{}),
+	}
 	for _, output := range outputs {
 		if err := a.connectOutput(ctx, output); err != nil {
 			var fatalErr *internal.FatalError
@@ -802,7 +1236,7 @@ func (a *Agent) startOutputs(
 		unit.outputs = append(unit.outputs, output)
 	}
 
-	return src, unit, nil
+	return dsts, unit, nil
 }
 
 // connectOutput connects to all outputs.
@@ -823,60 +1257,110 @@ func (*Agent) connectOutput(ctx context.Context, output *models.RunningOutput) e
 	return nil
 }
 
-// runOutputs begins processing metrics and returns until the source channel is
-// closed and all metrics have been written.  On shutdown metrics will be
+// runOutputs begins processing metrics and returns until the source channels
+// are closed and all metrics have been written.  On shutdown metrics will be
 // written one last time and dropped if unsuccessful.
 func (a *Agent) runOutputs(
 	unit *outputUnit,
 ) {
+	unit.Lock()
+	for _, output := range unit.outputs {
+		a.runOutput(unit.ctx, unit, output)
+	}
+	unit.Unlock()
+
 	var wg sync.WaitGroup
+	for name, src := range unit.srcs {
+		wg.Add(1)
+		go func(name string, src <-chan telegraf.Metric) {
+			defer wg.Done()
+			for metric := range src {
+				unit.RLock()
+				unit.fan(name, metric)
+				unit.RUnlock()
+			}
+		}(name, src)
+	}
+	wg.Wait()
 
-	// Start flush loop
-	interval := time.Duration(a.Config.Agent.FlushInterval)
-	jitter := time.Duration(a.Config.Agent.FlushJitter)
+	log.Println("I! [agent] Hang on, flushing any cached metrics before shutdown")
+	unit.cancel()
 
-	ctx, cancel := context.WithCancel(context.Background())
+	unit.Lock()
+	defer unit.Unlock()
+	for _, stop := range unit.stops {
+		stop()
+	}
 
-	for _, output := range unit.outputs {
-		interval := interval
-		// Overwrite agent flush_interval if this plugin has its own.
-		if output.Config.FlushInterval != 0 {
-			interval = output.Config.FlushInterval
-		}
+	log.Println("I! [agent] Stopping running outputs")
+	stopRunningOutputs(unit.outputs)
+}
 
-		jitter := jitter
-		// Overwrite agent flush_jitter if this plugin has its own.
-		if output.Config.FlushJitter != 0 {
-			jitter = output.Config.FlushJitter
+// fan writes the metric to all outputs of the given pipeline. The metric is
+// dropped if the pipeline has no outputs. The caller must hold the unit's
+// lock.
+func (unit *outputUnit) fan(pipeline string, metric telegraf.Metric) {
+	last := -1
+	for i, output := range unit.outputs {
+		if output.InPipeline(pipeline) {
+			last = i
 		}
+	}
+	if last < 0 {
+		metric.Drop()
+		return
+	}
 
-		wg.Add(1)
-		go func(output *models.RunningOutput) {
-			defer wg.Done()
-
-			ticker := NewRollingTicker(interval, jitter)
-			defer ticker.Stop()
+	for i, output := range unit.outputs[:last+1] {
+		if !output.InPipeline(pipeline) {
+			continue
+		}
+		if i == last {
+			output.AddMetricNoCopy(metric)
+		} else {
+			output.AddMetric(metric)
+		}
+	}
+}
 
-			a.flushLoop(ctx, output, ticker)
-		}(output)
+// runOutput starts the flush loop of the given output in the background and
+// registers the function to stop the loop in the unit. Stopping the loop
+// flushes the output one last time. The caller must hold the unit's lock.
+func (a *Agent) runOutput(
+	ctx context.Context,
+	unit *outputUnit,
+	output *models.RunningOutput,
+) {
+	// Overwrite agent flush_interval if this plugin has its own.
+	interval := time.Duration(a.Config.Agent.FlushInterval)
+	if output.Config.FlushInterval != 0 {
+		interval = output.Config.FlushInterval
 	}
 
-	for metric := range unit.src {
-		for i, output := range unit.outputs {
-			if i == len(unit.outputs)-1 {
-				output.AddMetricNoCopy(metric)
-			} else {
-				output.AddMetric(metric)
-			}
-		}
+	// Overwrite agent flush_jitter if this plugin has its own.
+	jitter := time.Duration(a.Config.Agent.FlushJitter)
+	if output.Config.FlushJitter != 0 {
+		jitter = output.Config.FlushJitter
 	}
 
-	log.Println("I! [agent] Hang on, flushing any cached metrics before shutdown")
-	cancel()
-	wg.Wait()
+	outputCtx, cancel := context.WithCancel(ctx)
+	flush := make(chan struct{}, 1)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
 
-	log.Println("I! [agent] Stopping running outputs")
-	stopRunningOutputs(unit.outputs)
+		ticker := NewRollingTicker(interval, jitter)
+		defer ticker.Stop()
+
+		a.flushLoop(outputCtx, output, ticker, flush)
+	}()
+
+	unit.flushes[output] = flush
+
+	unit.stops[output] = func() {
+		cancel()
+		<-done
+	}
 }
 
 // flushLoop runs an output's flush function periodically until the context is
@@ -885,6 +1369,7 @@ func (a *Agent) flushLoop(
 	ctx context.Context,
 	output *models.RunningOutput,
 	ticker Ticker,
+	flush <-chan struct{},
 ) {
 	logError := func(err error) {
 		if err != nil {
@@ -901,25 +1386,57 @@ func (a *Agent) flushLoop(
 		// Favor shutdown over other methods.
 		select {
 		case <-ctx.Done():
-			logError(a.flushOnce(output, ticker, output.Write))
+			a.drain(output, ticker, logError)
 			return
 		default:
 		}
 
 		select {
 		case <-ctx.Done():
-			logError(a.flushOnce(output, ticker, output.Write))
+			a.drain(output, ticker, logError)
 			return
 		case <-ticker.Elapsed():
 			logError(a.flushOnce(output, ticker, output.Write))
 		case <-flushRequested:
 			logError(a.flushOnce(output, ticker, output.Write))
+		case <-flush:
+			logError(a.flushOnce(output, ticker, output.Write))
 		case <-output.BatchReady:
 			logError(a.flushBatch(output, output.WriteBatch))
 		}
 	}
 }
 
+// shutdownRetryInterval is the minimum interval between write attempts when
+// retrying to write the buffered metrics on shutdown.
+var shutdownRetryInterval = time.Second
+
+// drain flushes the output on shutdown. If a shutdown timeout is configured,
+// writing is retried until the output's buffer is empty or the timeout is
+// reached.
+func (a *Agent) drain(output *models.RunningOutput, ticker Ticker, logError func(error)) {
+	logError(a.flushOnce(output, ticker, output.Write))
+
+	timeout := time.Duration(a.Config.Agent.ShutdownTimeout)
+	if timeout <= 0 || output.BufferLength() == 0 {
+		return
+	}
+
+	deadline := time.Now().Add(timeout)
+	log.Printf("I! [agent] Retrying to write %d buffered metrics to %s for up to %s",
+		output.BufferLength(), output.LogName(), timeout)
+	for output.BufferLength() > 0 {
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			log.Printf("W! [agent] Shutdown timeout reached with %d metrics remaining in the buffer of %s",
+				output.BufferLength(), output.LogName())
+			return
+		}
+		time.Sleep(min(max(output.RetryAfter(), shutdownRetryInterval), remaining))
+		logError(a.flushOnce(output, ticker, output.Write))
+	}
+}
+
 // flushOnce runs the output's Write function once, logging a warning each interval it fails to complete before the flush interval elapses.
 func (*Agent) flushOnce(output *models.RunningOutput, ticker Ticker, writeFunc func() error) error {
 	done := make(chan error)
@@ -999,64 +1516,24 @@ func (a *Agent) runTest(ctx context.Context, wait time.Duration, outputC chan<-
 
 	startTime := time.Now()
 
-	next := outputC
-
-	var apu []*processorUnit
-	var au *aggregatorUnit
-	if len(a.Config.Aggregators) != 0 {
-		procC := next
-		if len(a.Config.AggProcessors) != 0 && !*a.Config.Agent.SkipProcessorsAfterAggregators {
-			var err error
-			procC, apu, err = a.startProcessors(next, a.Config.AggProcessors)
-			if err != nil {
-				return err
-			}
-		}
-
-		next, au = a.startAggregators(procC, next, a.Config.Aggregators)
+	// All pipelines write to the same channel
+	outputCs := make(map[string]chan<- telegraf.Metric)
+	for _, name := range a.Config.Pipelines() {
+		outputCs[name] = outputC
 	}
 
-	var pu []*processorUnit
-	if len(a.Config.Processors) != 0 {
-		var err error
-		next, pu, err = a.startProcessors(next, a.Config.Processors)
-		if err != nil {
-			return err
-		}
+	dsts, mus, err := a.testStartMiddles(startTime, outputCs)
+	if err != nil {
+		return err
 	}
 
-	iu := a.testStartInputs(next, a.Config.Inputs)
-
-	var wg sync.WaitGroup
-	if au != nil {
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-			a.runProcessors(apu)
-		}()
-
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-			a.runAggregators(startTime, au)
-		}()
-	}
+	iu := a.testStartInputs(dsts, a.Config.Inputs)
+	a.testRunInputs(ctx, wait, iu)
 
-	if pu != nil {
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-			a.runProcessors(pu)
-		}()
+	for _, mu := range mus {
+		mu.wg.Wait()
 	}
-
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-		a.testRunInputs(ctx, wait, iu)
-	}()
-
-	wg.Wait()
+	close(outputC)
 
 	log.Printf("D! [agent] Stopped Successfully")
 
@@ -1105,34 +1582,17 @@ func (a *Agent) runOnce(ctx context.Context, wait time.Duration) error {
 	startTime := time.Now()
 
 	log.Printf("D! [agent] Connecting outputs")
-	next, ou, err := a.startOutputs(ctx, a.Config.Outputs)
+	outputCs, ou, err := a.startOutputs(ctx, a.Config.Outputs, a.Config.Pipelines())
 	if err != nil {
 		return err
 	}
 
-	var apu []*processorUnit
-	var au *aggregatorUnit
-	if len(a.Config.Aggregators) != 0 {
-		procC := next
-		if len(a.Config.AggProcessors) != 0 && !*a.Config.Agent.SkipProcessorsAfterAggregators {
-			procC, apu, err = a.startProcessors(next, a.Config.AggProcessors)
-			if err != nil {
-				return err
-			}
-		}
-
-		next, au = a.startAggregators(procC, next, a.Config.Aggregators)
-	}
-
-	var pu []*processorUnit
-	if len(a.Config.Processors) != 0 {
-		next, pu, err = a.startProcessors(next, a.Config.Processors)
-		if err != nil {
-			return err
-		}
+	dsts, mus, err := a.testStartMiddles(startTime, outputCs)
+	if err != nil {
+		return err
 	}
 
-	iu := a.testStartInputs(next, a.Config.Inputs)
+	iu := a.testStartInputs(dsts, a.Config.Inputs)
 
 	var wg sync.WaitGroup
 	wg.Add(1)
@@ -1141,32 +1601,15 @@ func (a *Agent) runOnce(ctx context.Context, wait time.Duration) error {
 		a.runOutputs(ou)
 	}()
 
-	if au != nil {
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-			a.runProcessors(apu)
-		}()
-
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-			a.runAggregators(startTime, au)
-		}()
-	}
-
-	if pu != nil {
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-			a.runProcessors(pu)
-		}()
-	}
-
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
 		a.testRunInputs(ctx, wait, iu)
+
+		for name, mu := range mus {
+			mu.wg.Wait()
+			close(outputCs[name])
+		}
 	}()
 
 	wg.Wait()
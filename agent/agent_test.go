@@ -2,10 +2,12 @@ package agent
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -15,8 +17,11 @@ import (
 	"github.com/influxdata/telegraf"
 	"github.com/influxdata/telegraf/config"
 	"github.com/influxdata/telegraf/models"
+	"github.com/influxdata/telegraf/persister"
 	_ "github.com/influxdata/telegraf/plugins/aggregators/all"
+	"github.com/influxdata/telegraf/plugins/inputs"
 	_ "github.com/influxdata/telegraf/plugins/inputs/all"
+	"github.com/influxdata/telegraf/plugins/outputs"
 	_ "github.com/influxdata/telegraf/plugins/outputs/all"
 	"github.com/influxdata/telegraf/plugins/parsers/influx"
 	_ "github.com/influxdata/telegraf/plugins/processors/all"
@@ -228,6 +233,246 @@ func TestCases(t *testing.T) {
 	}
 }
 
+func TestAgent_ShutdownTimeout(t *testing.T) {
+	inputs.Add("shutdown_test", func() telegraf.Input { return &reloadInput{} })
+	outputs.Add("shutdown_test", func() telegraf.Output { return &shutdownOutput{} })
+	shutdownRetryInterval = 10 * time.Millisecond
+
+	statefile := filepath.Join(t.TempDir(), "states.json")
+	cfg := `
+[agent]
+  interval = "50ms"
+  flush_interval = "1h"
+  omit_hostname = true
+  skip_processors_after_aggregators = true
+  shutdown_timeout = "%s"
+  statefile = "` + statefile + `"
+
+[[inputs.shutdown_test]]
+  name = "a"
+
+[[outputs.shutdown_test]]
+`
+	run := func(timeout string, recover bool) *models.RunningOutput {
+		c := config.NewConfig()
+		require.NoError(t, c.LoadConfigData([]byte(fmt.Sprintf(cfg, timeout)), config.EmptySourcePath))
+		a := NewAgent(c)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		errC := make(chan error, 1)
+		go func() {
+			errC <- a.Run(ctx)
+		}()
+
+		output := c.Outputs[0]
+		plugin := output.Output.(*shutdownOutput)
+		plugin.setFailing(true)
+		require.Eventually(t, func() bool {
+			return output.BufferLength() > 0
+		}, 5*time.Second, 10*time.Millisecond)
+
+		// Shut down while the output keeps failing
+		cancel()
+		if recover {
+			time.Sleep(100 * time.Millisecond)
+			plugin.setFailing(false)
+		}
+		require.NoError(t, <-errC)
+		return output
+	}
+
+	// Writing is retried until the output recovers
+	output := run("10s", true)
+	require.Zero(t, output.BufferLength())
+	require.Positive(t, output.Output.(*shutdownOutput).written())
+
+	// Metrics remaining after the timeout are persisted and restored
+	output = run("100ms", false)
+	remaining := output.BufferLength()
+	require.Positive(t, remaining)
+
+	restore := func() *models.RunningOutput {
+		c := config.NewConfig()
+		require.NoError(t, c.LoadConfigData([]byte(fmt.Sprintf(cfg, "100ms")), config.EmptySourcePath))
+		a := NewAgent(c)
+		require.NoError(t, a.initPersister())
+		require.NoError(t, a.loadStates())
+		require.NoError(t, c.Persister.Close())
+		return c.Outputs[0]
+	}
+	require.Equal(t, remaining, restore().BufferLength())
+
+	// Restored metrics are not restored again when restarting after a crash,
+	// i.e. without persisting the states on shutdown
+	require.Zero(t, restore().BufferLength())
+}
+
+func TestAgent_StatefileCheckpoint(t *testing.T) {
+	inputs.Add("checkpoint_test", func() telegraf.Input { return &checkpointInput{} })
+	outputs.Add("checkpoint_test", func() telegraf.Output { return &shutdownOutput{} })
+
+	statefile := filepath.Join(t.TempDir(), "states")
+	cfg := `
+[agent]
+  interval = "10ms"
+  flush_interval = "1h"
+  omit_hostname = true
+  skip_processors_after_aggregators = true
+  statefile = "` + statefile + `"
+  statefile_backend = "kv"
+  statefile_checkpoint_interval = "50ms"
+
+[[inputs.checkpoint_test]]
+
+[[outputs.checkpoint_test]]
+`
+	c := config.NewConfig()
+	require.NoError(t, c.LoadConfigData([]byte(cfg), config.EmptySourcePath))
+	a := NewAgent(c)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	errC := make(chan error, 1)
+	go func() {
+		errC <- a.Run(ctx)
+	}()
+
+	// The state must be stored while the agent is running
+	id := c.Inputs[0].ID()
+	require.Eventually(t, func() bool {
+		p := &persister.Persister{Filename: statefile, Backend: "kv"}
+		if err := p.Init(); err != nil {
+			return false
+		}
+		defer p.Close()
+		restored := &checkpointInput{}
+		if err := p.Register(id, restored); err != nil {
+			return false
+		}
+		return p.Load() == nil && restored.GetState().(int64) > 0
+	}, 5*time.Second, 50*time.Millisecond)
+
+	cancel()
+	require.NoError(t, <-errC)
+}
+
+func TestAgent_Pipelines(t *testing.T) {
+	inputs.Add("pipeline_test", func() telegraf.Input { return &reloadInput{} })
+	outputs.Add("pipeline_test", func() telegraf.Output { return &reloadOutput{} })
+
+	cfg := `
+[agent]
+  omit_hostname = true
+  skip_processors_after_aggregators = true
+
+[[inputs.pipeline_test]]
+  name = "a"
+
+[[inputs.pipeline_test]]
+  name = "b"
+  pipeline = "extra"
+
+[[processors.rename]]
+  [[processors.rename.replace]]
+    measurement = "b"
+    dest = "wrong"
+
+[[processors.rename]]
+  pipeline = "extra"
+  [[processors.rename.replace]]
+    measurement = "b"
+    dest = "renamed"
+
+[[outputs.pipeline_test]]
+
+[[outputs.pipeline_test]]
+  pipelines = ["extra"]
+
+[[outputs.pipeline_test]]
+  pipelines = ["default", "extra"]
+`
+	c := config.NewConfig()
+	require.NoError(t, c.LoadConfigData([]byte(cfg), config.EmptySourcePath))
+	a := NewAgent(c)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(t, a.Once(ctx, 0))
+
+	expected := []map[string]bool{
+		{"a": true},
+		{"renamed": true},
+		{"a": true, "renamed": true},
+	}
+	for i, output := range c.Outputs {
+		require.Equal(t, expected[i], output.Output.(*reloadOutput).names, "output %d", i)
+	}
+}
+
+type checkpointInput struct {
+	gathered atomic.Int64
+}
+
+func (*checkpointInput) SampleConfig() string {
+	return ""
+}
+
+func (i *checkpointInput) Gather(telegraf.Accumulator) error {
+	i.gathered.Add(1)
+	return nil
+}
+
+func (i *checkpointInput) GetState() interface{} {
+	return i.gathered.Load()
+}
+
+func (i *checkpointInput) SetState(state interface{}) error {
+	i.gathered.Store(state.(int64))
+	return nil
+}
+
+type shutdownOutput struct {
+	failing bool
+	count   int
+	sync.Mutex
+}
+
+func (*shutdownOutput) SampleConfig() string {
+	return ""
+}
+
+func (*shutdownOutput) Connect() error {
+	return nil
+}
+
+func (*shutdownOutput) Close() error {
+	return nil
+}
+
+func (o *shutdownOutput) Write(metrics []telegraf.Metric) error {
+	o.Lock()
+	defer o.Unlock()
+
+	if o.failing {
+		return errors.New("failing")
+	}
+	o.count += len(metrics)
+	return nil
+}
+
+func (o *shutdownOutput) setFailing(failing bool) {
+	o.Lock()
+	defer o.Unlock()
+	o.failing = failing
+}
+
+func (o *shutdownOutput) written() int {
+	o.Lock()
+	defer o.Unlock()
+	return o.count
+}
+
 // Implement a "test-mode" like call but collect the metrics
 func collect(ctx context.Context, a *Agent, wait time.Duration) ([]telegraf.Metric, error) {
 	var received []telegraf.Metric
@@ -0,0 +1,137 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/selfstat"
+)
+
+// Interval for checking the fill ratio of the output buffers
+const backpressureCheckInterval = 500 * time.Millisecond
+
+// backpressureMonitor signals backpressure to the inputs of a pipeline if the
+// buffers of the pipeline's outputs are filled above the high watermark and
+// releases the backpressure if all buffers are drained below the low
+// watermark again.
+type backpressureMonitor struct {
+	high float64
+	low  float64
+
+	// State and statistics of the individual pipelines
+	active      map[string]bool
+	stats       map[string]selfstat.Stat
+	activations map[string]selfstat.Stat
+}
+
+// newBackpressureMonitor returns a monitor for the given agent settings or
+// nil if the backpressure signal is disabled
+func newBackpressureMonitor(cfg *config.AgentConfig) (*backpressureMonitor, error) {
+	high, low := cfg.BackpressureHighWatermark, cfg.BackpressureLowWatermark
+	if high == 0 {
+		return nil, nil
+	}
+	if high < 0 || high > 1 {
+		return nil, fmt.Errorf("invalid 'backpressure_high_watermark' setting %v", high)
+	}
+	if low == 0 {
+		low = high / 2
+	}
+	if low < 0 || low >= high {
+		return nil, fmt.Errorf("invalid 'backpressure_low_watermark' setting %v", low)
+	}
+
+	return &backpressureMonitor{
+		high:        high,
+		low:         low,
+		active:      make(map[string]bool),
+		stats:       make(map[string]selfstat.Stat),
+		activations: make(map[string]selfstat.Stat),
+	}, nil
+}
+
+// run checks the output buffers of the pipeline in regular intervals. The
+// returned function stops monitoring.
+func (m *backpressureMonitor) run(ctx context.Context, p *pipeline) func() {
+	ctx, cancel := context.WithCancel(ctx)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(backpressureCheckInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				m.check(p)
+			}
+		}
+	}()
+
+	return func() {
+		cancel()
+		wg.Wait()
+	}
+}
+
+// check updates the backpressure state of each pipeline fed by inputs and
+// signals the state to the inputs
+func (m *backpressureMonitor) check(p *pipeline) {
+	p.inputs.Lock()
+	inputs := slices.Clone(p.inputs.inputs)
+	p.inputs.Unlock()
+
+	p.outputs.RLock()
+	outputs := slices.Clone(p.outputs.outputs)
+	p.outputs.RUnlock()
+
+	names := make(map[string]bool)
+	for _, input := range inputs {
+		names[input.Pipeline()] = true
+	}
+
+	for name := range names {
+		// Use the fullest buffer as the pipeline can only progress as fast as
+		// its slowest output
+		var fill float64
+		for _, output := range outputs {
+			if output.InPipeline(name) && output.MetricBufferLimit > 0 {
+				fill = max(fill, float64(output.BufferLength())/float64(output.MetricBufferLimit))
+			}
+		}
+
+		if _, found := m.stats[name]; !found {
+			tags := map[string]string{"pipeline": name}
+			m.stats[name] = selfstat.Register("backpressure", "active", tags)
+			m.activations[name] = selfstat.Register("backpressure", "activations", tags)
+		}
+
+		active := m.active[name]
+		switch {
+		case !active && fill >= m.high:
+			log.Printf("I! [agent] Output buffers of pipeline %q are %.0f%% full, signalling backpressure to inputs", name, 100*fill)
+			m.activations[name].Incr(1)
+			active = true
+		case active && fill < m.low:
+			log.Printf("I! [agent] Output buffers of pipeline %q drained to %.0f%%, releasing backpressure", name, 100*fill)
+			active = false
+		}
+		m.active[name] = active
+		if active {
+			m.stats[name].Set(1)
+		} else {
+			m.stats[name].Set(0)
+		}
+	}
+
+	for _, input := range inputs {
+		input.SetBackpressure(m.active[input.Pipeline()])
+	}
+}
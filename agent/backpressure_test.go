@@ -0,0 +1,145 @@
+package agent
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/models"
+	"github.com/influxdata/telegraf/testutil"
+)
+
+func TestBackpressureMonitorSettings(t *testing.T) {
+	tests := []struct {
+		name     string
+		high     float64
+		low      float64
+		expected string
+	}{
+		{
+			name:     "high watermark negative",
+			high:     -0.5,
+			expected: "invalid 'backpressure_high_watermark' setting",
+		},
+		{
+			name:     "high watermark above one",
+			high:     1.5,
+			expected: "invalid 'backpressure_high_watermark' setting",
+		},
+		{
+			name:     "low watermark negative",
+			high:     0.8,
+			low:      -0.1,
+			expected: "invalid 'backpressure_low_watermark' setting",
+		},
+		{
+			name:     "low watermark above high watermark",
+			high:     0.8,
+			low:      0.9,
+			expected: "invalid 'backpressure_low_watermark' setting",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.AgentConfig{
+				BackpressureHighWatermark: tt.high,
+				BackpressureLowWatermark:  tt.low,
+			}
+			_, err := newBackpressureMonitor(cfg)
+			require.ErrorContains(t, err, tt.expected)
+		})
+	}
+
+	// Disabled by default
+	m, err := newBackpressureMonitor(&config.AgentConfig{})
+	require.NoError(t, err)
+	require.Nil(t, m)
+
+	// Low watermark defaults to half of the high watermark
+	m, err = newBackpressureMonitor(&config.AgentConfig{BackpressureHighWatermark: 0.8})
+	require.NoError(t, err)
+	require.InDelta(t, 0.4, m.low, 1e-9)
+}
+
+func TestBackpressureMonitorCheck(t *testing.T) {
+	local := &backpressureInput{}
+	remote := &backpressureInput{}
+	inputs := []*models.RunningInput{
+		models.NewRunningInput(local, &models.InputConfig{Name: "local"}),
+		models.NewRunningInput(remote, &models.InputConfig{Name: "remote", Pipeline: "remote"}),
+		models.NewRunningInput(&reloadInput{}, &models.InputConfig{Name: "unsupported"}),
+	}
+
+	sink := &rotationOutput{}
+	outputs := []*models.RunningOutput{
+		models.NewRunningOutput(sink, &models.OutputConfig{Name: "local"}, 10, 10),
+		models.NewRunningOutput(&rotationOutput{}, &models.OutputConfig{Name: "remote", Pipelines: []string{"remote"}}, 10, 10),
+	}
+	require.NoError(t, outputs[0].Connect())
+
+	p := &pipeline{
+		inputs:  &inputUnit{inputs: inputs},
+		outputs: &outputUnit{outputs: outputs},
+	}
+
+	m, err := newBackpressureMonitor(&config.AgentConfig{
+		BackpressureHighWatermark: 0.8,
+		BackpressureLowWatermark:  0.3,
+	})
+	require.NoError(t, err)
+
+	// Below the high watermark
+	for range 7 {
+		outputs[0].AddMetric(testutil.TestMetric(42))
+	}
+	m.check(p)
+	require.Empty(t, local.signals)
+	require.Empty(t, remote.signals)
+
+	// Reaching the high watermark only affects the inputs of the pipeline
+	outputs[0].AddMetric(testutil.TestMetric(42))
+	m.check(p)
+	m.check(p)
+	require.Equal(t, []bool{true}, local.signals)
+	require.True(t, inputs[0].Backpressure())
+	require.Empty(t, remote.signals)
+	require.False(t, inputs[1].Backpressure())
+
+	// Backpressure is kept until falling below the low watermark
+	require.NoError(t, outputs[0].Write())
+	for range 3 {
+		outputs[0].AddMetric(testutil.TestMetric(42))
+	}
+	m.check(p)
+	require.Equal(t, []bool{true}, local.signals)
+
+	require.NoError(t, outputs[0].Write())
+	m.check(p)
+	require.Equal(t, []bool{true, false}, local.signals)
+	require.False(t, inputs[0].Backpressure())
+	require.Empty(t, remote.signals)
+}
+
+type backpressureInput struct {
+	signals []bool
+}
+
+func (*backpressureInput) SampleConfig() string {
+	return ""
+}
+
+func (*backpressureInput) Gather(telegraf.Accumulator) error {
+	return nil
+}
+
+func (*backpressureInput) Start(telegraf.Accumulator) error {
+	return nil
+}
+
+func (*backpressureInput) Stop() {}
+
+func (i *backpressureInput) SetBackpressure(active bool) {
+	i.signals = append(i.signals, active)
+}
@@ -0,0 +1,550 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"reflect"
+	"slices"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/internal"
+	"github.com/influxdata/telegraf/internal/snmp"
+	"github.com/influxdata/telegraf/models"
+	"github.com/influxdata/telegraf/plugins/processors"
+	"github.com/influxdata/telegraf/selfstat"
+)
+
+// ErrRestartRequired is returned by Reload if the configuration changes
+// cannot be applied to the running agent without a full restart.
+var ErrRestartRequired = errors.New("configuration change requires a restart")
+
+var (
+	reloadsTotal   = selfstat.Register("config_reload", "reloads", make(map[string]string))
+	reloadErrors   = selfstat.Register("config_reload", "errors", make(map[string]string))
+	pluginsAdded   = selfstat.Register("config_reload", "plugins_added", make(map[string]string))
+	pluginsRemoved = selfstat.Register("config_reload", "plugins_removed", make(map[string]string))
+	pluginsKept    = selfstat.Register("config_reload", "plugins_kept", make(map[string]string))
+)
+
+// pipeline holds the state of the running agent required to apply a
+// configuration reload.
+type pipeline struct {
+	ctx       context.Context
+	startTime time.Time
+	inputs    *inputUnit
+	middles   map[string]*middleUnit
+	outputs   *outputUnit
+
+	// Requests to replace the processor and aggregator chain of the
+	// individual pipelines
+	swaps map[string]chan *middleSwap
+}
+
+// middleSwap requests replacing the processor and aggregator chain of a
+// pipeline by the one created by the start function. Aggregators to retain
+// continue with their current window in the new chain.
+type middleSwap struct {
+	retain map[*models.RunningAggregator]bool
+	start  func(pipeline string) (*middleUnit, error)
+	done   chan error
+}
+
+// pluginDiff is the difference of a plugin type between the running and the
+// new configuration. The merged list is the plugin list of the new
+// configuration using the running instances for unchanged plugins.
+type pluginDiff[T interface {
+	comparable
+	ID() string
+	LogName() string
+}] struct {
+	merged  []T
+	added   []T
+	removed []T
+	kept    []T
+}
+
+// statsPlugin is a plugin model owning internal statistics.
+type statsPlugin interface {
+	LogName() string
+	UnregisterStats()
+}
+
+// diffPlugins compares the given plugins by their ID. Plugins with the same
+// configuration share the same ID so we match them in order of appearance.
+func diffPlugins[T interface {
+	comparable
+	ID() string
+	LogName() string
+}](previous, current []T) *pluginDiff[T] {
+	candidates := make(map[string][]T, len(previous))
+	for _, p := range previous {
+		candidates[p.ID()] = append(candidates[p.ID()], p)
+	}
+
+	d := &pluginDiff[T]{merged: make([]T, 0, len(current))}
+	matched := make(map[T]bool, len(previous))
+	for _, p := range current {
+		id := p.ID()
+		if c := candidates[id]; len(c) > 0 {
+			candidates[id] = c[1:]
+			matched[c[0]] = true
+			d.merged = append(d.merged, c[0])
+			d.kept = append(d.kept, c[0])
+			continue
+		}
+		d.merged = append(d.merged, p)
+		d.added = append(d.added, p)
+	}
+
+	for _, p := range previous {
+		if !matched[p] {
+			d.removed = append(d.removed, p)
+		}
+	}
+
+	return d
+}
+
+// reordered returns true if the sequence of plugins changed compared to the
+// given list of running plugins.
+func (d *pluginDiff[T]) reordered(previous []T) bool {
+	if len(d.merged) != len(previous) {
+		return true
+	}
+	for i, p := range previous {
+		if d.merged[i] != p {
+			return true
+		}
+	}
+	return false
+}
+
+func (d *pluginDiff[T]) logChanges() {
+	for _, p := range d.removed {
+		log.Printf("D! [agent] Removing %s", p.LogName())
+	}
+	for _, p := range d.added {
+		log.Printf("D! [agent] Adding %s", p.LogName())
+	}
+}
+
+// Reload applies the given configuration to the running agent. Only the
+// plugins differing between the running and the given configuration are
+// stopped and started, plugins with unchanged configuration keep running
+// including their buffers, aggregation windows and state. The processor and
+// aggregator chain is restarted if any processor or aggregator changed.
+// ErrRestartRequired is returned if the changes cannot be applied this way.
+func (a *Agent) Reload(cfg *config.Config) error {
+	a.reloadLock.Lock()
+	defer a.reloadLock.Unlock()
+	a.Lock()
+	defer a.Unlock()
+
+	reloadsTotal.Incr(1)
+	if err := a.reload(cfg); err != nil {
+		reloadErrors.Incr(1)
+		return err
+	}
+	return nil
+}
+
+func (a *Agent) reload(cfg *config.Config) (err error) {
+	// Release the plugins of the new configuration not taken over by the
+	// agent, i.e. the ones replaced by running instances and all plugins not
+	// started if the reload fails. Outputs open their buffer on creation.
+	var unused []statsPlugin
+	adopted := make(map[*models.RunningOutput]bool)
+	defer func() {
+		released := make(map[string]bool)
+		for _, output := range cfg.Outputs {
+			if !adopted[output] {
+				output.Release()
+				released[output.LogName()] = true
+			}
+		}
+		// The released outputs reset the buffer statistics shared with the
+		// running instances
+		for _, output := range a.Config.Outputs {
+			if released[output.LogName()] && !adopted[output] {
+				output.ResetBufferStats()
+			}
+		}
+
+		if err != nil {
+			unused = appendStatsPlugins(nil, cfg.Inputs)
+			unused = appendStatsPlugins(unused, cfg.Processors)
+			unused = appendStatsPlugins(unused, cfg.AggProcessors)
+			unused = appendStatsPlugins(unused, cfg.Aggregators)
+			for _, output := range cfg.Outputs {
+				if !adopted[output] {
+					unused = append(unused, output)
+				}
+			}
+		}
+		a.unregisterStats(unused)
+	}()
+
+	p := a.pipeline
+	if p == nil || p.ctx.Err() != nil {
+		return errors.New("agent is not running")
+	}
+
+	if requiresRestart(a.Config, cfg) {
+		return fmt.Errorf("agent settings or global tags changed: %w", ErrRestartRequired)
+	}
+	if !slices.Equal(a.Config.Pipelines(), cfg.Pipelines()) {
+		return fmt.Errorf("pipelines changed: %w", ErrRestartRequired)
+	}
+	cfg.Agent.SkipProcessorsAfterAggregators = a.Config.Agent.SkipProcessorsAfterAggregators
+
+	inputs := diffPlugins(a.Config.Inputs, cfg.Inputs)
+	processors := diffPlugins(a.Config.Processors, cfg.Processors)
+	aggProcessors := diffPlugins(a.Config.AggProcessors, cfg.AggProcessors)
+	aggregators := diffPlugins(a.Config.Aggregators, cfg.Aggregators)
+	outputs := diffPlugins(a.Config.Outputs, cfg.Outputs)
+
+	// Outputs referencing a replaced dead-letter output would keep writing
+	// to the stopped instance.
+	for _, output := range outputs.kept {
+		for _, target := range outputs.added {
+			if ref := output.Config.DeadLetterOutput; ref != "" && target.Config.Alias == ref {
+				return fmt.Errorf("dead-letter output %q of %s changed: %w", ref, output.LogName(), ErrRestartRequired)
+			}
+		}
+	}
+
+	// Initialize the new plugins before touching the running ones
+	if err := a.initAddedPlugins(inputs.added, processors.added, aggProcessors.added, aggregators.added, outputs.added); err != nil {
+		return err
+	}
+	for _, output := range outputs.added {
+		if err := linkDeadLetterOutput(output, outputs.merged); err != nil {
+			return err
+		}
+	}
+
+	// Connect the new outputs first so metrics of new inputs are not lost.
+	// Connecting is retried for unreachable outputs, so release the agent
+	// lock meanwhile to not block the admin API and secret rotation.
+	a.Unlock()
+	connected, err := a.connectAddedOutputs(p.ctx, outputs.added, adopted)
+	a.Lock()
+	if err == nil && p.ctx.Err() != nil {
+		err = errors.New("agent is shutting down")
+	}
+	if err != nil {
+		for _, output := range connected {
+			output.Close()
+			adopted[output] = true
+		}
+		return err
+	}
+
+	ou := p.outputs
+	for _, output := range connected {
+		adopted[output] = true
+		ou.Lock()
+		a.runOutput(ou.ctx, ou, output)
+		ou.outputs = append(ou.outputs, output)
+		ou.Unlock()
+	}
+
+	// Stop the removed inputs
+	iu := p.inputs
+	iu.Lock()
+	for _, input := range inputs.removed {
+		if stop, found := iu.stops[input]; found {
+			stop()
+			delete(iu.stops, input)
+		}
+		for i, running := range iu.inputs {
+			if running == input {
+				iu.inputs = append(iu.inputs[:i], iu.inputs[i+1:]...)
+				input.Stop()
+				break
+			}
+		}
+	}
+	iu.Unlock()
+
+	// Replace the processor and aggregator chain if anything changed there
+	a.Config.Inputs = inputs.merged
+	a.Config.Outputs = outputs.merged
+	middleChanged := processors.reordered(a.Config.Processors) ||
+		aggProcessors.reordered(a.Config.AggProcessors) ||
+		aggregators.reordered(a.Config.Aggregators)
+	if middleChanged {
+		a.Config.Processors = processors.merged
+		a.Config.AggProcessors = aggProcessors.merged
+		a.Config.Aggregators = aggregators.merged
+
+		retain := make(map[*models.RunningAggregator]bool, len(aggregators.kept))
+		for _, agg := range aggregators.kept {
+			retain[agg] = true
+		}
+		for _, name := range cfg.Pipelines() {
+			req := &middleSwap{
+				retain: retain,
+				start:  a.startMiddle,
+				done:   make(chan error),
+			}
+			select {
+			case p.swaps[name] <- req:
+			case <-p.ctx.Done():
+				return errors.New("agent is shutting down")
+			}
+			if err := <-req.done; err != nil {
+				return err
+			}
+		}
+	}
+
+	// Stop the removed outputs after flushing them one last time
+	for _, output := range outputs.removed {
+		ou.Lock()
+		stop, found := ou.stops[output]
+		delete(ou.stops, output)
+		delete(ou.flushes, output)
+		for i, running := range ou.outputs {
+			if running == output {
+				ou.outputs = append(ou.outputs[:i], ou.outputs[i+1:]...)
+				break
+			}
+		}
+		ou.Unlock()
+
+		// Outputs removed during startup are already closed
+		if found {
+			stop()
+			output.Close()
+		}
+	}
+
+	// Start the new inputs
+	iu.Lock()
+	for _, input := range inputs.added {
+		started, err := a.startInput(iu.dst(input), input)
+		if err != nil {
+			iu.Unlock()
+			return err
+		}
+		if !started {
+			continue
+		}
+		a.runInput(p.ctx, p.startTime, iu, input)
+		iu.inputs = append(iu.inputs, input)
+	}
+	iu.Unlock()
+
+	// Update the persister registrations
+	if a.Config.Persister != nil {
+		a.updatePersister(inputs, processors, aggregators, outputs)
+	}
+
+	// Log and record the outcome
+	var added, removed, kept int
+	for _, d := range []interface{ counts() (int, int, int) }{inputs, processors, aggregators, outputs} {
+		na, nr, nk := d.counts()
+		added += na
+		removed += nr
+		kept += nk
+	}
+	inputs.logChanges()
+	processors.logChanges()
+	aggregators.logChanges()
+	outputs.logChanges()
+	log.Printf("I! [agent] Reloaded configuration: %d plugin(s) added, %d removed, %d kept", added, removed, kept)
+	if middleChanged {
+		log.Printf("I! [agent] Restarted processors and aggregators")
+	}
+	pluginsAdded.Set(int64(added))
+	pluginsRemoved.Set(int64(removed))
+	pluginsKept.Set(int64(kept))
+
+	unused = appendStatsPlugins(unused, inputs.removed)
+	unused = appendStatsPlugins(unused, processors.removed)
+	unused = appendStatsPlugins(unused, aggProcessors.removed)
+	unused = appendStatsPlugins(unused, aggregators.removed)
+	unused = appendStatsPlugins(unused, outputs.removed)
+
+	return nil
+}
+
+// connectAddedOutputs connects the given outputs and returns the connected
+// ones. Outputs failing with a fatal error are closed and marked as adopted.
+func (a *Agent) connectAddedOutputs(
+	ctx context.Context,
+	outputs []*models.RunningOutput,
+	adopted map[*models.RunningOutput]bool,
+) ([]*models.RunningOutput, error) {
+	connected := make([]*models.RunningOutput, 0, len(outputs))
+	for _, output := range outputs {
+		if err := a.connectOutput(ctx, output); err != nil {
+			var fatalErr *internal.FatalError
+			if errors.As(err, &fatalErr) {
+				log.Printf("I! [agent] Failed to connect to [%s], error was %q;  shutting down plugin...", output.LogName(), err)
+				output.Close()
+				adopted[output] = true
+				continue
+			}
+			return connected, fmt.Errorf("connecting output %s: %w", output.LogName(), err)
+		}
+		connected = append(connected, output)
+	}
+	return connected, nil
+}
+
+func (d *pluginDiff[T]) counts() (added, removed, kept int) {
+	return len(d.added), len(d.removed), len(d.kept)
+}
+
+// unregisterStats removes the internal statistics of the given plugins unless
+// they are shared with a plugin of the running configuration.
+func (a *Agent) unregisterStats(plugins []statsPlugin) {
+	var running []statsPlugin
+	running = appendStatsPlugins(running, a.Config.Inputs)
+	running = appendStatsPlugins(running, a.Config.Processors)
+	running = appendStatsPlugins(running, a.Config.AggProcessors)
+	running = appendStatsPlugins(running, a.Config.Aggregators)
+	running = appendStatsPlugins(running, a.Config.Outputs)
+
+	inuse := make(map[string]bool, len(running))
+	for _, p := range running {
+		inuse[p.LogName()] = true
+	}
+	for _, p := range plugins {
+		if !inuse[p.LogName()] {
+			p.UnregisterStats()
+			inuse[p.LogName()] = true
+		}
+	}
+}
+
+func appendStatsPlugins[T statsPlugin](dst []statsPlugin, plugins []T) []statsPlugin {
+	for _, p := range plugins {
+		dst = append(dst, p)
+	}
+	return dst
+}
+
+// initAddedPlugins runs the Init function on the plugins added by a reload.
+func (a *Agent) initAddedPlugins(
+	inputs []*models.RunningInput,
+	procs, aggProcs []*models.RunningProcessor,
+	aggregators []*models.RunningAggregator,
+	outputs []*models.RunningOutput,
+) error {
+	for _, input := range inputs {
+		if tp, ok := input.Input.(snmp.TranslatorPlugin); ok {
+			tp.SetTranslator(a.Config.Agent.SnmpTranslator)
+		}
+		if err := input.Init(); err != nil {
+			return fmt.Errorf("could not initialize input %s: %w", input.LogName(), err)
+		}
+	}
+	for _, processor := range procs {
+		if err := processor.Init(); err != nil {
+			return fmt.Errorf("could not initialize processor %s: %w", processor.LogName(), err)
+		}
+	}
+	for _, aggregator := range aggregators {
+		if err := aggregator.Init(); err != nil {
+			return fmt.Errorf("could not initialize aggregator %s: %w", aggregator.LogName(), err)
+		}
+	}
+	if !*a.Config.Agent.SkipProcessorsAfterAggregators {
+		for _, processor := range aggProcs {
+			if err := processor.Init(); err != nil {
+				return fmt.Errorf("could not initialize processor %s: %w", processor.LogName(), err)
+			}
+		}
+	}
+	for _, output := range outputs {
+		if err := output.Init(); err != nil {
+			return fmt.Errorf("could not initialize output %s: %w", output.LogName(), err)
+		}
+	}
+	return nil
+}
+
+// updatePersister registers the stateful plugins added by a reload and
+// removes the registration of the removed ones.
+func (a *Agent) updatePersister(
+	inputs *pluginDiff[*models.RunningInput],
+	procs *pluginDiff[*models.RunningProcessor],
+	aggregators *pluginDiff[*models.RunningAggregator],
+	outputs *pluginDiff[*models.RunningOutput],
+) {
+	register := func(id, name string, plugin interface{}) {
+		if p, ok := plugin.(telegraf.StatefulPlugin); ok {
+			if err := a.Config.Persister.Register(id, p); err != nil {
+				log.Printf("E! [agent] Could not register %s: %v", name, err)
+			}
+		}
+	}
+	unregister := func(id string, plugin interface{}) {
+		if _, ok := plugin.(telegraf.StatefulPlugin); ok {
+			a.Config.Persister.Unregister(id)
+		}
+	}
+
+	for _, input := range inputs.removed {
+		unregister(input.ID(), input.Input)
+	}
+	for _, input := range inputs.added {
+		register(input.ID(), input.LogName(), input.Input)
+	}
+	for _, processor := range procs.removed {
+		unregister(processor.ID(), unwrapProcessor(processor))
+	}
+	for _, processor := range procs.added {
+		register(processor.ID(), processor.LogName(), unwrapProcessor(processor))
+	}
+	for _, aggregator := range aggregators.removed {
+		unregister(aggregator.ID(), aggregator.Aggregator)
+	}
+	for _, aggregator := range aggregators.added {
+		register(aggregator.ID(), aggregator.LogName(), aggregator.Aggregator)
+	}
+	for _, output := range outputs.removed {
+		unregister(output.ID(), output.Output)
+		a.Config.Persister.Unregister(bufferStateID(output))
+	}
+	for _, output := range outputs.added {
+		register(output.ID(), output.LogName(), output.Output)
+		if a.Config.Agent.ShutdownTimeout > 0 {
+			a.registerBufferState(output)
+		}
+	}
+}
+
+func unwrapProcessor(processor *models.RunningProcessor) interface{} {
+	if p, ok := processor.Processor.(processors.HasUnwrap); ok {
+		return p.Unwrap()
+	}
+	return processor.Processor
+}
+
+// requiresRestart checks if the agent settings or global tags differ between
+// the given configurations.
+func requiresRestart(previous, current *config.Config) bool {
+	if !reflect.DeepEqual(previous.Tags, current.Tags) {
+		return true
+	}
+
+	prev := *previous.Agent
+	cur := *current.Agent
+	skipPrev := prev.SkipProcessorsAfterAggregators != nil && *prev.SkipProcessorsAfterAggregators
+	skipCur := cur.SkipProcessorsAfterAggregators != nil && *cur.SkipProcessorsAfterAggregators
+	if skipPrev != skipCur {
+		return true
+	}
+	prev.SkipProcessorsAfterAggregators = nil
+	cur.SkipProcessorsAfterAggregators = nil
+
+	return !reflect.DeepEqual(prev, cur)
+}
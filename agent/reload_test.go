@@ -0,0 +1,395 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/plugins/inputs"
+	"github.com/influxdata/telegraf/plugins/outputs"
+	"github.com/influxdata/telegraf/selfstat"
+)
+
+type mockPlugin struct {
+	id string
+}
+
+func (m *mockPlugin) ID() string {
+	return m.id
+}
+
+func (m *mockPlugin) LogName() string {
+	return "mock::" + m.id
+}
+
+func TestDiffPlugins(t *testing.T) {
+	a1 := &mockPlugin{id: "a"}
+	a2 := &mockPlugin{id: "a"}
+	b := &mockPlugin{id: "b"}
+	c := &mockPlugin{id: "c"}
+
+	na := &mockPlugin{id: "a"}
+	nc := &mockPlugin{id: "c"}
+	nd := &mockPlugin{id: "d"}
+
+	d := diffPlugins([]*mockPlugin{a1, a2, b, c}, []*mockPlugin{nc, na, nd})
+	require.Equal(t, []*mockPlugin{c, a1, nd}, d.merged)
+	require.Equal(t, []*mockPlugin{c, a1}, d.kept)
+	require.Equal(t, []*mockPlugin{nd}, d.added)
+	require.Equal(t, []*mockPlugin{a2, b}, d.removed)
+	require.True(t, d.reordered([]*mockPlugin{a1, a2, b, c}))
+
+	d = diffPlugins([]*mockPlugin{a1, b}, []*mockPlugin{na, &mockPlugin{id: "b"}})
+	require.Empty(t, d.added)
+	require.Empty(t, d.removed)
+	require.False(t, d.reordered([]*mockPlugin{a1, b}))
+}
+
+func TestRequiresRestart(t *testing.T) {
+	previous := config.NewConfig()
+	require.NoError(t, previous.LoadConfigData([]byte("[agent]\n  interval = \"5s\"\n"), config.EmptySourcePath))
+	skip := false
+	previous.Agent.SkipProcessorsAfterAggregators = &skip
+
+	current := config.NewConfig()
+	require.NoError(t, current.LoadConfigData([]byte("[agent]\n  interval = \"5s\"\n"), config.EmptySourcePath))
+	require.False(t, requiresRestart(previous, current))
+
+	current = config.NewConfig()
+	require.NoError(t, current.LoadConfigData([]byte("[agent]\n  interval = \"10s\"\n"), config.EmptySourcePath))
+	require.True(t, requiresRestart(previous, current))
+
+	current = config.NewConfig()
+	require.NoError(t, current.LoadConfigData([]byte("[global_tags]\n  dc = \"a\"\n"), config.EmptySourcePath))
+	require.True(t, requiresRestart(previous, current))
+}
+
+func TestReload(t *testing.T) {
+	inputs.Add("reload_test", func() telegraf.Input { return &reloadInput{} })
+	outputs.Add("reload_test", func() telegraf.Output { return &reloadOutput{} })
+
+	agentCfg := `
+[agent]
+  interval = "50ms"
+  flush_interval = "50ms"
+  omit_hostname = true
+  skip_processors_after_aggregators = true
+
+[[outputs.reload_test]]
+`
+	cfgA := agentCfg + `
+[[inputs.reload_test]]
+  name = "a"
+
+[[inputs.reload_test]]
+  name = "b"
+`
+	cfgB := agentCfg + `
+[[inputs.reload_test]]
+  name = "b"
+
+[[inputs.reload_test]]
+  name = "c"
+
+[[processors.rename]]
+  [[processors.rename.replace]]
+    measurement = "c"
+    dest = "renamed"
+`
+	c := config.NewConfig()
+	require.NoError(t, c.LoadConfigData([]byte(cfgA), config.EmptySourcePath))
+	a := NewAgent(c)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	errC := make(chan error, 1)
+	go func() {
+		errC <- a.Run(ctx)
+	}()
+
+	output := c.Outputs[0]
+	plugin := output.Output.(*reloadOutput)
+	inputB := c.Inputs[1]
+	require.Eventually(t, func() bool {
+		return plugin.seen("a") && plugin.seen("b")
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// Apply the new config and make sure the unchanged plugins are kept
+	n := config.NewConfig()
+	require.NoError(t, n.LoadConfigData([]byte(cfgB), config.EmptySourcePath))
+	require.NoError(t, a.Reload(n))
+	require.Same(t, output, a.Config.Outputs[0])
+	require.Len(t, a.Config.Inputs, 2)
+	require.Same(t, inputB, a.Config.Inputs[0])
+	require.Len(t, a.Config.Processors, 1)
+	require.Equal(t, int64(2), pluginsKept.Get())
+	require.Equal(t, int64(2), pluginsAdded.Get())
+	require.Equal(t, int64(1), pluginsRemoved.Get())
+
+	plugin.reset()
+	require.Eventually(t, func() bool {
+		return plugin.seen("b") && plugin.seen("renamed")
+	}, 5*time.Second, 10*time.Millisecond)
+	require.False(t, plugin.seen("c"))
+
+	// Changing the agent settings should require a restart
+	n = config.NewConfig()
+	require.NoError(t, n.LoadConfigData([]byte(cfgB+"\n[global_tags]\n  foo = \"bar\"\n"), config.EmptySourcePath))
+	require.ErrorIs(t, a.Reload(n), ErrRestartRequired)
+
+	cancel()
+	require.NoError(t, <-errC)
+}
+
+func TestReloadReleasesDiscardedPlugins(t *testing.T) {
+	if _, err := os.Stat("/proc/self/fd"); err != nil {
+		t.Skip("counting open files requires /proc")
+	}
+
+	inputs.Add("reload_test", func() telegraf.Input { return &reloadInput{} })
+	outputs.Add("reload_test", func() telegraf.Output { return &reloadOutput{} })
+
+	dir := t.TempDir()
+	cfgBase := fmt.Sprintf(`
+[agent]
+  interval = "50ms"
+  flush_interval = "50ms"
+  omit_hostname = true
+  skip_processors_after_aggregators = true
+  buffer_strategy = "disk"
+  buffer_directory = %q
+
+[[outputs.reload_test]]
+
+[[inputs.reload_test]]
+  name = "b"
+`, dir)
+	cfgA := cfgBase + `
+[[inputs.reload_test]]
+  alias = "removed"
+  name = "a"
+`
+
+	c := config.NewConfig()
+	require.NoError(t, c.LoadConfigData([]byte(cfgA), config.EmptySourcePath))
+	a := NewAgent(c)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	errC := make(chan error, 1)
+	go func() {
+		errC <- a.Run(ctx)
+	}()
+
+	output := c.Outputs[0]
+	plugin := output.Output.(*reloadOutput)
+	require.Eventually(t, func() bool {
+		return plugin.seen("a") && plugin.seen("b")
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// The running disk buffer keeps a single segment file open. The output
+	// instance of the new configuration opens another handle on the same
+	// buffer directory which must be released by the reload.
+	singleFileOpen := func() bool {
+		return countOpenFiles(t, dir) == 1
+	}
+	require.Eventually(t, singleFileOpen, 5*time.Second, 10*time.Millisecond)
+	for range 3 {
+		n := config.NewConfig()
+		require.NoError(t, n.LoadConfigData([]byte(cfgBase), config.EmptySourcePath))
+		require.NoError(t, a.Reload(n))
+		require.Same(t, output, a.Config.Outputs[0])
+	}
+	require.Eventually(t, singleFileOpen, 5*time.Second, 10*time.Millisecond)
+
+	// A failing reload must release the outputs of the new configuration
+	n := config.NewConfig()
+	require.NoError(t, n.LoadConfigData([]byte(cfgBase+"\n[global_tags]\n  foo = \"bar\"\n"), config.EmptySourcePath))
+	require.ErrorIs(t, a.Reload(n), ErrRestartRequired)
+	require.Eventually(t, singleFileOpen, 5*time.Second, 10*time.Millisecond)
+
+	// The statistics of the removed input are gone
+	for _, m := range selfstat.Metrics() {
+		if m.Name() == "internal_gather" {
+			alias, _ := m.GetTag("alias")
+			require.NotEqual(t, "removed", alias)
+		}
+	}
+
+	cancel()
+	require.NoError(t, <-errC)
+}
+
+func TestReloadConnectingUnlocked(t *testing.T) {
+	inputs.Add("reload_test", func() telegraf.Input { return &reloadInput{} })
+	outputs.Add("reload_test", func() telegraf.Output { return &reloadOutput{} })
+
+	release := make(chan struct{})
+	connecting := make(chan struct{}, 1)
+	outputs.Add("reload_blocking", func() telegraf.Output {
+		return &blockingOutput{connecting: connecting, release: release}
+	})
+
+	cfg := `
+[agent]
+  interval = "50ms"
+  flush_interval = "50ms"
+  omit_hostname = true
+  skip_processors_after_aggregators = true
+
+[[outputs.reload_test]]
+
+[[inputs.reload_test]]
+  name = "a"
+`
+	c := config.NewConfig()
+	require.NoError(t, c.LoadConfigData([]byte(cfg), config.EmptySourcePath))
+	a := NewAgent(c)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	errC := make(chan error, 1)
+	go func() {
+		errC <- a.Run(ctx)
+	}()
+
+	plugin := c.Outputs[0].Output.(*reloadOutput)
+	require.Eventually(t, func() bool {
+		return plugin.seen("a")
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// Add an output blocking in connect
+	n := config.NewConfig()
+	require.NoError(t, n.LoadConfigData([]byte(cfg+"\n[[outputs.reload_blocking]]\n"), config.EmptySourcePath))
+	reloadC := make(chan error, 1)
+	go func() {
+		reloadC <- a.Reload(n)
+	}()
+
+	// The agent lock must be available while the output is connecting
+	select {
+	case <-connecting:
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "output not connecting")
+	}
+	require.True(t, a.TryLock())
+	a.Unlock()
+
+	close(release)
+	require.NoError(t, <-reloadC)
+	require.Len(t, a.Config.Outputs, 2)
+
+	cancel()
+	require.NoError(t, <-errC)
+}
+
+// countOpenFiles returns the number of files of the process opened in the
+// given directory.
+func countOpenFiles(t *testing.T, dir string) int {
+	t.Helper()
+
+	entries, err := os.ReadDir("/proc/self/fd")
+	require.NoError(t, err)
+
+	var count int
+	for _, entry := range entries {
+		target, err := os.Readlink(filepath.Join("/proc/self/fd", entry.Name()))
+		if err != nil {
+			continue
+		}
+		if strings.HasPrefix(target, dir+string(os.PathSeparator)) {
+			count++
+		}
+	}
+	return count
+}
+
+type reloadInput struct {
+	Name string `toml:"name"`
+}
+
+func (*reloadInput) SampleConfig() string {
+	return ""
+}
+
+func (i *reloadInput) Gather(acc telegraf.Accumulator) error {
+	acc.AddFields(i.Name, map[string]interface{}{"value": 42}, nil)
+	return nil
+}
+
+type reloadOutput struct {
+	names map[string]bool
+	sync.Mutex
+}
+
+func (*reloadOutput) SampleConfig() string {
+	return ""
+}
+
+func (*reloadOutput) Connect() error {
+	return nil
+}
+
+func (*reloadOutput) Close() error {
+	return nil
+}
+
+func (o *reloadOutput) Write(metrics []telegraf.Metric) error {
+	o.Lock()
+	defer o.Unlock()
+
+	if o.names == nil {
+		o.names = make(map[string]bool)
+	}
+	for _, m := range metrics {
+		o.names[m.Name()] = true
+	}
+	return nil
+}
+
+func (o *reloadOutput) seen(name string) bool {
+	o.Lock()
+	defer o.Unlock()
+	return o.names[name]
+}
+
+func (o *reloadOutput) reset() {
+	o.Lock()
+	defer o.Unlock()
+	o.names = nil
+}
+
+type blockingOutput struct {
+	connecting chan struct{}
+	release    chan struct{}
+}
+
+func (*blockingOutput) SampleConfig() string {
+	return ""
+}
+
+func (o *blockingOutput) Connect() error {
+	o.connecting <- struct{}{}
+	<-o.release
+	return nil
+}
+
+func (*blockingOutput) Close() error {
+	return nil
+}
+
+func (*blockingOutput) Write([]telegraf.Metric) error {
+	return nil
+}
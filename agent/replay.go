@@ -0,0 +1,134 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"slices"
+	"time"
+
+	"github.com/fatih/color"
+
+	"github.com/influxdata/telegraf"
+)
+
+// Replay pushes the given metrics through the processors and aggregators of
+// the given pipeline and sends the resulting metrics to the output channel.
+// The aggregation windows follow the timestamps of the replayed metrics
+// instead of the wall-clock, so the result only depends on the metrics. The
+// output channel is closed when Replay returns, also in case of an error.
+func (a *Agent) Replay(ctx context.Context, pipeline string, metrics []telegraf.Metric, outputC chan<- telegraf.Metric) error {
+	defer close(outputC)
+
+	// Set the default for processor skipping
+	if a.Config.Agent.SkipProcessorsAfterAggregators == nil {
+		msg := `The default value of 'skip_processors_after_aggregators' will change to 'true' with Telegraf v1.40.0! `
+		msg += `If you need the current default behavior, please explicitly set the option to 'false'!`
+		log.Print("W! [agent] ", color.YellowString(msg))
+		skipProcessorsAfterAggregators := false
+		a.Config.Agent.SkipProcessorsAfterAggregators = &skipProcessorsAfterAggregators
+	}
+
+	if !slices.Contains(a.Config.Pipelines(), pipeline) {
+		return fmt.Errorf("unknown pipeline %q", pipeline)
+	}
+
+	// Only initialize the plugins used for replaying
+	log.Printf("D! [agent] Initializing plugins")
+	for _, processor := range inPipeline(a.Config.Processors, pipeline) {
+		if err := processor.Init(); err != nil {
+			return fmt.Errorf("could not initialize processor %s: %w", processor.LogName(), err)
+		}
+	}
+	for _, aggregator := range inPipeline(a.Config.Aggregators, pipeline) {
+		if err := aggregator.Init(); err != nil {
+			return fmt.Errorf("could not initialize aggregator %s: %w", aggregator.LogName(), err)
+		}
+	}
+	if !*a.Config.Agent.SkipProcessorsAfterAggregators {
+		for _, processor := range inPipeline(a.Config.AggProcessors, pipeline) {
+			if err := processor.Init(); err != nil {
+				return fmt.Errorf("could not initialize processor %s: %w", processor.LogName(), err)
+			}
+		}
+	}
+
+	unit, err := a.startMiddle(pipeline)
+	if err != nil {
+		return err
+	}
+	if unit.aggregators != nil {
+		unit.aggregators.replay = true
+	}
+	a.runMiddle(time.Time{}, unit, outputC)
+
+	for _, m := range metrics {
+		if ctx.Err() != nil {
+			break
+		}
+		unit.src <- m
+	}
+	close(unit.src)
+	unit.wg.Wait()
+
+	log.Printf("D! [agent] Replay finished")
+
+	return ctx.Err()
+}
+
+// replayAggregators aggregates the metrics of the unit using the metric
+// timestamps as time. A window is pushed as soon as a metric later than the
+// window end arrives and all windows are pushed when the source channel is
+// closed. Aggregated metrics are timestamped with the end of their window.
+func (a *Agent) replayAggregators(unit *aggregatorUnit) {
+	interval := time.Duration(a.Config.Agent.Interval)
+	precision := getPrecision(time.Duration(a.Config.Agent.Precision), interval)
+
+	pushTimes := make([]time.Time, len(unit.aggregators))
+	accs := make([]*accumulator, 0, len(unit.aggregators))
+	for i, agg := range unit.aggregators {
+		accs = append(accs, &accumulator{
+			maker:     agg,
+			metrics:   unit.aggC,
+			precision: precision,
+			now:       func() time.Time { return pushTimes[i] },
+		})
+	}
+	push := func(i int) {
+		pushTimes[i] = unit.aggregators[i].EndPeriod()
+		unit.aggregators[i].Push(accs[i])
+	}
+
+	var now time.Time
+	for metric := range unit.src {
+		// Start the windows at the first metric
+		if now.IsZero() {
+			for _, agg := range unit.aggregators {
+				since, until := updateWindow(metric.Time(), a.Config.Agent.RoundInterval, agg.Period())
+				agg.UpdateWindow(since, until)
+			}
+		}
+		if metric.Time().After(now) {
+			now = metric.Time()
+		}
+
+		for i, agg := range unit.aggregators {
+			if now.Before(agg.EndPeriod()) {
+				continue
+			}
+			push(i)
+
+			// Skip the empty windows if the metric time jumps ahead
+			if !now.Before(agg.EndPeriod()) {
+				since, until := updateWindow(now, a.Config.Agent.RoundInterval, agg.Period())
+				agg.UpdateWindow(since, until)
+			}
+		}
+
+		unit.add(metric)
+	}
+
+	for i := range unit.aggregators {
+		push(i)
+	}
+}
@@ -0,0 +1,68 @@
+package agent
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/metric"
+	"github.com/influxdata/telegraf/testutil"
+)
+
+func TestAgent_Replay(t *testing.T) {
+	cfg := `
+[agent]
+  omit_hostname = true
+  skip_processors_after_aggregators = true
+
+[[processors.override]]
+  [processors.override.tags]
+    env = "test"
+
+[[aggregators.basicstats]]
+  period = "10s"
+  drop_original = true
+  stats = ["mean"]
+`
+	c := config.NewConfig()
+	require.NoError(t, c.LoadConfigData([]byte(cfg), config.EmptySourcePath))
+	a := NewAgent(c)
+
+	input := make([]telegraf.Metric, 0, 5)
+	for _, sec := range []int64{0, 5, 10, 12, 25} {
+		input = append(input, metric.New("cpu", map[string]string{}, map[string]interface{}{"value": float64(sec)}, time.Unix(sec, 0)))
+	}
+
+	outputC := make(chan telegraf.Metric, 10)
+	require.NoError(t, a.Replay(context.Background(), "default", input, outputC))
+
+	var actual []telegraf.Metric
+	for m := range outputC {
+		actual = append(actual, m)
+	}
+
+	// The windows follow the metric timestamps and aggregates are timestamped
+	// with the end of the window
+	tags := map[string]string{"env": "test"}
+	expected := []telegraf.Metric{
+		metric.New("cpu", tags, map[string]interface{}{"value_mean": 2.5}, time.Unix(10, 0)),
+		metric.New("cpu", tags, map[string]interface{}{"value_mean": 11.0}, time.Unix(20, 0)),
+		metric.New("cpu", tags, map[string]interface{}{"value_mean": 25.0}, time.Unix(30, 0)),
+	}
+	testutil.RequireMetricsEqual(t, expected, actual)
+}
+
+func TestAgent_ReplayUnknownPipeline(t *testing.T) {
+	c := config.NewConfig()
+	a := NewAgent(c)
+	outputC := make(chan telegraf.Metric)
+	require.ErrorContains(t, a.Replay(context.Background(), "foo", nil, outputC), `unknown pipeline "foo"`)
+
+	// The output channel must be closed on errors
+	_, open := <-outputC
+	require.False(t, open)
+}
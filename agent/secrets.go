@@ -0,0 +1,86 @@
+package agent
+
+import (
+	"context"
+	"log"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/selfstat"
+)
+
+// watchSecretStores watches the secret-stores supporting it for rotated
+// secrets and requests the plugins using those secrets to reconnect. The
+// returned function stops watching.
+func (a *Agent) watchSecretStores(ctx context.Context) func() {
+	ctx, cancel := context.WithCancel(ctx)
+	var wg sync.WaitGroup
+	for id, store := range a.Config.SecretStores {
+		watcher, ok := store.(telegraf.SecretStoreWatcher)
+		if !ok {
+			continue
+		}
+
+		rotations := selfstat.Register("secretstore", "rotations", map[string]string{"id": id})
+		wg.Add(1)
+		go func(id string) {
+			defer wg.Done()
+			err := watcher.Watch(ctx, func(keys []string) {
+				rotations.Incr(int64(len(keys)))
+				a.secretsRotated(id, keys)
+			})
+			if err != nil && ctx.Err() == nil {
+				log.Printf("E! [agent] Watching secret-store %q for rotated secrets failed: %v", id, err)
+			}
+		}(id)
+	}
+
+	return func() {
+		cancel()
+		wg.Wait()
+	}
+}
+
+// secretsRotated requests the running inputs and outputs using any of the
+// given secrets of the secret-store to reconnect
+func (a *Agent) secretsRotated(id string, keys []string) {
+	refs := make([]string, 0, len(keys))
+	for _, key := range keys {
+		refs = append(refs, "@{"+id+":"+key+"}")
+	}
+	log.Printf("I! [agent] Secret-store %q reported rotated secret(s) %s", id, strings.Join(keys, ", "))
+
+	a.Lock()
+	p := a.pipeline
+	a.Unlock()
+	if p == nil {
+		return
+	}
+
+	usesAny := func(secrets []string) bool {
+		return slices.ContainsFunc(secrets, func(s string) bool { return slices.Contains(refs, s) })
+	}
+
+	// Reconnect the inputs while holding the lock so they are not stopped
+	// concurrently e.g. by a reload or on shutdown
+	p.inputs.Lock()
+	for _, input := range p.inputs.inputs {
+		if p.ctx.Err() == nil && usesAny(input.Config.Secrets) {
+			log.Printf("D! [agent] Reconnecting %s", input.LogName())
+			input.Reconnect()
+		}
+	}
+	p.inputs.Unlock()
+
+	p.outputs.RLock()
+	outputs := slices.Clone(p.outputs.outputs)
+	p.outputs.RUnlock()
+	for _, output := range outputs {
+		if usesAny(output.Config.Secrets) {
+			log.Printf("D! [agent] Requesting %s to reconnect", output.LogName())
+			output.RequestReconnect()
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package agent
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/models"
+)
+
+func TestSecretsRotated(t *testing.T) {
+	affected := &rotationOutput{}
+	unaffected := &rotationOutput{}
+	outputs := []*models.RunningOutput{
+		models.NewRunningOutput(affected, &models.OutputConfig{
+			Name:    "affected",
+			Secrets: []string{"@{store:user}", "@{store:password}"},
+		}, 10, 100),
+		models.NewRunningOutput(unaffected, &models.OutputConfig{
+			Name:    "unaffected",
+			Secrets: []string{"@{other:password}"},
+		}, 10, 100),
+	}
+	for _, output := range outputs {
+		require.NoError(t, output.Connect())
+	}
+
+	input := &rotationInput{}
+	inputs := []*models.RunningInput{
+		models.NewRunningInput(input, &models.InputConfig{
+			Name:    "affected",
+			Secrets: []string{"@{store:password}"},
+		}),
+	}
+	require.NoError(t, inputs[0].Start(nil))
+
+	a := NewAgent(config.NewConfig())
+	a.pipeline = &pipeline{
+		ctx:     context.Background(),
+		inputs:  &inputUnit{inputs: inputs},
+		outputs: &outputUnit{outputs: outputs},
+	}
+	a.secretsRotated("store", []string{"password"})
+	require.Equal(t, 1, input.reconnects)
+
+	for _, output := range outputs {
+		require.NoError(t, output.Write())
+	}
+	require.Equal(t, 1, affected.closes)
+	require.Equal(t, 2, affected.connects)
+	require.Equal(t, 0, unaffected.closes)
+	require.Equal(t, 1, unaffected.connects)
+}
+
+type rotationOutput struct {
+	connects int
+	closes   int
+}
+
+func (*rotationOutput) SampleConfig() string {
+	return ""
+}
+
+func (o *rotationOutput) Connect() error {
+	o.connects++
+	return nil
+}
+
+func (o *rotationOutput) Close() error {
+	o.closes++
+	return nil
+}
+
+func (*rotationOutput) Write([]telegraf.Metric) error {
+	return nil
+}
+
+type rotationInput struct {
+	reconnects int
+}
+
+func (*rotationInput) SampleConfig() string {
+	return ""
+}
+
+func (*rotationInput) Start(telegraf.Accumulator) error {
+	return nil
+}
+
+func (*rotationInput) Stop() {}
+
+func (*rotationInput) Gather(telegraf.Accumulator) error {
+	return nil
+}
+
+func (i *rotationInput) Reconnect() error {
+	i.reconnects++
+	return nil
+}
@@ -0,0 +1,43 @@
+package agent
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/models"
+)
+
+// startTracer activates tracing of the metrics matching the configured
+// selector. The trace records are either logged or written to the output
+// with the configured alias.
+func (a *Agent) startTracer(cfg *config.TraceConfig) error {
+	emit := func(r *models.TraceRecord) {
+		log.Printf("I! [trace] %s", r)
+	}
+
+	if cfg.Output != "" {
+		var target *models.RunningOutput
+		for _, output := range a.Config.Outputs {
+			if output.Config.Alias == cfg.Output {
+				target = output
+				break
+			}
+		}
+		if target == nil {
+			return fmt.Errorf("trace output %q not found", cfg.Output)
+		}
+		emit = func(r *models.TraceRecord) {
+			target.AddTrace(r.Metric())
+		}
+	}
+
+	tracer, err := models.NewTracer(cfg.Selector, emit)
+	if err != nil {
+		return err
+	}
+	models.SetTracer(tracer)
+
+	log.Printf("W! [agent] Tracing metrics matching %q, this might impact performance!", cfg.Selector)
+	return nil
+}
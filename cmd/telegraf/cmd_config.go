@@ -2,6 +2,7 @@
 package main
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
@@ -9,11 +10,14 @@ import (
 	"net/url"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
 
 	"github.com/urfave/cli/v2"
 
 	"github.com/influxdata/telegraf/agent"
 	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/internal"
 	"github.com/influxdata/telegraf/logger"
 	"github.com/influxdata/telegraf/migrations"
 )
@@ -56,25 +60,9 @@ func getConfigCommands(configHandlingFlags []cli.Flag, outputBuffer io.Writer) [
 							return err
 						}
 
-						// Collect the given configuration files
-						configFiles := cCtx.StringSlice("config")
-						configDir := cCtx.StringSlice("config-directory")
-						for _, fConfigDirectory := range configDir {
-							files, err := config.WalkDirectory(fConfigDirectory)
-							if err != nil {
-								return err
-							}
-							configFiles = append(configFiles, files...)
-						}
-
-						// If no "config" or "config-directory" flag(s) was
-						// provided we should load default configuration files
-						if len(configFiles) == 0 {
-							paths, err := config.GetDefaultConfigPath()
-							if err != nil {
-								return err
-							}
-							configFiles = paths
+						configFiles, err := collectConfigFiles(cCtx)
+						if err != nil {
+							return err
 						}
 
 						// Load the config and try to initialize the plugins
@@ -88,6 +76,102 @@ func getConfigCommands(configHandlingFlags []cli.Flag, outputBuffer io.Writer) [
 						return ag.InitPlugins()
 					},
 				},
+				{
+					Name:  "lint",
+					Usage: "lint configuration file(s) for likely mistakes",
+					Description: `
+The 'lint' command reads the configuration files specified via '--config' or
+'--config-directory' and statically analyzes the settings for likely mistakes
+not detected when initializing the plugins, such as filters that can never
+match, outputs never receiving metrics, unused secret-stores, plugins with the
+same ID or processors skipped for aggregated metrics. If no configuration file
+is explicitly specified the command reads the default locations and uses those
+configuration files.
+The issues are reported with the location of the plugin definition, use the
+'--format' flag to get 'json' or 'sarif' output e.g. for continuous
+integration. The command fails if any issue is found.
+
+To lint the file 'mysettings.conf' use
+
+> telegraf config lint --config mysettings.conf
+`,
+					Flags: append([]cli.Flag{
+						&cli.StringFlag{
+							Name:  "format",
+							Usage: "output format of the issues, one of 'text', 'json' or 'sarif'",
+							Value: "text",
+						},
+					}, configHandlingFlags...),
+					Action: func(cCtx *cli.Context) error {
+						// Setup logging
+						logConfig := &logger.Config{Debug: cCtx.Bool("debug")}
+						if err := logger.SetupLogging(logConfig); err != nil {
+							return err
+						}
+
+						configFiles, err := collectConfigFiles(cCtx)
+						if err != nil {
+							return err
+						}
+
+						// Load the config without initializing the plugins
+						c := config.NewConfig()
+						c.Agent.Quiet = cCtx.Bool("quiet")
+						if err := c.LoadAll(configFiles...); err != nil {
+							return err
+						}
+
+						issues := c.Lint()
+						if err := printLintIssues(outputBuffer, cCtx.String("format"), issues); err != nil {
+							return err
+						}
+						if len(issues) > 0 {
+							return fmt.Errorf("found %d issue(s)", len(issues))
+						}
+						return nil
+					},
+				},
+				{
+					Name:  "show",
+					Usage: "show the effective configuration",
+					Description: `
+The 'show' command reads the configuration files specified via '--config' or
+'--config-directory' and prints the resulting configuration as TOML. All
+plugins are shown with the options not set in the configuration filled with
+their default values. Environment variables and variables are expanded and
+each option is annotated with the file and line it is defined in. Secrets are
+redacted except for references to secret-stores. If no configuration file is
+explicitly specified the command reads the default locations and uses those
+configuration files.
+
+To show the effective configuration of the files in '/etc/telegraf/telegraf.d'
+use
+
+> telegraf config show --config-directory /etc/telegraf/telegraf.d
+`,
+					Flags: configHandlingFlags,
+					Action: func(cCtx *cli.Context) error {
+						// Setup logging
+						logConfig := &logger.Config{Debug: cCtx.Bool("debug")}
+						if err := logger.SetupLogging(logConfig); err != nil {
+							return err
+						}
+
+						configFiles, err := collectConfigFiles(cCtx)
+						if err != nil {
+							return err
+						}
+
+						// Load the config without initializing the plugins
+						c := config.NewConfig()
+						c.Agent.Quiet = cCtx.Bool("quiet")
+						if err := c.LoadAll(configFiles...); err != nil {
+							return err
+						}
+
+						return c.Show(outputBuffer)
+					},
+				},
 				{
 					Name:  "create",
 					Usage: "create a full sample configuration and show it",
@@ -153,25 +237,9 @@ To migrate the file 'mysettings.conf' use
 						}
 						log.Printf("%d plugin migration(s) available", len(migrations.PluginMigrations))
 
-						// Collect the given configuration files
-						configFiles := cCtx.StringSlice("config")
-						configDir := cCtx.StringSlice("config-directory")
-						for _, fConfigDirectory := range configDir {
-							files, err := config.WalkDirectory(fConfigDirectory)
-							if err != nil {
-								return err
-							}
-							configFiles = append(configFiles, files...)
-						}
-
-						// If no "config" or "config-directory" flag(s) was
-						// provided we should load default configuration files
-						if len(configFiles) == 0 {
-							paths, err := config.GetDefaultConfigPath()
-							if err != nil {
-								return err
-							}
-							configFiles = paths
+						configFiles, err := collectConfigFiles(cCtx)
+						if err != nil {
+							return err
 						}
 
 						for _, fn := range configFiles {
@@ -223,7 +291,217 @@ To migrate the file 'mysettings.conf' use
 						return nil
 					},
 				},
+				{
+					Name:  "convert",
+					Usage: "convert the configuration(s) to another format",
+					Description: `
+The 'convert' command reads the configuration files specified via '--config' or
+'--config-directory' and converts them to the format given via '--format'.
+Supported formats are 'toml', 'yaml' and 'json', the format of the input files
+is detected by their extension. If no configuration file is explicitly
+specified the command reads the default locations and uses those configuration
+files. Converted files are stored at the location of the inputs with the
+extension replaced by the extension of the new format, i.e. '.conf' for TOML.
+If you are converting remote configurations the converted configurations are
+stored in the current directory using the filename of the URL.
+Environment variables and file-scoped variables are not substituted but kept
+in the converted files. Comments are not converted!
+
+To convert the file 'mysettings.conf' to YAML use
+
+> telegraf config convert --config mysettings.conf --format yaml
+`,
+					Flags: append([]cli.Flag{
+						&cli.StringFlag{
+							Name:     "format",
+							Usage:    "format to convert the configuration to (toml, yaml or json)",
+							Required: true,
+						},
+						&cli.BoolFlag{
+							Name:  "force",
+							Usage: "forces overwriting of an existing output file",
+						},
+					}, configHandlingFlags...),
+					Action: func(cCtx *cli.Context) error {
+						// Setup logging
+						logConfig := &logger.Config{Debug: cCtx.Bool("debug")}
+						if err := logger.SetupLogging(logConfig); err != nil {
+							return err
+						}
+
+						var ext string
+						switch format := cCtx.String("format"); format {
+						case config.FormatTOML:
+							ext = ".conf"
+						case config.FormatYAML, config.FormatJSON:
+							ext = "." + format
+						default:
+							return fmt.Errorf("unsupported format %q", format)
+						}
+
+						configFiles, err := collectConfigFiles(cCtx)
+						if err != nil {
+							return err
+						}
+
+						for _, fn := range configFiles {
+							format := config.DetectFormat(fn)
+							if format == cCtx.String("format") {
+								log.Printf("I! Skipping %q already in %s format", fn, format)
+								continue
+							}
+							log.Printf("D! Trying to convert %q...", fn)
+
+							// Read and convert the config file
+							data, remote, err := config.LoadConfigFile(fn)
+							if err != nil {
+								return fmt.Errorf("opening input %q failed: %w", fn, err)
+							}
+
+							out, err := config.ConvertFormat(data, format, cCtx.String("format"))
+							if err != nil {
+								return fmt.Errorf("converting %q failed: %w", fn, err)
+							}
+
+							// Construct the output filename
+							// For remote locations we just save the filename
+							// with the new extension.
+							outfn := fn
+							if remote {
+								u, err := url.Parse(fn)
+								if err != nil {
+									return fmt.Errorf("parsing remote config URL %q failed: %w", fn, err)
+								}
+								outfn = filepath.Base(u.Path)
+							}
+							outfn = strings.TrimSuffix(outfn, filepath.Ext(outfn)) + ext
+
+							log.Printf("I! Converted %q, writing result as %q", fn, outfn)
+
+							// Make sure the file does not exist yet if we should not overwrite
+							if !cCtx.Bool("force") {
+								if _, err := os.Stat(outfn); !errors.Is(err, os.ErrNotExist) {
+									return fmt.Errorf("output file %q already exists", outfn)
+								}
+							}
+
+							// Write the output file
+							if err := os.WriteFile(outfn, out, 0640); err != nil {
+								return fmt.Errorf("writing output %q failed: %w", outfn, err)
+							}
+						}
+						return nil
+					},
+				},
 			},
 		},
 	}
 }
+
+// printLintIssues writes the issues found when linting the configuration in
+// the given format
+func printLintIssues(w io.Writer, format string, issues []config.LintIssue) error {
+	switch format {
+	case "", "text":
+		for _, issue := range issues {
+			fmt.Fprintln(w, issue.String())
+		}
+		return nil
+	case "json":
+		if issues == nil {
+			issues = make([]config.LintIssue, 0)
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(issues)
+	case "sarif":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(newSarifReport(issues))
+	}
+	return fmt.Errorf("invalid format %q", format)
+}
+
+// sarifReport is a minimal Static Analysis Results Interchange Format (SARIF)
+// v2.1.0 log, see https://docs.oasis-open.org/sarif/sarif/v2.1.0/sarif-v2.1.0.html
+type sarifReport struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool struct {
+		Driver struct {
+			Name           string      `json:"name"`
+			InformationURI string      `json:"informationUri"`
+			Version        string      `json:"version,omitempty"`
+			Rules          []sarifRule `json:"rules"`
+		} `json:"driver"`
+	} `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifRule struct {
+	ID               string       `json:"id"`
+	ShortDescription sarifMessage `json:"shortDescription"`
+}
+
+type sarifResult struct {
+	RuleID    string          `json:"ruleId"`
+	Level     string          `json:"level"`
+	Message   sarifMessage    `json:"message"`
+	Locations []sarifLocation `json:"locations"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation struct {
+		ArtifactLocation struct {
+			URI string `json:"uri"`
+		} `json:"artifactLocation"`
+		Region struct {
+			StartLine int `json:"startLine"`
+		} `json:"region"`
+	} `json:"physicalLocation"`
+}
+
+func newSarifReport(issues []config.LintIssue) *sarifReport {
+	var run sarifRun
+	run.Tool.Driver.Name = "telegraf"
+	run.Tool.Driver.InformationURI = "https://github.com/influxdata/telegraf"
+	run.Tool.Driver.Version = internal.Version
+	rules := make([]string, 0, len(config.LintRules))
+	for id := range config.LintRules {
+		rules = append(rules, id)
+	}
+	sort.Strings(rules)
+	for _, id := range rules {
+		run.Tool.Driver.Rules = append(run.Tool.Driver.Rules, sarifRule{
+			ID:               id,
+			ShortDescription: sarifMessage{Text: config.LintRules[id]},
+		})
+	}
+
+	run.Results = make([]sarifResult, 0, len(issues))
+	for _, issue := range issues {
+		var loc sarifLocation
+		loc.PhysicalLocation.ArtifactLocation.URI = filepath.ToSlash(issue.Location.Source)
+		loc.PhysicalLocation.Region.StartLine = issue.Location.Line
+		run.Results = append(run.Results, sarifResult{
+			RuleID:    issue.Rule,
+			Level:     "warning",
+			Message:   sarifMessage{Text: issue.Plugin + ": " + issue.Message},
+			Locations: []sarifLocation{loc},
+		})
+	}
+
+	return &sarifReport{
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Version: "2.1.0",
+		Runs:    []sarifRun{run},
+	}
+}
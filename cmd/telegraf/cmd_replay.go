@@ -0,0 +1,198 @@
+// Command handling for the "replay" command
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"sort"
+	"syscall"
+
+	"github.com/urfave/cli/v2"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/agent"
+	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/logger"
+	"github.com/influxdata/telegraf/models"
+	"github.com/influxdata/telegraf/plugins/serializers/influx"
+)
+
+func getReplayCommands(configHandlingFlags []cli.Flag, outputBuffer io.Writer) []*cli.Command {
+	return []*cli.Command{
+		{
+			Name:  "replay",
+			Usage: "replay captured metrics through the configured processors and aggregators",
+			Description: `
+The 'replay' command reads metrics from the file given via '--data-file' and
+pushes them through the processors and aggregators of the configuration files
+specified via '--config' or '--config-directory'. Inputs and outputs of the
+configuration are not started, the resulting metrics are written in InfluxDB
+line-protocol to stdout or the file given via '--output-file'.
+The aggregation windows follow the timestamps of the replayed metrics instead
+of the wall-clock, so the result only depends on the replayed metrics. This
+allows to test processor and aggregator settings against golden files. The
+output is sorted by timestamp and series.
+
+The data is parsed as InfluxDB line-protocol by default. Use '--data-format' to
+select another parser or '--parser-config' to specify a file containing the
+parser settings in the same format as for input plugins.
+
+To replay the metrics in 'captured.influx' with the processors and aggregators
+of 'mysettings.conf' use
+
+> telegraf replay --config mysettings.conf --data-file captured.influx
+`,
+			Flags: append([]cli.Flag{
+				&cli.StringFlag{
+					Name:     "data-file",
+					Usage:    "file containing the metrics to replay, use '-' for stdin",
+					Required: true,
+				},
+				&cli.StringFlag{
+					Name:  "data-format",
+					Usage: "data format of the metrics to replay",
+				},
+				&cli.StringFlag{
+					Name:  "parser-config",
+					Usage: "file containing the parser settings for the metrics to replay",
+				},
+				&cli.StringFlag{
+					Name:  "pipeline",
+					Usage: "pipeline to replay the metrics through",
+					Value: models.DefaultPipeline,
+				},
+				&cli.StringFlag{
+					Name:  "output-file",
+					Usage: "file to write the resulting metrics to instead of stdout",
+				},
+			}, configHandlingFlags...),
+			Action: func(cCtx *cli.Context) error {
+				// Setup logging
+				logConfig := &logger.Config{Debug: cCtx.Bool("debug"), Quiet: cCtx.Bool("quiet")}
+				if err := logger.SetupLogging(logConfig); err != nil {
+					return err
+				}
+
+				configFiles, err := collectConfigFiles(cCtx)
+				if err != nil {
+					return err
+				}
+
+				c := config.NewConfig()
+				c.Agent.Quiet = cCtx.Bool("quiet")
+				if err := c.LoadAll(configFiles...); err != nil {
+					return err
+				}
+
+				// Setup the parser
+				var parserConfig []byte
+				switch {
+				case cCtx.String("parser-config") != "" && cCtx.String("data-format") != "":
+					return errors.New("'data-format' cannot be used together with 'parser-config'")
+				case cCtx.String("parser-config") != "":
+					buf, err := os.ReadFile(cCtx.String("parser-config"))
+					if err != nil {
+						return fmt.Errorf("reading parser settings failed: %w", err)
+					}
+					parserConfig = buf
+				case cCtx.String("data-format") != "":
+					parserConfig = []byte(fmt.Sprintf("data_format = %q", cCtx.String("data-format")))
+				default:
+					parserConfig = []byte(`data_format = "influx"`)
+				}
+				parser, err := c.NewParser("replay", parserConfig)
+				if err != nil {
+					return fmt.Errorf("creating parser failed: %w", err)
+				}
+
+				// Read the metrics to replay
+				var data []byte
+				if fn := cCtx.String("data-file"); fn == "-" {
+					data, err = io.ReadAll(os.Stdin)
+				} else {
+					data, err = os.ReadFile(fn)
+				}
+				if err != nil {
+					return fmt.Errorf("reading data failed: %w", err)
+				}
+				metrics, err := parser.Parse(data)
+				if err != nil {
+					return fmt.Errorf("parsing data failed: %w", err)
+				}
+
+				// Replay the metrics and collect the result
+				ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+				defer cancel()
+
+				outputC := make(chan telegraf.Metric, 100)
+				resultC := make(chan []telegraf.Metric)
+				go func() {
+					var result []telegraf.Metric
+					for m := range outputC {
+						result = append(result, m)
+					}
+					resultC <- result
+				}()
+
+				ag := agent.NewAgent(c)
+				if err := ag.Replay(ctx, cCtx.String("pipeline"), metrics, outputC); err != nil {
+					return err
+				}
+				result := <-resultC
+
+				// Write the result
+				w := outputBuffer
+				if fn := cCtx.String("output-file"); fn != "" {
+					f, err := os.Create(fn)
+					if err != nil {
+						return fmt.Errorf("creating output file failed: %w", err)
+					}
+					defer f.Close()
+					w = f
+				}
+				return writeReplayResult(w, result)
+			},
+		},
+	}
+}
+
+// writeReplayResult writes the metrics in line-protocol sorted by timestamp
+// and series to get a reproducible output
+func writeReplayResult(w io.Writer, metrics []telegraf.Metric) error {
+	serializer := &influx.Serializer{SortFields: true, UintSupport: true}
+	if err := serializer.Init(); err != nil {
+		return err
+	}
+
+	type line struct {
+		timestamp int64
+		octets    []byte
+	}
+	lines := make([]line, 0, len(metrics))
+	for _, m := range metrics {
+		octets, err := serializer.Serialize(m)
+		if err != nil {
+			return fmt.Errorf("serializing metric failed: %w", err)
+		}
+		lines = append(lines, line{timestamp: m.Time().UnixNano(), octets: octets})
+		m.Accept()
+	}
+	sort.SliceStable(lines, func(i, j int) bool {
+		if lines[i].timestamp != lines[j].timestamp {
+			return lines[i].timestamp < lines[j].timestamp
+		}
+		return bytes.Compare(lines[i].octets, lines[j].octets) < 0
+	})
+
+	for _, l := range lines {
+		if _, err := w.Write(l.octets); err != nil {
+			return err
+		}
+	}
+	return nil
+}
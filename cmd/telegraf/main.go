@@ -86,6 +86,25 @@ func processFilterFlags(ctx *cli.Context) Filters {
 	return Filters{sectionFilters, inputFilters, outputFilters, aggregatorFilters, processorFilters, secretstoreFilters}
 }
 
+// collectConfigFiles returns the configuration files given via the "config"
+// flags and found in the "config-directory" directories. If none of those
+// flags is provided, the default configuration files are returned.
+func collectConfigFiles(cCtx *cli.Context) ([]string, error) {
+	configFiles := cCtx.StringSlice("config")
+	for _, fConfigDirectory := range cCtx.StringSlice("config-directory") {
+		files, err := config.WalkDirectory(fConfigDirectory)
+		if err != nil {
+			return nil, err
+		}
+		configFiles = append(configFiles, files...)
+	}
+
+	if len(configFiles) == 0 {
+		return config.GetDefaultConfigPath()
+	}
+	return configFiles, nil
+}
+
 func deleteEmpty(s []string) []string {
 	var r []string
 	for _, str := range s {
@@ -106,7 +125,7 @@ func runApp(args []string, outputBuffer io.Writer, pprof Server, c TelegrafConfi
 		},
 		&cli.StringSliceFlag{
 			Name:  "config-directory",
-			Usage: "directory containing additional *.conf files",
+			Usage: "directory containing additional *.conf, *.conf.yaml, *.conf.yml or *.conf.json files",
 		},
 		&cli.StringFlag{
 			Name: "section-filter",
@@ -260,6 +279,7 @@ func runApp(args []string, outputBuffer io.Writer, pprof Server, c TelegrafConfi
 		getConfigCommands(configHandlingFlags, outputBuffer),
 		getSecretStoreCommands(m)...,
 	)
+	commands = append(commands, getReplayCommands(configHandlingFlags, outputBuffer)...)
 	commands = append(commands, getPluginCommands(outputBuffer)...)
 	commands = append(commands, getServiceCommands(outputBuffer)...)
 
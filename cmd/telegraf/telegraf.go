@@ -10,6 +10,7 @@ import (
 	"os"
 	"os/signal"
 	"strings"
+	"sync"
 	"syscall"
 	"time"
 
@@ -81,6 +82,10 @@ type Telegraf struct {
 
 	cfg *config.Config
 
+	// Running agent used for reloading the configuration
+	agent   *agent.Agent
+	agentMu sync.Mutex
+
 	GlobalFlags
 	WindowFlags
 }
@@ -149,61 +154,49 @@ func (t *Telegraf) reloadLoop() error {
 		signals := make(chan os.Signal, 1)
 		signal.Notify(signals, os.Interrupt, syscall.SIGHUP,
 			syscall.SIGTERM, syscall.SIGINT)
-		if t.watchConfig != "" {
-			for _, fConfig := range t.configFiles {
-				if isURL(fConfig) {
-					continue
-				}
-
-				if _, err := os.Stat(fConfig); err != nil {
-					log.Printf("W! Cannot watch config %s: %s", fConfig, err)
-				} else {
-					go t.watchLocalConfig(ctx, signals, fConfig)
-				}
-			}
-			for _, fConfigDirectory := range t.configDir {
-				if _, err := os.Stat(fConfigDirectory); err != nil {
-					log.Printf("W! Cannot watch config directory %s: %s", fConfigDirectory, err)
-				} else {
-					go t.watchLocalConfig(ctx, signals, fConfigDirectory)
-				}
-			}
-		}
-		if t.configURLWatchInterval > 0 {
-			remoteConfigs := make([]string, 0)
-			for _, fConfig := range t.configFiles {
-				if isURL(fConfig) {
-					remoteConfigs = append(remoteConfigs, fConfig)
-				}
-			}
-			if len(remoteConfigs) > 0 {
-				go t.watchRemoteConfigs(ctx, signals, t.configURLWatchInterval, remoteConfigs)
-			}
-		}
+		watchCtx, watchCancel := context.WithCancel(ctx)
+		t.startConfigWatchers(watchCtx, signals)
 		go func() {
-			select {
-			case sig := <-signals:
-				if sig == syscall.SIGHUP {
-					log.Println("I! Reloading Telegraf config")
-					// May need to update the list of known config files
-					// if a delete or create occured. That way on the reload
-					// we ensure we watch the correct files.
-					if err := t.getConfigFiles(); err != nil {
-						log.Println("E! Error loading config files: ", err)
+			defer func() { watchCancel() }()
+			for {
+				select {
+				case sig := <-signals:
+					if sig == syscall.SIGHUP {
+						log.Println("I! Reloading Telegraf config")
+						// May need to update the list of known config files
+						// if a delete or create occured. That way on the reload
+						// we ensure we watch the correct files.
+						if err := t.getConfigFiles(); err != nil {
+							log.Println("E! Error loading config files: ", err)
+						}
+
+						// Try to apply the changes to the running agent and
+						// only restart the agent if this is not possible.
+						err := t.reloadAgent()
+						if err == nil {
+							// The watchers stop after the first change so
+							// restart them to catch subsequent changes.
+							watchCancel()
+							watchCtx, watchCancel = context.WithCancel(ctx)
+							t.startConfigWatchers(watchCtx, signals)
+							continue
+						}
+						log.Printf("I! Restarting agent: %v", err)
+						<-reload
+						reload <- true
 					}
-					<-reload
-					reload <- true
+					cancel()
+				case err := <-t.pprofErr:
+					log.Printf("E! pprof server failed: %v", err)
+					cancel()
+				case <-stop:
+					cancel()
 				}
-				cancel()
-			case err := <-t.pprofErr:
-				log.Printf("E! pprof server failed: %v", err)
-				cancel()
-			case <-stop:
-				cancel()
+				return
 			}
 		}()
 
-		err := t.runAgent(ctx, reloadConfig)
+		err := t.runAgent(ctx, reloadConfig, signals)
 		if err != nil && !errors.Is(err, context.Canceled) {
 			return fmt.Errorf("[telegraf] Error running agent: %w", err)
 		}
@@ -213,6 +206,65 @@ func (t *Telegraf) reloadLoop() error {
 	return nil
 }
 
+// startConfigWatchers starts watching the local and remote configuration
+// files and directories for changes if requested.
+func (t *Telegraf) startConfigWatchers(ctx context.Context, signals chan os.Signal) {
+	if t.watchConfig != "" {
+		for _, fConfig := range t.configFiles {
+			if isURL(fConfig) {
+				continue
+			}
+
+			if _, err := os.Stat(fConfig); err != nil {
+				log.Printf("W! Cannot watch config %s: %s", fConfig, err)
+			} else {
+				go t.watchLocalConfig(ctx, signals, fConfig)
+			}
+		}
+		for _, fConfigDirectory := range t.configDir {
+			if _, err := os.Stat(fConfigDirectory); err != nil {
+				log.Printf("W! Cannot watch config directory %s: %s", fConfigDirectory, err)
+			} else {
+				go t.watchLocalConfig(ctx, signals, fConfigDirectory)
+			}
+		}
+	}
+	if t.configURLWatchInterval > 0 {
+		remoteConfigs := make([]string, 0)
+		for _, fConfig := range t.configFiles {
+			if isURL(fConfig) {
+				remoteConfigs = append(remoteConfigs, fConfig)
+			}
+		}
+		if len(remoteConfigs) > 0 {
+			go t.watchRemoteConfigs(ctx, signals, t.configURLWatchInterval, remoteConfigs)
+		}
+	}
+}
+
+// reloadAgent applies the current configuration to the running agent
+// restarting only the plugins with changed configuration.
+func (t *Telegraf) reloadAgent() error {
+	t.agentMu.Lock()
+	ag := t.agent
+	t.agentMu.Unlock()
+	if ag == nil {
+		return errors.New("agent is not running")
+	}
+
+	c, err := t.loadConfiguration()
+	if err != nil {
+		return err
+	}
+	return ag.Reload(c)
+}
+
+func (t *Telegraf) setAgent(ag *agent.Agent) {
+	t.agentMu.Lock()
+	t.agent = ag
+	t.agentMu.Unlock()
+}
+
 func (t *Telegraf) watchLocalConfig(ctx context.Context, signals chan os.Signal, fConfig string) {
 	var mytomb tomb.Tomb
 	var watcher watch.FileWatcher
@@ -341,7 +393,7 @@ func (t *Telegraf) getConfigFiles() error {
 	return nil
 }
 
-func (t *Telegraf) runAgent(ctx context.Context, reloadConfig bool) error {
+func (t *Telegraf) runAgent(ctx context.Context, reloadConfig bool, signals chan os.Signal) error {
 	c := t.cfg
 	var err error
 	if reloadConfig {
@@ -472,6 +524,19 @@ func (t *Telegraf) runAgent(ctx context.Context, reloadConfig bool) error {
 		}
 	}
 
+	// Requesting a reload via the admin API behaves like receiving SIGHUP
+	ag.SetReloadHandler(func() error {
+		select {
+		case signals <- syscall.SIGHUP:
+		default:
+			return errors.New("reload already pending")
+		}
+		return nil
+	})
+
+	t.setAgent(ag)
+	defer t.setAgent(nil)
+
 	return ag.Run(ctx)
 }
 
@@ -14,6 +14,7 @@ import (
 	"reflect"
 	"regexp"
 	"runtime"
+	"slices"
 	"sort"
 	"strconv"
 	"strings"
@@ -30,6 +31,8 @@ import (
 	"github.com/influxdata/telegraf/models"
 	"github.com/influxdata/telegraf/persister"
 	"github.com/influxdata/telegraf/plugins/aggregators"
+	"github.com/influxdata/telegraf/plugins/common/auth"
+	common_tls "github.com/influxdata/telegraf/plugins/common/tls"
 	"github.com/influxdata/telegraf/plugins/inputs"
 	"github.com/influxdata/telegraf/plugins/outputs"
 	"github.com/influxdata/telegraf/plugins/parsers"
@@ -81,6 +84,7 @@ type Config struct {
 
 	SecretStores      map[string]telegraf.SecretStore
 	secretStoreSource map[string][]string
+	secretStoreRefs   map[string]int
 
 	Agent       *AgentConfig
 	Inputs      []*models.RunningInput
@@ -92,6 +96,28 @@ type Config struct {
 	fileProcessors    OrderedPlugins
 	fileAggProcessors OrderedPlugins
 
+	// Output groups and their members collected while loading, the members
+	// are collected per file to keep the order of appearance
+	outputGroups       []*outputGroup
+	outputGroupMembers map[string][]*models.OutputGroupMember
+	fileGroupMembers   OrderedPlugins
+
+	// Locations of the plugin definitions keyed by the plugin's configuration
+	// or, for secret-stores, by the store itself
+	locations map[any]Location
+
+	// Loaded plugins, the locations of the agent and global tag options and
+	// the files defining template options for showing the configuration
+	pluginSources  []*pluginSource
+	optionSources  map[string]map[string]Location
+	templateSource map[any]string
+
+	// Named plugin templates, the files currently including other files and
+	// all files loaded via includes
+	templates    map[string]*ast.Table
+	includeStack []string
+	included     map[string]bool
+
 	// Parsers are created by their inputs during gather. Config doesn't keep track of them
 	// like the other plugins because they need to be garbage collected (See issue #11809)
 
@@ -116,6 +142,12 @@ func (op OrderedPlugins) Len() int           { return len(op) }
 func (op OrderedPlugins) Swap(i, j int)      { op[i], op[j] = op[j], op[i] }
 func (op OrderedPlugins) Less(i, j int) bool { return op[i].Line < op[j].Line }
 
+// outputGroup is an output group defined in the configuration
+type outputGroup struct {
+	config *models.OutputGroupConfig
+	output *models.OutputConfig
+}
+
 // NewConfig creates a new struct to hold the Telegraf config.
 // For historical reasons, It holds the actual instances of the running plugins
 // once the configuration is parsed.
@@ -139,8 +171,14 @@ func NewConfig() *Config {
 		AggProcessors:      make([]*models.RunningProcessor, 0),
 		SecretStores:       make(map[string]telegraf.SecretStore),
 		secretStoreSource:  make(map[string][]string),
+		secretStoreRefs:    make(map[string]int),
 		fileProcessors:     make([]*OrderedPlugin, 0),
 		fileAggProcessors:  make([]*OrderedPlugin, 0),
+		outputGroupMembers: make(map[string][]*models.OutputGroupMember),
+		locations:          make(map[any]Location),
+		optionSources:      make(map[string]map[string]Location),
+		templateSource:     make(map[any]string),
+		templates:          make(map[string]*ast.Table),
 		InputFilters:       make([]string, 0),
 		OutputFilters:      make([]string, 0),
 		SecretStoreFilters: make([]string, 0),
@@ -204,6 +242,11 @@ type AgentConfig struct {
 	// ie, a jitter of 5s and interval 10s means flushes will happen every 10-15s
 	FlushJitter Duration
 
+	// ShutdownTimeout is the maximum time to keep retrying to write buffered
+	// metrics to the outputs on shutdown. By default, outputs are flushed only
+	// once on shutdown.
+	ShutdownTimeout Duration `toml:"shutdown_timeout"`
+
 	// MetricBatchSize is the maximum number of metrics that is written to an
 	// output plugin in one call.
 	MetricBatchSize int
@@ -275,6 +318,14 @@ type AgentConfig struct {
 	// the state in the file will be restored for the plugins.
 	Statefile string `toml:"statefile"`
 
+	// StatefileBackend is the backend used to store the states, either
+	// "file", "directory" or "kv".
+	StatefileBackend string `toml:"statefile_backend"`
+
+	// StatefileCheckpointInterval is the interval for periodically storing
+	// the states in addition to storing the states on termination.
+	StatefileCheckpointInterval Duration `toml:"statefile_checkpoint_interval"`
+
 	// Flag to always keep tags explicitly defined in the plugin itself and
 	// ensure those tags always pass filtering.
 	AlwaysIncludeLocalTags bool `toml:"always_include_local_tags"`
@@ -293,12 +344,110 @@ type AgentConfig struct {
 	ConfigURLRetryAttempts int `toml:"config_url_retry_attempts"`
 
 	// BufferStrategy is the metric buffer type to use for a given output plugin.
-	// Supported types currently are "memory" and "disk".
+	// Supported types currently are "memory", "disk" and "overflow".
 	BufferStrategy string `toml:"buffer_strategy"`
 
 	// BufferDirectory is the directory to store buffer files for serialized
-	// to disk metrics when using the "disk" buffer strategy.
+	// to disk metrics when using the "disk" or "overflow" buffer strategy.
 	BufferDirectory string `toml:"buffer_directory"`
+
+	// BufferSizeLimit is the maximum number of bytes each output may occupy
+	// in the buffer directory when using the "disk" or "overflow" buffer
+	// strategy. The
+	// oldest metrics are evicted when exceeding the limit.
+	BufferSizeLimit Size `toml:"buffer_size_limit"`
+
+	// BufferMaxAge is the maximum age of metrics kept on disk when using the
+	// "disk" or "overflow" buffer strategy. Older metrics are evicted.
+	BufferMaxAge Duration `toml:"buffer_max_age"`
+
+	// BackpressureHighWatermark is the fill ratio of the output buffers at
+	// which service inputs supporting it are signalled to pause consuming.
+	// Zero disables the backpressure signal.
+	BackpressureHighWatermark float64 `toml:"backpressure_high_watermark"`
+
+	// BackpressureLowWatermark is the fill ratio of the output buffers below
+	// which paused service inputs are signalled to resume consuming. Defaults
+	// to half of the high watermark.
+	BackpressureLowWatermark float64 `toml:"backpressure_low_watermark"`
+
+	// Admin contains the settings of the HTTP admin API, the API is disabled
+	// if no address is configured.
+	Admin *AdminConfig `toml:"admin"`
+
+	// Trace contains the settings for tracing individual metrics through the
+	// pipeline, tracing is disabled if no selector is configured.
+	Trace *TraceConfig `toml:"trace"`
+}
+
+// AdminConfig contains the settings of the HTTP admin API of the agent.
+type AdminConfig struct {
+	// Address to listen on, e.g. "localhost:8089"
+	Address string `toml:"address"`
+
+	auth.BasicAuth
+	common_tls.ServerConfig
+}
+
+// TraceConfig contains the settings for tracing metrics through the pipeline.
+type TraceConfig struct {
+	// Selector is a CEL expression selecting the metrics to trace
+	Selector string `toml:"selector"`
+
+	// Output is the alias of the output to write the trace records to, the
+	// records are logged if empty.
+	Output string `toml:"output"`
+}
+
+// Pipelines returns the sorted names of the default pipeline and all
+// pipelines fed by the configured inputs.
+func (c *Config) Pipelines() []string {
+	names := []string{models.DefaultPipeline}
+	for _, input := range c.Inputs {
+		if !slices.Contains(names, input.Pipeline()) {
+			names = append(names, input.Pipeline())
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// checkPipelines makes sure all pipelines referenced by processors,
+// aggregators and outputs are fed by inputs and all named pipelines fed by
+// inputs have outputs to write to.
+func (c *Config) checkPipelines() error {
+	names := c.Pipelines()
+	known := func(name string) bool {
+		return slices.Contains(names, name)
+	}
+
+	for _, processor := range c.Processors {
+		if !known(processor.Pipeline()) {
+			return fmt.Errorf("pipeline %q of %s has no inputs", processor.Pipeline(), processor.LogName())
+		}
+	}
+	for _, aggregator := range c.Aggregators {
+		if !known(aggregator.Pipeline()) {
+			return fmt.Errorf("pipeline %q of %s has no inputs", aggregator.Pipeline(), aggregator.LogName())
+		}
+	}
+
+	used := make(map[string]bool, len(names))
+	for _, output := range c.Outputs {
+		for _, name := range output.Pipelines() {
+			if !known(name) {
+				return fmt.Errorf("pipeline %q of %s has no inputs", name, output.LogName())
+			}
+			used[name] = true
+		}
+	}
+	for _, name := range names {
+		if name != models.DefaultPipeline && !used[name] {
+			return fmt.Errorf("pipeline %q has no outputs", name)
+		}
+	}
+
+	return nil
 }
 
 // InputNames returns a list of strings of the configured inputs.
@@ -451,7 +600,9 @@ func sliceContains(name string, list []string) bool {
 	return false
 }
 
-// WalkDirectory collects all toml files that need to be loaded
+// WalkDirectory collects all configuration files that need to be loaded, i.e.
+// TOML files with a ".conf" extension as well as YAML and JSON files with a
+// ".conf.yaml", ".conf.yml" or ".conf.json" extension
 func WalkDirectory(path string) ([]string, error) {
 	var files []string
 	walkfn := func(thispath string, info os.FileInfo, _ error) error {
@@ -468,8 +619,7 @@ func WalkDirectory(path string) ([]string, error) {
 
 			return nil
 		}
-		name := info.Name()
-		if len(name) < 6 || name[len(name)-5:] != ".conf" {
+		if !isConfigFile(info.Name()) {
 			return nil
 		}
 		files = append(files, thispath)
@@ -481,7 +631,7 @@ func WalkDirectory(path string) ([]string, error) {
 // Try to find a default config file at these locations (in order):
 //  1. $TELEGRAF_CONFIG_PATH
 //  2. $HOME/.telegraf/telegraf.conf
-//  3. /etc/telegraf/telegraf.conf and /etc/telegraf/telegraf.d/*.conf
+//  3. /etc/telegraf/telegraf.conf and the configuration files in /etc/telegraf/telegraf.d
 func GetDefaultConfigPath() ([]string, error) {
 	envfile := os.Getenv("TELEGRAF_CONFIG_PATH")
 	homefile := os.ExpandEnv("${HOME}/.telegraf/telegraf.conf")
@@ -525,7 +675,7 @@ func GetDefaultConfigPath() ([]string, error) {
 
 	// if we got here, we didn't find a file in a default location
 	return nil, fmt.Errorf("no config file specified, and could not find one"+
-		" in $TELEGRAF_CONFIG_PATH, %s, %s, or %s/*.conf", homefile, etcfile, etcfolder)
+		" in $TELEGRAF_CONFIG_PATH, %s, %s, or %s", homefile, etcfile, etcfolder)
 }
 
 // isURL checks if string is valid url
@@ -564,6 +714,10 @@ func (c *Config) LoadAll(configFiles ...string) error {
 	sort.Stable(c.Processors)
 	sort.Stable(c.AggProcessors)
 
+	if err := c.buildOutputGroups(); err != nil {
+		return err
+	}
+
 	// Set snmp agent translator default
 	if c.Agent.SnmpTranslator == "" {
 		c.Agent.SnmpTranslator = "netsnmp"
@@ -577,6 +731,15 @@ func (c *Config) LoadAll(configFiles ...string) error {
 	}
 	c.NumberSecrets = uint64(count)
 
+	if err := c.checkPipelines(); err != nil {
+		// Filtering plugins, e.g. for testing, might remove the inputs or
+		// outputs of a pipeline so only warn in this case
+		if len(c.InputFilters) == 0 && len(c.OutputFilters) == 0 {
+			return err
+		}
+		log.Printf("W! %v", err)
+	}
+
 	// Let's link all secrets to their secret-stores
 	return c.LinkSecrets()
 }
@@ -593,9 +756,10 @@ func WithSourcePath(path string) cfgDataOption {
 	}
 }
 
-// LoadConfigData loads TOML-formatted config data
+// LoadConfigData loads TOML-formatted config data, YAML and JSON data is
+// detected by the extension of the given path
 func (c *Config) LoadConfigData(data []byte, path string) error {
-	tbl, err := parseConfig(data)
+	tbl, err := parseConfigFormat(data, DetectFormat(path))
 	if err != nil {
 		return fmt.Errorf("error parsing data: %w", err)
 	}
@@ -610,6 +774,7 @@ func (c *Config) LoadConfigData(data []byte, path string) error {
 			if err = c.toml.UnmarshalTable(subTable, c.Tags); err != nil {
 				return fmt.Errorf("error parsing table name %q: %w", tableName, err)
 			}
+			c.addOptionSources("global_tags", path, subTable)
 		}
 	}
 
@@ -629,6 +794,7 @@ func (c *Config) LoadConfigData(data []byte, path string) error {
 		if err = c.toml.UnmarshalTable(subTable, c.Agent); err != nil {
 			return fmt.Errorf("error parsing [agent]: %w", err)
 		}
+		c.addOptionSources("agent", path, subTable)
 	}
 
 	if !c.Agent.OmitHostname {
@@ -657,6 +823,7 @@ func (c *Config) LoadConfigData(data []byte, path string) error {
 	if c.Agent.Statefile != "" {
 		c.Persister = &persister.Persister{
 			Filename: c.Agent.Statefile,
+			Backend:  c.Agent.StatefileBackend,
 		}
 	}
 
@@ -667,19 +834,36 @@ func (c *Config) LoadConfigData(data []byte, path string) error {
 			tbl.Line, keys(c.UnusedFields))
 	}
 
+	// Load the included files before the plugins of this file
+	if err := c.loadIncludes(tbl, path); err != nil {
+		return err
+	}
+
+	// Apply the templates to the plugins
+	if err := c.collectTemplates(tbl, path); err != nil {
+		return err
+	}
+	if err := c.applyTemplates(tbl); err != nil {
+		return err
+	}
+
 	// Initialize the file-sorting slices
 	c.fileProcessors = make(OrderedPlugins, 0)
 	c.fileAggProcessors = make(OrderedPlugins, 0)
+	c.fileGroupMembers = make(OrderedPlugins, 0)
 
 	// Parse all the rest of the plugins:
 	for name, val := range tbl.Fields {
+		if name == "include" {
+			continue
+		}
 		subTable, ok := val.(*ast.Table)
 		if !ok {
 			return fmt.Errorf("invalid configuration, error parsing field %q as table", name)
 		}
 
 		switch name {
-		case "agent", "global_tags", "tags":
+		case "agent", "global_tags", "tags", "templates", "variables":
 		case "outputs":
 			for pluginName, pluginVal := range subTable.Fields {
 				switch pluginSubTable := pluginVal.(type) {
@@ -774,6 +958,16 @@ func (c *Config) LoadConfigData(data []byte, path string) error {
 						name, pluginName, subTable.Line, keys(c.UnusedFields))
 				}
 			}
+		case "output_groups":
+			for groupName, groupVal := range subTable.Fields {
+				groupTable, ok := groupVal.(*ast.Table)
+				if !ok {
+					return fmt.Errorf("unsupported config format: output group %s", groupName)
+				}
+				if err = c.addOutputGroup(groupName, path, groupTable); err != nil {
+					return fmt.Errorf("error parsing output group %s, %w", groupName, err)
+				}
+			}
 		case "secretstores":
 			for pluginName, pluginVal := range subTable.Fields {
 				switch pluginSubTable := pluginVal.(type) {
@@ -814,6 +1008,15 @@ func (c *Config) LoadConfigData(data []byte, path string) error {
 		c.AggProcessors = append(c.AggProcessors, op.plugin.(*models.RunningProcessor))
 	}
 
+	sort.Sort(c.fileGroupMembers)
+	if c.outputGroupMembers == nil {
+		c.outputGroupMembers = make(map[string][]*models.OutputGroupMember)
+	}
+	for _, op := range c.fileGroupMembers {
+		member := op.plugin.(*models.OutputGroupMember)
+		c.outputGroupMembers[member.Config.Group] = append(c.outputGroupMembers[member.Config.Group], member)
+	}
+
 	return nil
 }
 
@@ -931,7 +1134,11 @@ func parseConfig(contents []byte) (*ast.Table, error) {
 	if err != nil {
 		return nil, err
 	}
-	outputBytes, err := substituteEnvironment(contents, OldEnvVarReplacement)
+	variables, err := extractVariables(contents, OldEnvVarReplacement)
+	if err != nil {
+		return nil, err
+	}
+	outputBytes, err := substituteVariables(contents, OldEnvVarReplacement, variables)
 	if err != nil {
 		return nil, err
 	}
@@ -963,6 +1170,8 @@ func (c *Config) addAggregator(name, source string, table *ast.Table) error {
 		return err
 	}
 
+	c.setLocation(conf, source, table.Line)
+	c.addPluginSource("aggregators", name, aggregator, source, table)
 	c.Aggregators = append(c.Aggregators, models.NewRunningAggregator(aggregator, conf))
 	return nil
 }
@@ -1010,6 +1219,8 @@ func (c *Config) addSecretStore(name, source string, table *ast.Table) error {
 		return fmt.Errorf("duplicate ID %q for secretstore %q", storeID, name)
 	}
 	c.SecretStores[storeID] = store
+	c.setLocation(store, source, table.Line)
+	c.addPluginSource("secretstores", name, store, source, table)
 	if _, found := c.secretStoreSource[name]; !found {
 		c.secretStoreSource[name] = make([]string, 0)
 	}
@@ -1027,6 +1238,7 @@ func (c *Config) LinkSecrets() error {
 			if !found {
 				return fmt.Errorf("unknown secret-store for %q", ref)
 			}
+			c.secretStoreRefs[storeID]++
 			resolver, err := store.GetResolver(key)
 			if err != nil {
 				return fmt.Errorf("retrieving resolver for %q failed: %w", ref, err)
@@ -1041,6 +1253,20 @@ func (c *Config) LinkSecrets() error {
 	return nil
 }
 
+// secretReferences returns the distinct references to secret-stores used by
+// the given secrets
+func secretReferences(secrets []*Secret) []string {
+	var refs []string
+	for _, s := range secrets {
+		for _, ref := range s.GetUnlinked() {
+			if !slices.Contains(refs, ref) {
+				refs = append(refs, ref)
+			}
+		}
+	}
+	return refs
+}
+
 func (c *Config) probeParser(parentCategory, parentName string, table *ast.Table) bool {
 	dataFormat := c.getFieldString(table, "data_format")
 	if dataFormat == "" {
@@ -1118,6 +1344,28 @@ func (c *Config) probeSerializer(table *ast.Table) bool {
 	return true
 }
 
+// NewParser creates a parser from the given settings, e.g. for parsing data
+// outside of an input plugin. The settings use the same options as the parser
+// of an input plugin including "data_format" to select the parser.
+func (c *Config) NewParser(name string, data []byte) (*models.RunningParser, error) {
+	tbl, err := parseConfig(data)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing data: %w", err)
+	}
+
+	parser, err := c.addParser("inputs", name, tbl)
+	if err != nil {
+		return nil, err
+	}
+	if c.hasErrs() {
+		return nil, c.firstErr()
+	}
+	if len(c.UnusedFields) > 0 {
+		return nil, fmt.Errorf("parser settings specified the fields %q, but they were not used", keys(c.UnusedFields))
+	}
+	return parser, nil
+}
+
 func (c *Config) addSerializer(parentname string, table *ast.Table) (*models.RunningSerializer, error) {
 	conf := &models.SerializerConfig{
 		Parent: parentname,
@@ -1174,6 +1422,8 @@ func (c *Config) addProcessor(name, source string, table *ast.Table) error {
 	if err != nil {
 		return err
 	}
+	c.setLocation(processorBeforeConfig, source, table.Line)
+	c.addPluginSource("processors", name, processorBefore, source, table)
 	rf := models.NewRunningProcessor(processorBefore, processorBeforeConfig)
 	c.fileProcessors = append(c.fileProcessors, &OrderedPlugin{table.Line, rf})
 
@@ -1186,6 +1436,7 @@ func (c *Config) addProcessor(name, source string, table *ast.Table) error {
 	if err != nil {
 		return err
 	}
+	c.setLocation(processorAfterConfig, source, table.Line)
 	rf = models.NewRunningProcessor(processorAfter, processorAfterConfig)
 	c.fileAggProcessors = append(c.fileAggProcessors, &OrderedPlugin{table.Line, rf})
 
@@ -1319,9 +1570,11 @@ func (c *Config) addOutput(name, source string, table *ast.Table) error {
 		return err
 	}
 
+	secrets := len(unlinkedSecrets)
 	if err := c.toml.UnmarshalTable(table, output); err != nil {
 		return err
 	}
+	outputConfig.Secrets = secretReferences(unlinkedSecrets[secrets:])
 
 	if err := c.printUserDeprecation("outputs", name, output); err != nil {
 		return err
@@ -1343,12 +1596,101 @@ func (c *Config) addOutput(name, source string, table *ast.Table) error {
 		}
 	}
 
+	c.setLocation(outputConfig, source, table.Line)
+	c.addPluginSource("outputs", name, output, source, table)
+
+	// Members of output groups are run by the output of the group
+	if outputConfig.Group != "" {
+		member := &models.OutputGroupMember{Output: output, Config: outputConfig}
+		c.fileGroupMembers = append(c.fileGroupMembers, &OrderedPlugin{table.Line, member})
+		return nil
+	}
+
 	ro := models.NewRunningOutput(output, outputConfig, c.Agent.MetricBatchSize, c.Agent.MetricBufferLimit)
 	c.Outputs = append(c.Outputs, ro)
 
 	return nil
 }
 
+// addOutputGroup parses the settings of an output group. The group's output
+// is created after loading all files, as the members might be defined in
+// other files.
+func (c *Config) addOutputGroup(name, source string, table *ast.Table) error {
+	for _, g := range c.outputGroups {
+		if g.config.Name == name {
+			return errors.New("duplicate output group")
+		}
+	}
+
+	// The group supports all common output settings
+	outputConfig, err := c.buildOutput("group", source, table)
+	if err != nil {
+		return err
+	}
+	outputConfig.Alias = name
+	c.setLocation(outputConfig, source, table.Line)
+	c.addPluginSource("output_groups", name, nil, source, table)
+
+	groupConfig := &models.OutputGroupConfig{
+		Name:     name,
+		Mode:     c.getFieldString(table, "mode"),
+		HashTags: c.getFieldStringSlice(table, "hash_tags"),
+	}
+	groupConfig.RetryInterval, _ = c.getFieldDuration(table, "member_retry_interval")
+	if c.hasErrs() {
+		return c.firstErr()
+	}
+
+	c.outputGroups = append(c.outputGroups, &outputGroup{config: groupConfig, output: outputConfig})
+	return nil
+}
+
+// buildOutputGroups creates the outputs of the defined output groups running
+// the collected members.
+func (c *Config) buildOutputGroups() error {
+	for _, g := range c.outputGroups {
+		members := c.outputGroupMembers[g.config.Name]
+		delete(c.outputGroupMembers, g.config.Name)
+
+		// All members might be excluded by the output filters
+		if len(members) == 0 && len(c.OutputFilters) > 0 {
+			continue
+		}
+
+		output, err := models.NewOutputGroup(g.config, members)
+		if err != nil {
+			return err
+		}
+
+		// Changing any member changes the group
+		ids := make([]string, 0, len(members)+1)
+		ids = append(ids, g.output.ID)
+		for _, m := range members {
+			ids = append(ids, m.Config.ID)
+			for _, ref := range m.Config.Secrets {
+				if !slices.Contains(g.output.Secrets, ref) {
+					g.output.Secrets = append(g.output.Secrets, ref)
+				}
+			}
+		}
+		g.output.ID = combinePluginIDs(ids...)
+
+		ro := models.NewRunningOutput(output, g.output, c.Agent.MetricBatchSize, c.Agent.MetricBufferLimit)
+		c.Outputs = append(c.Outputs, ro)
+	}
+	c.outputGroups = nil
+
+	if len(c.outputGroupMembers) > 0 {
+		names := make([]string, 0, len(c.outputGroupMembers))
+		for name := range c.outputGroupMembers {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return fmt.Errorf("output group %q is not defined", names[0])
+	}
+	return nil
+}
+
 func (c *Config) addInput(name, source string, table *ast.Table) error {
 	if len(c.InputFilters) > 0 && !sliceContains(name, c.InputFilters) {
 		return nil
@@ -1403,9 +1745,11 @@ func (c *Config) addInput(name, source string, table *ast.Table) error {
 		return err
 	}
 
+	secrets := len(unlinkedSecrets)
 	if err := c.toml.UnmarshalTable(table, input); err != nil {
 		return err
 	}
+	pluginConfig.Secrets = secretReferences(unlinkedSecrets[secrets:])
 
 	if err := c.printUserDeprecation("inputs", name, input); err != nil {
 		return err
@@ -1427,6 +1771,8 @@ func (c *Config) addInput(name, source string, table *ast.Table) error {
 		}
 	}
 
+	c.setLocation(pluginConfig, source, table.Line)
+	c.addPluginSource("inputs", name, input, source, table)
 	rp := models.NewRunningInput(input, pluginConfig)
 	rp.SetDefaultTags(c.Tags)
 	c.Inputs = append(c.Inputs, rp)
@@ -1462,6 +1808,7 @@ func (c *Config) buildAggregator(name, source string, tbl *ast.Table) (*models.A
 	conf.NameOverride = c.getFieldString(tbl, "name_override")
 	conf.Alias = c.getFieldString(tbl, "alias")
 	conf.LogLevel = c.getFieldString(tbl, "log_level")
+	conf.Pipeline = c.getFieldString(tbl, "pipeline")
 
 	conf.Tags = make(map[string]string)
 	if node, ok := tbl.Fields["tags"]; ok {
@@ -1499,6 +1846,7 @@ func (c *Config) buildProcessor(category, name, source string, tbl *ast.Table) (
 	conf.Order = c.getFieldInt64(tbl, "order")
 	conf.Alias = c.getFieldString(tbl, "alias")
 	conf.LogLevel = c.getFieldString(tbl, "log_level")
+	conf.Pipeline = c.getFieldString(tbl, "pipeline")
 
 	if c.hasErrs() {
 		return nil, c.firstErr()
@@ -1596,6 +1944,18 @@ func (c *Config) buildFilter(plugin string, tbl *ast.Table) (models.Filter, erro
 	return f, nil
 }
 
+// buildCardinality parses the settings limiting the number of unique series
+// of an input or output
+func (c *Config) buildCardinality(tbl *ast.Table) models.CardinalityConfig {
+	cfg := models.CardinalityConfig{
+		Limit:    c.getFieldInt(tbl, "cardinality_limit"),
+		Action:   c.getFieldString(tbl, "cardinality_action"),
+		DropTags: c.getFieldStringSlice(tbl, "cardinality_drop_tags"),
+	}
+	cfg.Window, _ = c.getFieldDuration(tbl, "cardinality_window")
+	return cfg
+}
+
 // buildInput parses input specific items from the ast.Table,
 // builds the filter and returns a
 // models.InputConfig to be inserted into models.RunningInput
@@ -1618,6 +1978,8 @@ func (c *Config) buildInput(name, source string, tbl *ast.Table) (*models.InputC
 	cp.NameOverride = c.getFieldString(tbl, "name_override")
 	cp.Alias = c.getFieldString(tbl, "alias")
 	cp.LogLevel = c.getFieldString(tbl, "log_level")
+	cp.Pipeline = c.getFieldString(tbl, "pipeline")
+	cp.Cardinality = c.buildCardinality(tbl)
 
 	cp.Tags = make(map[string]string)
 	if node, ok := tbl.Fields["tags"]; ok {
@@ -1658,6 +2020,8 @@ func (c *Config) buildOutput(name, source string, tbl *ast.Table) (*models.Outpu
 		Filter:          filter,
 		BufferStrategy:  c.Agent.BufferStrategy,
 		BufferDirectory: c.Agent.BufferDirectory,
+		BufferSizeLimit: int64(c.Agent.BufferSizeLimit),
+		BufferMaxAge:    time.Duration(c.Agent.BufferMaxAge),
 	}
 
 	// TODO: support FieldPass/FieldDrop on outputs
@@ -1672,13 +2036,24 @@ func (c *Config) buildOutput(name, source string, tbl *ast.Table) (*models.Outpu
 	oc.NamePrefix = c.getFieldString(tbl, "name_prefix")
 	oc.StartupErrorBehavior = c.getFieldString(tbl, "startup_error_behavior")
 	oc.LogLevel = c.getFieldString(tbl, "log_level")
+	oc.RetryInitialBackoff, _ = c.getFieldDuration(tbl, "write_retry_initial_backoff")
+	oc.RetryMaxBackoff, _ = c.getFieldDuration(tbl, "write_retry_max_backoff")
+	oc.RetryJitter, _ = c.getFieldDuration(tbl, "write_retry_jitter")
+	oc.RetryMaxAttempts = c.getFieldInt(tbl, "write_retry_max_attempts")
+	oc.RetryPermanentErrors = c.getFieldStringSlice(tbl, "write_retry_permanent_errors")
+	oc.DeadLetterOutput = c.getFieldString(tbl, "dead_letter_output")
+	oc.DeadLetterFile = c.getFieldString(tbl, "dead_letter_file")
+	oc.Pipelines = c.getFieldStringSlice(tbl, "pipelines")
+	oc.Group = c.getFieldString(tbl, "group")
+	oc.Cardinality = c.buildCardinality(tbl)
 
 	if c.hasErrs() {
 		return nil, c.firstErr()
 	}
 
-	if oc.BufferStrategy == "disk" {
-		log.Printf("W! Using disk buffer strategy for plugin outputs.%s, this is an experimental feature", name)
+	switch oc.BufferStrategy {
+	case "disk", "overflow":
+		log.Printf("W! Using %s buffer strategy for plugin outputs.%s, this is an experimental feature", oc.BufferStrategy, name)
 	}
 
 	// Generate an ID for the plugin
@@ -1691,17 +2066,19 @@ func (c *Config) missingTomlField(_ reflect.Type, key string) error {
 	// General options to ignore
 	case "alias", "always_include_local_tags",
 		"buffer_strategy", "buffer_directory",
+		"cardinality_action", "cardinality_drop_tags", "cardinality_limit", "cardinality_window",
 		"collection_jitter", "collection_offset",
-		"data_format", "delay", "drop", "drop_original",
+		"data_format", "dead_letter_file", "dead_letter_output", "delay", "drop", "drop_original",
 		"fielddrop", "fieldexclude", "fieldinclude", "fieldpass", "flush_interval", "flush_jitter",
-		"grace",
+		"grace", "group",
 		"interval",
 		"log_level", "lvm", // What is this used for?
 		"metric_batch_size", "metric_buffer_limit", "metricpass",
 		"name_override", "name_prefix", "name_suffix", "namedrop", "namedrop_separator", "namepass", "namepass_separator",
 		"order",
-		"pass", "period", "precision",
-		"tagdrop", "tagexclude", "taginclude", "tagpass", "tags", "startup_error_behavior":
+		"pass", "period", "pipeline", "pipelines", "precision",
+		"tagdrop", "tagexclude", "taginclude", "tagpass", "tags", "startup_error_behavior",
+		"write_retry_initial_backoff", "write_retry_jitter", "write_retry_max_attempts", "write_retry_max_backoff", "write_retry_permanent_errors":
 
 	// Secret-store options to ignore
 	case "id":
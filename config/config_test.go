@@ -11,6 +11,7 @@ import (
 	"reflect"
 	"regexp"
 	"runtime"
+	"sort"
 	"strings"
 	"sync"
 	"testing"
@@ -210,6 +211,26 @@ func TestConfig_LoadSingleInput_WithCommentInArray(t *testing.T) {
 	require.ElementsMatch(t, input.Servers, []string{"localhost"})
 }
 
+func TestConfig_WalkDirectoryFormats(t *testing.T) {
+	dir := t.TempDir()
+	for _, fn := range []string{
+		"a.conf", "b.conf.yaml", "c.conf.yml", "d.conf.json", "e.toml", "f.txt", ".conf",
+		"credentials.json", "schema.yaml", "configmap.yml", ".conf.json", "g.conf.txt",
+	} {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, fn), nil, 0600))
+	}
+
+	files, err := config.WalkDirectory(dir)
+	require.NoError(t, err)
+	expected := []string{
+		filepath.Join(dir, "a.conf"),
+		filepath.Join(dir, "b.conf.yaml"),
+		filepath.Join(dir, "c.conf.yml"),
+		filepath.Join(dir, "d.conf.json"),
+	}
+	require.Equal(t, expected, files)
+}
+
 func TestConfig_LoadDirectory(t *testing.T) {
 	c := config.NewConfig()
 
@@ -925,6 +946,200 @@ func TestConfig_MultipleProcessorsOrder(t *testing.T) {
 	}
 }
 
+func TestConfig_Pipelines(t *testing.T) {
+	c := config.NewConfig()
+	require.NoError(t, c.LoadAll("./testdata/pipelines.toml"))
+	require.Equal(t, []string{"default", "remote"}, c.Pipelines())
+
+	require.Len(t, c.Inputs, 2)
+	require.Equal(t, "default", c.Inputs[0].Pipeline())
+	require.Equal(t, "remote", c.Inputs[1].Pipeline())
+	require.Len(t, c.Processors, 1)
+	require.Equal(t, "remote", c.Processors[0].Pipeline())
+	require.Len(t, c.Outputs, 2)
+	require.Equal(t, []string{"default"}, c.Outputs[0].Pipelines())
+	require.Equal(t, []string{"default", "remote"}, c.Outputs[1].Pipelines())
+	require.False(t, c.Outputs[0].InPipeline("remote"))
+	require.True(t, c.Outputs[1].InPipeline("remote"))
+}
+
+func TestConfig_PipelinesInvalid(t *testing.T) {
+	c := config.NewConfig()
+	require.ErrorContains(t, c.LoadAll("./testdata/pipelines_no_outputs.toml"), `pipeline "remote" has no outputs`)
+
+	c = config.NewConfig()
+	require.ErrorContains(t, c.LoadAll("./testdata/pipelines_no_inputs.toml"), `pipeline "remote" of processors.processor has no inputs`)
+}
+
+func TestConfig_PipelinesFiltered(t *testing.T) {
+	c := config.NewConfig()
+	c.InputFilters = []string{"memcached"}
+	require.NoError(t, c.LoadAll("./testdata/pipelines_filtered.toml"))
+	require.Len(t, c.Inputs, 1)
+	require.Len(t, c.Outputs, 2)
+
+	c = config.NewConfig()
+	c.OutputFilters = []string{"http"}
+	require.NoError(t, c.LoadAll("./testdata/pipelines_filtered.toml"))
+	require.Len(t, c.Inputs, 2)
+	require.Len(t, c.Outputs, 1)
+}
+
+func TestConfig_Cardinality(t *testing.T) {
+	c := config.NewConfig()
+	require.NoError(t, c.LoadAll("./testdata/cardinality.toml"))
+	require.Empty(t, c.UnusedFields)
+
+	require.Len(t, c.Inputs, 1)
+	expected := models.CardinalityConfig{
+		Limit:    1000,
+		Window:   10 * time.Minute,
+		Action:   "drop_tags",
+		DropTags: []string{"id", "path"},
+	}
+	require.Equal(t, expected, c.Inputs[0].Config.Cardinality)
+
+	require.Len(t, c.Outputs, 1)
+	require.Equal(t, models.CardinalityConfig{Limit: 5000}, c.Outputs[0].Config.Cardinality)
+	require.NoError(t, c.Outputs[0].Init())
+	require.Equal(t, models.DefaultCardinalityWindow, c.Outputs[0].Config.Cardinality.Window)
+	require.Equal(t, "drop", c.Outputs[0].Config.Cardinality.Action)
+}
+
+func TestConfig_WriteRetry(t *testing.T) {
+	c := config.NewConfig()
+	require.NoError(t, c.LoadAll("./testdata/write_retry.toml"))
+	require.Empty(t, c.UnusedFields)
+
+	// The generic options must not interfere with the plugin's own settings
+	require.Len(t, c.Outputs, 1)
+	cfg := c.Outputs[0].Config
+	require.Equal(t, time.Second, cfg.RetryInitialBackoff)
+	require.Equal(t, time.Minute, cfg.RetryMaxBackoff)
+	require.Equal(t, 500*time.Millisecond, cfg.RetryJitter)
+	require.Equal(t, 5, cfg.RetryMaxAttempts)
+	require.Equal(t, []string{"*400 Bad Request*"}, cfg.RetryPermanentErrors)
+	plugin := c.Outputs[0].Output.(*MockupOutputPluginRetry)
+	require.Equal(t, config.Duration(10*time.Second), plugin.RetryMaxBackoff)
+}
+
+func TestConfig_OutputGroups(t *testing.T) {
+	c := config.NewConfig()
+	require.NoError(t, c.LoadAll("./testdata/output_groups.toml"))
+
+	require.Len(t, c.Outputs, 2)
+	require.Equal(t, "outputs.http", c.Outputs[0].LogName())
+	require.Equal(t, "outputs.group::cluster", c.Outputs[1].LogName())
+	require.Equal(t, 42, c.Outputs[1].Config.MetricBatchSize)
+	require.IsType(t, &models.OutputGroup{}, c.Outputs[1].Output)
+	require.NotEqual(t, c.Outputs[0].ID(), c.Outputs[1].ID())
+}
+
+func TestConfig_OutputGroupsUndefined(t *testing.T) {
+	c := config.NewConfig()
+	require.ErrorContains(t, c.LoadAll("./testdata/output_groups_undefined.toml"), `output group "cluster" is not defined`)
+}
+
+func TestConfig_Includes(t *testing.T) {
+	c := config.NewConfig()
+	require.NoError(t, c.LoadAll("./testdata/include/main.toml"))
+	require.Len(t, c.Inputs, 3)
+
+	// Plugins of included files are loaded first and variables are only
+	// available in the file defining them
+	expected := []struct {
+		servers []string
+		tags    map[string]string
+	}{
+		{
+			servers: []string{"${server}"},
+			tags:    map[string]string{},
+		},
+		{
+			servers: []string{"remote:11211"},
+			tags:    map[string]string{"env": "prod"},
+		},
+		{
+			servers: []string{"localhost"},
+			tags:    map[string]string{"env": "prod", "role": "cache"},
+		},
+	}
+	for i, input := range c.Inputs {
+		require.Equal(t, expected[i].servers, input.Input.(*MockupInputPlugin).Servers, "input %d", i)
+		require.Equal(t, expected[i].tags, input.Config.Tags, "input %d", i)
+	}
+	require.Equal(t, filepath.Join("testdata", "include", "common", "templates.toml"), c.Inputs[0].Config.Source)
+	require.Zero(t, c.Inputs[0].Config.Interval)
+	require.Equal(t, 30*time.Second, c.Inputs[1].Config.Interval)
+	require.Equal(t, 30*time.Second, c.Inputs[2].Config.Interval)
+}
+
+func TestConfig_IncludesShared(t *testing.T) {
+	c := config.NewConfig()
+	require.NoError(t, c.LoadAll("./testdata/include/shared_a.toml", "./testdata/include/shared_b.toml"))
+	require.Len(t, c.Inputs, 2)
+	for _, input := range c.Inputs {
+		require.Equal(t, 30*time.Second, input.Config.Interval)
+	}
+}
+
+func TestConfig_IncludesInvalid(t *testing.T) {
+	c := config.NewConfig()
+	require.ErrorContains(t, c.LoadAll("./testdata/include/cycle.toml"), "include cycle detected")
+
+	c = config.NewConfig()
+	require.ErrorContains(t, c.LoadAll("./testdata/include/template_undefined.toml"), `undefined template "unknown"`)
+}
+
+func TestConfig_Formats(t *testing.T) {
+	t.Setenv("MY_TEST_SERVER", "192.168.1.1")
+
+	for _, fn := range []string{"telegraf.toml", "telegraf.yaml", "telegraf.json"} {
+		t.Run(fn, func(t *testing.T) {
+			c := config.NewConfig()
+			require.NoError(t, c.LoadAll(filepath.Join("testdata", "formats", fn)))
+
+			require.Equal(t, map[string]string{"dc": "us-east-1"}, c.Tags)
+			require.Equal(t, config.Duration(10*time.Second), c.Agent.Interval)
+			require.True(t, c.Agent.OmitHostname)
+
+			// Inputs of different plugins are not ordered
+			require.Len(t, c.Inputs, 2)
+			sort.Slice(c.Inputs, func(i, j int) bool { return c.Inputs[i].Config.Name > c.Inputs[j].Config.Name })
+			memcached, ok := c.Inputs[0].Input.(*MockupInputPlugin)
+			require.True(t, ok)
+			require.Equal(t, []string{"localhost", "192.168.1.1"}, memcached.Servers)
+			require.Equal(t, 11211, memcached.Port)
+			password, err := memcached.Password.Get()
+			require.NoError(t, err)
+			require.Equal(t, "secret", password.String())
+			password.Destroy()
+
+			cfg := c.Inputs[0].Config
+			require.Equal(t, 5*time.Second, cfg.Interval)
+			require.Equal(t, []string{"metricname1"}, cfg.Filter.NamePass)
+			require.Equal(t, []string{"other", "stuff"}, cfg.Filter.FieldExclude)
+			require.Len(t, cfg.Filter.TagPassFilters, 1)
+			require.Equal(t, "goodtag", cfg.Filter.TagPassFilters[0].Name)
+			require.Equal(t, []string{"mytag", `tag with "quotes"`}, cfg.Filter.TagPassFilters[0].Values)
+			require.Equal(t, map[string]string{"tag.with.dots": "value"}, cfg.Tags)
+
+			listener, ok := c.Inputs[1].Input.(*MockupInputPlugin)
+			require.True(t, ok)
+			require.Equal(t, config.Duration(time.Second), listener.WriteTimeout)
+			require.Equal(t, config.Size(1024*1024), listener.MaxBodySize)
+			require.Equal(t, []string{"/path/"}, listener.Paths)
+
+			require.Len(t, c.Processors, 2)
+			for i, option := range []string{"first", "second"} {
+				p, ok := c.Processors[i].Processor.(processors.HasUnwrap).Unwrap().(*MockupProcessorPlugin)
+				require.True(t, ok)
+				require.Equal(t, option, p.Option)
+			}
+		})
+	}
+}
+
 func TestConfig_ProcessorsWithParsers(t *testing.T) {
 	formats := []string{
 		"collectd",
@@ -1410,6 +1625,24 @@ func (*MockupOutputPlugin) Write([]telegraf.Metric) error {
 	return nil
 }
 
+// Mockup OUTPUT plugin with a setting named like the generic retry options
+type MockupOutputPluginRetry struct {
+	RetryMaxBackoff config.Duration `toml:"retry_max_backoff"`
+}
+
+func (*MockupOutputPluginRetry) Connect() error {
+	return nil
+}
+func (*MockupOutputPluginRetry) Close() error {
+	return nil
+}
+func (*MockupOutputPluginRetry) SampleConfig() string {
+	return "Mockup test output plugin"
+}
+func (*MockupOutputPluginRetry) Write([]telegraf.Metric) error {
+	return nil
+}
+
 type MockupOutputPluginSerializerNew struct {
 	Serializer telegraf.Serializer
 }
@@ -1545,6 +1778,9 @@ func init() {
 	outputs.Add("http", func() telegraf.Output {
 		return &MockupOutputPlugin{}
 	})
+	outputs.Add("retry_test", func() telegraf.Output {
+		return &MockupOutputPluginRetry{}
+	})
 	outputs.Add("serializer_test_new", func() telegraf.Output {
 		return &MockupOutputPluginSerializerNew{}
 	})
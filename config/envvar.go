@@ -3,12 +3,15 @@ package config
 import (
 	"bytes"
 	"errors"
+	"fmt"
 	"io"
 	"os"
 	"strings"
 
 	"github.com/compose-spec/compose-go/template"
 	"github.com/compose-spec/compose-go/utils"
+	"github.com/influxdata/toml"
+	"github.com/influxdata/toml/ast"
 )
 
 type trimmer struct {
@@ -219,7 +222,51 @@ func (t *trimmer) comment() error {
 	}
 }
 
+// extractVariables returns the file-scoped variables defined in the
+// "variables" table of the given configuration. The values of the variables
+// may reference environment variables. As the configuration is parsed before
+// substituting the variables, they can only be referenced where the
+// configuration is valid TOML without them, e.g. within strings.
+func extractVariables(contents []byte, oldReplacementBehavior bool) (map[string]string, error) {
+	substituted, err := substituteEnvironment(contents, oldReplacementBehavior)
+	if err != nil {
+		return nil, err
+	}
+	root, err := toml.Parse(substituted)
+	if err != nil {
+		return nil, err
+	}
+	val, ok := root.Fields["variables"]
+	if !ok {
+		return nil, nil
+	}
+	tbl, ok := val.(*ast.Table)
+	if !ok {
+		return nil, errors.New("variables must be a table")
+	}
+
+	variables := make(map[string]string, len(tbl.Fields))
+	for name, val := range tbl.Fields {
+		kv, ok := val.(*ast.KeyValue)
+		if !ok {
+			return nil, fmt.Errorf("variable %q is not a string", name)
+		}
+		str, ok := kv.Value.(*ast.String)
+		if !ok {
+			return nil, fmt.Errorf("variable %q is not a string", name)
+		}
+		variables[name] = str.Value
+	}
+	return variables, nil
+}
+
 func substituteEnvironment(contents []byte, oldReplacementBehavior bool) ([]byte, error) {
+	return substituteVariables(contents, oldReplacementBehavior, nil)
+}
+
+// substituteVariables replaces references to the given variables and to
+// environment variables, the given variables take precedence
+func substituteVariables(contents []byte, oldReplacementBehavior bool, variables map[string]string) ([]byte, error) {
 	options := []template.Option{
 		template.WithReplacementFunction(func(s string, m template.Mapping, cfg *template.Config) (string, error) {
 			result, applied, err := template.DefaultReplacementAppliedFunc(s, m, cfg)
@@ -243,6 +290,9 @@ func substituteEnvironment(contents []byte, oldReplacementBehavior bool) ([]byte
 
 	envMap := utils.GetAsEqualsMap(os.Environ())
 	retVal, err := template.SubstituteWithOptions(string(contents), func(k string) (string, bool) {
+		if v, ok := variables[k]; ok {
+			return v, ok
+		}
 		if v, ok := envMap[k]; ok {
 			return v, ok
 		}
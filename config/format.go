@@ -0,0 +1,515 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/influxdata/toml"
+	"github.com/influxdata/toml/ast"
+	"gopkg.in/yaml.v2"
+
+	"github.com/influxdata/telegraf/internal/orderedjson"
+)
+
+// Supported configuration formats
+const (
+	FormatTOML = "toml"
+	FormatYAML = "yaml"
+	FormatJSON = "json"
+)
+
+// bareKeyRe matches TOML keys not requiring quotes
+var bareKeyRe = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// DetectFormat returns the configuration format of the given file or URL
+// based on its extension, TOML is assumed for unknown extensions.
+func DetectFormat(path string) string {
+	if u, err := url.Parse(path); err == nil && fetchURLRe.MatchString(path) {
+		path = u.Path
+	}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		return FormatYAML
+	case ".json":
+		return FormatJSON
+	}
+	return FormatTOML
+}
+
+// isConfigFile returns true if the given filename denotes a configuration file
+// to load from a configuration directory. Those are TOML files with a ".conf"
+// extension and files of the other formats with a ".conf" infix, e.g.
+// "inputs.conf.yaml", to not pick up unrelated YAML or JSON files.
+func isConfigFile(name string) bool {
+	ext := filepath.Ext(name)
+	if ext == "" || ext == name {
+		return false
+	}
+	if ext == ".conf" {
+		return true
+	}
+	base := strings.TrimSuffix(name, ext)
+	return DetectFormat(name) != FormatTOML && filepath.Ext(base) == ".conf" && base != ".conf"
+}
+
+// ConvertFormat converts the configuration data from one format to another.
+// Environment variables and file-scoped variables are kept as they are.
+func ConvertFormat(data []byte, from, to string) ([]byte, error) {
+	var tree yaml.MapSlice
+	switch from {
+	case FormatTOML:
+		contents, err := removeComments(trimBOM(data))
+		if err != nil {
+			return nil, err
+		}
+		tbl, err := toml.Parse(contents)
+		if err != nil {
+			return nil, fmt.Errorf("parsing TOML failed: %w", err)
+		}
+		tree, err = tomlToTree(tbl)
+		if err != nil {
+			return nil, err
+		}
+	case FormatYAML, FormatJSON:
+		var err error
+		if tree, err = decodeTree(trimBOM(data), from); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unsupported format %q", from)
+	}
+
+	switch to {
+	case FormatTOML:
+		return encodeTOML(tree)
+	case FormatYAML:
+		return yaml.Marshal(tree)
+	case FormatJSON:
+		return orderedjson.MarshalIndent(tree, "  ")
+	}
+	return nil, fmt.Errorf("unsupported format %q", to)
+}
+
+// parseConfigFormat parses the configuration data of the given format. YAML
+// and JSON data is converted to TOML after substituting the variables.
+func parseConfigFormat(contents []byte, format string) (*ast.Table, error) {
+	if format == FormatTOML {
+		return parseConfig(contents)
+	}
+	contents = trimBOM(contents)
+
+	// The file-scoped variables might reference environment variables, so
+	// collect them from the data with environment variables substituted. The
+	// data might not be valid before substituting the variables, so ignore
+	// errors here and report them when decoding the data.
+	var variables map[string]string
+	if substituted, err := substituteEnvironment(contents, OldEnvVarReplacement); err == nil {
+		if tree, err := decodeTree(substituted, format); err == nil {
+			variables = treeVariables(tree)
+		}
+	}
+	contents, err := substituteVariables(contents, OldEnvVarReplacement, variables)
+	if err != nil {
+		return nil, err
+	}
+
+	tree, err := decodeTree(contents, format)
+	if err != nil {
+		return nil, err
+	}
+	data, err := encodeTOML(tree)
+	if err != nil {
+		return nil, err
+	}
+	return toml.Parse(data)
+}
+
+// treeVariables returns the string values of the "variables" table
+func treeVariables(tree yaml.MapSlice) map[string]string {
+	for _, item := range tree {
+		if fmt.Sprint(item.Key) != "variables" {
+			continue
+		}
+		table, ok := item.Value.(yaml.MapSlice)
+		if !ok {
+			return nil
+		}
+		variables := make(map[string]string, len(table))
+		for _, v := range table {
+			if s, ok := v.Value.(string); ok {
+				variables[fmt.Sprint(v.Key)] = s
+			}
+		}
+		return variables
+	}
+	return nil
+}
+
+// decodeTree decodes YAML or JSON data into a tree keeping the order of keys
+func decodeTree(data []byte, format string) (yaml.MapSlice, error) {
+	if format == FormatJSON {
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.UseNumber()
+		value, err := decodeJSONValue(dec)
+		if err != nil {
+			return nil, fmt.Errorf("parsing JSON failed: %w", err)
+		}
+		tree, ok := value.(yaml.MapSlice)
+		if !ok {
+			return nil, errors.New("parsing JSON failed: configuration must be an object")
+		}
+		return tree, nil
+	}
+
+	var tree yaml.MapSlice
+	if err := yaml.Unmarshal(data, &tree); err != nil {
+		return nil, fmt.Errorf("parsing YAML failed: %w", err)
+	}
+	return tree, nil
+}
+
+func decodeJSONValue(dec *json.Decoder) (interface{}, error) {
+	token, err := dec.Token()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+
+	switch v := token.(type) {
+	case json.Delim:
+		switch v {
+		case '{':
+			obj := yaml.MapSlice{}
+			for dec.More() {
+				key, err := dec.Token()
+				if err != nil {
+					return nil, err
+				}
+				value, err := decodeJSONValue(dec)
+				if err != nil {
+					return nil, err
+				}
+				obj = append(obj, yaml.MapItem{Key: key, Value: value})
+			}
+			_, err := dec.Token()
+			return obj, err
+		case '[':
+			arr := make([]interface{}, 0)
+			for dec.More() {
+				value, err := decodeJSONValue(dec)
+				if err != nil {
+					return nil, err
+				}
+				arr = append(arr, value)
+			}
+			_, err := dec.Token()
+			return arr, err
+		}
+		return nil, fmt.Errorf("unexpected delimiter %q", v)
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i, nil
+		}
+		return v.Float64()
+	}
+	return token, nil
+}
+
+// tomlToTree converts a TOML table into a tree ordered by the lines of the
+// fields
+func tomlToTree(tbl *ast.Table) (yaml.MapSlice, error) {
+	type field struct {
+		key   string
+		line  int
+		value interface{}
+	}
+	fields := make([]field, 0, len(tbl.Fields))
+	for key, val := range tbl.Fields {
+		switch v := val.(type) {
+		case *ast.KeyValue:
+			fields = append(fields, field{key, v.Line, v.Value})
+		case *ast.Table:
+			fields = append(fields, field{key, v.Line, v})
+		case []*ast.Table:
+			line := 0
+			if len(v) > 0 {
+				line = v[0].Line
+			}
+			fields = append(fields, field{key, line, v})
+		default:
+			return nil, fmt.Errorf("unexpected type %T for %q", val, key)
+		}
+	}
+	sort.SliceStable(fields, func(i, j int) bool {
+		if fields[i].line != fields[j].line {
+			return fields[i].line < fields[j].line
+		}
+		return fields[i].key < fields[j].key
+	})
+
+	tree := make(yaml.MapSlice, 0, len(fields))
+	for _, f := range fields {
+		value, err := tomlValue(f.value)
+		if err != nil {
+			return nil, fmt.Errorf("converting %q failed: %w", f.key, err)
+		}
+		tree = append(tree, yaml.MapItem{Key: f.key, Value: value})
+	}
+	return tree, nil
+}
+
+func tomlValue(val interface{}) (interface{}, error) {
+	switch v := val.(type) {
+	case *ast.String:
+		return v.Value, nil
+	case *ast.Integer:
+		return strconv.ParseInt(strings.ReplaceAll(v.Value, "_", ""), 0, 64)
+	case *ast.Float:
+		return strconv.ParseFloat(strings.ReplaceAll(v.Value, "_", ""), 64)
+	case *ast.Boolean:
+		return v.Value == "true", nil
+	case *ast.Datetime:
+		return v.Value, nil
+	case *ast.Array:
+		values := make([]interface{}, 0, len(v.Value))
+		for _, e := range v.Value {
+			value, err := tomlValue(e)
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, value)
+		}
+		return values, nil
+	case *ast.Table:
+		return tomlToTree(v)
+	case []*ast.Table:
+		values := make([]interface{}, 0, len(v))
+		for _, t := range v {
+			value, err := tomlToTree(t)
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, value)
+		}
+		return values, nil
+	}
+	return nil, fmt.Errorf("unexpected type %T", val)
+}
+
+// encodeTOML converts the tree into TOML. The key-values of a table are
+// written before its sub-tables, the tables are written in the order of the
+// tree.
+func encodeTOML(tree yaml.MapSlice) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := writeTOMLTable(&buf, nil, tree); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func writeTOMLTable(buf *bytes.Buffer, path []string, table yaml.MapSlice) error {
+	// Write the key-values first as the following tables end the table
+	for _, item := range table {
+		key := fmt.Sprint(item.Key)
+		if item.Value == nil || isTOMLTable(item.Value) || isTOMLArrayOfTables(item.Value) {
+			continue
+		}
+		buf.WriteString(tomlIndent(len(path)))
+		buf.WriteString(tomlKey(key))
+		buf.WriteString(" = ")
+		if err := writeTOMLValue(buf, item.Value); err != nil {
+			return fmt.Errorf("converting %q failed: %w", strings.Join(append(path, key), "."), err)
+		}
+		buf.WriteByte('\n')
+	}
+
+	for _, item := range table {
+		key := fmt.Sprint(item.Key)
+		subPath := append(append(make([]string, 0, len(path)+1), path...), key)
+		switch v := item.Value.(type) {
+		case yaml.MapSlice:
+			// Tables only containing other tables are implicitly defined
+			if len(v) == 0 || hasTOMLKeyValues(v) {
+				writeTOMLHeader(buf, subPath, "[", "]")
+			}
+			if err := writeTOMLTable(buf, subPath, v); err != nil {
+				return err
+			}
+		case []interface{}:
+			if !isTOMLArrayOfTables(v) {
+				continue
+			}
+			for _, e := range v {
+				writeTOMLHeader(buf, subPath, "[[", "]]")
+				if err := writeTOMLTable(buf, subPath, e.(yaml.MapSlice)); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func writeTOMLHeader(buf *bytes.Buffer, path []string, open, closing string) {
+	keys := make([]string, 0, len(path))
+	for _, k := range path {
+		keys = append(keys, tomlKey(k))
+	}
+	if buf.Len() > 0 {
+		buf.WriteByte('\n')
+	}
+	buf.WriteString(strings.Repeat("  ", max(len(path)-2, 0)))
+	buf.WriteString(open + strings.Join(keys, ".") + closing + "\n")
+}
+
+// tomlIndent returns the indentation of the key-values of a table with the
+// given depth, plugin tables such as "[[inputs.cpu]]" are not indented
+func tomlIndent(depth int) string {
+	return strings.Repeat("  ", max(depth-1, min(depth, 1)))
+}
+
+func isTOMLTable(v interface{}) bool {
+	_, ok := v.(yaml.MapSlice)
+	return ok
+}
+
+func isTOMLArrayOfTables(v interface{}) bool {
+	arr, ok := v.([]interface{})
+	if !ok || len(arr) == 0 {
+		return false
+	}
+	for _, e := range arr {
+		if !isTOMLTable(e) {
+			return false
+		}
+	}
+	return true
+}
+
+func hasTOMLKeyValues(table yaml.MapSlice) bool {
+	for _, item := range table {
+		if item.Value != nil && !isTOMLTable(item.Value) && !isTOMLArrayOfTables(item.Value) {
+			return true
+		}
+	}
+	return false
+}
+
+func tomlKey(key string) string {
+	if bareKeyRe.MatchString(key) {
+		return key
+	}
+	return tomlString(key)
+}
+
+func writeTOMLValue(buf *bytes.Buffer, value interface{}) error {
+	switch v := value.(type) {
+	case string:
+		buf.WriteString(tomlString(v))
+	case bool:
+		buf.WriteString(strconv.FormatBool(v))
+	case int:
+		buf.WriteString(strconv.Itoa(v))
+	case int64:
+		buf.WriteString(strconv.FormatInt(v, 10))
+	case uint64:
+		buf.WriteString(strconv.FormatUint(v, 10))
+	case float64:
+		switch {
+		case math.IsNaN(v):
+			buf.WriteString("nan")
+		case math.IsInf(v, 1):
+			buf.WriteString("inf")
+		case math.IsInf(v, -1):
+			buf.WriteString("-inf")
+		default:
+			s := strconv.FormatFloat(v, 'g', -1, 64)
+			if !strings.ContainsAny(s, ".e") {
+				s += ".0"
+			}
+			buf.WriteString(s)
+		}
+	case time.Time:
+		buf.WriteString(tomlString(v.Format(time.RFC3339Nano)))
+	case []interface{}:
+		buf.WriteByte('[')
+		for i, e := range v {
+			if i > 0 {
+				buf.WriteString(", ")
+			}
+			if e == nil {
+				return errors.New("null values are not supported in arrays")
+			}
+			if err := writeTOMLValue(buf, e); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte(']')
+	case yaml.MapSlice:
+		// Tables inside arrays of values
+		buf.WriteByte('{')
+		first := true
+		for _, item := range v {
+			if item.Value == nil {
+				continue
+			}
+			if !first {
+				buf.WriteString(", ")
+			}
+			first = false
+			buf.WriteString(tomlKey(fmt.Sprint(item.Key)) + " = ")
+			if err := writeTOMLValue(buf, item.Value); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte('}')
+	default:
+		return fmt.Errorf("unsupported type %T", value)
+	}
+	return nil
+}
+
+// tomlString quotes the string as TOML basic string
+func tomlString(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"':
+			b.WriteString(`\"`)
+		case '\\':
+			b.WriteString(`\\`)
+		case '\b':
+			b.WriteString(`\b`)
+		case '\t':
+			b.WriteString(`\t`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\f':
+			b.WriteString(`\f`)
+		case '\r':
+			b.WriteString(`\r`)
+		default:
+			if r < 0x20 || r == 0x7f {
+				fmt.Fprintf(&b, `\u%04X`, r)
+			} else {
+				b.WriteRune(r)
+			}
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
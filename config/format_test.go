@@ -0,0 +1,125 @@
+package config_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/influxdata/telegraf/config"
+)
+
+func TestDetectFormat(t *testing.T) {
+	tests := map[string]string{
+		"telegraf.conf":                         config.FormatTOML,
+		"telegraf.toml":                         config.FormatTOML,
+		"/etc/telegraf/telegraf.yaml":           config.FormatYAML,
+		"telegraf.YML":                          config.FormatYAML,
+		"telegraf.json":                         config.FormatJSON,
+		"https://example.com/telegraf.json?v=1": config.FormatJSON,
+		"https://example.com/config":            config.FormatTOML,
+	}
+	for path, expected := range tests {
+		require.Equal(t, expected, config.DetectFormat(path), path)
+	}
+}
+
+func TestConvertFormat(t *testing.T) {
+	// All formats of the test configuration must result in the same JSON
+	expected, err := os.ReadFile(filepath.Join("testdata", "formats", "telegraf.toml"))
+	require.NoError(t, err)
+	expected, err = config.ConvertFormat(expected, config.FormatTOML, config.FormatJSON)
+	require.NoError(t, err)
+
+	for _, format := range []string{config.FormatYAML, config.FormatJSON} {
+		t.Run(format, func(t *testing.T) {
+			data, err := os.ReadFile(filepath.Join("testdata", "formats", "telegraf."+format))
+			require.NoError(t, err)
+			actual, err := config.ConvertFormat(data, format, config.FormatJSON)
+			require.NoError(t, err)
+			require.JSONEq(t, string(expected), string(actual))
+		})
+	}
+}
+
+func TestConvertFormatRoundtrip(t *testing.T) {
+	input := []byte(`
+[agent]
+  interval = "10s"
+  round_interval = true
+  metric_batch_size = 1000
+
+[[inputs.file]]
+  files = ["C:\\temp\\file.txt", "${MY_FILE}"]
+  ratio = 0.5
+  command = "line1\n\tline2 with \"quotes\" and ünïcödé\u0001"
+  [inputs.file.tags]
+    "key with spaces" = "value"
+
+[[inputs.file]]
+  files = []
+
+[[outputs.file]]
+  files = ["stdout"]
+  [[outputs.file.routes]]
+    name = "a"
+  [[outputs.file.routes]]
+    name = "b"
+`)
+	expected, err := config.ConvertFormat(input, config.FormatTOML, config.FormatJSON)
+	require.NoError(t, err)
+
+	for _, format := range []string{config.FormatTOML, config.FormatYAML, config.FormatJSON} {
+		t.Run(format, func(t *testing.T) {
+			converted, err := config.ConvertFormat(input, config.FormatTOML, format)
+			require.NoError(t, err)
+			actual, err := config.ConvertFormat(converted, format, config.FormatJSON)
+			require.NoError(t, err)
+			require.JSONEq(t, string(expected), string(actual))
+		})
+	}
+}
+
+func TestConvertFormatTOMLOutput(t *testing.T) {
+	input := []byte(`{
+  "agent": {"interval": "10s", "flush_jitter": "0s"},
+  "inputs": {
+    "cpu": [{"percpu": true, "tags": {"dc": "eu"}}, {}]
+  },
+  "outputs": {"file": [{"files": ["stdout"], "ratio": 1.0}]}
+}`)
+	expected := `[agent]
+  interval = "10s"
+  flush_jitter = "0s"
+
+[[inputs.cpu]]
+  percpu = true
+
+  [inputs.cpu.tags]
+    dc = "eu"
+
+[[inputs.cpu]]
+
+[[outputs.file]]
+  files = ["stdout"]
+  ratio = 1.0
+`
+	actual, err := config.ConvertFormat(input, config.FormatJSON, config.FormatTOML)
+	require.NoError(t, err)
+	require.Equal(t, expected, string(actual))
+}
+
+func TestConvertFormatInvalid(t *testing.T) {
+	_, err := config.ConvertFormat([]byte(`[1, 2]`), config.FormatJSON, config.FormatTOML)
+	require.ErrorContains(t, err, "configuration must be an object")
+
+	_, err = config.ConvertFormat([]byte("inputs:\n  cpu:\n    - tags: [a, null]\n"), config.FormatYAML, config.FormatTOML)
+	require.ErrorContains(t, err, "null values are not supported")
+
+	_, err = config.ConvertFormat([]byte("interval = ${INTERVAL}"), config.FormatTOML, config.FormatYAML)
+	require.ErrorContains(t, err, "parsing TOML failed")
+
+	_, err = config.ConvertFormat([]byte(`{}`), config.FormatJSON, "xml")
+	require.ErrorContains(t, err, `unsupported format "xml"`)
+}
@@ -305,6 +305,48 @@ func TestEnvironmentSubstitutionNewBehavior(t *testing.T) {
 	}
 }
 
+func TestSubstituteVariables(t *testing.T) {
+	t.Setenv("REGION", "eu")
+	t.Setenv("PORT", "8080")
+
+	contents := []byte(`
+[variables]
+  region = "${REGION}-west"
+  port = "9090"
+
+[[inputs.http]]
+  urls = ["http://${region}:${port}", "http://${REGION}:${PORT}"]
+`)
+	variables, err := extractVariables(contents, false)
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"region": "eu-west", "port": "9090"}, variables)
+
+	actual, err := substituteVariables(contents, false, variables)
+	require.NoError(t, err)
+	require.Contains(t, string(actual), `urls = ["http://eu-west:9090", "http://eu:8080"]`)
+
+	_, err = extractVariables([]byte("[variables]\n  port = 9090\n"), false)
+	require.ErrorContains(t, err, `variable "port" is not a string`)
+
+	// Table headers within strings must not end the variables table
+	contents = []byte(`
+[[inputs.exec]]
+  command = '''
+[variables]
+  region = "wrong"
+'''
+
+[variables]
+  query = """
+[header]
+"""
+  region = "eu"
+`)
+	variables, err = extractVariables(contents, false)
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"query": "[header]\n", "region": "eu"}, variables)
+}
+
 func TestParseConfig(t *testing.T) {
 	tests := []struct {
 		name     string
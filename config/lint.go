@@ -0,0 +1,305 @@
+package config
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/influxdata/telegraf/filter"
+	"github.com/influxdata/telegraf/metric"
+	"github.com/influxdata/telegraf/models"
+)
+
+// Rules checked when linting the configuration
+const (
+	LintRuleDuplicateID        = "duplicate-plugin-id"
+	LintRuleFilterNeverMatches = "filter-never-matches"
+	LintRuleOutputNoMetrics    = "output-without-metrics"
+	LintRuleUnusedSecretStore  = "unused-secret-store"
+	LintRuleSkippedProcessor   = "processor-skipped-after-aggregators"
+)
+
+// LintRules contains a short description of each lint rule
+var LintRules = map[string]string{
+	LintRuleDuplicateID:        "Plugins share the same ID",
+	LintRuleFilterNeverMatches: "Metric filter can never match",
+	LintRuleOutputNoMetrics:    "Output never receives any metric",
+	LintRuleUnusedSecretStore:  "Secret-store is never referenced",
+	LintRuleSkippedProcessor:   "Processor does not process aggregated metrics",
+}
+
+// Location is the position of a plugin definition in the configuration
+type Location struct {
+	Source string `json:"source"`
+	Line   int    `json:"line"`
+}
+
+func (l Location) String() string {
+	if l.Source == "" {
+		return "<unknown>"
+	}
+	return fmt.Sprintf("%s:%d", l.Source, l.Line)
+}
+
+// LintIssue is a potential problem found when linting the configuration
+type LintIssue struct {
+	Rule     string   `json:"rule"`
+	Plugin   string   `json:"plugin"`
+	Location Location `json:"location"`
+	Message  string   `json:"message"`
+}
+
+func (i *LintIssue) String() string {
+	return fmt.Sprintf("%s: %s: %s [%s]", i.Location, i.Plugin, i.Message, i.Rule)
+}
+
+func (c *Config) setLocation(key any, source string, line int) {
+	c.locations[key] = Location{Source: source, Line: line}
+}
+
+// lintPlugin contains the plugin properties checked by the linter
+type lintPlugin struct {
+	name     string
+	id       string
+	location Location
+	filter   *models.Filter
+}
+
+// Lint statically analyzes the loaded configuration for settings that are
+// valid but most likely not doing what the user intended. The returned issues
+// are sorted by their location.
+func (c *Config) Lint() []LintIssue {
+	plugins := make([]lintPlugin, 0, len(c.Inputs)+len(c.Processors)+len(c.Aggregators)+len(c.Outputs))
+	for _, p := range c.Inputs {
+		plugins = append(plugins, lintPlugin{p.LogName(), p.Config.ID, c.locations[p.Config], &p.Config.Filter})
+	}
+	for _, p := range c.Processors {
+		plugins = append(plugins, lintPlugin{p.LogName(), p.Config.ID, c.locations[p.Config], &p.Config.Filter})
+	}
+	for _, p := range c.Aggregators {
+		plugins = append(plugins, lintPlugin{p.LogName(), p.Config.ID, c.locations[p.Config], &p.Config.Filter})
+	}
+	for _, p := range c.Outputs {
+		plugins = append(plugins, lintPlugin{p.LogName(), p.Config.ID, c.locations[p.Config], &p.Config.Filter})
+	}
+
+	var issues []LintIssue
+	issues = append(issues, lintDuplicateIDs(plugins)...)
+	issues = append(issues, lintFilters(plugins)...)
+	issues = append(issues, c.lintOutputs()...)
+	issues = append(issues, c.lintSecretStores()...)
+	issues = append(issues, c.lintProcessorOrder()...)
+
+	sort.SliceStable(issues, func(i, j int) bool {
+		if issues[i].Location.Source != issues[j].Location.Source {
+			return issues[i].Location.Source < issues[j].Location.Source
+		}
+		return issues[i].Location.Line < issues[j].Location.Line
+	})
+	return issues
+}
+
+// lintDuplicateIDs reports plugins with the same ID as an earlier plugin, as
+// those cannot be distinguished when persisting states or reloading the
+// configuration
+func lintDuplicateIDs(plugins []lintPlugin) []LintIssue {
+	var issues []LintIssue
+	seen := make(map[string]lintPlugin, len(plugins))
+	for _, p := range plugins {
+		first, found := seen[p.id]
+		if !found {
+			seen[p.id] = p
+			continue
+		}
+		issues = append(issues, LintIssue{
+			Rule:     LintRuleDuplicateID,
+			Plugin:   p.name,
+			Location: p.location,
+			Message:  fmt.Sprintf("plugin has the same ID as %s at %s", first.name, first.location),
+		})
+	}
+	return issues
+}
+
+// lintFilters reports plugins with filters rejecting all metrics
+func lintFilters(plugins []lintPlugin) []LintIssue {
+	var issues []LintIssue
+	for _, p := range plugins {
+		if reason := filterNeverMatches(p.filter); reason != "" {
+			issues = append(issues, LintIssue{
+				Rule:     LintRuleFilterNeverMatches,
+				Plugin:   p.name,
+				Location: p.location,
+				Message:  reason,
+			})
+		}
+	}
+	return issues
+}
+
+// filterNeverMatches returns the reason if the filter rejects all metrics
+func filterNeverMatches(f *models.Filter) string {
+	if f.NameDropSeparators == "" && sliceContains("*", f.NameDrop) {
+		return `"namedrop" drops all metrics`
+	}
+	if len(f.NamePass) > 0 && patternsCovered(f.NamePass, f.NamePassSeparators, f.NameDrop, f.NameDropSeparators) {
+		return `all "namepass" patterns are also matched by "namedrop"`
+	}
+	if sliceContains("*", f.FieldExclude) {
+		return `"fieldexclude" removes all fields`
+	}
+	if len(f.FieldInclude) > 0 && patternsCovered(f.FieldInclude, "", f.FieldExclude, "") {
+		return `all "fieldinclude" patterns are also matched by "fieldexclude"`
+	}
+	return ""
+}
+
+// patternsCovered returns true if every name matching one of the given
+// patterns is also matched by one of the covering patterns. The check is
+// conservative and only considers patterns using the '*' wildcard.
+func patternsCovered(patterns []string, separators string, covering []string, coveringSeparators string) bool {
+	if len(covering) == 0 {
+		return false
+	}
+	for _, pattern := range patterns {
+		// A literal name is covered if matched by a covering pattern, a pattern
+		// only if the wildcards match the same set of characters.
+		if strings.ContainsAny(pattern, `?[]{}\!`) {
+			return false
+		}
+		if strings.Contains(pattern, "*") && separators != coveringSeparators {
+			return false
+		}
+
+		covered := false
+		for _, c := range covering {
+			if strings.ContainsAny(c, `?[]{}\!`) {
+				continue
+			}
+			f, err := filter.Compile([]string{c}, []rune(coveringSeparators)...)
+			if err == nil && f.Match(pattern) {
+				covered = true
+				break
+			}
+		}
+		if !covered {
+			return false
+		}
+	}
+	return true
+}
+
+// lintOutputs reports outputs whose name filter rejects all metrics of their
+// pipelines. The metric names are only known if all inputs of the pipelines
+// override the name and no processor or aggregator might change it.
+func (c *Config) lintOutputs() []LintIssue {
+	var issues []LintIssue
+outputs:
+	for _, o := range c.Outputs {
+		if len(o.Config.Filter.NamePass) == 0 && len(o.Config.Filter.NameDrop) == 0 {
+			continue
+		}
+		for _, p := range c.Processors {
+			if o.InPipeline(p.Pipeline()) {
+				continue outputs
+			}
+		}
+		for _, a := range c.Aggregators {
+			if o.InPipeline(a.Pipeline()) {
+				continue outputs
+			}
+		}
+
+		var names []string
+		for _, i := range c.Inputs {
+			if !o.InPipeline(i.Pipeline()) {
+				continue
+			}
+			if i.Config.NameOverride == "" {
+				continue outputs
+			}
+			names = append(names, i.Config.MeasurementPrefix+i.Config.NameOverride+i.Config.MeasurementSuffix)
+		}
+		if len(names) == 0 {
+			continue
+		}
+
+		// Only check the name filters as the tags are unknown
+		f := &models.Filter{
+			NamePass:           o.Config.Filter.NamePass,
+			NamePassSeparators: o.Config.Filter.NamePassSeparators,
+			NameDrop:           o.Config.Filter.NameDrop,
+			NameDropSeparators: o.Config.Filter.NameDropSeparators,
+		}
+		if err := f.Compile(); err != nil {
+			continue
+		}
+		passed := false
+		for _, name := range names {
+			m := metric.New(name, nil, map[string]interface{}{"value": true}, time.Time{})
+			if ok, err := f.Select(m); err != nil || ok {
+				passed = true
+				break
+			}
+		}
+		if !passed {
+			sort.Strings(names)
+			issues = append(issues, LintIssue{
+				Rule:     LintRuleOutputNoMetrics,
+				Plugin:   o.LogName(),
+				Location: c.locations[o.Config],
+				Message:  fmt.Sprintf("name filter rejects all metrics of the inputs (%s)", strings.Join(slices.Compact(names), ", ")),
+			})
+		}
+	}
+	return issues
+}
+
+// lintSecretStores reports secret-stores not referenced by any secret
+func (c *Config) lintSecretStores() []LintIssue {
+	var issues []LintIssue
+	for id, store := range c.SecretStores {
+		if c.secretStoreRefs[id] > 0 {
+			continue
+		}
+		issues = append(issues, LintIssue{
+			Rule:     LintRuleUnusedSecretStore,
+			Plugin:   fmt.Sprintf("secretstores (id %q)", id),
+			Location: c.locations[store],
+			Message:  "secret-store is not referenced by any secret",
+		})
+	}
+	return issues
+}
+
+// lintProcessorOrder reports processors defined after an aggregator of the
+// same pipeline in the same file, as those processors do not process the
+// aggregated metrics if processors are skipped after aggregators
+func (c *Config) lintProcessorOrder() []LintIssue {
+	skip := c.Agent.SkipProcessorsAfterAggregators
+	if skip == nil || !*skip {
+		return nil
+	}
+
+	var issues []LintIssue
+	for _, p := range c.Processors {
+		loc := c.locations[p.Config]
+		for _, a := range c.Aggregators {
+			aggLoc := c.locations[a.Config]
+			if a.Pipeline() != p.Pipeline() || aggLoc.Source != loc.Source || aggLoc.Line > loc.Line {
+				continue
+			}
+			issues = append(issues, LintIssue{
+				Rule:     LintRuleSkippedProcessor,
+				Plugin:   p.LogName(),
+				Location: loc,
+				Message: fmt.Sprintf("processor is defined after %s at %s but does not process aggregated metrics "+
+					"as 'skip_processors_after_aggregators' is enabled", a.LogName(), aggLoc),
+			})
+			break
+		}
+	}
+	return issues
+}
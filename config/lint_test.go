@@ -0,0 +1,83 @@
+package config_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/plugins/aggregators"
+)
+
+type MockupAggregatorPlugin struct{}
+
+func (*MockupAggregatorPlugin) SampleConfig() string {
+	return "Mockup test aggregator plugin"
+}
+func (*MockupAggregatorPlugin) Add(telegraf.Metric)       {}
+func (*MockupAggregatorPlugin) Push(telegraf.Accumulator) {}
+func (*MockupAggregatorPlugin) Reset()                    {}
+
+func init() {
+	aggregators.Add("lint", func() telegraf.Aggregator { return &MockupAggregatorPlugin{} })
+}
+
+func TestConfig_Lint(t *testing.T) {
+	c := config.NewConfig()
+	require.NoError(t, c.LoadAll("./testdata/lint.toml"))
+
+	source := "./testdata/lint.toml"
+	expected := []config.LintIssue{
+		{
+			Rule:     config.LintRuleUnusedSecretStore,
+			Plugin:   `secretstores (id "unused")`,
+			Location: config.Location{Source: source, Line: 8},
+			Message:  "secret-store is not referenced by any secret",
+		},
+		{
+			Rule:     config.LintRuleFilterNeverMatches,
+			Plugin:   "inputs.memcached",
+			Location: config.Location{Source: source, Line: 15},
+			Message:  `"namedrop" drops all metrics`,
+		},
+		{
+			Rule:     config.LintRuleDuplicateID,
+			Plugin:   "inputs.memcached",
+			Location: config.Location{Source: source, Line: 21},
+			Message:  "plugin has the same ID as inputs.memcached at ./testdata/lint.toml:15",
+		},
+		{
+			Rule:     config.LintRuleFilterNeverMatches,
+			Plugin:   "inputs.memcached",
+			Location: config.Location{Source: source, Line: 21},
+			Message:  `"namedrop" drops all metrics`,
+		},
+		{
+			Rule:     config.LintRuleSkippedProcessor,
+			Plugin:   "processors.processor",
+			Location: config.Location{Source: source, Line: 30},
+			Message: "processor is defined after aggregators.lint at ./testdata/lint.toml:27 but does not process " +
+				"aggregated metrics as 'skip_processors_after_aggregators' is enabled",
+		},
+		{
+			Rule:     config.LintRuleOutputNoMetrics,
+			Plugin:   "outputs.http",
+			Location: config.Location{Source: source, Line: 37},
+			Message:  "name filter rejects all metrics of the inputs (memcached, secret)",
+		},
+		{
+			Rule:     config.LintRuleFilterNeverMatches,
+			Plugin:   "outputs.http",
+			Location: config.Location{Source: source, Line: 41},
+			Message:  `all "fieldinclude" patterns are also matched by "fieldexclude"`,
+		},
+	}
+	require.Equal(t, expected, c.Lint())
+}
+
+func TestConfig_LintClean(t *testing.T) {
+	c := config.NewConfig()
+	require.NoError(t, c.LoadAll("./testdata/pipelines.toml"))
+	require.Empty(t, c.Lint())
+}
@@ -77,3 +77,14 @@ func generatePluginID(prefix string, table *ast.Table) (string, error) {
 
 	return hex.EncodeToString(hash.Sum(nil)), nil
 }
+
+// combinePluginIDs derives a single ID from the IDs of multiple plugins, e.g.
+// for plugins running other plugins.
+func combinePluginIDs(ids ...string) string {
+	hash := sha256.New()
+	for _, id := range ids {
+		hash.Write([]byte(id))
+		hash.Write([]byte{0})
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
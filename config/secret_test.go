@@ -417,6 +417,33 @@ func TestSecretStoreStatic(t *testing.T) {
 	}
 }
 
+func TestSecretStoreReferences(t *testing.T) {
+	cfg := []byte(
+		`
+[[inputs.mockup]]
+	secret = "@{mock:user}:@{mock:password}@@{mock:user}"
+[[inputs.mockup]]
+	secret = "plain"
+`)
+
+	c := NewConfig()
+	require.NoError(t, c.LoadConfigData(cfg, EmptySourcePath))
+	require.Len(t, c.Inputs, 2)
+	require.Equal(t, []string{"@{mock:user}", "@{mock:password}"}, c.Inputs[0].Config.Secrets)
+	require.Empty(t, c.Inputs[1].Config.Secrets)
+
+	// Link the secrets to not leak them into other tests
+	store := &MockupSecretStore{
+		Secrets: map[string][]byte{
+			"user":     []byte("admin"),
+			"password": []byte("pa$$word"),
+		},
+	}
+	require.NoError(t, store.Init())
+	c.SecretStores["mock"] = store
+	require.NoError(t, c.LinkSecrets())
+}
+
 func TestSecretStoreInvalidKeys(t *testing.T) {
 	cfg := []byte(
 		`
@@ -0,0 +1,414 @@
+package config
+
+import (
+	"bytes"
+	"encoding"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/influxdata/toml"
+	"github.com/influxdata/toml/ast"
+	"gopkg.in/yaml.v2"
+
+	"github.com/influxdata/telegraf/plugins/processors"
+)
+
+// redacted replaces secret values when showing the configuration
+const redacted = "<redacted>"
+
+var (
+	durationType     = reflect.TypeOf(Duration(0))
+	timeDurationType = reflect.TypeOf(time.Duration(0))
+	sizeType         = reflect.TypeOf(Size(0))
+	secretType       = reflect.TypeOf(Secret{})
+)
+
+// showCategories defines the order of the plugin categories when showing the
+// configuration
+var showCategories = []string{"secretstores", "inputs", "processors", "aggregators", "outputs", "output_groups"}
+
+// pluginSource is a loaded plugin together with the table it was created from
+type pluginSource struct {
+	category string
+	name     string
+	plugin   any
+	location Location
+	table    *ast.Table
+}
+
+func (c *Config) addPluginSource(category, name string, plugin any, source string, table *ast.Table) {
+	if p, ok := plugin.(processors.HasUnwrap); ok {
+		plugin = p.Unwrap()
+	}
+	c.pluginSources = append(c.pluginSources, &pluginSource{
+		category: category,
+		name:     name,
+		plugin:   plugin,
+		location: Location{Source: source, Line: table.Line},
+		table:    table,
+	})
+}
+
+// addOptionSources records the location of the options of the given section,
+// options of later files override earlier ones as for the settings
+func (c *Config) addOptionSources(section, source string, table *ast.Table) {
+	if _, found := c.optionSources[section]; !found {
+		c.optionSources[section] = make(map[string]Location)
+	}
+	for key, val := range table.Fields {
+		if kv, ok := val.(*ast.KeyValue); ok {
+			c.optionSources[section][key] = Location{Source: source, Line: kv.Line}
+		}
+	}
+}
+
+// addTemplateSource records the file defining the options of the template as
+// those options are merged into plugins of other files
+func (c *Config) addTemplateSource(source string, table *ast.Table) {
+	for _, val := range table.Fields {
+		c.templateSource[val] = source
+		switch v := val.(type) {
+		case *ast.Table:
+			c.addTemplateSource(source, v)
+		case []*ast.Table:
+			for _, t := range v {
+				c.addTemplateSource(source, t)
+			}
+		}
+	}
+}
+
+// Show writes the effective configuration in TOML format. Options are filled
+// with their default values and annotated with the location they are defined
+// at. Secrets are redacted except for references to secret-stores.
+func (c *Config) Show(w io.Writer) error {
+	var buf bytes.Buffer
+
+	// Global tags including the ones added by the agent
+	buf.WriteString("[global_tags]\n")
+	tags := make([]string, 0, len(c.Tags))
+	for k := range c.Tags {
+		tags = append(tags, k)
+	}
+	sort.Strings(tags)
+	for _, k := range tags {
+		comment := "default"
+		if loc, found := c.optionSources["global_tags"][k]; found {
+			comment = loc.String()
+		}
+		if err := writeShowOption(&buf, k, c.Tags[k], comment); err != nil {
+			return err
+		}
+	}
+
+	buf.WriteString("\n[agent]\n")
+	for _, option := range structOptions(reflect.ValueOf(c.Agent)) {
+		loc, found := c.optionSources["agent"][option.key]
+		if !found && option.deprecated {
+			continue
+		}
+		value, ok := showValue(option.value)
+		if !ok {
+			continue
+		}
+		comment := "default"
+		if found {
+			comment = loc.String()
+		}
+		if err := writeShowOption(&buf, option.key, value, comment); err != nil {
+			return fmt.Errorf("showing agent option %q failed: %w", option.key, err)
+		}
+	}
+
+	for _, category := range showCategories {
+		var plugins []*pluginSource
+		for _, p := range c.pluginSources {
+			if p.category == category {
+				plugins = append(plugins, p)
+			}
+		}
+		sort.SliceStable(plugins, func(i, j int) bool {
+			if plugins[i].location.Source != plugins[j].location.Source {
+				return plugins[i].location.Source < plugins[j].location.Source
+			}
+			return plugins[i].location.Line < plugins[j].location.Line
+		})
+		for _, p := range plugins {
+			if err := c.showPlugin(&buf, p); err != nil {
+				return fmt.Errorf("showing %s.%s at %s failed: %w", p.category, p.name, p.location, err)
+			}
+		}
+	}
+
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+func (c *Config) showPlugin(buf *bytes.Buffer, p *pluginSource) error {
+	fmt.Fprintf(buf, "\n# %s\n", p.location)
+	if p.category == "output_groups" {
+		fmt.Fprintf(buf, "[%s.%s]\n", p.category, tomlKey(p.name))
+	} else {
+		fmt.Fprintf(buf, "[[%s.%s]]\n", p.category, tomlKey(p.name))
+	}
+
+	// Collect the options set in the table in order of appearance
+	type setting struct {
+		key   string
+		line  int
+		value interface{}
+	}
+	settings := make([]setting, 0, len(p.table.Fields))
+	for key, val := range p.table.Fields {
+		switch v := val.(type) {
+		case *ast.KeyValue:
+			settings = append(settings, setting{key, v.Line, val})
+		case *ast.Table:
+			settings = append(settings, setting{key, v.Line, val})
+		case []*ast.Table:
+			if len(v) > 0 {
+				settings = append(settings, setting{key, v[0].Line, val})
+			}
+		}
+	}
+	sort.Slice(settings, func(i, j int) bool {
+		if settings[i].line != settings[j].line {
+			return settings[i].line < settings[j].line
+		}
+		return settings[i].key < settings[j].key
+	})
+
+	// Use the values of the plugin for the options set, as the plugin might
+	// modify or convert the values, falling back to the table for options not
+	// handled by the plugin itself, e.g. filters or parser options.
+	options := structOptions(reflect.ValueOf(p.plugin))
+	used := make([]bool, len(options))
+	for _, s := range settings {
+		source := p.location.Source
+		if src, found := c.templateSource[s.value]; found {
+			source = src
+		}
+		comment := Location{Source: source, Line: s.line}.String()
+
+		var value interface{}
+		var found bool
+		for i, option := range options {
+			if !option.matches(s.key) {
+				continue
+			}
+			used[i] = true
+			if option.value.Type() == secretType {
+				value, found = redactSecret(s.value), true
+			} else {
+				value, found = showValue(option.value)
+			}
+			break
+		}
+		if !found {
+			v, err := tomlValue(tableValue(s.value))
+			if err != nil {
+				return err
+			}
+			value = v
+		}
+		if err := writeShowOption(buf, s.key, value, comment); err != nil {
+			return err
+		}
+	}
+
+	// Add the options not set with their default values
+	for i, option := range options {
+		if used[i] || option.deprecated {
+			continue
+		}
+		value, ok := showValue(option.value)
+		if !ok {
+			continue
+		}
+		if err := writeShowOption(buf, option.key, value, "default"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeShowOption(buf *bytes.Buffer, key string, value interface{}, comment string) error {
+	buf.WriteString("  " + tomlKey(key) + " = ")
+	if err := writeTOMLValue(buf, value); err != nil {
+		return fmt.Errorf("showing option %q failed: %w", key, err)
+	}
+	buf.WriteString("  # " + comment + "\n")
+	return nil
+}
+
+// tableValue returns the value of a table field
+func tableValue(val interface{}) interface{} {
+	if kv, ok := val.(*ast.KeyValue); ok {
+		return kv.Value
+	}
+	return val
+}
+
+// redactSecret replaces all parts of the secret except references to
+// secret-stores
+func redactSecret(val interface{}) string {
+	str, ok := tableValue(val).(*ast.String)
+	if !ok {
+		return redacted
+	}
+
+	var b strings.Builder
+	var last int
+	for _, match := range secretPattern.FindAllStringIndex(str.Value, -1) {
+		if match[0] > last {
+			b.WriteString(redacted)
+		}
+		b.WriteString(str.Value[match[0]:match[1]])
+		last = match[1]
+	}
+	if last < len(str.Value) {
+		b.WriteString(redacted)
+	}
+	return b.String()
+}
+
+// structOption is an option of a plugin or settings struct
+type structOption struct {
+	key        string
+	tagged     bool
+	deprecated bool
+	value      reflect.Value
+}
+
+// matches returns true if the given key is decoded into the option
+func (o *structOption) matches(key string) bool {
+	if o.tagged {
+		return key == o.key
+	}
+	return toml.DefaultConfig.NormFieldName(nil, key) == toml.DefaultConfig.NormFieldName(nil, o.key)
+}
+
+// structOptions returns the options of the given struct in order of their
+// definition including the options of embedded structs
+func structOptions(v reflect.Value) []structOption {
+	for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return nil
+	}
+
+	t := v.Type()
+	options := make([]structOption, 0, t.NumField())
+	for i := range t.NumField() {
+		field := t.Field(i)
+		tag, _, _ := strings.Cut(field.Tag.Get("toml"), ",")
+		if tag == "-" {
+			continue
+		}
+		if field.Anonymous && tag == "" {
+			options = append(options, structOptions(v.Field(i))...)
+			continue
+		}
+		if !field.IsExported() {
+			continue
+		}
+		key := tag
+		if key == "" {
+			key = toml.DefaultConfig.FieldToKey(t, field.Name)
+		}
+		options = append(options, structOption{
+			key:        key,
+			tagged:     tag != "",
+			deprecated: field.Tag.Get("deprecated") != "",
+			value:      v.Field(i),
+		})
+	}
+	return options
+}
+
+// showValue converts the value of an option for writing it as TOML and
+// returns false if the value cannot be represented in the configuration
+func showValue(v reflect.Value) (interface{}, bool) {
+	if !v.IsValid() {
+		return nil, false
+	}
+
+	switch v.Type() {
+	case durationType, timeDurationType:
+		return time.Duration(v.Int()).String(), true
+	case sizeType:
+		return v.Int(), true
+	case secretType:
+		if v.CanAddr() && v.Addr().Interface().(*Secret).Empty() {
+			return "", true
+		}
+		return redacted, true
+	}
+	if v.CanInterface() && v.Kind() != reflect.Pointer {
+		if m, ok := v.Interface().(encoding.TextMarshaler); ok {
+			if text, err := m.MarshalText(); err == nil {
+				return string(text), true
+			}
+		}
+	}
+
+	switch v.Kind() {
+	case reflect.Bool:
+		return v.Bool(), true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int(), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return v.Uint(), true
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), true
+	case reflect.String:
+		return v.String(), true
+	case reflect.Slice, reflect.Array:
+		values := make([]interface{}, 0, v.Len())
+		for i := range v.Len() {
+			value, ok := showValue(v.Index(i))
+			if !ok {
+				return nil, false
+			}
+			values = append(values, value)
+		}
+		return values, true
+	case reflect.Map:
+		keys := v.MapKeys()
+		sort.Slice(keys, func(i, j int) bool {
+			return fmt.Sprint(keys[i].Interface()) < fmt.Sprint(keys[j].Interface())
+		})
+		table := make(yaml.MapSlice, 0, len(keys))
+		for _, k := range keys {
+			value, ok := showValue(v.MapIndex(k))
+			if !ok {
+				return nil, false
+			}
+			table = append(table, yaml.MapItem{Key: fmt.Sprint(k.Interface()), Value: value})
+		}
+		return table, true
+	case reflect.Struct:
+		options := structOptions(v)
+		table := make(yaml.MapSlice, 0, len(options))
+		for _, option := range options {
+			if value, ok := showValue(option.value); ok {
+				table = append(table, yaml.MapItem{Key: option.key, Value: value})
+			}
+		}
+		return table, true
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			return nil, false
+		}
+		return showValue(v.Elem())
+	}
+	return nil, false
+}
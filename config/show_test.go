@@ -0,0 +1,62 @@
+package config_test
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/influxdata/telegraf/config"
+)
+
+func TestConfig_Show(t *testing.T) {
+	t.Setenv("SHOW_DC", "eu")
+
+	c := config.NewConfig()
+	require.NoError(t, c.LoadAll(filepath.Join("testdata", "show.toml")))
+
+	var buf bytes.Buffer
+	require.NoError(t, c.Show(&buf))
+	actual := buf.String()
+
+	source := filepath.Join("testdata", "show.toml")
+	expected := []string{
+		"[global_tags]\n  dc = \"eu\"  # " + source + ":2\n",
+		"[agent]\n  interval = \"5s\"  # " + source + ":5\n  round_interval = true  # default\n",
+		"  omit_hostname = true  # " + source + ":6\n",
+		"# " + source + ":8\n[[secretstores.mockup]]\n  id = \"store\"  # " + source + ":9\n",
+		"# " + source + ":12\n[[inputs.memcached]]\n",
+		"  servers = [\"localhost\"]  # " + source + ":13\n",
+		"  password = \"<redacted>@{store:password}\"  # " + source + ":14\n",
+		"  interval = \"1s\"  # " + source + ":15\n",
+		"  tags = {role = \"cache\"}  # " + source + ":16\n",
+		"  write_timeout = \"0s\"  # default\n",
+		"  max_body_size = 0  # default\n",
+	}
+	for _, e := range expected {
+		require.Contains(t, actual, e)
+	}
+	require.NotContains(t, actual, "user:")
+}
+
+func TestConfig_ShowTemplates(t *testing.T) {
+	c := config.NewConfig()
+	require.NoError(t, c.LoadAll(filepath.Join("testdata", "include", "main.toml")))
+
+	var buf bytes.Buffer
+	require.NoError(t, c.Show(&buf))
+	actual := buf.String()
+
+	// Options of templates refer to the file defining the template
+	main := filepath.Join("testdata", "include", "main.toml")
+	templates := filepath.Join("testdata", "include", "common", "templates.toml")
+	expected := []string{
+		"# " + main + ":10\n[[inputs.memcached]]\n  interval = \"30s\"  # " + templates + ":2\n",
+		"  servers = [\"localhost\"]  # " + templates + ":8\n",
+		"  tags = {env = \"prod\", role = \"cache\"}  # " + main + ":12\n",
+	}
+	for _, e := range expected {
+		require.Contains(t, actual, e)
+	}
+}
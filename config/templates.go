@@ -0,0 +1,219 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/influxdata/toml/ast"
+)
+
+// templateCategories are the plugin categories supporting templates
+var templateCategories = []string{"inputs", "outputs", "processors", "aggregators", "secretstores"}
+
+// loadIncludes loads the configuration files listed in the "include" setting
+// before the plugins of the including file. Relative paths are resolved
+// against the location of the including file and local paths may contain
+// glob patterns. Files included multiple times are only loaded once.
+func (c *Config) loadIncludes(tbl *ast.Table, path string) error {
+	if _, found := tbl.Fields["include"]; !found {
+		return nil
+	}
+	includes := c.getFieldStringSlice(tbl, "include")
+	if c.hasErrs() {
+		return c.firstErr()
+	}
+
+	c.includeStack = append(c.includeStack, path)
+	defer func() {
+		c.includeStack = c.includeStack[:len(c.includeStack)-1]
+	}()
+
+	for _, include := range includes {
+		locations, err := resolveInclude(path, include)
+		if err != nil {
+			return fmt.Errorf("resolving include %q failed: %w", include, err)
+		}
+
+		for _, location := range locations {
+			if slices.Contains(c.includeStack, location) {
+				return fmt.Errorf("include cycle detected for %q", location)
+			}
+			key := location
+			if !fetchURLRe.MatchString(location) {
+				if abs, err := filepath.Abs(location); err == nil {
+					key = abs
+				}
+			}
+			if c.included[key] {
+				log.Printf("D! Skipping already included config: %s", location)
+				continue
+			}
+			if c.included == nil {
+				c.included = make(map[string]bool)
+			}
+			c.included[key] = true
+
+			if !c.Agent.Quiet {
+				log.Printf("I! Loading included config: %s", location)
+			}
+			data, _, err := LoadConfigFileWithRetries(location, c.Agent.ConfigURLRetryAttempts)
+			if err != nil {
+				return fmt.Errorf("loading included file %s failed: %w", location, err)
+			}
+			if err := c.LoadConfigData(data, location); err != nil {
+				return fmt.Errorf("loading included file %s failed: %w", location, err)
+			}
+		}
+	}
+	return nil
+}
+
+// resolveInclude returns the locations of the given include relative to the
+// location of the including file
+func resolveInclude(base, include string) ([]string, error) {
+	if fetchURLRe.MatchString(include) {
+		return []string{include}, nil
+	}
+
+	// Resolve includes of remote files relative to the URL
+	if fetchURLRe.MatchString(base) {
+		u, err := url.Parse(base)
+		if err != nil {
+			return nil, err
+		}
+		ref, err := url.Parse(include)
+		if err != nil {
+			return nil, err
+		}
+		return []string{u.ResolveReference(ref).String()}, nil
+	}
+
+	location := include
+	if !filepath.IsAbs(include) && base != "" {
+		location = filepath.Join(filepath.Dir(base), include)
+	}
+	if !strings.ContainsAny(location, "*?[") {
+		return []string{location}, nil
+	}
+
+	matches, err := filepath.Glob(location)
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(matches)
+	return matches, nil
+}
+
+// collectTemplates stores the plugin templates defined in the "templates"
+// table for use in the current and all following files
+func (c *Config) collectTemplates(tbl *ast.Table, path string) error {
+	val, found := tbl.Fields["templates"]
+	if !found {
+		return nil
+	}
+	subTable, ok := val.(*ast.Table)
+	if !ok {
+		return errors.New("invalid configuration, error parsing templates table")
+	}
+
+	for name, val := range subTable.Fields {
+		template, ok := val.(*ast.Table)
+		if !ok {
+			return fmt.Errorf("invalid configuration, error parsing template %q as table", name)
+		}
+		if _, found := c.templates[name]; found {
+			return fmt.Errorf("duplicate template %q", name)
+		}
+		c.templates[name] = template
+		c.addTemplateSource(path, template)
+	}
+	return nil
+}
+
+// applyTemplates merges the templates referenced by the "extends" setting of
+// the plugins into the plugin settings
+func (c *Config) applyTemplates(tbl *ast.Table) error {
+	for _, category := range templateCategories {
+		val, found := tbl.Fields[category]
+		if !found {
+			continue
+		}
+		subTable, ok := val.(*ast.Table)
+		if !ok {
+			continue
+		}
+
+		for pluginName, pluginVal := range subTable.Fields {
+			var tables []*ast.Table
+			switch v := pluginVal.(type) {
+			case *ast.Table:
+				tables = []*ast.Table{v}
+			case []*ast.Table:
+				tables = v
+			}
+			for _, t := range tables {
+				if err := c.extend(t, nil); err != nil {
+					return fmt.Errorf("error parsing %s.%s, line %d: %w", category, pluginName, t.Line, err)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// extend merges the template referenced by the "extends" setting into the
+// given table. Settings of the table take precedence over the template
+// settings and sub-tables are merged. Templates can extend other templates.
+func (c *Config) extend(tbl *ast.Table, seen []string) error {
+	val, found := tbl.Fields["extends"]
+	if !found {
+		return nil
+	}
+	kv, ok := val.(*ast.KeyValue)
+	if !ok {
+		return errors.New(`"extends" must be a template name`)
+	}
+	str, ok := kv.Value.(*ast.String)
+	if !ok {
+		return errors.New(`"extends" must be a template name`)
+	}
+	name := str.Value
+
+	if slices.Contains(seen, name) {
+		return fmt.Errorf("template cycle detected for %q", name)
+	}
+	template, found := c.templates[name]
+	if !found {
+		return fmt.Errorf("undefined template %q", name)
+	}
+	if err := c.extend(template, append(seen, name)); err != nil {
+		return err
+	}
+
+	delete(tbl.Fields, "extends")
+	mergeTable(tbl, template)
+	return nil
+}
+
+// mergeTable adds the fields of src not present in dst to dst and merges
+// sub-tables present in both
+func mergeTable(dst, src *ast.Table) {
+	for key, srcVal := range src.Fields {
+		dstVal, found := dst.Fields[key]
+		if !found {
+			dst.Fields[key] = srcVal
+			continue
+		}
+		dstTable, dstOK := dstVal.(*ast.Table)
+		srcTable, srcOK := srcVal.(*ast.Table)
+		if dstOK && srcOK {
+			mergeTable(dstTable, srcTable)
+		}
+	}
+}
@@ -21,3 +21,27 @@ type ServiceInput interface {
 	// to the accumulator before returning.
 	Stop()
 }
+
+// BackpressureInput is an optional interface for service inputs able to pause
+// consuming from their source, e.g. a message broker. The agent signals
+// backpressure if the outputs fed by the input cannot keep up, so the data is
+// retained upstream instead of being dropped by Telegraf.
+type BackpressureInput interface {
+	ServiceInput
+
+	// SetBackpressure is called with true if the input should pause consuming
+	// and with false if it should resume. The function must not block.
+	SetBackpressure(active bool)
+}
+
+// ReconnectableInput is an optional interface for service inputs able to
+// reconnect to their source while running, e.g. to pick up rotated
+// credentials. Service inputs not implementing it are not reconnected.
+type ReconnectableInput interface {
+	ServiceInput
+
+	// Reconnect closes the connection to the source and connects again with
+	// the current settings. The function might be called concurrently to
+	// Gather but not to Start or Stop.
+	Reconnect() error
+}
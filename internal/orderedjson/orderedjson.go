@@ -0,0 +1,70 @@
+// Package orderedjson encodes YAML documents as JSON keeping the order of the
+// keys, which is lost when converting to maps for the standard encoder.
+package orderedjson
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+// MarshalIndent encodes the value as indented JSON terminated by a newline.
+// The keys of yaml.MapSlice values are written in their original order.
+func MarshalIndent(value interface{}, indent string) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := write(&buf, value); err != nil {
+		return nil, err
+	}
+	var out bytes.Buffer
+	if err := json.Indent(&out, buf.Bytes(), "", indent); err != nil {
+		return nil, err
+	}
+	out.WriteByte('\n')
+	return out.Bytes(), nil
+}
+
+// write writes the value as compact JSON keeping the order of the keys
+func write(buf *bytes.Buffer, value interface{}) error {
+	switch v := value.(type) {
+	case yaml.MapSlice:
+		buf.WriteByte('{')
+		for i, item := range v {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			key, err := json.Marshal(fmt.Sprint(item.Key))
+			if err != nil {
+				return err
+			}
+			buf.Write(key)
+			buf.WriteByte(':')
+			if err := write(buf, item.Value); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte('}')
+	case []interface{}:
+		buf.WriteByte('[')
+		for i, e := range v {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			if err := write(buf, e); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte(']')
+	case time.Time:
+		return write(buf, v.Format(time.RFC3339Nano))
+	default:
+		octets, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		buf.Write(octets)
+	}
+	return nil
+}
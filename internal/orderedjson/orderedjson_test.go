@@ -0,0 +1,36 @@
+package orderedjson
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+)
+
+func TestMarshalIndent(t *testing.T) {
+	doc := yaml.MapSlice{
+		{Key: "zulu", Value: 1},
+		{Key: "alpha", Value: []interface{}{"a", true}},
+		{Key: "nested", Value: yaml.MapSlice{
+			{Key: "time", Value: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)},
+			{Key: "beta", Value: nil},
+		}},
+	}
+
+	expected := `{
+  "zulu": 1,
+  "alpha": [
+    "a",
+    true
+  ],
+  "nested": {
+    "time": "2024-01-02T03:04:05Z",
+    "beta": null
+  }
+}
+`
+	actual, err := MarshalIndent(doc, "  ")
+	require.NoError(t, err)
+	require.Equal(t, expected, string(actual))
+}
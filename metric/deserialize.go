@@ -25,8 +25,9 @@ var (
 )
 
 type serializedMetric struct {
-	M   telegraf.Metric
-	TID telegraf.TrackingID
+	M      telegraf.Metric
+	TID    telegraf.TrackingID
+	Traced bool
 }
 
 func ToBytes(m telegraf.Metric) ([]byte, error) {
@@ -36,6 +37,7 @@ func ToBytes(m telegraf.Metric) ([]byte, error) {
 	} else {
 		sm.M = m
 	}
+	sm.Traced = Traced(m)
 
 	if tm, ok := m.(telegraf.TrackingMetric); ok {
 		sm.TID = tm.TrackingID()
@@ -63,6 +65,9 @@ func FromBytes(b []byte) (telegraf.Metric, error) {
 	}
 
 	m := sm.M
+	if sm.Traced {
+		SetTraced(m)
+	}
 	if sm.TID != 0 {
 		mu.Lock()
 		td := trackingStore[sm.TID]
@@ -83,3 +88,20 @@ func FromBytes(b []byte) (telegraf.Metric, error) {
 	}
 	return m, nil
 }
+
+// FromBytesWithoutTracking deserializes the given bytes into a metric. In
+// contrast to FromBytes, tracking information is neither looked up nor
+// released, so the returned metric is never a tracking metric.
+func FromBytesWithoutTracking(b []byte) (telegraf.Metric, error) {
+	var sm *serializedMetric
+	if err := gob.NewDecoder(bytes.NewBuffer(b)).Decode(&sm); err != nil {
+		return nil, fmt.Errorf("failed to decode metric from bytes: %w", err)
+	}
+	if sm == nil || sm.M == nil {
+		return nil, errors.New("no metric found in data")
+	}
+	if sm.Traced {
+		SetTraced(sm.M)
+	}
+	return sm.M, nil
+}
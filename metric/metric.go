@@ -16,6 +16,9 @@ type metric struct {
 	MetricTime   time.Time
 
 	MetricType telegraf.ValueType
+
+	// Marks the metric to be recorded by the pipeline tracer
+	traced bool
 }
 
 func New(
@@ -73,6 +76,7 @@ func FromMetric(other telegraf.Metric) telegraf.Metric {
 		MetricFields: make([]*telegraf.Field, len(other.FieldList())),
 		MetricTime:   other.Time(),
 		MetricType:   other.Type(),
+		traced:       Traced(other),
 	}
 
 	for i, tag := range other.TagList() {
@@ -253,6 +257,7 @@ func (m *metric) Copy() telegraf.Metric {
 		MetricFields: make([]*telegraf.Field, len(m.MetricFields)),
 		MetricTime:   m.MetricTime,
 		MetricType:   m.MetricType,
+		traced:       m.traced,
 	}
 
 	for i, tag := range m.MetricTags {
@@ -385,3 +390,28 @@ func convertField(v interface{}) interface{} {
 	}
 	return nil
 }
+
+// SetTraced marks the metric to be recorded by the pipeline tracer. The mark
+// is kept when copying or serializing the metric. Metrics not created by this
+// package cannot be marked.
+func SetTraced(m telegraf.Metric) {
+	if raw, ok := unwrap(m).(*metric); ok {
+		raw.traced = true
+	}
+}
+
+// Traced returns true if the metric was marked using SetTraced.
+func Traced(m telegraf.Metric) bool {
+	raw, ok := unwrap(m).(*metric)
+	return ok && raw.traced
+}
+
+func unwrap(m telegraf.Metric) telegraf.Metric {
+	for {
+		um, ok := m.(telegraf.UnwrappableMetric)
+		if !ok {
+			return m
+		}
+		m = um.Unwrap()
+	}
+}
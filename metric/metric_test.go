@@ -326,3 +326,27 @@ func TestValueType(t *testing.T) {
 
 	require.Equal(t, telegraf.Gauge, m.Type())
 }
+
+func TestTracedKeptOnCopy(t *testing.T) {
+	m := New("cpu", nil, map[string]interface{}{"value": 42}, time.Unix(0, 0))
+	require.False(t, Traced(m))
+	SetTraced(m)
+	require.True(t, Traced(m))
+	require.True(t, Traced(m.Copy()))
+	require.True(t, Traced(FromMetric(m)))
+
+	Init()
+	buf, err := ToBytes(m)
+	require.NoError(t, err)
+	restored, err := FromBytes(buf)
+	require.NoError(t, err)
+	require.True(t, Traced(restored))
+	restored, err = FromBytesWithoutTracking(buf)
+	require.NoError(t, err)
+	require.True(t, Traced(restored))
+
+	// The mark is kept for tracking metrics as well
+	tm, _ := WithTracking(m, func(telegraf.DeliveryInfo) {})
+	require.True(t, Traced(tm))
+	require.True(t, Traced(tm.Copy()))
+}
@@ -3,6 +3,7 @@ package models
 import (
 	"fmt"
 	"sync"
+	"time"
 
 	"github.com/influxdata/telegraf"
 	"github.com/influxdata/telegraf/metric"
@@ -94,8 +95,10 @@ type BufferStats struct {
 	BufferLimit     selfstat.Stat
 }
 
-// NewBuffer returns a new empty Buffer with the given capacity.
-func NewBuffer(name, id, alias string, capacity int, strategy, path string) (Buffer, error) {
+// NewBuffer returns a new empty Buffer with the given capacity. The size limit
+// in bytes and the maximum age of metrics only apply to the disk part of the
+// buffer.
+func NewBuffer(name, id, alias string, capacity int, strategy, path string, sizeLimit int64, maxAge time.Duration) (Buffer, error) {
 	registerGob()
 
 	bs := NewBufferStats(name, alias, capacity)
@@ -104,7 +107,9 @@ func NewBuffer(name, id, alias string, capacity int, strategy, path string) (Buf
 	case "", "memory":
 		return NewMemoryBuffer(capacity, bs)
 	case "disk":
-		return NewDiskBuffer(name, id, path, bs)
+		return NewDiskBuffer(name, id, path, sizeLimit, maxAge, bs)
+	case "overflow":
+		return NewOverflowBuffer(name, id, path, capacity, sizeLimit, maxAge, bs)
 	}
 	return nil, fmt.Errorf("invalid buffer strategy %q", strategy)
 }
@@ -4,10 +4,12 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"os"
 	"path/filepath"
 	"slices"
 	"sort"
 	"sync"
+	"time"
 
 	"github.com/tidwall/wal"
 
@@ -22,6 +24,17 @@ type DiskBuffer struct {
 	file *wal.Log
 	path string
 
+	// Limits of the buffer, zero means unlimited
+	sizeLimit int64
+	maxAge    time.Duration
+
+	// Number of bytes occupied by the metrics currently in the buffer
+	size int64
+
+	// Sizes of the entries in the WAL file starting at the read index to not
+	// read the entries again when removing them
+	sizes []int64
+
 	batchFirst uint64 // Index of the first metric in the batch
 	batchSize  uint64 // Number of metrics currently in the batch
 
@@ -40,11 +53,23 @@ type DiskBuffer struct {
 	mask []int
 }
 
-func NewDiskBuffer(name, id, path string, stats BufferStats) (*DiskBuffer, error) {
+func NewDiskBuffer(name, id, path string, sizeLimit int64, maxAge time.Duration, stats BufferStats) (*DiskBuffer, error) {
 	filePath := filepath.Join(path, id)
 	walFile, err := wal.Open(filePath, nil)
 	if err != nil {
-		return nil, fmt.Errorf("failed to open wal file: %w", err)
+		// The WAL file might be corrupted e.g. after a crash. Move the file
+		// out of the way for later inspection and start with an empty buffer
+		// instead of failing the output.
+		target, qerr := quarantine(filePath)
+		if qerr != nil {
+			return nil, fmt.Errorf("failed to open wal file: %w; quarantining failed: %w", err, qerr)
+		}
+		log.Printf("E! Opening WAL file for plugin outputs.%s (%s) failed: %v; moved the file to %q", name, id, err, target)
+
+		walFile, err = wal.Open(filePath, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open wal file: %w", err)
+		}
 	}
 	//nolint:errcheck // cannot error here
 	if index, _ := walFile.FirstIndex(); index == 0 {
@@ -57,13 +82,197 @@ func NewDiskBuffer(name, id, path string, stats BufferStats) (*DiskBuffer, error
 		BufferStats: stats,
 		file:        walFile,
 		path:        filePath,
+		sizeLimit:   sizeLimit,
+		maxAge:      maxAge,
+	}
+	if err := buf.scan(name, id); err != nil {
+		buf.file.Close()
+		return nil, err
 	}
 	if buf.length() > 0 {
 		buf.originalEnd = buf.writeIndex()
 	}
+	buf.enforceSizeLimit()
+	buf.BufferSize.Set(int64(buf.length()))
 	return buf, nil
 }
 
+// scan checks all entries of the WAL file and determines the size of the
+// buffer. If unreadable entries are found, the WAL file is rewritten with the
+// readable entries only and the original file is quarantined.
+func (b *DiskBuffer) scan(name, id string) error {
+	if b.entries() == 0 {
+		return nil
+	}
+
+	var corrupted int
+	first, end := b.readIndex(), b.writeIndex()
+	for index := first; index < end; index++ {
+		data, err := b.file.Read(index)
+		if err == nil {
+			_, err = metric.FromBytesWithoutTracking(data)
+		}
+		if err != nil {
+			corrupted++
+			continue
+		}
+		b.size += int64(len(data))
+		b.sizes = append(b.sizes, int64(len(data)))
+	}
+	if corrupted == 0 {
+		return nil
+	}
+
+	target, err := b.rewrite()
+	if err != nil {
+		return fmt.Errorf("removing %d unreadable entries from wal file failed: %w", corrupted, err)
+	}
+	log.Printf("E! Removed %d unreadable entries from WAL file for plugin outputs.%s (%s); moved the original file to %q",
+		corrupted, name, id, target)
+	AgentMetricsDropped.Incr(int64(corrupted))
+	b.MetricsDropped.Incr(int64(corrupted))
+
+	return nil
+}
+
+// rewrite copies all readable entries of the WAL file to a new WAL file and
+// replaces the current file with the new one. The original file is moved to
+// the returned location.
+func (b *DiskBuffer) rewrite() (string, error) {
+	tmpPath := b.path + ".tmp"
+	if err := os.RemoveAll(tmpPath); err != nil {
+		return "", err
+	}
+	tmp, err := wal.Open(tmpPath, nil)
+	if err != nil {
+		return "", err
+	}
+
+	var written uint64
+	first, end := b.readIndex(), b.writeIndex()
+	for index := first; index < end; index++ {
+		data, err := b.file.Read(index)
+		if err != nil {
+			continue
+		}
+		if _, err := metric.FromBytesWithoutTracking(data); err != nil {
+			continue
+		}
+		written++
+		if err := tmp.Write(written, data); err != nil {
+			tmp.Close()
+			return "", err
+		}
+	}
+	if err := tmp.Close(); err != nil {
+		return "", err
+	}
+
+	if err := b.file.Close(); err != nil {
+		return "", err
+	}
+	target, err := quarantine(b.path)
+	if err != nil {
+		return "", err
+	}
+	if err := os.Rename(tmpPath, b.path); err != nil {
+		return "", err
+	}
+	b.file, err = wal.Open(b.path, nil)
+	return target, err
+}
+
+// prepend inserts the given metrics in front of the metrics currently in the
+// buffer. As the WAL file can only be appended to, the file is rewritten with
+// the given metrics followed by all remaining entries. Must not be called
+// during a transaction.
+func (b *DiskBuffer) prepend(metrics []telegraf.Metric) error {
+	b.Lock()
+	defer b.Unlock()
+
+	if len(metrics) == 0 {
+		return nil
+	}
+
+	tmpPath := b.path + ".tmp"
+	if err := os.RemoveAll(tmpPath); err != nil {
+		return err
+	}
+	tmp, err := wal.Open(tmpPath, nil)
+	if err != nil {
+		return err
+	}
+
+	var written uint64
+	var size int64
+	sizes := make([]int64, 0, len(metrics)+b.length())
+	write := func(data []byte) error {
+		written++
+		size += int64(len(data))
+		sizes = append(sizes, int64(len(data)))
+		return tmp.Write(written, data)
+	}
+	for _, m := range metrics {
+		data, err := metric.ToBytes(m)
+		if err != nil {
+			tmp.Close()
+			return err
+		}
+		if err := write(data); err != nil {
+			tmp.Close()
+			return err
+		}
+	}
+	if b.length() > 0 {
+		first, end := b.readIndex(), b.writeIndex()
+		for index := first; index < end; index++ {
+			if slices.Contains(b.mask, int(index-first)) {
+				continue
+			}
+			data, err := b.file.Read(index)
+			if err != nil {
+				b.dropCorrupted()
+				continue
+			}
+			if err := write(data); err != nil {
+				tmp.Close()
+				return err
+			}
+		}
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	if err := b.file.Close(); err != nil {
+		return err
+	}
+	if err := os.RemoveAll(b.path); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpPath, b.path); err != nil {
+		return err
+	}
+	if b.file, err = wal.Open(b.path, nil); err != nil {
+		return err
+	}
+
+	b.size = size
+	b.sizes = sizes
+	b.mask = b.mask[:0]
+	b.isEmpty = false
+	b.originalEnd = 0
+	b.BufferSize.Set(int64(b.length()))
+	return nil
+}
+
+// quarantine moves the WAL file at the given path out of the way and returns
+// the new location of the file.
+func quarantine(path string) (string, error) {
+	target := fmt.Sprintf("%s.corrupt-%d", path, time.Now().UnixNano())
+	return target, os.Rename(path, target)
+}
+
 func (b *DiskBuffer) Len() int {
 	b.Lock()
 	defer b.Unlock()
@@ -115,6 +324,7 @@ func (b *DiskBuffer) Add(metrics ...telegraf.Metric) int {
 		// as soon as a new metric is added, if this was empty, try to flush the "empty" metric out
 		b.handleEmptyFile()
 	}
+	dropped += b.enforceSizeLimit()
 	b.BufferSize.Set(int64(b.length()))
 	return dropped
 }
@@ -126,6 +336,8 @@ func (b *DiskBuffer) addSingleMetric(m telegraf.Metric) bool {
 	}
 	err = b.file.Write(b.writeIndex(), data)
 	if err == nil {
+		b.size += int64(len(data))
+		b.sizes = append(b.sizes, int64(len(data)))
 		b.metricAdded()
 		return true
 	}
@@ -142,24 +354,33 @@ func (b *DiskBuffer) BeginTransaction(batchSize int) *Transaction {
 	b.batchFirst = b.readIndex()
 	b.batchSize = 0
 
+	// Metrics are not ordered by time, so check the age of each metric
+	var cutoff time.Time
+	if b.maxAge > 0 {
+		cutoff = time.Now().Add(-b.maxAge)
+	}
+
 	metrics := make([]telegraf.Metric, 0, batchSize)
 	offsets := make([]int, 0, batchSize)
-	readIndex := b.batchFirst
 	endIndex := b.writeIndex()
-	offset := 0
-	for batchSize > 0 && readIndex < endIndex {
-		data, err := b.file.Read(readIndex)
-		if err != nil {
-			panic(err)
-		}
-		readIndex++
-		offset++
-
+	var masked bool
+	for index := b.batchFirst; batchSize > 0 && index < endIndex; index++ {
+		offset := int(index - b.batchFirst)
 		if slices.Contains(b.mask, offset) {
 			// Metric is masked by a previous write and is scheduled for removal
 			continue
 		}
 
+		data, err := b.file.Read(index)
+		if err != nil {
+			// Unreadable entry, drop it instead of failing the output
+			log.Printf("E! Reading metric at index %d failed: %v; dropping metric", index, err)
+			b.dropCorrupted()
+			b.mask = append(b.mask, offset)
+			masked = true
+			continue
+		}
+
 		// Validate that a tracking metric is from this instance of telegraf and skip ones from older instances.
 		// A tracking metric can be skipped here because metric.Accept() is only called once data is successfully
 		// written to an output, so any tracking metrics from older instances can be dropped and reacquired to
@@ -170,16 +391,26 @@ func (b *DiskBuffer) BeginTransaction(batchSize int) *Transaction {
 		//                     as it was here when we opened the wal file in this instance.
 		m, err := metric.FromBytes(data)
 		if err != nil {
-			if errors.Is(err, metric.ErrSkipTracking) {
-				// could not look up tracking information for metric, skip
-				continue
+			if !errors.Is(err, metric.ErrSkipTracking) {
+				// Undecodable entry, drop it instead of failing the output
+				log.Printf("E! Decoding metric at index %d failed: %v; dropping metric", index, err)
+				b.dropCorrupted()
 			}
-			// non-recoverable error in deserialization, abort
-			log.Printf("E! raw metric data: %v", data)
-			panic(err)
+			b.mask = append(b.mask, offset)
+			masked = true
+			continue
 		}
-		if _, ok := m.(telegraf.TrackingMetric); ok && readIndex < b.originalEnd {
+		if _, ok := m.(telegraf.TrackingMetric); ok && index < b.originalEnd {
 			// tracking metric left over from previous instance, skip
+			b.mask = append(b.mask, offset)
+			masked = true
+			continue
+		}
+		if !cutoff.IsZero() && m.Time().Before(cutoff) {
+			// Metric exceeds the maximum age, drop it instead of writing
+			b.metricDropped(m)
+			b.mask = append(b.mask, offset)
+			masked = true
 			continue
 		}
 
@@ -188,6 +419,16 @@ func (b *DiskBuffer) BeginTransaction(batchSize int) *Transaction {
 		b.batchSize++
 		batchSize--
 	}
+	if masked {
+		sort.Ints(b.mask)
+	}
+
+	if len(metrics) == 0 {
+		b.resetBatch()
+		b.removeMasked()
+		b.BufferSize.Set(int64(b.length()))
+		return &Transaction{}
+	}
 	return &Transaction{Batch: metrics, valid: true, state: offsets}
 }
 
@@ -223,56 +464,142 @@ func (b *DiskBuffer) EndTransaction(tx *Transaction) {
 
 	// Remove the metrics that are marked for removal from the front of the
 	// WAL file. All other metrics must be kept.
-	if len(b.mask) == 0 || b.mask[0] != 0 {
-		// Mask is empty or the first index is not the front of the file, so
-		// exit early as there is nothing to remove
-		return
-	}
+	b.resetBatch()
+	b.removeMasked()
+	b.enforceSizeLimit()
+	b.BufferSize.Set(int64(b.length()))
+}
 
-	// Determine up to which index we can remove the entries from the WAL file
-	var removeIdx int
+// removeMasked removes the consecutive range of masked metrics at the front of
+// the WAL file.
+func (b *DiskBuffer) removeMasked() {
+	var n int
 	for i, offset := range b.mask {
 		if offset != i {
 			break
 		}
-		removeIdx = offset
+		n = i + 1
+	}
+	b.removeFront(n)
+}
+
+// removeFront removes the given number of entries from the front of the WAL
+// file and updates the mask and the buffer size accordingly.
+func (b *DiskBuffer) removeFront(n int) {
+	if n <= 0 {
+		return
 	}
 
-	// Remove the metrics in front from the WAL file
-	b.isEmpty = b.entries()-removeIdx-1 <= 0
-	if b.isEmpty {
+	first := b.readIndex()
+	for _, size := range b.sizes[:min(n, len(b.sizes))] {
+		b.size -= size
+	}
+
+	if n >= b.entries() {
 		// WAL files cannot be fully empty but need to contain at least one
 		// item to not throw an error
-		if err := b.file.TruncateFront(b.writeIndex()); err != nil {
-			log.Printf("E! batch length: %d, first: %d, size: %d", len(tx.Batch), b.batchFirst, b.batchSize)
+		b.isEmpty = true
+		if err := b.file.TruncateFront(b.writeIndex() - 1); err != nil {
+			log.Printf("E! removing %d entries, first: %d", n, first)
 			panic(err)
 		}
+		b.mask = b.mask[:0]
+		b.sizes = b.sizes[:0]
 	} else {
-		if err := b.file.TruncateFront(b.batchFirst + uint64(removeIdx+1)); err != nil {
-			log.Printf("E! batch length: %d, first: %d, size: %d", len(tx.Batch), b.batchFirst, b.batchSize)
+		if err := b.file.TruncateFront(first + uint64(n)); err != nil {
+			log.Printf("E! removing %d entries, first: %d", n, first)
 			panic(err)
 		}
-	}
 
-	// Truncate the mask and update the relative offsets
-	b.mask = b.mask[:removeIdx]
-	for i := range b.mask {
-		b.mask[i] -= removeIdx
+		// Drop the removed offsets from the mask and update the relative
+		// offsets of the remaining ones
+		mask := b.mask[:0]
+		for _, offset := range b.mask {
+			if offset >= n {
+				mask = append(mask, offset-n)
+			}
+		}
+		b.mask = mask
+		b.sizes = slices.Delete(b.sizes, 0, n)
 	}
 
 	// check if the original end index is still valid, clear if not
 	if b.originalEnd < b.readIndex() {
 		b.originalEnd = 0
 	}
+}
 
-	b.resetBatch()
-	b.BufferSize.Set(int64(b.length()))
+// enforceSizeLimit evicts the oldest metrics until the buffer is within its
+// size limit and returns the number of evicted metrics. Metrics are not
+// evicted during a transaction as this would invalidate the batch.
+func (b *DiskBuffer) enforceSizeLimit() int {
+	if b.sizeLimit <= 0 || b.size <= b.sizeLimit || b.batchSize > 0 {
+		return 0
+	}
+
+	// Drop the metrics at the front of the buffer until enough space is
+	// freed. Metrics already removed by a previous transaction only need to
+	// be removed from the file. Unreadable metrics are always dropped.
+	excess := b.size - b.sizeLimit
+	var n, evicted int
+	first := b.readIndex()
+	for offset, size := range b.sizes {
+		if excess <= 0 {
+			break
+		}
+		excess -= size
+		n++
+		if slices.Contains(b.mask, offset) {
+			continue
+		}
+
+		// Restore the tracking information of the metric to notify about
+		// the metric being dropped
+		evicted++
+		data, err := b.file.Read(first + uint64(offset))
+		if err != nil {
+			b.dropCorrupted()
+			continue
+		}
+		if m, err := metric.FromBytes(data); err == nil {
+			b.metricDropped(m)
+		} else {
+			b.dropCorrupted()
+		}
+	}
+	b.removeFront(n)
+
+	return evicted
+}
+
+// dropCorrupted counts a metric dropped that cannot be restored from disk.
+func (b *DiskBuffer) dropCorrupted() {
+	AgentMetricsDropped.Incr(1)
+	b.MetricsDropped.Incr(1)
 }
 
 func (b *DiskBuffer) Stats() BufferStats {
 	return b.BufferStats
 }
 
+// DiskUsage returns the number of bytes occupied by the buffer's files.
+func (b *DiskBuffer) DiskUsage() int64 {
+	entries, err := os.ReadDir(b.path)
+	if err != nil {
+		return 0
+	}
+
+	var size int64
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil || info.IsDir() {
+			continue
+		}
+		size += info.Size()
+	}
+	return size
+}
+
 func (b *DiskBuffer) Close() error {
 	return b.file.Close()
 }
@@ -1,6 +1,7 @@
 package models
 
 import (
+	"os"
 	"path/filepath"
 	"testing"
 	"time"
@@ -19,7 +20,7 @@ func TestDiskBufferRetainsTrackingInformation(t *testing.T) {
 	var delivered int
 	mm, _ := metric.WithTracking(m, func(telegraf.DeliveryInfo) { delivered++ })
 
-	buf, err := NewBuffer("test", "123", "", 0, "disk", t.TempDir())
+	buf, err := NewBuffer("test", "123", "", 0, "disk", t.TempDir(), 0, 0)
 	require.NoError(t, err)
 	buf.Stats().MetricsAdded.Set(0)
 	buf.Stats().MetricsWritten.Set(0)
@@ -78,7 +79,7 @@ func TestDiskBufferTrackingDroppedFromOldWal(t *testing.T) {
 	walfile.Close()
 
 	// Create a buffer
-	buf, err := NewBuffer("123", "123", "", 0, "disk", path)
+	buf, err := NewBuffer("123", "123", "", 0, "disk", path, 0, 0)
 	require.NoError(t, err)
 	buf.Stats().MetricsAdded.Set(0)
 	buf.Stats().MetricsWritten.Set(0)
@@ -93,3 +94,184 @@ func TestDiskBufferTrackingDroppedFromOldWal(t *testing.T) {
 	}
 	testutil.RequireMetricsEqual(t, expected, tx.Batch)
 }
+
+func TestDiskBufferTruncatesWrittenMetrics(t *testing.T) {
+	buf, err := NewBuffer("test", "123", "", 0, "disk", t.TempDir(), 0, 0)
+	require.NoError(t, err)
+	defer buf.Close()
+
+	for i := range 10 {
+		buf.Add(metric.New("cpu", map[string]string{}, map[string]interface{}{"value": i}, time.Unix(int64(i), 0)))
+	}
+	diskBuf := buf.(*DiskBuffer)
+	require.Equal(t, 10, diskBuf.entries())
+
+	tx := buf.BeginTransaction(4)
+	tx.AcceptAll()
+	buf.EndTransaction(tx)
+	require.Equal(t, 6, buf.Len())
+	require.Equal(t, 6, diskBuf.entries())
+
+	tx = buf.BeginTransaction(10)
+	tx.AcceptAll()
+	buf.EndTransaction(tx)
+	require.Equal(t, 0, buf.Len())
+	require.Zero(t, diskBuf.size)
+}
+
+func TestDiskBufferSizeTracking(t *testing.T) {
+	path := t.TempDir()
+	buf, err := NewBuffer("test", "123", "", 0, "disk", path, 0, 0)
+	require.NoError(t, err)
+	diskBuf := buf.(*DiskBuffer)
+
+	// Compute the size of the remaining entries from disk
+	remaining := func() int64 {
+		var size int64
+		first, end := diskBuf.readIndex(), diskBuf.writeIndex()
+		for index := first; index < end; index++ {
+			data, err := diskBuf.file.Read(index)
+			require.NoError(t, err)
+			size += int64(len(data))
+		}
+		return size
+	}
+
+	for i := range 10 {
+		buf.Add(metric.New("cpu", map[string]string{}, map[string]interface{}{"value": i}, time.Unix(int64(i), 0)))
+	}
+	require.Equal(t, remaining(), diskBuf.size)
+
+	// Remove metrics from the front and in the middle of the buffer
+	tx := buf.BeginTransaction(4)
+	tx.Accept = []int{0, 2}
+	buf.EndTransaction(tx)
+	require.Equal(t, 8, buf.Len())
+	require.Equal(t, 9, diskBuf.entries())
+	require.Equal(t, remaining(), diskBuf.size)
+
+	tx = buf.BeginTransaction(3)
+	tx.AcceptAll()
+	buf.EndTransaction(tx)
+	require.Equal(t, 5, buf.Len())
+	require.Equal(t, 5, diskBuf.entries())
+	require.Equal(t, remaining(), diskBuf.size)
+	size := diskBuf.size
+	require.NoError(t, buf.Close())
+
+	// The size is restored when opening the buffer again
+	buf, err = NewBuffer("test", "123", "", 0, "disk", path, 0, 0)
+	require.NoError(t, err)
+	defer buf.Close()
+	require.Equal(t, size, buf.(*DiskBuffer).size)
+	require.Len(t, buf.(*DiskBuffer).sizes, 5)
+}
+
+func TestDiskBufferSizeLimit(t *testing.T) {
+	registerGob()
+
+	metrics := make([]telegraf.Metric, 0, 10)
+	for i := range 10 {
+		metrics = append(metrics, metric.New("cpu", map[string]string{}, map[string]interface{}{"value": i}, time.Unix(int64(i), 0)))
+	}
+	data, err := metric.ToBytes(metrics[0])
+	require.NoError(t, err)
+
+	buf, err := NewBuffer("test", "123", "", 0, "disk", t.TempDir(), int64(3*len(data)), 0)
+	require.NoError(t, err)
+	buf.Stats().MetricsDropped.Set(0)
+	defer buf.Close()
+
+	require.Equal(t, 7, buf.Add(metrics...))
+	require.Equal(t, 3, buf.Len())
+	require.Equal(t, int64(7), buf.Stats().MetricsDropped.Get())
+	require.Equal(t, int64(3*len(data)), buf.(*DiskBuffer).size)
+
+	// The newest metrics must be kept
+	tx := buf.BeginTransaction(10)
+	testutil.RequireMetricsEqual(t, metrics[7:], tx.Batch)
+	tx.AcceptAll()
+	buf.EndTransaction(tx)
+}
+
+func TestDiskBufferMaxAge(t *testing.T) {
+	// Metrics are not ordered by time, so old metrics behind a newer one
+	// must be dropped as well
+	now := time.Now()
+	metrics := []telegraf.Metric{
+		metric.New("cpu", map[string]string{}, map[string]interface{}{"value": 1}, now),
+		metric.New("cpu", map[string]string{}, map[string]interface{}{"value": 2}, now.Add(-3*time.Hour)),
+		metric.New("cpu", map[string]string{}, map[string]interface{}{"value": 3}, now),
+		metric.New("cpu", map[string]string{}, map[string]interface{}{"value": 4}, now.Add(-2*time.Hour)),
+	}
+
+	buf, err := NewBuffer("test", "123", "", 0, "disk", t.TempDir(), 0, time.Hour)
+	require.NoError(t, err)
+	buf.Stats().MetricsDropped.Set(0)
+	defer buf.Close()
+
+	buf.Add(metrics...)
+	require.Equal(t, 4, buf.Len())
+
+	tx := buf.BeginTransaction(10)
+	testutil.RequireMetricsEqual(t, []telegraf.Metric{metrics[0], metrics[2]}, tx.Batch)
+	require.Equal(t, int64(2), buf.Stats().MetricsDropped.Get())
+	tx.AcceptAll()
+	buf.EndTransaction(tx)
+	require.Equal(t, 0, buf.Len())
+}
+
+func TestDiskBufferCorruptedEntries(t *testing.T) {
+	metrics := []telegraf.Metric{
+		metric.New("cpu", map[string]string{}, map[string]interface{}{"value": 1}, time.Unix(1, 0)),
+		metric.New("cpu", map[string]string{}, map[string]interface{}{"value": 2}, time.Unix(2, 0)),
+	}
+
+	registerGob()
+
+	// Prefill the WAL file with valid and invalid entries
+	path := t.TempDir()
+	walfile, err := wal.Open(filepath.Join(path, "123"), nil)
+	require.NoError(t, err)
+	data, err := metric.ToBytes(metrics[0])
+	require.NoError(t, err)
+	require.NoError(t, walfile.Write(1, data))
+	require.NoError(t, walfile.Write(2, []byte("garbage")))
+	data, err = metric.ToBytes(metrics[1])
+	require.NoError(t, err)
+	require.NoError(t, walfile.Write(3, data))
+	require.NoError(t, walfile.Close())
+
+	// Create a buffer which should skip the corrupted entry
+	buf, err := NewBuffer("123", "123", "", 0, "disk", path, 0, 0)
+	require.NoError(t, err)
+	defer buf.Close()
+	require.Equal(t, 2, buf.Len())
+
+	tx := buf.BeginTransaction(10)
+	testutil.RequireMetricsEqual(t, metrics, tx.Batch)
+	tx.AcceptAll()
+	buf.EndTransaction(tx)
+
+	// The original file must be quarantined
+	quarantined, err := filepath.Glob(filepath.Join(path, "123.corrupt-*"))
+	require.NoError(t, err)
+	require.Len(t, quarantined, 1)
+}
+
+func TestDiskBufferCorruptedFile(t *testing.T) {
+	// Create a WAL segment with invalid content
+	path := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(path, "123"), 0750))
+	segment := filepath.Join(path, "123", "00000000000000000001")
+	require.NoError(t, os.WriteFile(segment, []byte("\xff\xff\xff\xff\xff\xff\xff\xff\xff\xff"), 0640))
+
+	buf, err := NewBuffer("123", "123", "", 0, "disk", path, 0, 0)
+	require.NoError(t, err)
+	defer buf.Close()
+	require.Equal(t, 0, buf.Len())
+
+	quarantined, err := filepath.Glob(filepath.Join(path, "123.corrupt-*"))
+	require.NoError(t, err)
+	require.Len(t, quarantined, 1)
+}
@@ -0,0 +1,175 @@
+package models
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/influxdata/telegraf"
+)
+
+// OverflowBuffer keeps metrics in memory and only spills metrics to disk if
+// the in-memory buffer is full. Metrics on disk are always newer than the
+// metrics in memory, so new metrics are added to disk as long as there are
+// metrics on disk to preserve the order of metrics.
+type OverflowBuffer struct {
+	BufferStats
+	sync.Mutex
+
+	memory *MemoryBuffer
+	disk   *DiskBuffer
+}
+
+// overflowTransaction holds the transactions of the underlying buffers
+type overflowTransaction struct {
+	memory *Transaction
+	disk   *Transaction
+}
+
+func NewOverflowBuffer(name, id, path string, capacity int, sizeLimit int64, maxAge time.Duration, stats BufferStats) (*OverflowBuffer, error) {
+	memory, err := NewMemoryBuffer(capacity, stats)
+	if err != nil {
+		return nil, err
+	}
+	disk, err := NewDiskBuffer(name, id, path, sizeLimit, maxAge, stats)
+	if err != nil {
+		return nil, err
+	}
+
+	buf := &OverflowBuffer{
+		BufferStats: stats,
+		memory:      memory,
+		disk:        disk,
+	}
+	buf.BufferSize.Set(int64(buf.length()))
+	return buf, nil
+}
+
+func (b *OverflowBuffer) Len() int {
+	b.Lock()
+	defer b.Unlock()
+
+	return b.length()
+}
+
+func (b *OverflowBuffer) length() int {
+	return b.memory.Len() + b.disk.Len()
+}
+
+// memoryLen returns the number of metrics kept in memory.
+func (b *OverflowBuffer) memoryLen() int {
+	return b.memory.Len()
+}
+
+func (b *OverflowBuffer) Add(metrics ...telegraf.Metric) int {
+	b.Lock()
+	defer b.Unlock()
+
+	dropped := 0
+	for i, m := range metrics {
+		// The length of the memory buffer includes the metrics of a running
+		// transaction, so kept metrics can always be restored.
+		if b.disk.Len() == 0 && b.memory.Len() < b.memory.cap {
+			dropped += b.memory.Add(m)
+			continue
+		}
+
+		// Spill the remaining metrics to disk
+		dropped += b.disk.Add(metrics[i:]...)
+		break
+	}
+
+	b.BufferSize.Set(int64(b.length()))
+	return dropped
+}
+
+func (b *OverflowBuffer) BeginTransaction(batchSize int) *Transaction {
+	b.Lock()
+	defer b.Unlock()
+
+	// Take the oldest metrics from memory first and only read from disk if
+	// the memory buffer is drained completely.
+	memoryTx := b.memory.BeginTransaction(batchSize)
+	diskTx := &Transaction{}
+	if len(memoryTx.Batch) < batchSize {
+		diskTx = b.disk.BeginTransaction(batchSize - len(memoryTx.Batch))
+	}
+
+	if len(memoryTx.Batch)+len(diskTx.Batch) == 0 {
+		b.BufferSize.Set(int64(b.length()))
+		return &Transaction{}
+	}
+
+	batch := make([]telegraf.Metric, 0, len(memoryTx.Batch)+len(diskTx.Batch))
+	batch = append(batch, memoryTx.Batch...)
+	batch = append(batch, diskTx.Batch...)
+
+	return &Transaction{
+		Batch: batch,
+		valid: true,
+		state: &overflowTransaction{memory: memoryTx, disk: diskTx},
+	}
+}
+
+func (b *OverflowBuffer) EndTransaction(tx *Transaction) {
+	// Ignore invalid transactions and make sure they can only be finished once
+	if !tx.valid {
+		return
+	}
+	tx.valid = false
+
+	// Distribute the accepted and rejected metrics to the transactions of the
+	// underlying buffers
+	state := tx.state.(*overflowTransaction)
+	split := len(state.memory.Batch)
+	for _, idx := range tx.Accept {
+		if idx < split {
+			state.memory.Accept = append(state.memory.Accept, idx)
+		} else {
+			state.disk.Accept = append(state.disk.Accept, idx-split)
+		}
+	}
+	for _, idx := range tx.Reject {
+		if idx < split {
+			state.memory.Reject = append(state.memory.Reject, idx)
+		} else {
+			state.disk.Reject = append(state.disk.Reject, idx-split)
+		}
+	}
+
+	b.Lock()
+	defer b.Unlock()
+
+	b.memory.EndTransaction(state.memory)
+	b.disk.EndTransaction(state.disk)
+	b.BufferSize.Set(int64(b.length()))
+}
+
+func (b *OverflowBuffer) Stats() BufferStats {
+	return b.BufferStats
+}
+
+// DiskUsage returns the number of bytes occupied by the spilled metrics.
+func (b *OverflowBuffer) DiskUsage() int64 {
+	return b.disk.DiskUsage()
+}
+
+// Close persists the metrics kept in memory to disk, in front of the already
+// spilled metrics, to not lose them on shutdown.
+func (b *OverflowBuffer) Close() error {
+	b.Lock()
+	defer b.Unlock()
+
+	var err error
+	if n := b.memory.Len(); n > 0 {
+		tx := b.memory.BeginTransaction(n)
+		if perr := b.disk.prepend(tx.Batch); perr != nil {
+			err = fmt.Errorf("persisting %d metrics failed: %w", n, perr)
+		}
+	}
+
+	if cerr := b.disk.Close(); cerr != nil && err == nil {
+		err = cerr
+	}
+	return err
+}
@@ -0,0 +1,114 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/metric"
+	"github.com/influxdata/telegraf/testutil"
+)
+
+func newOverflowTestMetrics(n int) []telegraf.Metric {
+	metrics := make([]telegraf.Metric, 0, n)
+	for i := range n {
+		metrics = append(metrics, metric.New("cpu", map[string]string{}, map[string]interface{}{"value": i}, time.Unix(int64(i), 0)))
+	}
+	return metrics
+}
+
+func TestOverflowBufferSpillsToDisk(t *testing.T) {
+	metrics := newOverflowTestMetrics(10)
+
+	buf, err := NewBuffer("test", "123", "", 4, "overflow", t.TempDir(), 0, 0)
+	require.NoError(t, err)
+	buf.Stats().MetricsDropped.Set(0)
+	defer buf.Close()
+
+	require.Zero(t, buf.Add(metrics[:3]...))
+	overflowBuf := buf.(*OverflowBuffer)
+	require.Equal(t, 3, overflowBuf.memoryLen())
+	require.Zero(t, overflowBuf.disk.Len())
+
+	// Metrics exceeding the memory capacity must not be dropped but spilled
+	require.Zero(t, buf.Add(metrics[3:]...))
+	require.Equal(t, 10, buf.Len())
+	require.Equal(t, 4, overflowBuf.memoryLen())
+	require.Equal(t, 6, overflowBuf.disk.Len())
+	require.Zero(t, buf.Stats().MetricsDropped.Get())
+
+	// Metrics must be drained in order across memory and disk
+	tx := buf.BeginTransaction(6)
+	testutil.RequireMetricsEqual(t, metrics[:6], tx.Batch)
+	tx.AcceptAll()
+	buf.EndTransaction(tx)
+	require.Equal(t, 4, buf.Len())
+	require.Zero(t, overflowBuf.memoryLen())
+
+	// New metrics must be added after the spilled ones to preserve the order
+	extra := metric.New("cpu", map[string]string{}, map[string]interface{}{"value": 10}, time.Unix(10, 0))
+	require.Zero(t, buf.Add(extra))
+	require.Zero(t, overflowBuf.memoryLen())
+
+	tx = buf.BeginTransaction(10)
+	testutil.RequireMetricsEqual(t, append(metrics[6:], extra), tx.Batch)
+	tx.AcceptAll()
+	buf.EndTransaction(tx)
+	require.Zero(t, buf.Len())
+
+	// Once the disk is drained, metrics are kept in memory again
+	require.Zero(t, buf.Add(metrics[0]))
+	require.Equal(t, 1, overflowBuf.memoryLen())
+	require.Zero(t, overflowBuf.disk.Len())
+}
+
+func TestOverflowBufferPartialReject(t *testing.T) {
+	metrics := newOverflowTestMetrics(6)
+
+	buf, err := NewBuffer("test", "123", "", 3, "overflow", t.TempDir(), 0, 0)
+	require.NoError(t, err)
+	defer buf.Close()
+
+	buf.Add(metrics...)
+
+	// Accept one metric from memory and one from disk, keep the others
+	tx := buf.BeginTransaction(6)
+	tx.Accept = []int{0, 4}
+	buf.EndTransaction(tx)
+	require.Equal(t, 4, buf.Len())
+
+	tx = buf.BeginTransaction(6)
+	testutil.RequireMetricsEqual(t, []telegraf.Metric{metrics[1], metrics[2], metrics[3], metrics[5]}, tx.Batch)
+	tx.AcceptAll()
+	buf.EndTransaction(tx)
+	require.Zero(t, buf.Len())
+}
+
+func TestOverflowBufferPersistsMemoryOnClose(t *testing.T) {
+	metrics := newOverflowTestMetrics(6)
+	path := t.TempDir()
+
+	buf, err := NewBuffer("test", "123", "", 3, "overflow", path, 0, 0)
+	require.NoError(t, err)
+	buf.Add(metrics...)
+
+	// Write the first metric to make sure only buffered metrics are persisted
+	tx := buf.BeginTransaction(1)
+	tx.AcceptAll()
+	buf.EndTransaction(tx)
+	require.NoError(t, buf.Close())
+
+	buf, err = NewBuffer("test", "123", "", 3, "overflow", path, 0, 0)
+	require.NoError(t, err)
+	defer buf.Close()
+	require.Equal(t, 5, buf.Len())
+	require.Zero(t, buf.(*OverflowBuffer).memoryLen())
+
+	tx = buf.BeginTransaction(10)
+	testutil.RequireMetricsEqual(t, metrics[1:], tx.Batch)
+	tx.AcceptAll()
+	buf.EndTransaction(tx)
+	require.Zero(t, buf.Len())
+}
@@ -24,7 +24,7 @@ func (s *BufferSuiteTest) SetupTest() {
 	switch s.bufferType {
 	case "", "memory":
 		s.hasMaxCapacity = true
-	case "disk":
+	case "disk", "overflow":
 		path, err := os.MkdirTemp("", "*-buffer-test")
 		s.Require().NoError(err)
 		s.bufferPath = path
@@ -47,9 +47,13 @@ func TestDiskBufferSuite(t *testing.T) {
 	suite.Run(t, &BufferSuiteTest{bufferType: "disk"})
 }
 
+func TestOverflowBufferSuite(t *testing.T) {
+	suite.Run(t, &BufferSuiteTest{bufferType: "overflow"})
+}
+
 func (s *BufferSuiteTest) newTestBuffer(capacity int) Buffer {
 	s.T().Helper()
-	buf, err := NewBuffer("test", "123", "", capacity, s.bufferType, s.bufferPath)
+	buf, err := NewBuffer("test", "123", "", capacity, s.bufferType, s.bufferPath, 0, 0)
 	s.Require().NoError(err)
 	buf.Stats().MetricsAdded.Set(0)
 	buf.Stats().MetricsWritten.Set(0)
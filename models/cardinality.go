@@ -0,0 +1,221 @@
+package models
+
+import (
+	"fmt"
+	"math"
+	"math/bits"
+	"sync"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/selfstat"
+)
+
+// Default time-window for counting unique series
+const DefaultCardinalityWindow = time.Hour
+
+const (
+	// Number of bits of the series sketch per series of the limit and the
+	// number of bits set per series. This results in a false-positive rate
+	// of about 0.2% at the limit.
+	cardinalityBitsPerSeries = 16
+	cardinalityHashes        = 4
+)
+
+// CardinalityConfig configures the limit on the number of unique series of a
+// plugin
+type CardinalityConfig struct {
+	// Maximum number of unique series within the window, zero disables
+	// limiting
+	Limit int
+	// Time-window for counting unique series
+	Window time.Duration
+	// Action taken for new series exceeding the limit, one of "drop",
+	// "drop_tags" or "log"
+	Action string
+	// Tag keys removed from new series exceeding the limit for the
+	// "drop_tags" action
+	DropTags []string
+}
+
+// cardinalityLimiter tracks the number of unique series seen within a
+// sliding time-window and limits new series exceeding the configured budget.
+//
+// Series are tracked by their hash in Bloom-filters sized by the limit to
+// bound the memory used. The window is split into two halves with a filter
+// each, so series are forgotten between one and one and a half windows after
+// being seen last. The number of unique series is estimated from the number of
+// bits set in the filters.
+type cardinalityLimiter struct {
+	config *CardinalityConfig
+	log    telegraf.Logger
+
+	// Filters of the current and the previous half of the window and the
+	// number of bits set in the current and in any of the filters
+	current     []uint64
+	previous    []uint64
+	setCurrent  int
+	setUnion    int
+	nbits       uint64
+	start       time.Time
+	warned      bool
+	now         func() time.Time
+	cardinality selfstat.Stat
+	limited     selfstat.Stat
+
+	sync.Mutex
+}
+
+func newCardinalityLimiter(cfg *CardinalityConfig, measurement string, tags map[string]string, log telegraf.Logger) *cardinalityLimiter {
+	return &cardinalityLimiter{
+		config:      cfg,
+		log:         log,
+		now:         time.Now,
+		cardinality: selfstat.Register(measurement, "cardinality", tags),
+		limited:     selfstat.Register(measurement, "cardinality_limited", tags),
+	}
+}
+
+func (l *cardinalityLimiter) init() error {
+	if l.config.Limit < 0 {
+		return fmt.Errorf("invalid 'cardinality_limit' setting %d", l.config.Limit)
+	}
+	if l.config.Window < 0 {
+		return fmt.Errorf("invalid 'cardinality_window' setting %s", l.config.Window)
+	}
+	if l.config.Window == 0 {
+		l.config.Window = DefaultCardinalityWindow
+	}
+	switch l.config.Action {
+	case "":
+		l.config.Action = "drop"
+	case "drop", "log":
+	case "drop_tags":
+		if len(l.config.DropTags) == 0 {
+			return fmt.Errorf("'cardinality_drop_tags' required for %q action", l.config.Action)
+		}
+	default:
+		return fmt.Errorf("invalid 'cardinality_action' setting %q", l.config.Action)
+	}
+
+	words := (l.config.Limit*cardinalityBitsPerSeries + 63) / 64
+	l.current = make([]uint64, words)
+	l.previous = make([]uint64, words)
+	l.nbits = uint64(words) * 64
+	l.start = l.now()
+
+	return nil
+}
+
+// apply records the series of the metric and returns false if the metric
+// should be dropped. Metrics of new series exceeding the limit are modified
+// or dropped depending on the configured action.
+func (l *cardinalityLimiter) apply(m telegraf.Metric) bool {
+	l.Lock()
+	defer l.Unlock()
+
+	l.rotate()
+
+	id := m.HashID()
+	if l.contains(id) || l.estimate() < l.config.Limit {
+		l.add(id)
+		return true
+	}
+
+	l.limited.Incr(1)
+	if !l.warned {
+		l.log.Warnf("Number of unique series exceeds the limit of %d, action %q is applied to new series", l.config.Limit, l.config.Action)
+		l.warned = true
+	}
+
+	switch l.config.Action {
+	case "log":
+		l.add(id)
+	case "drop_tags":
+		for _, key := range l.config.DropTags {
+			m.RemoveTag(key)
+		}
+		l.add(m.HashID())
+	default:
+		return false
+	}
+	return true
+}
+
+// rotate starts a new half of the window if the current half elapsed
+func (l *cardinalityLimiter) rotate() {
+	t := l.now()
+	elapsed := t.Sub(l.start)
+	if elapsed < l.config.Window/2 {
+		return
+	}
+
+	if elapsed < l.config.Window {
+		l.current, l.previous = l.previous, l.current
+		l.setUnion = l.setCurrent
+	} else {
+		clear(l.previous)
+		l.setUnion = 0
+	}
+	clear(l.current)
+	l.setCurrent = 0
+	l.start = t
+	l.warned = false
+	l.cardinality.Set(int64(l.estimate()))
+}
+
+// contains returns true if the series was seen within the window
+func (l *cardinalityLimiter) contains(id uint64) bool {
+	inCurrent, inPrevious := true, true
+	h1, h2 := l.hashes(id)
+	for i := range uint64(cardinalityHashes) {
+		word, mask := l.position(h1 + i*h2)
+		inCurrent = inCurrent && l.current[word]&mask != 0
+		inPrevious = inPrevious && l.previous[word]&mask != 0
+	}
+	return inCurrent || inPrevious
+}
+
+// add records the series in the current half of the window
+func (l *cardinalityLimiter) add(id uint64) {
+	h1, h2 := l.hashes(id)
+	for i := range uint64(cardinalityHashes) {
+		word, mask := l.position(h1 + i*h2)
+		if l.current[word]&mask != 0 {
+			continue
+		}
+		l.current[word] |= mask
+		l.setCurrent++
+		if l.previous[word]&mask == 0 {
+			l.setUnion++
+		}
+	}
+	l.cardinality.Set(int64(l.estimate()))
+}
+
+// estimate returns the estimated number of unique series within the window
+// based on the fraction of bits set
+func (l *cardinalityLimiter) estimate() int {
+	if uint64(l.setUnion) >= l.nbits {
+		return math.MaxInt
+	}
+	m := float64(l.nbits)
+	n := -m / cardinalityHashes * math.Log1p(-float64(l.setUnion)/m)
+	return int(math.Round(n))
+}
+
+// hashes derives the two hashes used for computing the bit positions of the
+// series using double-hashing
+func (*cardinalityLimiter) hashes(id uint64) (h1, h2 uint64) {
+	// Mix the hash to decorrelate the two hashes (splitmix64 finalizer)
+	z := id + 0x9e3779b97f4a7c15
+	z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+	z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+	z ^= z >> 31
+	return id, bits.RotateLeft64(z, 32) | 1
+}
+
+func (l *cardinalityLimiter) position(h uint64) (word int, mask uint64) {
+	bit := h % l.nbits
+	return int(bit / 64), uint64(1) << (bit % 64)
+}
@@ -0,0 +1,164 @@
+package models
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/metric"
+	"github.com/influxdata/telegraf/testutil"
+)
+
+func TestCardinalityLimiterInitFail(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   CardinalityConfig
+		expected string
+	}{
+		{
+			name:     "negative limit",
+			config:   CardinalityConfig{Limit: -1},
+			expected: "invalid 'cardinality_limit' setting",
+		},
+		{
+			name:     "negative window",
+			config:   CardinalityConfig{Limit: 10, Window: -time.Second},
+			expected: "invalid 'cardinality_window' setting",
+		},
+		{
+			name:     "invalid action",
+			config:   CardinalityConfig{Limit: 10, Action: "foo"},
+			expected: "invalid 'cardinality_action' setting",
+		},
+		{
+			name:     "missing tags",
+			config:   CardinalityConfig{Limit: 10, Action: "drop_tags"},
+			expected: "'cardinality_drop_tags' required",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := newCardinalityLimiter(&tt.config, "test", map[string]string{"test": t.Name()}, testutil.Logger{})
+			require.ErrorContains(t, l.init(), tt.expected)
+		})
+	}
+}
+
+func TestCardinalityLimiterDefaults(t *testing.T) {
+	cfg := &CardinalityConfig{Limit: 10}
+	l := newCardinalityLimiter(cfg, "test", map[string]string{"test": t.Name()}, testutil.Logger{})
+	require.NoError(t, l.init())
+	require.Equal(t, DefaultCardinalityWindow, cfg.Window)
+	require.Equal(t, "drop", cfg.Action)
+}
+
+func TestCardinalityLimiterEstimate(t *testing.T) {
+	for _, n := range []int{10, 100, 1000, 10000} {
+		t.Run(strconv.Itoa(n), func(t *testing.T) {
+			l := newCardinalityLimiter(&CardinalityConfig{Limit: 10 * n}, "test", map[string]string{"test": t.Name()}, testutil.Logger{})
+			require.NoError(t, l.init())
+			for i := range n {
+				require.True(t, l.apply(seriesMetric(i)))
+			}
+			// Seeing the series again must not change the estimate
+			for i := range n {
+				require.True(t, l.apply(seriesMetric(i)))
+			}
+			require.InEpsilon(t, n, l.estimate(), 0.05)
+			require.InEpsilon(t, n, l.cardinality.Get(), 0.05)
+		})
+	}
+}
+
+func TestCardinalityLimiterDrop(t *testing.T) {
+	l := newCardinalityLimiter(&CardinalityConfig{Limit: 100}, "test", map[string]string{"test": t.Name()}, testutil.Logger{})
+	require.NoError(t, l.init())
+
+	var accepted int
+	for i := range 200 {
+		if l.apply(seriesMetric(i)) {
+			accepted++
+		}
+	}
+	require.InDelta(t, 100, accepted, 5)
+	require.Equal(t, int64(200-accepted), l.limited.Get())
+
+	// Known series must still be accepted
+	require.True(t, l.apply(seriesMetric(0)))
+}
+
+func TestCardinalityLimiterLog(t *testing.T) {
+	l := newCardinalityLimiter(&CardinalityConfig{Limit: 100, Action: "log"}, "test", map[string]string{"test": t.Name()}, testutil.Logger{})
+	require.NoError(t, l.init())
+
+	for i := range 200 {
+		require.True(t, l.apply(seriesMetric(i)))
+	}
+	require.Positive(t, l.limited.Get())
+	require.InEpsilon(t, 200, l.estimate(), 0.05)
+}
+
+func TestCardinalityLimiterDropTags(t *testing.T) {
+	l := newCardinalityLimiter(
+		&CardinalityConfig{Limit: 100, Action: "drop_tags", DropTags: []string{"id"}},
+		"test", map[string]string{"test": t.Name()}, testutil.Logger{},
+	)
+	require.NoError(t, l.init())
+
+	var stripped int
+	for i := range 200 {
+		m := seriesMetric(i)
+		require.True(t, l.apply(m))
+		if _, found := m.GetTag("id"); !found {
+			stripped++
+			require.Equal(t, map[string]string{"host": "localhost"}, m.Tags())
+		}
+	}
+	require.Equal(t, int64(stripped), l.limited.Get())
+	require.InDelta(t, 100, stripped, 5)
+}
+
+func TestCardinalityLimiterWindow(t *testing.T) {
+	now := time.Now()
+	cfg := &CardinalityConfig{Limit: 100, Window: time.Hour}
+	l := newCardinalityLimiter(cfg, "test", map[string]string{"test": t.Name()}, testutil.Logger{})
+	l.now = func() time.Time { return now }
+	require.NoError(t, l.init())
+
+	// Fill up the budget
+	for i := 0; l.apply(seriesMetric(i)); i++ {
+		require.Less(t, i, 200)
+	}
+	require.False(t, l.apply(seriesMetric(1000)))
+
+	// Series seen in the previous half of the window are still known
+	now = now.Add(30 * time.Minute)
+	require.GreaterOrEqual(t, l.estimate(), 100)
+	require.False(t, l.apply(seriesMetric(1001)))
+
+	// Keep one series active
+	require.True(t, l.apply(seriesMetric(0)))
+
+	// All series except the active one are forgotten after the window
+	now = now.Add(30 * time.Minute)
+	require.True(t, l.apply(seriesMetric(1002)))
+	require.Equal(t, 2, l.estimate())
+	require.True(t, l.apply(seriesMetric(1003)))
+
+	// All series are forgotten if nothing happens for a whole window
+	now = now.Add(time.Hour)
+	l.rotate()
+	require.Zero(t, l.estimate())
+}
+
+func seriesMetric(i int) telegraf.Metric {
+	return metric.New(
+		"test",
+		map[string]string{"host": "localhost", "id": strconv.Itoa(i)},
+		map[string]interface{}{"value": 42},
+		time.Unix(0, 0),
+	)
+}
@@ -2,6 +2,8 @@ package models
 
 import (
 	"reflect"
+	"sync"
+	"time"
 
 	"github.com/influxdata/telegraf"
 )
@@ -14,6 +16,16 @@ func logName(pluginType, name, alias string) string {
 	return pluginType + "." + name + "::" + alias
 }
 
+// statTags returns the tags of the internal statistics of a plugin. Plugins
+// with the same name and alias share their statistics.
+func statTags(pluginType, name, alias string) map[string]string {
+	tags := map[string]string{pluginType: name}
+	if alias != "" {
+		tags["alias"] = alias
+	}
+	return tags
+}
+
 func SetLoggerOnPlugin(i interface{}, logger telegraf.Logger) {
 	valI := reflect.ValueOf(i)
 
@@ -36,3 +48,25 @@ func SetLoggerOnPlugin(i interface{}, logger telegraf.Logger) {
 			valI.Type().Name(), field.Type().String())
 	}
 }
+
+// lastError keeps track of the most recent error of a plugin
+type lastError struct {
+	err       error
+	timestamp time.Time
+	sync.Mutex
+}
+
+func (e *lastError) set(err error) {
+	e.Lock()
+	defer e.Unlock()
+
+	e.err = err
+	e.timestamp = time.Now()
+}
+
+func (e *lastError) get() (time.Time, error) {
+	e.Lock()
+	defer e.Unlock()
+
+	return e.timestamp, e.err
+}
@@ -0,0 +1,74 @@
+package models
+
+import (
+	"os"
+	"sync"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/plugins/serializers/influx"
+)
+
+// deadLetterSink receives the metrics an output gave up on according to its
+// retry policy.
+type deadLetterSink interface {
+	Write(metrics []telegraf.Metric) error
+	Close() error
+}
+
+// deadLetterOutput forwards metrics to the buffer of another output.
+type deadLetterOutput struct {
+	target *RunningOutput
+}
+
+func (d *deadLetterOutput) Write(metrics []telegraf.Metric) error {
+	for _, m := range metrics {
+		d.target.add(m.Copy())
+	}
+	return nil
+}
+
+func (*deadLetterOutput) Close() error {
+	return nil
+}
+
+// deadLetterFile appends metrics in line-protocol format to a local file.
+type deadLetterFile struct {
+	file       *os.File
+	serializer *influx.Serializer
+
+	sync.Mutex
+}
+
+func newDeadLetterFile(path string) (*deadLetterFile, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0640)
+	if err != nil {
+		return nil, err
+	}
+
+	serializer := &influx.Serializer{SortFields: true, UintSupport: true}
+	if err := serializer.Init(); err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	return &deadLetterFile{file: f, serializer: serializer}, nil
+}
+
+func (d *deadLetterFile) Write(metrics []telegraf.Metric) error {
+	d.Lock()
+	defer d.Unlock()
+
+	octets, err := d.serializer.SerializeBatch(metrics)
+	if err != nil {
+		return err
+	}
+	_, err = d.file.Write(octets)
+	return err
+}
+
+func (d *deadLetterFile) Close() error {
+	d.Lock()
+	defer d.Unlock()
+
+	return d.file.Close()
+}
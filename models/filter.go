@@ -142,19 +142,7 @@ func (f *Filter) Select(metric telegraf.Metric) (bool, error) {
 	}
 
 	if f.metricFilter != nil {
-		result, _, err := f.metricFilter.Eval(map[string]interface{}{
-			"name":   metric.Name(),
-			"tags":   metric.Tags(),
-			"fields": metric.Fields(),
-			"time":   metric.Time(),
-		})
-		if err != nil {
-			return true, err
-		}
-		if r, ok := result.Value().(bool); ok {
-			return r, nil
-		}
-		return true, fmt.Errorf("invalid result type %T", result.Value())
+		return evalMetricSelector(f.metricFilter, metric)
 	}
 
 	return true, nil
@@ -237,14 +225,19 @@ func (f *Filter) compileMetricFilter() error {
 	// Reset internal state
 	f.metricFilter = nil
 
-	// Initialize the expression
-	expression := f.MetricPass
-
 	// Check if we need to call into CEL at all and quit early
-	if expression == "" {
+	if f.MetricPass == "" {
 		return nil
 	}
 
+	var err error
+	f.metricFilter, err = compileMetricSelector(f.MetricPass)
+	return err
+}
+
+// compileMetricSelector compiles the given CEL expression selecting metrics
+// based on their name, tags, fields and time.
+func compileMetricSelector(expression string) (cel.Program, error) {
 	// Declare the computation environment for the filter including custom functions
 	env, err := cel.NewEnv(
 		cel.Declarations(
@@ -263,25 +256,42 @@ func (f *Filter) compileMetricFilter() error {
 		ext.Strings(),
 	)
 	if err != nil {
-		return fmt.Errorf("creating environment failed: %w", err)
+		return nil, fmt.Errorf("creating environment failed: %w", err)
 	}
 
 	// Compile the program
 	ast, issues := env.Compile(expression)
 	if issues.Err() != nil {
-		return issues.Err()
+		return nil, issues.Err()
 	}
 	// Check if we got a boolean expression needed for filtering
 	if ast.OutputType() != cel.BoolType {
-		return errors.New("expression needs to return a boolean")
+		return nil, errors.New("expression needs to return a boolean")
 	}
 
 	// Get the final program
 	options := cel.EvalOptions(
 		cel.OptOptimize,
 	)
-	f.metricFilter, err = env.Program(ast, options)
-	return err
+	return env.Program(ast, options)
+}
+
+// evalMetricSelector evaluates the compiled selector for the given metric.
+// Metrics are selected in case of errors.
+func evalMetricSelector(selector cel.Program, metric telegraf.Metric) (bool, error) {
+	result, _, err := selector.Eval(map[string]interface{}{
+		"name":   metric.Name(),
+		"tags":   metric.Tags(),
+		"fields": metric.Fields(),
+		"time":   metric.Time(),
+	})
+	if err != nil {
+		return true, err
+	}
+	if r, ok := result.Value().(bool); ok {
+		return r, nil
+	}
+	return true, fmt.Errorf("invalid result type %T", result.Value())
 }
 
 func ShouldPassFilters(include, exclude filter.Filter, key string) bool {
@@ -0,0 +1,326 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/internal"
+	logging "github.com/influxdata/telegraf/logger"
+	"github.com/influxdata/telegraf/selfstat"
+)
+
+// Modes of distributing metrics among the members of an output group
+const (
+	OutputGroupFailover   = "failover"
+	OutputGroupRoundRobin = "round_robin"
+	OutputGroupHash       = "hash"
+)
+
+// DefaultOutputGroupRetryInterval is the time an unhealthy member is skipped
+// if no other interval is configured
+const DefaultOutputGroupRetryInterval = 30 * time.Second
+
+// OutputGroupConfig contains the settings of an output group
+type OutputGroupConfig struct {
+	Name string
+
+	// Mode of distributing the metrics, one of "failover" (default),
+	// "round_robin" or "hash"
+	Mode string
+
+	// HashTags are the tags used to select the member in "hash" mode, all
+	// tags and the metric name are used if empty
+	HashTags []string
+
+	// RetryInterval is the time an unhealthy member is skipped before
+	// writing to it again
+	RetryInterval time.Duration
+}
+
+// OutputGroupMember is an output plugin being part of an output group
+type OutputGroupMember struct {
+	Output telegraf.Output
+	Config *OutputConfig
+}
+
+// OutputGroup is an output distributing the metrics written to it among its
+// member outputs. The group is run by a single RunningOutput, so all members
+// share the buffer of the group.
+type OutputGroup struct {
+	config  *OutputGroupConfig
+	members []*groupMember
+	next    int
+
+	sync.Mutex
+}
+
+type groupMember struct {
+	output    telegraf.Output
+	name      string
+	log       telegraf.Logger
+	connected bool
+	failedAt  time.Time
+
+	Healthy        selfstat.Stat
+	MetricsWritten selfstat.Stat
+	WriteErrors    selfstat.Stat
+}
+
+// NewOutputGroup creates an output group with the given members.
+func NewOutputGroup(config *OutputGroupConfig, members []*OutputGroupMember) (*OutputGroup, error) {
+	switch config.Mode {
+	case "":
+		config.Mode = OutputGroupFailover
+	case OutputGroupFailover, OutputGroupRoundRobin, OutputGroupHash:
+	default:
+		return nil, fmt.Errorf("invalid mode %q for output group %q", config.Mode, config.Name)
+	}
+	if config.RetryInterval <= 0 {
+		config.RetryInterval = DefaultOutputGroupRetryInterval
+	}
+	if len(members) == 0 {
+		return nil, fmt.Errorf("output group %q has no members", config.Name)
+	}
+
+	g := &OutputGroup{config: config}
+	for i, m := range members {
+		// Distinguish members of the same plugin without an alias by their
+		// position in the group
+		tags := map[string]string{
+			"group":  config.Name,
+			"member": strconv.Itoa(i),
+			"output": m.Config.Name,
+		}
+		if m.Config.Alias != "" {
+			tags["alias"] = m.Config.Alias
+		}
+
+		logger := logging.New("outputs", m.Config.Name, m.Config.Alias)
+		if err := logger.SetLogLevel(m.Config.LogLevel); err != nil {
+			logger.Error(err)
+		}
+		SetLoggerOnPlugin(m.Output, logger)
+
+		g.members = append(g.members, &groupMember{
+			output:         m.Output,
+			name:           logName("outputs", m.Config.Name, m.Config.Alias),
+			log:            logger,
+			Healthy:        selfstat.Register("output_group", "healthy", tags),
+			MetricsWritten: selfstat.Register("output_group", "metrics_written", tags),
+			WriteErrors:    selfstat.Register("output_group", "write_errors", tags),
+		})
+	}
+
+	return g, nil
+}
+
+func (*OutputGroup) SampleConfig() string {
+	return ""
+}
+
+func (g *OutputGroup) Init() error {
+	for _, m := range g.members {
+		if p, ok := m.output.(telegraf.Initializer); ok {
+			if err := p.Init(); err != nil {
+				return fmt.Errorf("initializing %s failed: %w", m.name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// Connect connects all members. An error is only returned if no member
+// could be connected, the other members are connected on their next write.
+func (g *OutputGroup) Connect() error {
+	g.Lock()
+	defer g.Unlock()
+
+	errs := make([]error, 0, len(g.members))
+	for _, m := range g.members {
+		if err := m.connect(); err != nil {
+			m.log.Errorf("Connecting failed: %v", err)
+			errs = append(errs, fmt.Errorf("%s: %w", m.name, err))
+		}
+	}
+	if len(errs) == len(g.members) {
+		return fmt.Errorf("connecting members of output group %q failed: %w", g.config.Name, errors.Join(errs...))
+	}
+	return nil
+}
+
+func (g *OutputGroup) Close() error {
+	g.Lock()
+	defer g.Unlock()
+
+	errs := make([]error, 0, len(g.members))
+	for _, m := range g.members {
+		if !m.connected {
+			continue
+		}
+		if err := m.output.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", m.name, err))
+		}
+		m.connected = false
+	}
+	return errors.Join(errs...)
+}
+
+func (g *OutputGroup) Write(metrics []telegraf.Metric) error {
+	g.Lock()
+	defer g.Unlock()
+
+	switch g.config.Mode {
+	case OutputGroupRoundRobin:
+		start := g.next
+		g.next = (g.next + 1) % len(g.members)
+		return g.writeFrom(start, metrics)
+	case OutputGroupHash:
+		return g.writeHashed(metrics)
+	}
+	return g.writeFrom(0, metrics)
+}
+
+// writeFrom writes the metrics to the first healthy member starting at the
+// given index and falls back to the following members on error. Unhealthy
+// members are only used if all members are unhealthy.
+func (g *OutputGroup) writeFrom(start int, metrics []telegraf.Metric) error {
+	now := time.Now()
+	candidates := make([]*groupMember, 0, len(g.members))
+	var unhealthy []*groupMember
+	for i := range g.members {
+		m := g.members[(start+i)%len(g.members)]
+		if m.healthy(now, g.config.RetryInterval) {
+			candidates = append(candidates, m)
+		} else {
+			unhealthy = append(unhealthy, m)
+		}
+	}
+	candidates = append(candidates, unhealthy...)
+
+	errs := make([]error, 0, len(candidates))
+	for _, m := range candidates {
+		err := m.write(metrics)
+		if err == nil {
+			return nil
+		}
+
+		// The member wrote parts of the batch so we cannot pass the batch
+		// to another member without duplicating metrics
+		var partialErr *internal.PartialWriteError
+		if errors.As(err, &partialErr) {
+			return err
+		}
+
+		m.log.Errorf("Writing to member of output group %q failed: %v", g.config.Name, err)
+		errs = append(errs, fmt.Errorf("%s: %w", m.name, err))
+	}
+	return errors.Join(errs...)
+}
+
+// writeHashed distributes the metrics among the members based on the hash of
+// the configured tags. Metrics of a failed member are passed to the next
+// member.
+func (g *OutputGroup) writeHashed(metrics []telegraf.Metric) error {
+	indices := make([][]int, len(g.members))
+	for i, m := range metrics {
+		idx := int(g.hash(m) % uint64(len(g.members)))
+		indices[idx] = append(indices[idx], i)
+	}
+
+	var accept, reject []int
+	var errs []error
+	for idx, batchIndices := range indices {
+		if len(batchIndices) == 0 {
+			continue
+		}
+		batch := make([]telegraf.Metric, 0, len(batchIndices))
+		for _, i := range batchIndices {
+			batch = append(batch, metrics[i])
+		}
+
+		err := g.writeFrom(idx, batch)
+		if err == nil {
+			accept = append(accept, batchIndices...)
+			continue
+		}
+		errs = append(errs, err)
+
+		// Map the indices of a partial write back to the complete batch
+		var partialErr *internal.PartialWriteError
+		if errors.As(err, &partialErr) {
+			for _, i := range partialErr.MetricsAccept {
+				accept = append(accept, batchIndices[i])
+			}
+			for _, i := range partialErr.MetricsReject {
+				reject = append(reject, batchIndices[i])
+			}
+		}
+	}
+
+	if len(errs) == 0 {
+		return nil
+	}
+	return &internal.PartialWriteError{
+		Err:           errors.Join(errs...),
+		MetricsAccept: accept,
+		MetricsReject: reject,
+	}
+}
+
+func (g *OutputGroup) hash(m telegraf.Metric) uint64 {
+	if len(g.config.HashTags) == 0 {
+		return m.HashID()
+	}
+
+	h := fnv.New64a()
+	for _, key := range g.config.HashTags {
+		v, _ := m.GetTag(key)
+		h.Write([]byte(v))
+		h.Write([]byte{0})
+	}
+	return h.Sum64()
+}
+
+// healthy returns true if the member did not fail within the retry interval
+func (m *groupMember) healthy(now time.Time, interval time.Duration) bool {
+	return m.failedAt.IsZero() || now.Sub(m.failedAt) >= interval
+}
+
+func (m *groupMember) connect() error {
+	if err := m.output.Connect(); err != nil {
+		m.failed()
+		return err
+	}
+	m.connected = true
+	m.Healthy.Set(1)
+	return nil
+}
+
+func (m *groupMember) write(metrics []telegraf.Metric) error {
+	if !m.connected {
+		if err := m.connect(); err != nil {
+			return fmt.Errorf("connecting failed: %w", err)
+		}
+	}
+
+	if err := m.output.Write(metrics); err != nil {
+		m.failed()
+		return err
+	}
+
+	m.failedAt = time.Time{}
+	m.Healthy.Set(1)
+	m.MetricsWritten.Incr(int64(len(metrics)))
+	return nil
+}
+
+func (m *groupMember) failed() {
+	m.failedAt = time.Now()
+	m.Healthy.Set(0)
+	m.WriteErrors.Incr(1)
+}
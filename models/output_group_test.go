@@ -0,0 +1,150 @@
+package models
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/internal"
+	"github.com/influxdata/telegraf/metric"
+)
+
+type groupTestOutput struct {
+	failing bool
+	metrics []telegraf.Metric
+}
+
+func (*groupTestOutput) SampleConfig() string {
+	return ""
+}
+
+func (*groupTestOutput) Connect() error {
+	return nil
+}
+
+func (*groupTestOutput) Close() error {
+	return nil
+}
+
+func (o *groupTestOutput) Write(metrics []telegraf.Metric) error {
+	if o.failing {
+		return errors.New("failing")
+	}
+	o.metrics = append(o.metrics, metrics...)
+	return nil
+}
+
+func newTestOutputGroup(t *testing.T, mode string, n int) (*OutputGroup, []*groupTestOutput) {
+	outputs := make([]*groupTestOutput, 0, n)
+	members := make([]*OutputGroupMember, 0, n)
+	for range n {
+		o := &groupTestOutput{}
+		outputs = append(outputs, o)
+		members = append(members, &OutputGroupMember{Output: o, Config: &OutputConfig{Name: "test"}})
+	}
+
+	g, err := NewOutputGroup(&OutputGroupConfig{Name: t.Name(), Mode: mode, HashTags: []string{"host"}}, members)
+	require.NoError(t, err)
+	require.NoError(t, g.Init())
+	require.NoError(t, g.Connect())
+
+	// Reset the shared statistics of the members
+	for _, m := range g.members {
+		m.MetricsWritten.Set(0)
+		m.WriteErrors.Set(0)
+	}
+	return g, outputs
+}
+
+func groupTestMetrics(hosts ...string) []telegraf.Metric {
+	metrics := make([]telegraf.Metric, 0, len(hosts))
+	for _, host := range hosts {
+		metrics = append(metrics, metric.New("cpu", map[string]string{"host": host}, map[string]interface{}{"value": 42}, time.Unix(0, 0)))
+	}
+	return metrics
+}
+
+func TestOutputGroupInvalid(t *testing.T) {
+	_, err := NewOutputGroup(&OutputGroupConfig{Name: "foo", Mode: "random"}, []*OutputGroupMember{
+		{Output: &groupTestOutput{}, Config: &OutputConfig{Name: "test"}},
+	})
+	require.ErrorContains(t, err, `invalid mode "random"`)
+
+	_, err = NewOutputGroup(&OutputGroupConfig{Name: "foo"}, nil)
+	require.ErrorContains(t, err, "has no members")
+}
+
+func TestOutputGroupFailover(t *testing.T) {
+	g, outputs := newTestOutputGroup(t, "", 2)
+
+	require.NoError(t, g.Write(groupTestMetrics("a")))
+	require.Len(t, outputs[0].metrics, 1)
+	require.Empty(t, outputs[1].metrics)
+
+	// Fall back to the second member and skip the failed member afterwards
+	outputs[0].failing = true
+	require.NoError(t, g.Write(groupTestMetrics("b")))
+	outputs[0].failing = false
+	require.NoError(t, g.Write(groupTestMetrics("c")))
+	require.Len(t, outputs[0].metrics, 1)
+	require.Len(t, outputs[1].metrics, 2)
+	require.Equal(t, int64(0), g.members[0].Healthy.Get())
+	require.Equal(t, int64(1), g.members[0].WriteErrors.Get())
+	require.Equal(t, int64(1), g.members[1].Healthy.Get())
+	require.Equal(t, int64(2), g.members[1].MetricsWritten.Get())
+
+	// The failed member is used again after the retry interval
+	g.members[0].failedAt = time.Now().Add(-2 * DefaultOutputGroupRetryInterval)
+	require.NoError(t, g.Write(groupTestMetrics("d")))
+	require.Len(t, outputs[0].metrics, 2)
+	require.Equal(t, int64(1), g.members[0].Healthy.Get())
+
+	// Fail if all members fail
+	outputs[0].failing = true
+	outputs[1].failing = true
+	require.ErrorContains(t, g.Write(groupTestMetrics("e")), "failing")
+}
+
+func TestOutputGroupRoundRobin(t *testing.T) {
+	g, outputs := newTestOutputGroup(t, OutputGroupRoundRobin, 3)
+
+	for range 6 {
+		require.NoError(t, g.Write(groupTestMetrics("a")))
+	}
+	for _, o := range outputs {
+		require.Len(t, o.metrics, 2)
+	}
+}
+
+func TestOutputGroupHash(t *testing.T) {
+	g, outputs := newTestOutputGroup(t, OutputGroupHash, 2)
+
+	hosts := []string{"a", "b", "c", "d", "e", "f", "a", "b"}
+	require.NoError(t, g.Write(groupTestMetrics(hosts...)))
+
+	// Metrics of the same host always end up at the same member
+	seen := make(map[string]int)
+	total := 0
+	for i, o := range outputs {
+		for _, m := range o.metrics {
+			host, _ := m.GetTag("host")
+			if idx, found := seen[host]; found {
+				require.Equal(t, idx, i, host)
+			}
+			seen[host] = i
+		}
+		total += len(o.metrics)
+	}
+	require.Equal(t, len(hosts), total)
+
+	// Metrics are accepted partially if all members fail for some of them
+	outputs[0].failing = true
+	outputs[1].failing = true
+	err := g.Write(groupTestMetrics(hosts...))
+	var partialErr *internal.PartialWriteError
+	require.ErrorAs(t, err, &partialErr)
+	require.Empty(t, partialErr.MetricsAccept)
+}
@@ -0,0 +1,44 @@
+package models
+
+import "slices"
+
+// DefaultPipeline is the name of the pipeline of all plugins not assigned to
+// a pipeline explicitly
+const DefaultPipeline = "default"
+
+func pipelineName(name string) string {
+	if name == "" {
+		return DefaultPipeline
+	}
+	return name
+}
+
+// Pipeline returns the name of the pipeline the input feeds
+func (r *RunningInput) Pipeline() string {
+	return pipelineName(r.Config.Pipeline)
+}
+
+// Pipeline returns the name of the pipeline the processor is part of
+func (rp *RunningProcessor) Pipeline() string {
+	return pipelineName(rp.Config.Pipeline)
+}
+
+// Pipeline returns the name of the pipeline the aggregator is part of
+func (r *RunningAggregator) Pipeline() string {
+	return pipelineName(r.Config.Pipeline)
+}
+
+// Pipelines returns the names of the pipelines the output receives metrics
+// from
+func (r *RunningOutput) Pipelines() []string {
+	if len(r.Config.Pipelines) == 0 {
+		return []string{DefaultPipeline}
+	}
+	return r.Config.Pipelines
+}
+
+// InPipeline returns true if the output receives metrics from the given
+// pipeline
+func (r *RunningOutput) InPipeline(name string) bool {
+	return slices.Contains(r.Pipelines(), name)
+}
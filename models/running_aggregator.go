@@ -84,6 +84,9 @@ type AggregatorConfig struct {
 	MeasurementSuffix string
 	Tags              map[string]string
 	Filter            Filter
+
+	// Pipeline the aggregator is part of, the default pipeline if empty
+	Pipeline string
 }
 
 func (r *RunningAggregator) LogName() string {
@@ -129,6 +132,7 @@ func (r *RunningAggregator) MakeMetric(telegrafMetric telegraf.Metric) telegraf.
 		r.Config.MeasurementSuffix,
 		r.Config.Tags,
 		nil)
+	selectTrace(m)
 
 	r.MetricsPushed.Incr(1)
 
@@ -142,6 +146,7 @@ func (r *RunningAggregator) Add(m telegraf.Metric) bool {
 	if err != nil {
 		r.log.Errorf("filtering failed: %v", err)
 	} else if !ok {
+		trace(r.LogName(), TraceStageAggregator, "skipped", m)
 		return false
 	}
 
@@ -153,6 +158,7 @@ func (r *RunningAggregator) Add(m telegraf.Metric) bool {
 
 	r.Config.Filter.Modify(m)
 	if len(m.FieldList()) == 0 {
+		trace(r.LogName(), TraceStageAggregator, "filtered", m)
 		r.MetricsFiltered.Incr(1)
 		return r.Config.DropOriginal
 	}
@@ -163,10 +169,12 @@ func (r *RunningAggregator) Add(m telegraf.Metric) bool {
 	if m.Time().Before(r.periodStart.Add(-r.Config.Grace)) || m.Time().After(r.periodEnd.Add(r.Config.Delay)) {
 		r.log.Debugf("Metric is outside aggregation window; discarding. %s: m: %s e: %s g: %s",
 			m.Time(), r.periodStart, r.periodEnd, r.Config.Grace)
+		trace(r.LogName(), TraceStageAggregator, "discarded", m)
 		r.MetricsDropped.Incr(1)
 		return r.Config.DropOriginal
 	}
 
+	trace(r.LogName(), TraceStageAggregator, "consumed", m)
 	r.Aggregator.Add(m)
 	return r.Config.DropOriginal
 }
@@ -186,6 +194,12 @@ func (r *RunningAggregator) Push(acc telegraf.Accumulator) {
 	r.Aggregator.Reset()
 }
 
+// UnregisterStats removes the internal statistics of the aggregator from the
+// selfstat registry.
+func (r *RunningAggregator) UnregisterStats() {
+	selfstat.Unregister("aggregate", statTags("aggregator", r.Config.Name, r.Config.Alias))
+}
+
 func (r *RunningAggregator) Log() telegraf.Logger {
 	return r.log
 }
@@ -3,6 +3,7 @@ package models
 import (
 	"errors"
 	"fmt"
+	"sync/atomic"
 	"time"
 
 	"github.com/influxdata/telegraf"
@@ -24,16 +25,21 @@ type RunningInput struct {
 	log         telegraf.Logger
 	defaultTags map[string]string
 
-	startAcc    telegraf.Accumulator
-	started     bool
-	retries     uint64
-	gatherStart time.Time
-	gatherEnd   time.Time
+	startAcc     telegraf.Accumulator
+	started      atomic.Bool
+	retries      uint64
+	gatherStart  time.Time
+	gatherEnd    time.Time
+	paused       atomic.Bool
+	backpressure atomic.Bool
+	lastError    lastError
+	cardinality  *cardinalityLimiter
 
 	MetricsGathered selfstat.Stat
 	GatherTime      selfstat.Stat
 	GatherTimeouts  selfstat.Stat
 	StartupErrors   selfstat.Stat
+	Reconnects      selfstat.Stat
 }
 
 func NewRunningInput(input telegraf.Input, config *InputConfig) *RunningInput {
@@ -53,6 +59,11 @@ func NewRunningInput(input telegraf.Input, config *InputConfig) *RunningInput {
 	}
 	SetLoggerOnPlugin(input, logger)
 
+	var cardinality *cardinalityLimiter
+	if config.Cardinality.Limit != 0 {
+		cardinality = newCardinalityLimiter(&config.Cardinality, "gather", tags, logger)
+	}
+
 	return &RunningInput{
 		Input:  input,
 		Config: config,
@@ -76,7 +87,13 @@ func NewRunningInput(input telegraf.Input, config *InputConfig) *RunningInput {
 			"startup_errors",
 			tags,
 		),
-		log: logger,
+		Reconnects: selfstat.Register(
+			"gather",
+			"reconnects",
+			tags,
+		),
+		log:         logger,
+		cardinality: cardinality,
 	}
 }
 
@@ -101,12 +118,29 @@ type InputConfig struct {
 	Filter                  Filter
 	AlwaysIncludeLocalTags  bool
 	AlwaysIncludeGlobalTags bool
+
+	// Pipeline the input feeds, the default pipeline if empty
+	Pipeline string
+
+	// Limit on the number of unique series emitted by the input
+	Cardinality CardinalityConfig
+
+	// References to secret-store secrets used by the plugin
+	Secrets []string
 }
 
 func (*RunningInput) metricFiltered(metric telegraf.Metric) {
 	metric.Drop()
 }
 
+// UnregisterStats removes the internal statistics of the input from the
+// selfstat registry.
+func (r *RunningInput) UnregisterStats() {
+	tags := statTags("input", r.Config.Name, r.Config.Alias)
+	selfstat.Unregister("gather", tags)
+	selfstat.Unregister("write", tags)
+}
+
 func (r *RunningInput) LogName() string {
 	return logName("inputs", r.Config.Name, r.Config.Alias)
 }
@@ -126,6 +160,12 @@ func (r *RunningInput) Init() error {
 		return fmt.Errorf("invalid 'time_source' setting %q", r.Config.TimeSource)
 	}
 
+	if r.cardinality != nil {
+		if err := r.cardinality.init(); err != nil {
+			return err
+		}
+	}
+
 	if p, ok := r.Input.(telegraf.Initializer); ok {
 		return p.Init()
 	}
@@ -142,7 +182,7 @@ func (r *RunningInput) Start(acc telegraf.Accumulator) error {
 	r.startAcc = acc
 	err := plugin.Start(acc)
 	if err == nil {
-		r.started = true
+		r.started.Store(true)
 		return nil
 	}
 	r.StartupErrors.Incr(1)
@@ -193,10 +233,18 @@ func (r *RunningInput) ID() string {
 }
 
 func (r *RunningInput) MakeMetric(metric telegraf.Metric) telegraf.Metric {
+	// Drop metrics pushed by service inputs while being paused
+	if r.paused.Load() {
+		metric.Drop()
+		return nil
+	}
+
+	selectTrace(metric)
 	ok, err := r.Config.Filter.Select(metric)
 	if err != nil {
 		r.log.Errorf("filtering failed: %v", err)
 	} else if !ok {
+		trace(r.LogName(), TraceStageInput, "filtered", metric)
 		r.metricFiltered(metric)
 		return nil
 	}
@@ -211,6 +259,7 @@ func (r *RunningInput) MakeMetric(metric telegraf.Metric) telegraf.Metric {
 
 	r.Config.Filter.Modify(metric)
 	if len(metric.FieldList()) == 0 {
+		trace(r.LogName(), TraceStageInput, "filtered", metric)
 		r.metricFiltered(metric)
 		return nil
 	}
@@ -234,6 +283,16 @@ func (r *RunningInput) MakeMetric(metric telegraf.Metric) telegraf.Metric {
 	default:
 	}
 
+	// Select metrics matching the selector only after modification as well
+	selectTrace(metric)
+	if r.cardinality != nil && !r.cardinality.apply(metric) {
+		trace(r.LogName(), TraceStageInput, "dropped", metric)
+		metric.Drop()
+		return nil
+	}
+
+	trace(r.LogName(), TraceStageInput, "emitted", metric)
+
 	r.MetricsGathered.Incr(1)
 	GlobalMetricsGathered.Incr(1)
 	return metric
@@ -241,7 +300,7 @@ func (r *RunningInput) MakeMetric(metric telegraf.Metric) telegraf.Metric {
 
 func (r *RunningInput) Gather(acc telegraf.Accumulator) error {
 	// Try to connect if we are not yet started up
-	if plugin, ok := r.Input.(telegraf.ServiceInput); ok && !r.started {
+	if plugin, ok := r.Input.(telegraf.ServiceInput); ok && !r.started.Load() {
 		r.retries++
 		if err := plugin.Start(r.startAcc); err != nil {
 			var serr *internal.StartupError
@@ -251,7 +310,7 @@ func (r *RunningInput) Gather(acc telegraf.Accumulator) error {
 			}
 			r.log.Debugf("Partially connected after %d attempts", r.retries)
 		} else {
-			r.started = true
+			r.started.Store(true)
 			r.log.Debugf("Successfully connected after %d attempts", r.retries)
 		}
 	}
@@ -272,6 +331,78 @@ func (r *RunningInput) Log() telegraf.Logger {
 	return r.log
 }
 
+// Reconnect reconnects service inputs supporting it, e.g. to pick up rotated
+// credentials. Inputs not being service inputs are not connected permanently
+// and service inputs not yet started pick up the credentials when starting.
+// The caller must make sure the input is not stopped concurrently.
+func (r *RunningInput) Reconnect() {
+	if _, ok := r.Input.(telegraf.ServiceInput); !ok || !r.started.Load() {
+		return
+	}
+	plugin, ok := r.Input.(telegraf.ReconnectableInput)
+	if !ok {
+		r.log.Warn("Input does not support reconnecting, restart Telegraf to apply rotated secrets")
+		return
+	}
+
+	r.log.Info("Reconnecting to apply rotated secrets")
+	r.Reconnects.Incr(1)
+	if err := plugin.Reconnect(); err != nil {
+		r.lastError.set(err)
+		r.log.Errorf("Reconnecting failed: %v", err)
+	}
+}
+
+// SetBackpressure signals the input to pause or resume consuming from its
+// source. The signal is only forwarded to service inputs supporting
+// backpressure and only if the state changes.
+func (r *RunningInput) SetBackpressure(active bool) {
+	plugin, ok := r.Input.(telegraf.BackpressureInput)
+	if !ok || r.backpressure.Swap(active) == active {
+		return
+	}
+	if active {
+		r.log.Info("Pausing consumption due to backpressure of the outputs")
+	} else {
+		r.log.Info("Resuming consumption")
+	}
+	plugin.SetBackpressure(active)
+}
+
+// Backpressure returns true if the input was signalled to pause consuming due
+// to backpressure.
+func (r *RunningInput) Backpressure() bool {
+	return r.backpressure.Load()
+}
+
+// Pause stops the input from collecting metrics until Resume is called.
+// Gather is not called while the input is paused and metrics of service
+// inputs received during this time are dropped.
+func (r *RunningInput) Pause() {
+	r.paused.Store(true)
+}
+
+// Resume restarts collecting metrics for a paused input.
+func (r *RunningInput) Resume() {
+	r.paused.Store(false)
+}
+
+// Paused returns true if the input is currently paused.
+func (r *RunningInput) Paused() bool {
+	return r.paused.Load()
+}
+
+// SetLastError records the given error as the most recent error of the input.
+func (r *RunningInput) SetLastError(err error) {
+	r.lastError.set(err)
+}
+
+// LastError returns the most recent error of the input and the time it
+// occurred. The error is nil if the input did not report any error yet.
+func (r *RunningInput) LastError() (time.Time, error) {
+	return r.lastError.get()
+}
+
 func (r *RunningInput) IncrGatherTimeouts() {
 	GlobalGatherTimeouts.Incr(1)
 	r.GatherTimeouts.Incr(1)
@@ -533,6 +533,86 @@ func TestRunningInputProbingSuccess(t *testing.T) {
 	}
 }
 
+func TestRunningInputPause(t *testing.T) {
+	ri := NewRunningInput(&mockInput{}, &InputConfig{
+		Name: "TestRunningInput",
+	})
+	m := metric.New("RITest",
+		map[string]string{},
+		map[string]interface{}{"value": int64(101)},
+		time.Now())
+
+	ri.Pause()
+	require.True(t, ri.Paused())
+	require.Nil(t, ri.MakeMetric(m))
+
+	ri.Resume()
+	require.False(t, ri.Paused())
+	require.NotNil(t, ri.MakeMetric(m))
+}
+
+func TestRunningInputLastError(t *testing.T) {
+	ri := NewRunningInput(&mockInput{}, &InputConfig{
+		Name: "TestRunningInput",
+	})
+
+	ts, err := ri.LastError()
+	require.NoError(t, err)
+	require.True(t, ts.IsZero())
+
+	before := time.Now()
+	ri.SetLastError(errors.New("gather failed"))
+	ts, err = ri.LastError()
+	require.EqualError(t, err, "gather failed")
+	require.False(t, ts.Before(before))
+}
+
+func TestRunningInputReconnect(t *testing.T) {
+	mi := &mockReconnectableInput{}
+	ri := NewRunningInput(mi, &InputConfig{Name: "test_reconnect"})
+	require.NoError(t, ri.Init())
+
+	// Inputs not started yet pick up the credentials on start
+	ri.Reconnect()
+	require.Equal(t, 0, mi.reconnects)
+
+	acc := &testutil.Accumulator{}
+	require.NoError(t, ri.Start(acc))
+	require.NoError(t, ri.Gather(acc))
+
+	// Reconnect e.g. due to rotated credentials without restarting the input
+	ri.Reconnect()
+	require.Equal(t, 1, mi.reconnects)
+	require.Equal(t, 1, mi.starts)
+	require.Equal(t, 0, mi.stops)
+	require.Equal(t, int64(1), ri.Reconnects.Get())
+
+	// Failing reconnects are recorded and keep the input running
+	mi.err = errors.New("invalid credentials")
+	ri.Reconnect()
+	require.Equal(t, 2, mi.reconnects)
+	_, err := ri.LastError()
+	require.ErrorContains(t, err, "invalid credentials")
+	require.NoError(t, ri.Gather(acc))
+	require.Equal(t, 1, mi.starts)
+	require.Equal(t, 0, mi.stops)
+
+	// Service inputs not supporting reconnects are not restarted
+	ms := &mockServiceInput{}
+	ri = NewRunningInput(ms, &InputConfig{Name: "test_reconnect_unsupported"})
+	require.NoError(t, ri.Start(acc))
+	ri.Reconnect()
+	require.NoError(t, ri.Gather(acc))
+	require.Equal(t, 1, ms.starts)
+	require.Equal(t, 0, ms.stops)
+	require.Equal(t, int64(0), ri.Reconnects.Get())
+
+	// Reconnects are ignored for inputs not being service inputs
+	ri = NewRunningInput(&mockInput{}, &InputConfig{Name: "test_reconnect_plain"})
+	ri.Reconnect()
+	require.Equal(t, int64(0), ri.Reconnects.Get())
+}
+
 type mockInput struct {
 	probeReturn error
 }
@@ -548,3 +628,65 @@ func (m *mockInput) Probe() error {
 func (*mockInput) Gather(telegraf.Accumulator) error {
 	return nil
 }
+
+type mockServiceInput struct {
+	starts int
+	stops  int
+}
+
+func (*mockServiceInput) SampleConfig() string {
+	return ""
+}
+
+func (m *mockServiceInput) Start(telegraf.Accumulator) error {
+	m.starts++
+	return nil
+}
+
+func (m *mockServiceInput) Stop() {
+	m.stops++
+}
+
+func (*mockServiceInput) Gather(telegraf.Accumulator) error {
+	return nil
+}
+
+type mockReconnectableInput struct {
+	mockServiceInput
+	reconnects int
+	err        error
+}
+
+func (m *mockReconnectableInput) Reconnect() error {
+	m.reconnects++
+	return m.err
+}
+
+func TestRunningInputCardinalityLimit(t *testing.T) {
+	ri := NewRunningInput(&mockInput{}, &InputConfig{
+		Name:        "TestRunningInputCardinalityLimit",
+		Cardinality: CardinalityConfig{Limit: 10},
+	})
+	require.NoError(t, ri.Init())
+
+	var emitted int
+	for i := range 20 {
+		if ri.MakeMetric(seriesMetric(i)) != nil {
+			emitted++
+		}
+	}
+	require.InDelta(t, 10, emitted, 1)
+	require.InDelta(t, 10, ri.cardinality.cardinality.Get(), 1)
+	require.Equal(t, int64(20-emitted), ri.cardinality.limited.Get())
+
+	// Known series are still emitted
+	require.NotNil(t, ri.MakeMetric(seriesMetric(0)))
+}
+
+func TestRunningInputCardinalityInvalid(t *testing.T) {
+	ri := NewRunningInput(&mockInput{}, &InputConfig{
+		Name:        "TestRunningInputCardinalityInvalid",
+		Cardinality: CardinalityConfig{Limit: 10, Action: "foo"},
+	})
+	require.ErrorContains(t, ri.Init(), "invalid 'cardinality_action' setting")
+}
@@ -8,8 +8,10 @@ import (
 	"time"
 
 	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/filter"
 	"github.com/influxdata/telegraf/internal"
 	logging "github.com/influxdata/telegraf/logger"
+	"github.com/influxdata/telegraf/metric"
 	"github.com/influxdata/telegraf/selfstat"
 )
 
@@ -41,6 +43,30 @@ type OutputConfig struct {
 
 	BufferStrategy  string
 	BufferDirectory string
+	BufferSizeLimit int64
+	BufferMaxAge    time.Duration
+
+	RetryInitialBackoff  time.Duration
+	RetryMaxBackoff      time.Duration
+	RetryJitter          time.Duration
+	RetryMaxAttempts     int
+	RetryPermanentErrors []string
+
+	DeadLetterOutput string
+	DeadLetterFile   string
+
+	// Pipelines the output receives metrics from, the default pipeline if
+	// empty
+	Pipelines []string
+
+	// Group the output is a member of, if any
+	Group string
+
+	// References to secret-store secrets used by the plugin
+	Secrets []string
+
+	// Limit on the number of unique series written by the output
+	Cardinality CardinalityConfig
 
 	LogLevel string
 }
@@ -56,17 +82,38 @@ type RunningOutput struct {
 	MetricBufferLimit int
 	MetricBatchSize   int
 
-	MetricsFiltered selfstat.Stat
-	WriteTime       selfstat.Stat
-	StartupErrors   selfstat.Stat
+	MetricsFiltered     selfstat.Stat
+	WriteTime           selfstat.Stat
+	StartupErrors       selfstat.Stat
+	WriteRetries        selfstat.Stat
+	RetryBackoff        selfstat.Stat
+	MetricsDeadLettered selfstat.Stat
+	Reconnects          selfstat.Stat
 
 	BatchReady chan time.Time
 
 	buffer Buffer
 	log    telegraf.Logger
 
-	started bool
-	retries uint64
+	started   bool
+	retries   uint64
+	reconnect atomic.Bool
+
+	// Retry state of the currently failing batch
+	permanentErrors filter.Filter
+	writeAttempts   int
+	backoff         time.Duration
+	nextAttempt     time.Time
+
+	deadLetter  deadLetterSink
+	lastError   lastError
+	cardinality *cardinalityLimiter
+
+	// Metrics remaining in the in-memory buffer on close, serialized for
+	// persisting them as state
+	persistBuffer bool
+	leftover      [][]byte
+	leftoverMutex sync.Mutex
 
 	aggMutex sync.Mutex
 }
@@ -100,7 +147,8 @@ func NewRunningOutput(output telegraf.Output, config *OutputConfig, batchSize, b
 		batchSize = DefaultMetricBatchSize
 	}
 
-	b, err := NewBuffer(config.Name, config.ID, config.Alias, bufferLimit, config.BufferStrategy, config.BufferDirectory)
+	b, err := NewBuffer(config.Name, config.ID, config.Alias, bufferLimit, config.BufferStrategy, config.BufferDirectory,
+		config.BufferSizeLimit, config.BufferMaxAge)
 	if err != nil {
 		panic(err)
 	}
@@ -127,8 +175,31 @@ func NewRunningOutput(output telegraf.Output, config *OutputConfig, batchSize, b
 			"startup_errors",
 			tags,
 		),
+		WriteRetries: selfstat.Register(
+			"write",
+			"write_retries",
+			tags,
+		),
+		RetryBackoff: selfstat.Register(
+			"write",
+			"retry_backoff_ns",
+			tags,
+		),
+		MetricsDeadLettered: selfstat.Register(
+			"write",
+			"metrics_dead_lettered",
+			tags,
+		),
+		Reconnects: selfstat.Register(
+			"write",
+			"reconnects",
+			tags,
+		),
 		log: logger,
 	}
+	if config.Cardinality.Limit != 0 {
+		ro.cardinality = newCardinalityLimiter(&config.Cardinality, "write", tags, logger)
+	}
 
 	return ro
 }
@@ -156,6 +227,37 @@ func (r *RunningOutput) Init() error {
 		return fmt.Errorf("invalid 'startup_error_behavior' setting %q", r.Config.StartupErrorBehavior)
 	}
 
+	if r.Config.RetryMaxAttempts < 0 {
+		return fmt.Errorf("invalid 'write_retry_max_attempts' setting %d", r.Config.RetryMaxAttempts)
+	}
+	if r.Config.RetryMaxBackoff > 0 && r.Config.RetryMaxBackoff < r.Config.RetryInitialBackoff {
+		return errors.New("'write_retry_max_backoff' must not be smaller than 'write_retry_initial_backoff'")
+	}
+	if len(r.Config.RetryPermanentErrors) > 0 {
+		f, err := filter.Compile(r.Config.RetryPermanentErrors)
+		if err != nil {
+			return fmt.Errorf("compiling 'write_retry_permanent_errors' failed: %w", err)
+		}
+		r.permanentErrors = f
+	}
+
+	if r.Config.DeadLetterOutput != "" && r.Config.DeadLetterFile != "" {
+		return errors.New("'dead_letter_output' and 'dead_letter_file' are mutually exclusive")
+	}
+	if r.Config.DeadLetterFile != "" {
+		sink, err := newDeadLetterFile(r.Config.DeadLetterFile)
+		if err != nil {
+			return fmt.Errorf("opening dead-letter file failed: %w", err)
+		}
+		r.deadLetter = sink
+	}
+
+	if r.cardinality != nil {
+		if err := r.cardinality.init(); err != nil {
+			return err
+		}
+	}
+
 	if p, ok := r.Output.(telegraf.Initializer); ok {
 		err := p.Init()
 		if err != nil {
@@ -201,9 +303,68 @@ func (r *RunningOutput) Close() {
 		r.log.Errorf("Error closing output: %v", err)
 	}
 
+	if r.persistBuffer {
+		leftover := r.serializeBuffer()
+		if len(leftover) > 0 {
+			r.log.Infof("Persisting %d metrics remaining in the buffer", len(leftover))
+		}
+		r.leftoverMutex.Lock()
+		r.leftover = leftover
+		r.leftoverMutex.Unlock()
+	}
+
 	if err := r.buffer.Close(); err != nil {
 		r.log.Errorf("Error closing output buffer: %v", err)
 	}
+
+	if r.deadLetter != nil {
+		if err := r.deadLetter.Close(); err != nil {
+			r.log.Errorf("Error closing dead-letter destination: %v", err)
+		}
+	}
+}
+
+// Release frees the buffer and dead-letter destination of an output that was
+// never connected. In contrast to Close, the plugin itself is not closed.
+func (r *RunningOutput) Release() {
+	if err := r.buffer.Close(); err != nil {
+		r.log.Errorf("Error closing output buffer: %v", err)
+	}
+
+	if r.deadLetter != nil {
+		if err := r.deadLetter.Close(); err != nil {
+			r.log.Errorf("Error closing dead-letter destination: %v", err)
+		}
+	}
+}
+
+// ResetBufferStats sets the buffer statistics to the current state of the
+// buffer. This is required if another output with the same name and alias
+// registered the shared statistics anew.
+func (r *RunningOutput) ResetBufferStats() {
+	stats := r.buffer.Stats()
+	stats.BufferSize.Set(int64(r.buffer.Len()))
+	stats.BufferLimit.Set(int64(r.MetricBufferLimit))
+}
+
+// UnregisterStats removes the internal statistics of the output from the
+// selfstat registry.
+func (r *RunningOutput) UnregisterStats() {
+	selfstat.Unregister("write", statTags("output", r.Config.Name, r.Config.Alias))
+}
+
+// SetDeadLetterOutput sets the output receiving the metrics given up by this
+// output according to the retry policy. The metrics are added to the target
+// without applying the target's selection filters.
+func (r *RunningOutput) SetDeadLetterOutput(target *RunningOutput) error {
+	if target == r {
+		return errors.New("output cannot be its own dead-letter output")
+	}
+	if target.Config.DeadLetterOutput != "" || target.Config.DeadLetterFile != "" {
+		return fmt.Errorf("dead-letter output %s must not have a dead-letter destination itself", target.LogName())
+	}
+	r.deadLetter = &deadLetterOutput{target: target}
+	return nil
 }
 
 // AddMetric adds a metric to the output.
@@ -213,6 +374,7 @@ func (r *RunningOutput) AddMetric(metric telegraf.Metric) {
 	if err != nil {
 		r.log.Errorf("filtering failed: %v", err)
 	} else if !ok {
+		trace(r.LogName(), TraceStageOutput, "filtered", metric)
 		r.MetricsFiltered.Incr(1)
 		return
 	}
@@ -227,6 +389,7 @@ func (r *RunningOutput) AddMetricNoCopy(metric telegraf.Metric) {
 	if err != nil {
 		r.log.Errorf("filtering failed: %v", err)
 	} else if !ok {
+		trace(r.LogName(), TraceStageOutput, "filtered", metric)
 		r.metricFiltered(metric)
 		return
 	}
@@ -234,14 +397,28 @@ func (r *RunningOutput) AddMetricNoCopy(metric telegraf.Metric) {
 	r.add(metric)
 }
 
+// AddTrace adds the metric of a trace record to the output bypassing all
+// filters of the output.
+func (r *RunningOutput) AddTrace(metric telegraf.Metric) {
+	r.buffer.Add(metric)
+}
+
 func (r *RunningOutput) add(metric telegraf.Metric) {
 	r.Config.Filter.Modify(metric)
 	if len(metric.FieldList()) == 0 {
+		trace(r.LogName(), TraceStageOutput, "filtered", metric)
 		r.metricFiltered(metric)
 		return
 	}
 
+	if r.cardinality != nil && !r.cardinality.apply(metric) {
+		trace(r.LogName(), TraceStageOutput, "dropped", metric)
+		metric.Drop()
+		return
+	}
+
 	if output, ok := r.Output.(telegraf.AggregatingOutput); ok {
+		trace(r.LogName(), TraceStageOutput, "aggregated", metric)
 		r.aggMutex.Lock()
 		output.Add(metric)
 		r.aggMutex.Unlock()
@@ -260,6 +437,7 @@ func (r *RunningOutput) add(metric telegraf.Metric) {
 		metric.AddSuffix(r.Config.NameSuffix)
 	}
 
+	trace(r.LogName(), TraceStageOutput, "buffered", metric)
 	dropped := r.buffer.Add(metric)
 	atomic.AddInt64(&r.droppedMetrics, int64(dropped))
 
@@ -276,6 +454,8 @@ func (r *RunningOutput) add(metric telegraf.Metric) {
 // Write writes all metrics to the output, stopping when all have been sent on
 // or error.
 func (r *RunningOutput) Write() error {
+	r.closeForReconnect()
+
 	// Try to connect if we are not yet started up
 	if !r.started {
 		r.retries++
@@ -283,6 +463,7 @@ func (r *RunningOutput) Write() error {
 			var serr *internal.StartupError
 			if !errors.As(err, &serr) || !serr.Retry || !serr.Partial {
 				r.StartupErrors.Incr(1)
+				r.lastError.set(err)
 				return internal.ErrNotConnected
 			}
 			r.log.Debugf("Partially connected after %d attempts", r.retries)
@@ -300,6 +481,10 @@ func (r *RunningOutput) Write() error {
 		r.aggMutex.Unlock()
 	}
 
+	if r.backingOff() {
+		return nil
+	}
+
 	atomic.StoreInt64(&r.newMetricsCount, 0)
 
 	// Only process the metrics in the buffer now. Metrics added while we are
@@ -313,6 +498,7 @@ func (r *RunningOutput) Write() error {
 		}
 		err := r.writeMetrics(tx.Batch)
 		r.updateTransaction(tx, err)
+		r.traceTransaction(tx)
 		r.buffer.EndTransaction(tx)
 		if err != nil {
 			return err
@@ -323,28 +509,58 @@ func (r *RunningOutput) Write() error {
 
 // WriteBatch writes a single batch of metrics to the output.
 func (r *RunningOutput) WriteBatch() error {
+	r.closeForReconnect()
+
 	// Try to connect if we are not yet started up
 	if !r.started {
 		r.retries++
 		if err := r.Output.Connect(); err != nil {
 			r.StartupErrors.Incr(1)
+			r.lastError.set(err)
 			return internal.ErrNotConnected
 		}
 		r.started = true
 		r.log.Debugf("Successfully connected after %d attempts", r.retries)
 	}
 
+	if r.backingOff() {
+		return nil
+	}
+
 	tx := r.buffer.BeginTransaction(r.MetricBatchSize)
 	if len(tx.Batch) == 0 {
 		return nil
 	}
 	err := r.writeMetrics(tx.Batch)
 	r.updateTransaction(tx, err)
+	r.traceTransaction(tx)
 	r.buffer.EndTransaction(tx)
 
 	return err
 }
 
+// RequestReconnect requests closing and reconnecting the output before the
+// next write, e.g. to pick up rotated credentials. Metrics stay buffered
+// while the output is reconnecting.
+func (r *RunningOutput) RequestReconnect() {
+	r.reconnect.Store(true)
+}
+
+// closeForReconnect closes the output if a reconnect was requested, so the
+// following write connects the output again
+func (r *RunningOutput) closeForReconnect() {
+	if !r.reconnect.CompareAndSwap(true, false) || !r.started {
+		return
+	}
+	r.log.Info("Reconnecting to apply rotated secrets")
+	if err := r.Output.Close(); err != nil {
+		r.log.Errorf("Error closing output for reconnect: %v", err)
+	}
+	r.started = false
+	r.retries = 0
+	r.Reconnects.Incr(1)
+}
+
 func (r *RunningOutput) writeMetrics(metrics []telegraf.Metric) error {
 	dropped := atomic.LoadInt64(&r.droppedMetrics)
 	if dropped > 0 {
@@ -359,35 +575,158 @@ func (r *RunningOutput) writeMetrics(metrics []telegraf.Metric) error {
 
 	if err == nil {
 		r.log.Debugf("Wrote batch of %d metrics in %s", len(metrics), elapsed)
+	} else {
+		r.lastError.set(err)
 	}
 	return err
 }
 
-func (*RunningOutput) updateTransaction(tx *Transaction, err error) {
+func (r *RunningOutput) updateTransaction(tx *Transaction, err error) {
 	// No error indicates all metrics were written successfully
 	if err == nil {
 		tx.AcceptAll()
+		r.resetRetry()
 		return
 	}
 
 	// A non-partial-write-error indicated none of the metrics were written
-	// successfully and we should keep them for the next write cycle
+	// successfully and we should keep them for the next write cycle.
+	// Otherwise, transfer the accepted and rejected indices based on the
+	// write error values.
 	var writeErr *internal.PartialWriteError
-	if !errors.As(err, &writeErr) {
-		tx.KeepAll()
+	if errors.As(err, &writeErr) {
+		tx.Accept = writeErr.MetricsAccept
+		tx.Reject = writeErr.MetricsReject
+	}
+
+	// Check if we should retry the remaining metrics or give up on them
+	// according to the retry policy.
+	if keep := tx.InferKeep(); len(keep) > 0 {
+		if r.retryable(err) {
+			r.scheduleRetry()
+		} else {
+			tx.Reject = append(tx.Reject, keep...)
+			r.resetRetry()
+		}
+	} else {
+		r.resetRetry()
+	}
+
+	r.deadLetterRejected(tx)
+}
+
+// traceTransaction records the result of the write for all traced metrics of
+// the transaction. This must happen before ending the transaction as the
+// buffer releases the metrics at this point.
+func (r *RunningOutput) traceTransaction(tx *Transaction) {
+	if activeTracer.Load() == nil {
+		return
+	}
+
+	for _, idx := range tx.Accept {
+		trace(r.LogName(), TraceStageOutput, "accepted", tx.Batch[idx])
+	}
+	for _, idx := range tx.Reject {
+		trace(r.LogName(), TraceStageOutput, "rejected", tx.Batch[idx])
+	}
+	for _, idx := range tx.InferKeep() {
+		trace(r.LogName(), TraceStageOutput, "retry", tx.Batch[idx])
+	}
+}
+
+// backingOff returns true if the output is waiting for the backoff of a
+// previously failed write to expire.
+func (r *RunningOutput) backingOff() bool {
+	wait := time.Until(r.nextAttempt)
+	if wait <= 0 {
+		return false
+	}
+	r.log.Debugf("Backing off after %d failed write attempt(s), retrying in %s", r.writeAttempts, wait)
+	return true
+}
+
+// RetryAfter returns the remaining backoff time until the next write attempt
+// of a previously failed write.
+func (r *RunningOutput) RetryAfter() time.Duration {
+	return max(time.Until(r.nextAttempt), 0)
+}
+
+// retryable checks the given write error against the retry policy
+func (r *RunningOutput) retryable(err error) bool {
+	if r.permanentErrors != nil && r.permanentErrors.Match(err.Error()) {
+		r.log.Errorf("Permanent write error, giving up on metrics: %v", err)
+		return false
+	}
+
+	if r.Config.RetryMaxAttempts > 0 && r.writeAttempts+1 >= r.Config.RetryMaxAttempts {
+		r.log.Errorf("Giving up on metrics after %d failed write attempts: %v", r.writeAttempts+1, err)
+		return false
+	}
+
+	return true
+}
+
+// scheduleRetry records a failed write attempt and computes the exponential
+// backoff for the next attempt.
+func (r *RunningOutput) scheduleRetry() {
+	r.writeAttempts++
+	r.WriteRetries.Incr(1)
+
+	if r.Config.RetryInitialBackoff <= 0 {
+		return
+	}
+
+	if r.backoff == 0 {
+		r.backoff = r.Config.RetryInitialBackoff
+	} else {
+		r.backoff *= 2
+	}
+	if r.Config.RetryMaxBackoff > 0 && r.backoff > r.Config.RetryMaxBackoff {
+		r.backoff = r.Config.RetryMaxBackoff
+	}
+
+	delay := r.backoff + internal.RandomDuration(r.Config.RetryJitter)
+	r.nextAttempt = time.Now().Add(delay)
+	r.RetryBackoff.Set(delay.Nanoseconds())
+}
+
+func (r *RunningOutput) resetRetry() {
+	if r.writeAttempts == 0 && r.backoff == 0 {
+		return
+	}
+	r.writeAttempts = 0
+	r.backoff = 0
+	r.nextAttempt = time.Time{}
+	r.RetryBackoff.Set(0)
+}
+
+// deadLetterRejected passes the rejected metrics of the transaction to the
+// dead-letter destination if any. This must happen before ending the
+// transaction as the buffer releases the metrics at this point.
+func (r *RunningOutput) deadLetterRejected(tx *Transaction) {
+	if r.deadLetter == nil || len(tx.Reject) == 0 {
 		return
 	}
 
-	// Transfer the accepted and rejected indices based on the write error values
-	tx.Accept = writeErr.MetricsAccept
-	tx.Reject = writeErr.MetricsReject
+	metrics := make([]telegraf.Metric, 0, len(tx.Reject))
+	for _, idx := range tx.Reject {
+		metrics = append(metrics, tx.Batch[idx])
+	}
+	if err := r.deadLetter.Write(metrics); err != nil {
+		r.log.Errorf("Writing %d metrics to dead-letter destination failed: %v", len(metrics), err)
+		return
+	}
+	r.MetricsDeadLettered.Incr(int64(len(metrics)))
 }
 
 func (r *RunningOutput) LogBufferStatus() {
 	nBuffer := r.buffer.Len()
-	if r.Config.BufferStrategy == "disk" {
+	switch r.Config.BufferStrategy {
+	case "disk":
 		r.log.Debugf("Buffer fullness: %d metrics", nBuffer)
-	} else {
+	case "overflow":
+		r.log.Debugf("Buffer fullness: %d metrics (%d in memory)", nBuffer, r.buffer.(*OverflowBuffer).memoryLen())
+	default:
 		r.log.Debugf("Buffer fullness: %d / %d metrics", nBuffer, r.MetricBufferLimit)
 	}
 }
@@ -399,3 +738,97 @@ func (r *RunningOutput) Log() telegraf.Logger {
 func (r *RunningOutput) BufferLength() int {
 	return r.buffer.Len()
 }
+
+// BufferStats returns the statistics of the output's metric buffer.
+func (r *RunningOutput) BufferStats() BufferStats {
+	return r.buffer.Stats()
+}
+
+// BufferDiskUsage returns the number of bytes occupied by the metric buffer
+// on disk. In-memory buffers always report zero.
+func (r *RunningOutput) BufferDiskUsage() int64 {
+	switch b := r.buffer.(type) {
+	case *DiskBuffer:
+		return b.DiskUsage()
+	case *OverflowBuffer:
+		return b.DiskUsage()
+	}
+	return 0
+}
+
+// BufferState returns a stateful plugin for persisting the metrics remaining
+// in the output's buffer on close and restoring them on startup. The result is
+// nil if the buffer persists the metrics itself, e.g. when buffering on disk.
+func (r *RunningOutput) BufferState() telegraf.StatefulPlugin {
+	if _, ok := r.buffer.(*MemoryBuffer); !ok {
+		return nil
+	}
+	r.persistBuffer = true
+	return &bufferState{output: r}
+}
+
+// serializeBuffer takes all metrics out of the buffer and serializes them.
+// The buffer must not be used afterwards.
+func (r *RunningOutput) serializeBuffer() [][]byte {
+	tx := r.buffer.BeginTransaction(r.buffer.Len())
+	serialized := make([][]byte, 0, len(tx.Batch))
+	for _, m := range tx.Batch {
+		data, err := metric.ToBytes(m)
+		if err != nil {
+			r.log.Errorf("Serializing metric failed: %v; dropping metric", err)
+			stats := r.buffer.Stats()
+			stats.metricDropped(m)
+			continue
+		}
+		serialized = append(serialized, data)
+	}
+	return serialized
+}
+
+// bufferState exposes the metrics remaining in the output's buffer as state.
+type bufferState struct {
+	output *RunningOutput
+}
+
+func (s *bufferState) GetState() interface{} {
+	s.output.leftoverMutex.Lock()
+	defer s.output.leftoverMutex.Unlock()
+
+	// Persist an empty buffer as long as the output is running
+	if s.output.leftover == nil {
+		return [][]byte{}
+	}
+	return s.output.leftover
+}
+
+func (s *bufferState) SetState(state interface{}) error {
+	serialized, ok := state.([][]byte)
+	if !ok {
+		return fmt.Errorf("invalid buffer state type %T", state)
+	}
+
+	metrics := make([]telegraf.Metric, 0, len(serialized))
+	for _, data := range serialized {
+		m, err := metric.FromBytes(data)
+		if err != nil {
+			// Tracking metrics cannot be restored as the tracking information
+			// is lost on shutdown.
+			if !errors.Is(err, metric.ErrSkipTracking) {
+				s.output.log.Errorf("Restoring metric failed: %v; dropping metric", err)
+			}
+			continue
+		}
+		metrics = append(metrics, m)
+	}
+	s.output.log.Debugf("Restoring %d metrics persisted on the last shutdown", len(metrics))
+	dropped := s.output.buffer.Add(metrics...)
+	atomic.AddInt64(&s.output.droppedMetrics, int64(dropped))
+
+	return nil
+}
+
+// LastError returns the most recent error of connecting or writing to the
+// output and the time it occurred. The error is nil if no error occurred yet.
+func (r *RunningOutput) LastError() (time.Time, error) {
+	return r.lastError.get()
+}
@@ -2,6 +2,8 @@ package models
 
 import (
 	"errors"
+	"os"
+	"path/filepath"
 	"sync"
 	"testing"
 	"time"
@@ -431,16 +433,20 @@ func TestRunningOutputInternalMetrics(t *testing.T) {
 				"alias":  "test_alias",
 			},
 			map[string]interface{}{
-				"buffer_limit":     10,
-				"buffer_size":      0,
-				"errors":           0,
-				"metrics_added":    0,
-				"metrics_rejected": 0,
-				"metrics_dropped":  0,
-				"metrics_filtered": 0,
-				"metrics_written":  0,
-				"write_time_ns":    0,
-				"startup_errors":   0,
+				"buffer_limit":          10,
+				"buffer_size":           0,
+				"errors":                0,
+				"metrics_added":         0,
+				"metrics_rejected":      0,
+				"metrics_dropped":       0,
+				"metrics_filtered":      0,
+				"metrics_written":       0,
+				"write_time_ns":         0,
+				"startup_errors":        0,
+				"write_retries":         0,
+				"retry_backoff_ns":      0,
+				"metrics_dead_lettered": 0,
+				"reconnects":            0,
 			},
 			time.Unix(0, 0),
 		),
@@ -457,6 +463,35 @@ func TestRunningOutputInternalMetrics(t *testing.T) {
 	testutil.RequireMetricsEqual(t, expected, actual, testutil.IgnoreTime())
 }
 
+func TestRunningOutputReconnect(t *testing.T) {
+	mo := &mockOutput{}
+	ro := NewRunningOutput(mo, &OutputConfig{Name: "test_reconnect"}, 5, 10)
+	require.NoError(t, ro.Init())
+	require.NoError(t, ro.Connect())
+	require.Equal(t, 1, mo.connects)
+
+	ro.AddMetric(testutil.TestMetric(101, "metric1"))
+	require.NoError(t, ro.Write())
+	require.Equal(t, 0, mo.closes)
+
+	// Request a reconnect e.g. due to rotated credentials and make sure the
+	// output is closed and connected again before writing
+	ro.RequestReconnect()
+	ro.AddMetric(testutil.TestMetric(102, "metric2"))
+	require.NoError(t, ro.Write())
+	require.Equal(t, 1, mo.closes)
+	require.Equal(t, 2, mo.connects)
+	require.Len(t, mo.Metrics(), 2)
+	require.Equal(t, int64(1), ro.Reconnects.Get())
+
+	// Further writes must not reconnect
+	ro.AddMetric(testutil.TestMetric(103, "metric3"))
+	require.NoError(t, ro.WriteBatch())
+	require.Equal(t, 1, mo.closes)
+	require.Equal(t, 2, mo.connects)
+	require.Len(t, mo.Metrics(), 3)
+}
+
 func TestRunningOutputStartupBehaviorInvalid(t *testing.T) {
 	ro := NewRunningOutput(
 		&mockOutput{},
@@ -864,6 +899,221 @@ func TestRunningOutputWriteBatchPartialSuccessAndLoss(t *testing.T) {
 	require.Zero(t, model.buffer.Len())
 }
 
+func TestRunningOutputRetryBackoff(t *testing.T) {
+	conf := &OutputConfig{
+		Name:                "test_retry_backoff",
+		Filter:              Filter{},
+		RetryInitialBackoff: time.Hour,
+	}
+
+	m := &mockOutput{batchAcceptSize: -1}
+	ro := NewRunningOutput(m, conf, 5, 10)
+	require.NoError(t, ro.Init())
+
+	for _, metric := range first5 {
+		ro.AddMetric(metric)
+	}
+
+	// The first write fails and the output should back off
+	require.Error(t, ro.Write())
+	require.Equal(t, 1, m.writes)
+	require.Equal(t, int64(1), ro.WriteRetries.Get())
+	require.GreaterOrEqual(t, ro.RetryBackoff.Get(), time.Hour.Nanoseconds())
+
+	// Further writes should not reach the output during the backoff
+	m.batchAcceptSize = 0
+	require.NoError(t, ro.Write())
+	require.NoError(t, ro.WriteBatch())
+	require.Equal(t, 1, m.writes)
+	require.Empty(t, m.Metrics())
+	require.Equal(t, 5, ro.BufferLength())
+
+	// Expire the backoff and make sure the metrics are written
+	ro.nextAttempt = time.Now()
+	require.NoError(t, ro.Write())
+	require.Len(t, m.Metrics(), 5)
+	require.Zero(t, ro.RetryBackoff.Get())
+}
+
+func TestRunningOutputRetryBackoffExponential(t *testing.T) {
+	conf := &OutputConfig{
+		Name:                "test_retry_backoff_exponential",
+		Filter:              Filter{},
+		RetryInitialBackoff: time.Second,
+		RetryMaxBackoff:     5 * time.Second,
+	}
+
+	m := &mockOutput{batchAcceptSize: -1}
+	ro := NewRunningOutput(m, conf, 5, 10)
+	require.NoError(t, ro.Init())
+	ro.AddMetric(testutil.TestMetric(101, "metric1"))
+
+	expected := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second}
+	for _, backoff := range expected {
+		ro.nextAttempt = time.Time{}
+		require.Error(t, ro.Write())
+		require.Equal(t, backoff.Nanoseconds(), ro.RetryBackoff.Get())
+	}
+}
+
+func TestRunningOutputRetryMaxAttempts(t *testing.T) {
+	conf := &OutputConfig{
+		Name:             "test_retry_max_attempts",
+		Filter:           Filter{},
+		RetryMaxAttempts: 3,
+	}
+
+	m := &mockOutput{batchAcceptSize: -1}
+	ro := NewRunningOutput(m, conf, 5, 10)
+	require.NoError(t, ro.Init())
+
+	for _, metric := range first5 {
+		ro.AddMetric(metric)
+	}
+
+	require.Error(t, ro.Write())
+	require.Error(t, ro.Write())
+	require.Equal(t, 5, ro.BufferLength())
+
+	// The third attempt exhausts the retries and the batch is dropped
+	require.Error(t, ro.Write())
+	require.Equal(t, 3, m.writes)
+	require.Zero(t, ro.BufferLength())
+	require.Equal(t, int64(2), ro.WriteRetries.Get())
+}
+
+func TestRunningOutputRetryPermanentError(t *testing.T) {
+	conf := &OutputConfig{
+		Name:                 "test_retry_permanent_error",
+		Filter:               Filter{},
+		RetryPermanentErrors: []string{"failed*"},
+	}
+
+	m := &mockOutput{batchAcceptSize: -1}
+	ro := NewRunningOutput(m, conf, 5, 10)
+	require.NoError(t, ro.Init())
+
+	for _, metric := range first5 {
+		ro.AddMetric(metric)
+	}
+
+	require.Error(t, ro.Write())
+	require.Equal(t, 1, m.writes)
+	require.Zero(t, ro.BufferLength())
+	require.Zero(t, ro.WriteRetries.Get())
+}
+
+func TestRunningOutputDeadLetterFile(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "dead_letter.influx")
+	conf := &OutputConfig{
+		Name:             "test_dead_letter_file",
+		Filter:           Filter{},
+		RetryMaxAttempts: 1,
+		DeadLetterFile:   filename,
+	}
+
+	m := &mockOutput{batchAcceptSize: -1}
+	ro := NewRunningOutput(m, conf, 5, 10)
+	require.NoError(t, ro.Init())
+
+	ro.AddMetric(testutil.TestMetric(101, "metric1"))
+	ro.AddMetric(testutil.TestMetric(102, "metric2"))
+	require.Error(t, ro.Write())
+	require.Zero(t, ro.BufferLength())
+	require.Equal(t, int64(2), ro.MetricsDeadLettered.Get())
+	ro.Close()
+
+	buf, err := os.ReadFile(filename)
+	require.NoError(t, err)
+	expected := "metric1,tag1=value1 value=101i 1257894000000000000\n" +
+		"metric2,tag1=value1 value=102i 1257894000000000000\n"
+	require.Equal(t, expected, string(buf))
+}
+
+func TestRunningOutputBufferState(t *testing.T) {
+	conf := &OutputConfig{
+		Name:   "test_buffer_state",
+		Filter: Filter{},
+	}
+
+	m := &mockOutput{batchAcceptSize: -1}
+	ro := NewRunningOutput(m, conf, 5, 10)
+	require.NoError(t, ro.Init())
+	state := ro.BufferState()
+	require.NotNil(t, state)
+
+	for _, metric := range first5 {
+		ro.AddMetric(metric)
+	}
+	require.Error(t, ro.Write())
+	ro.Close()
+
+	// The remaining metrics must be restored in a new instance
+	persisted := state.GetState()
+	require.Len(t, persisted, 5)
+
+	m = &mockOutput{}
+	ro = NewRunningOutput(m, conf, 5, 10)
+	require.NoError(t, ro.Init())
+	require.NoError(t, ro.BufferState().SetState(persisted))
+	require.Equal(t, 5, ro.BufferLength())
+	require.NoError(t, ro.Write())
+	testutil.RequireMetricsEqual(t, first5, m.Metrics())
+}
+
+func TestRunningOutputBufferStateDisk(t *testing.T) {
+	conf := &OutputConfig{
+		Name:            "test_buffer_state_disk",
+		Filter:          Filter{},
+		BufferStrategy:  "disk",
+		BufferDirectory: t.TempDir(),
+	}
+
+	ro := NewRunningOutput(&mockOutput{}, conf, 5, 10)
+	defer ro.Close()
+	require.Nil(t, ro.BufferState())
+}
+
+func TestRunningOutputDeadLetterOutput(t *testing.T) {
+	conf := &OutputConfig{
+		Name:             "test_dead_letter_output",
+		Filter:           Filter{},
+		RetryMaxAttempts: 1,
+	}
+	m := &mockOutput{batchAcceptSize: -1}
+	ro := NewRunningOutput(m, conf, 5, 10)
+	require.NoError(t, ro.Init())
+
+	// Setup a dead-letter output not selecting any metric
+	dlConf := &OutputConfig{
+		Filter: Filter{NamePass: []string{"nothing"}},
+	}
+	require.NoError(t, dlConf.Filter.Compile())
+	dl := &mockOutput{}
+	dlo := NewRunningOutput(dl, dlConf, 5, 10)
+	require.NoError(t, dlo.Init())
+
+	require.Error(t, ro.SetDeadLetterOutput(ro))
+	require.NoError(t, ro.SetDeadLetterOutput(dlo))
+
+	// Partially rejected metrics should end up in the dead-letter output
+	// as well
+	idx := 1
+	m.batchAcceptSize = 3
+	m.metricFatalIndex = &idx
+	for _, metric := range first5 {
+		ro.AddMetric(metric)
+	}
+	require.Error(t, ro.Write())
+	require.Len(t, m.Metrics(), 2)
+	require.Zero(t, ro.BufferLength())
+	require.Equal(t, 3, dlo.BufferLength())
+
+	require.NoError(t, dlo.Write())
+	expected := []telegraf.Metric{first5[1], first5[3], first5[4]}
+	testutil.RequireMetricsEqual(t, expected, dl.Metrics())
+}
+
 // Benchmark adding metrics.
 func BenchmarkRunningOutputAddWrite(b *testing.B) {
 	conf := &OutputConfig{
@@ -919,9 +1169,13 @@ type mockOutput struct {
 	startupError      error
 	startupErrorCount int
 	writes            int
+
+	connects int
+	closes   int
 }
 
 func (m *mockOutput) Connect() error {
+	m.connects++
 	if m.startupErrorCount == 0 {
 		return nil
 	}
@@ -931,7 +1185,8 @@ func (m *mockOutput) Connect() error {
 	return m.startupError
 }
 
-func (*mockOutput) Close() error {
+func (m *mockOutput) Close() error {
+	m.closes++
 	return nil
 }
 
@@ -998,3 +1253,36 @@ func (m *perfOutput) Write([]telegraf.Metric) error {
 	}
 	return nil
 }
+
+func TestRunningOutputCardinalityLimit(t *testing.T) {
+	conf := &OutputConfig{
+		Name: "TestRunningOutputCardinalityLimit",
+		Cardinality: CardinalityConfig{
+			Limit:    10,
+			Action:   "drop_tags",
+			DropTags: []string{"id"},
+		},
+	}
+
+	m := &mockOutput{}
+	ro := NewRunningOutput(m, conf, 1000, 10000)
+	require.NoError(t, ro.Init())
+
+	for i := range 20 {
+		ro.AddMetric(seriesMetric(i))
+	}
+	require.NoError(t, ro.Write())
+
+	// All metrics are written, those of series exceeding the limit without
+	// the dropped tag
+	metrics := m.Metrics()
+	require.Len(t, metrics, 20)
+	var stripped int
+	for _, metric := range metrics {
+		if !metric.HasTag("id") {
+			stripped++
+		}
+	}
+	require.InDelta(t, 10, stripped, 1)
+	require.Equal(t, int64(stripped), ro.cardinality.limited.Get())
+}
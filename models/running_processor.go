@@ -30,6 +30,9 @@ type ProcessorConfig struct {
 	Order    int64
 	Filter   Filter
 	LogLevel string
+
+	// Pipeline the processor is part of, the default pipeline if empty
+	Pipeline string
 }
 
 func NewRunningProcessor(processor telegraf.StreamingProcessor, config *ProcessorConfig) *RunningProcessor {
@@ -98,6 +101,7 @@ func (rp *RunningProcessor) Add(m telegraf.Metric, acc telegraf.Accumulator) err
 		rp.log.Errorf("filtering failed: %v", err)
 	} else if !ok {
 		// pass downstream
+		trace(rp.LogName(), TraceStageProcessor, "skipped", m)
 		acc.AddMetric(m)
 		return nil
 	}
@@ -105,13 +109,25 @@ func (rp *RunningProcessor) Add(m telegraf.Metric, acc telegraf.Accumulator) err
 	rp.Config.Filter.Modify(m)
 	if len(m.FieldList()) == 0 {
 		// drop metric
+		trace(rp.LogName(), TraceStageProcessor, "filtered", m)
 		rp.metricFiltered(m)
 		return nil
 	}
 
+	if t := tracing(m); t != nil {
+		t.record(rp.LogName(), TraceStageProcessor, "input", m)
+		acc = &traceAccumulator{Accumulator: acc, tracer: t, plugin: rp.LogName()}
+	}
+
 	return rp.Processor.Add(m, acc)
 }
 
+// UnregisterStats removes the internal statistics of the processor from the
+// selfstat registry.
+func (rp *RunningProcessor) UnregisterStats() {
+	selfstat.Unregister("process", statTags("processor", rp.Config.Name, rp.Config.Alias))
+}
+
 func (rp *RunningProcessor) Stop() {
 	rp.Processor.Stop()
 }
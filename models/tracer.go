@@ -0,0 +1,162 @@
+package models
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/cel-go/cel"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/metric"
+	"github.com/influxdata/telegraf/plugins/serializers/influx"
+)
+
+// TraceMeasurement is the name of the metrics emitted for trace records.
+// Metrics of this name are never traced themselves.
+const TraceMeasurement = "telegraf_trace"
+
+// Stages of the pipeline recorded in trace records
+const (
+	TraceStageInput      = "input"
+	TraceStageProcessor  = "processor"
+	TraceStageAggregator = "aggregator"
+	TraceStageOutput     = "output"
+)
+
+// TraceRecord describes a single hop of a traced metric through the pipeline.
+type TraceRecord struct {
+	Time   time.Time
+	Plugin string
+	Stage  string
+	Action string
+	Series uint64
+	Line   string
+}
+
+func (r *TraceRecord) String() string {
+	return fmt.Sprintf("%s %s %s (series %016x): %s", r.Stage, r.Plugin, r.Action, r.Series, r.Line)
+}
+
+// Metric converts the record to a metric suitable for writing to an output.
+func (r *TraceRecord) Metric() telegraf.Metric {
+	return metric.New(
+		TraceMeasurement,
+		map[string]string{
+			"plugin": r.Plugin,
+			"stage":  r.Stage,
+			"action": r.Action,
+		},
+		map[string]interface{}{
+			"metric":    r.Line,
+			"series_id": fmt.Sprintf("%016x", r.Series),
+		},
+		r.Time,
+	)
+}
+
+// Tracer records the hops of all metrics matching a CEL selector through the
+// pipeline and passes the records to the emit function.
+type Tracer struct {
+	selector   cel.Program
+	emit       func(*TraceRecord)
+	serializer *influx.Serializer
+
+	sync.Mutex
+}
+
+// activeTracer is the tracer used by all running plugins, nil if tracing is
+// disabled
+var activeTracer atomic.Pointer[Tracer]
+
+// NewTracer creates a tracer for metrics matching the given CEL expression.
+// The expression uses the same variables as the "metricpass" filter option.
+func NewTracer(selector string, emit func(*TraceRecord)) (*Tracer, error) {
+	program, err := compileMetricSelector(selector)
+	if err != nil {
+		return nil, fmt.Errorf("compiling trace selector failed: %w", err)
+	}
+
+	serializer := &influx.Serializer{SortFields: true, UintSupport: true}
+	if err := serializer.Init(); err != nil {
+		return nil, err
+	}
+
+	return &Tracer{
+		selector:   program,
+		emit:       emit,
+		serializer: serializer,
+	}, nil
+}
+
+// SetTracer activates the given tracer for all running plugins. Passing nil
+// disables tracing.
+func SetTracer(t *Tracer) {
+	activeTracer.Store(t)
+}
+
+// selectTrace marks the metric as traced if it matches the selector of the
+// active tracer. The selector is only evaluated where metrics enter the
+// pipeline, so metrics are still traced after being renamed or retagged.
+func selectTrace(m telegraf.Metric) {
+	t := activeTracer.Load()
+	if t == nil || m.Name() == TraceMeasurement || metric.Traced(m) {
+		return
+	}
+	// Do not trace metrics if the selector cannot be evaluated
+	if ok, err := evalMetricSelector(t.selector, m); err == nil && ok {
+		metric.SetTraced(m)
+	}
+}
+
+// tracing returns the active tracer if the given metric is traced
+func tracing(m telegraf.Metric) *Tracer {
+	t := activeTracer.Load()
+	if t == nil || !metric.Traced(m) {
+		return nil
+	}
+	return t
+}
+
+// trace records the hop of the metric if the metric should be traced
+func trace(plugin, stage, action string, m telegraf.Metric) {
+	if t := tracing(m); t != nil {
+		t.record(plugin, stage, action, m)
+	}
+}
+
+func (t *Tracer) record(plugin, stage, action string, m telegraf.Metric) {
+	// Serialize the metric at the time of recording as it is modified further
+	// down the pipeline
+	t.Lock()
+	line, err := t.serializer.Serialize(m)
+	t.Unlock()
+	if err != nil {
+		line = []byte(err.Error())
+	}
+
+	t.emit(&TraceRecord{
+		Time:   time.Now(),
+		Plugin: plugin,
+		Stage:  stage,
+		Action: action,
+		Series: m.HashID(),
+		Line:   string(bytes.TrimSpace(line)),
+	})
+}
+
+// traceAccumulator marks and records all metrics emitted by a processor for a
+// traced input metric, including new metrics created by the processor
+type traceAccumulator struct {
+	telegraf.Accumulator
+	tracer *Tracer
+	plugin string
+}
+
+func (a *traceAccumulator) AddMetric(m telegraf.Metric) {
+	metric.SetTraced(m)
+	a.tracer.record(a.plugin, TraceStageProcessor, "output", m)
+	a.Accumulator.AddMetric(m)
+}
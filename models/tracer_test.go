@@ -0,0 +1,183 @@
+package models
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/metric"
+	"github.com/influxdata/telegraf/testutil"
+)
+
+type traceRecorder struct {
+	records []*TraceRecord
+	sync.Mutex
+}
+
+func (r *traceRecorder) emit(record *TraceRecord) {
+	r.Lock()
+	defer r.Unlock()
+	r.records = append(r.records, record)
+}
+
+func (r *traceRecorder) hops() []string {
+	r.Lock()
+	defer r.Unlock()
+	hops := make([]string, 0, len(r.records))
+	for _, record := range r.records {
+		hops = append(hops, record.Plugin+" "+record.Action)
+	}
+	return hops
+}
+
+// renameProcessor is a streaming processor renaming all metrics
+type renameProcessor struct{}
+
+func (*renameProcessor) SampleConfig() string             { return "" }
+func (*renameProcessor) Start(telegraf.Accumulator) error { return nil }
+func (*renameProcessor) Stop()                            {}
+func (*renameProcessor) Add(m telegraf.Metric, acc telegraf.Accumulator) error {
+	m.SetName("renamed")
+	acc.AddMetric(m)
+	return nil
+}
+
+func TestTracerInvalidSelector(t *testing.T) {
+	_, err := NewTracer("name", func(*TraceRecord) {})
+	require.ErrorContains(t, err, "compiling trace selector failed")
+}
+
+func TestTracerPipeline(t *testing.T) {
+	recorder := &traceRecorder{}
+	tracer, err := NewTracer(`tags.host == "traced"`, recorder.emit)
+	require.NoError(t, err)
+	SetTracer(tracer)
+	defer SetTracer(nil)
+
+	input := NewRunningInput(&mockInput{}, &InputConfig{Name: "mock"})
+	processor := NewRunningProcessor(&renameProcessor{}, &ProcessorConfig{Name: "rename"})
+	output := NewRunningOutput(&mockOutput{}, &OutputConfig{Name: "mock", Filter: Filter{NamePass: []string{"renamed"}}}, 10, 10)
+	require.NoError(t, output.Config.Filter.Compile())
+
+	now := time.Unix(0, 0)
+	for _, host := range []string{"traced", "other"} {
+		m := metric.New("cpu", map[string]string{"host": host}, map[string]interface{}{"value": 42}, now)
+		m = input.MakeMetric(m)
+		require.NotNil(t, m)
+
+		acc := &testutil.Accumulator{}
+		require.NoError(t, processor.Add(m, acc))
+		for _, pm := range acc.GetTelegrafMetrics() {
+			output.AddMetric(pm)
+		}
+	}
+
+	// Metrics filtered by the output are recorded as well
+	m := input.MakeMetric(metric.New("cpu", map[string]string{"host": "traced"}, map[string]interface{}{"value": 23}, now))
+	output.AddMetric(m)
+	require.NoError(t, output.Write())
+
+	expected := []string{
+		"inputs.mock emitted",
+		"processors.rename input",
+		"processors.rename output",
+		"outputs.mock buffered",
+		"inputs.mock emitted",
+		"outputs.mock filtered",
+		"outputs.mock accepted",
+	}
+	require.Equal(t, expected, recorder.hops())
+	require.Equal(t, "cpu,host=traced value=42i 0", recorder.records[1].Line)
+	require.Equal(t, "renamed,host=traced value=42i 0", recorder.records[2].Line)
+}
+
+// retagProcessor is a streaming processor removing the host tag and emitting
+// an additional metric
+type retagProcessor struct{}
+
+func (*retagProcessor) SampleConfig() string             { return "" }
+func (*retagProcessor) Start(telegraf.Accumulator) error { return nil }
+func (*retagProcessor) Stop()                            {}
+func (*retagProcessor) Add(m telegraf.Metric, acc telegraf.Accumulator) error {
+	m.RemoveTag("host")
+	acc.AddMetric(m)
+	acc.AddMetric(metric.New("extra", nil, map[string]interface{}{"value": 1}, m.Time()))
+	return nil
+}
+
+func TestTracerModifiedMetric(t *testing.T) {
+	recorder := &traceRecorder{}
+	tracer, err := NewTracer(`tags.host == "traced"`, recorder.emit)
+	require.NoError(t, err)
+	SetTracer(tracer)
+	defer SetTracer(nil)
+
+	input := NewRunningInput(&mockInput{}, &InputConfig{Name: "mock"})
+	processor := NewRunningProcessor(&retagProcessor{}, &ProcessorConfig{Name: "retag"})
+	aggregator := NewRunningAggregator(&mockAggregator{}, &AggregatorConfig{
+		Name:   "mock",
+		Period: time.Hour,
+		Delay:  time.Hour,
+	})
+	require.NoError(t, aggregator.Config.Filter.Compile())
+	aggregator.UpdateWindow(time.Unix(0, 0), time.Unix(0, 0).Add(time.Hour))
+	output := NewRunningOutput(&mockOutput{}, &OutputConfig{Name: "mock"}, 10, 10)
+
+	now := time.Unix(60, 0)
+	m := input.MakeMetric(metric.New("cpu", map[string]string{"host": "traced"}, map[string]interface{}{"value": 42}, now))
+	require.NotNil(t, m)
+
+	acc := &testutil.Accumulator{}
+	require.NoError(t, processor.Add(m, acc))
+	emitted := acc.GetTelegrafMetrics()
+	require.Len(t, emitted, 2)
+	for _, pm := range emitted {
+		// Copies as done by the agent for multiple consumers keep the mark
+		aggregator.Add(pm.Copy())
+		output.AddMetric(pm)
+	}
+	require.NoError(t, output.Write())
+
+	expected := []string{
+		"inputs.mock emitted",
+		"processors.retag input",
+		"processors.retag output",
+		"processors.retag output",
+		"aggregators.mock consumed",
+		"outputs.mock buffered",
+		"aggregators.mock consumed",
+		"outputs.mock buffered",
+		"outputs.mock accepted",
+		"outputs.mock accepted",
+	}
+	require.Equal(t, expected, recorder.hops())
+}
+
+func TestTraceRecordMetric(t *testing.T) {
+	record := &TraceRecord{
+		Time:   time.Unix(0, 0),
+		Plugin: "outputs.file",
+		Stage:  TraceStageOutput,
+		Action: "accepted",
+		Series: 42,
+		Line:   "cpu value=42i 0",
+	}
+
+	expected := metric.New(
+		TraceMeasurement,
+		map[string]string{"plugin": "outputs.file", "stage": "output", "action": "accepted"},
+		map[string]interface{}{"metric": "cpu value=42i 0", "series_id": "000000000000002a"},
+		time.Unix(0, 0),
+	)
+	testutil.RequireMetricEqual(t, expected, record.Metric())
+
+	// Trace metrics are never traced themselves
+	SetTracer(&Tracer{})
+	defer SetTracer(nil)
+	m := record.Metric()
+	selectTrace(m)
+	require.Nil(t, tracing(m))
+}
@@ -0,0 +1,217 @@
+package persister
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// backend stores and loads the serialized states of all plugins
+type backend interface {
+	load() (map[string]entry, error)
+	store(states map[string]entry) error
+	close() error
+}
+
+// entry is the serialized state of a plugin together with its version
+type entry struct {
+	Version int             `json:"version,omitempty"`
+	State   json.RawMessage `json:"state"`
+}
+
+func newBackend(kind, path string) (backend, error) {
+	switch kind {
+	case "", "file":
+		return &fileBackend{path: path}, nil
+	case "directory":
+		return &directoryBackend{path: path}, nil
+	case "kv":
+		return &kvBackend{path: path}, nil
+	}
+	return nil, fmt.Errorf("invalid statefile backend %q", kind)
+}
+
+// decodeEntry decodes a serialized entry. Entries written by previous
+// versions of Telegraf contain the serialized state as byte-string only.
+func decodeEntry(data []byte) (entry, error) {
+	var e entry
+	if len(data) > 0 && data[0] == '"' {
+		var state []byte
+		if err := json.Unmarshal(data, &state); err != nil {
+			return e, err
+		}
+		e.State = state
+		return e, nil
+	}
+
+	err := json.Unmarshal(data, &e)
+	return e, err
+}
+
+// writeFileAtomic writes the data to a temporary file and renames the file to
+// the given name to never leave a partially written file behind.
+func writeFileAtomic(filename string, data []byte) error {
+	f, err := os.CreateTemp(filepath.Dir(filename), filepath.Base(filename)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	tmpname := f.Name()
+
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		os.Remove(tmpname)
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		os.Remove(tmpname)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmpname)
+		return err
+	}
+
+	if err := os.Rename(tmpname, filename); err != nil {
+		os.Remove(tmpname)
+		return err
+	}
+	return nil
+}
+
+// fileBackend stores the states of all plugins in a single JSON file
+type fileBackend struct {
+	path string
+}
+
+func (b *fileBackend) load() (map[string]entry, error) {
+	in, err := os.ReadFile(b.path)
+	if err != nil {
+		return nil, err
+	}
+
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(in, &raw); err != nil {
+		return nil, fmt.Errorf("unmarshalling states failed: %w", err)
+	}
+
+	states := make(map[string]entry, len(raw))
+	for id, data := range raw {
+		e, err := decodeEntry(data)
+		if err != nil {
+			return nil, fmt.Errorf("unmarshalling state for %q failed: %w", id, err)
+		}
+		states[id] = e
+	}
+	return states, nil
+}
+
+func (b *fileBackend) store(states map[string]entry) error {
+	serialized, err := json.Marshal(states)
+	if err != nil {
+		return fmt.Errorf("marshalling states failed: %w", err)
+	}
+	return writeFileAtomic(b.path, serialized)
+}
+
+func (*fileBackend) close() error {
+	return nil
+}
+
+// directoryBackend stores the state of each plugin in a separate file within
+// a directory. Only the files of changed states are written.
+type directoryBackend struct {
+	path    string
+	written map[string][]byte
+}
+
+func (b *directoryBackend) load() (map[string]entry, error) {
+	files, err := os.ReadDir(b.path)
+	if err != nil {
+		return nil, err
+	}
+
+	states := make(map[string]entry, len(files))
+	for _, f := range files {
+		name := f.Name()
+		if f.IsDir() || !strings.HasSuffix(name, ".json") {
+			continue
+		}
+		id, err := url.PathUnescape(strings.TrimSuffix(name, ".json"))
+		if err != nil {
+			continue
+		}
+
+		data, err := os.ReadFile(filepath.Join(b.path, name))
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeEntry(data)
+		if err != nil {
+			return nil, fmt.Errorf("unmarshalling state for %q failed: %w", id, err)
+		}
+		states[id] = e
+	}
+	return states, nil
+}
+
+func (b *directoryBackend) store(states map[string]entry) error {
+	if err := os.MkdirAll(b.path, 0750); err != nil {
+		return err
+	}
+	if b.written == nil {
+		b.written = make(map[string][]byte)
+	}
+
+	for id, e := range states {
+		serialized, err := json.Marshal(e)
+		if err != nil {
+			return fmt.Errorf("marshalling state for %q failed: %w", id, err)
+		}
+		if previous, found := b.written[id]; found && bytes.Equal(previous, serialized) {
+			continue
+		}
+		if err := writeFileAtomic(b.filename(id), serialized); err != nil {
+			return err
+		}
+		b.written[id] = serialized
+	}
+
+	// Remove the states of plugins not existing anymore
+	files, err := os.ReadDir(b.path)
+	if err != nil {
+		return err
+	}
+	for _, f := range files {
+		name := f.Name()
+		if f.IsDir() || !strings.HasSuffix(name, ".json") {
+			continue
+		}
+		id, err := url.PathUnescape(strings.TrimSuffix(name, ".json"))
+		if err != nil {
+			continue
+		}
+		if _, found := states[id]; found {
+			continue
+		}
+		if err := os.Remove(filepath.Join(b.path, name)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		delete(b.written, id)
+	}
+
+	return nil
+}
+
+func (b *directoryBackend) filename(id string) string {
+	return filepath.Join(b.path, url.PathEscape(id)+".json")
+}
+
+func (*directoryBackend) close() error {
+	return nil
+}
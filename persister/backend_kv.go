@@ -0,0 +1,166 @@
+package persister
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/tidwall/wal"
+)
+
+// kvCompactThreshold is the number of outdated records in the log to accept
+// before compacting the log
+const kvCompactThreshold = 64
+
+// kvBackend is an embedded key-value store keeping the states in an
+// append-only log. Each store only appends the changed states which are then
+// replayed on load with the latest record of a plugin winning. The log is
+// compacted by appending a snapshot of all states and truncating all previous
+// records.
+type kvBackend struct {
+	path    string
+	log     *wal.Log
+	current map[string]entry
+	records int
+}
+
+// kvRecord is a single record in the log, the entry is nil for plugins whose
+// state was removed.
+type kvRecord struct {
+	ID    string `json:"id"`
+	Entry *entry `json:"entry,omitempty"`
+}
+
+func (b *kvBackend) open() error {
+	if b.log != nil {
+		return nil
+	}
+
+	l, err := wal.Open(b.path, nil)
+	if err != nil {
+		return fmt.Errorf("opening key-value store failed: %w", err)
+	}
+	b.log = l
+	b.current = make(map[string]entry)
+	b.records = 0
+
+	// Replay the log to get the current states
+	first, err := l.FirstIndex()
+	if err != nil {
+		return err
+	}
+	last, err := l.LastIndex()
+	if err != nil {
+		return err
+	}
+	if first == 0 {
+		return nil
+	}
+	for index := first; index <= last; index++ {
+		data, err := l.Read(index)
+		if err != nil {
+			return fmt.Errorf("reading record %d failed: %w", index, err)
+		}
+		var r kvRecord
+		if err := json.Unmarshal(data, &r); err != nil {
+			return fmt.Errorf("unmarshalling record %d failed: %w", index, err)
+		}
+		if r.Entry == nil {
+			delete(b.current, r.ID)
+		} else {
+			b.current[r.ID] = *r.Entry
+		}
+		b.records++
+	}
+	return nil
+}
+
+func (b *kvBackend) load() (map[string]entry, error) {
+	if b.log == nil {
+		if _, err := os.Stat(b.path); err != nil {
+			return nil, err
+		}
+	}
+	if err := b.open(); err != nil {
+		return nil, err
+	}
+
+	states := make(map[string]entry, len(b.current))
+	for id, e := range b.current {
+		states[id] = e
+	}
+	return states, nil
+}
+
+func (b *kvBackend) store(states map[string]entry) error {
+	if err := b.open(); err != nil {
+		return err
+	}
+
+	// Collect the changed and removed states
+	changed := make([]kvRecord, 0)
+	for id, e := range states {
+		if previous, found := b.current[id]; found && previous.Version == e.Version && bytes.Equal(previous.State, e.State) {
+			continue
+		}
+		changed = append(changed, kvRecord{ID: id, Entry: &e})
+	}
+	for id := range b.current {
+		if _, found := states[id]; !found {
+			changed = append(changed, kvRecord{ID: id})
+		}
+	}
+	if len(changed) == 0 {
+		return nil
+	}
+
+	// Compact the log if it contains too many outdated records
+	compact := len(states) > 0 && b.records+len(changed)-len(states) > kvCompactThreshold
+	if compact {
+		changed = changed[:0]
+		for id, e := range states {
+			changed = append(changed, kvRecord{ID: id, Entry: &e})
+		}
+	}
+
+	// Append the records in one batch to apply all or none of them
+	last, err := b.log.LastIndex()
+	if err != nil {
+		return err
+	}
+	var batch wal.Batch
+	for i, r := range changed {
+		data, err := json.Marshal(r)
+		if err != nil {
+			return fmt.Errorf("marshalling state for %q failed: %w", r.ID, err)
+		}
+		batch.Write(last+uint64(i)+1, data)
+	}
+	if err := b.log.WriteBatch(&batch); err != nil {
+		return err
+	}
+	b.records += len(changed)
+
+	if compact && last > 0 {
+		if err := b.log.TruncateFront(last + 1); err != nil {
+			return fmt.Errorf("compacting key-value store failed: %w", err)
+		}
+		b.records = len(changed)
+	}
+
+	b.current = make(map[string]entry, len(states))
+	for id, e := range states {
+		b.current[id] = e
+	}
+	return nil
+}
+
+func (b *kvBackend) close() error {
+	if b.log == nil {
+		return nil
+	}
+	err := b.log.Close()
+	b.log = nil
+	return err
+}
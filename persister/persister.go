@@ -3,8 +3,9 @@ package persister
 import (
 	"encoding/json"
 	"fmt"
-	"os"
+	"log"
 	"reflect"
+	"sync"
 
 	"github.com/influxdata/telegraf"
 )
@@ -12,16 +13,31 @@ import (
 type Persister struct {
 	Filename string
 
+	// Backend to store the states with, one of "file" (default), "directory"
+	// or "kv"
+	Backend string
+
+	backend  backend
 	register map[string]telegraf.StatefulPlugin
+	sync.Mutex
 }
 
 func (p *Persister) Init() error {
 	p.register = make(map[string]telegraf.StatefulPlugin)
 
+	b, err := newBackend(p.Backend, p.Filename)
+	if err != nil {
+		return err
+	}
+	p.backend = b
+
 	return nil
 }
 
 func (p *Persister) Register(id string, plugin telegraf.StatefulPlugin) error {
+	p.Lock()
+	defer p.Unlock()
+
 	if _, found := p.register[id]; found {
 		return fmt.Errorf("plugin with ID %q already registered", id)
 	}
@@ -30,74 +46,103 @@ func (p *Persister) Register(id string, plugin telegraf.StatefulPlugin) error {
 	return nil
 }
 
+// Unregister removes the plugin with the given ID, e.g. because the plugin
+// was removed during a configuration reload.
+func (p *Persister) Unregister(id string) {
+	p.Lock()
+	defer p.Unlock()
+
+	delete(p.register, id)
+}
+
+// Load restores the states of the registered plugins. States of unknown
+// plugins are ignored. States which cannot be restored, e.g. because the
+// version of the state changed, are dropped with a warning.
 func (p *Persister) Load() error {
-	// Read the states from disk
-	in, err := os.ReadFile(p.Filename)
+	// Read the states from the backend
+	states, err := p.backend.load()
 	if err != nil {
-		return fmt.Errorf("reading states file failed: %w", err)
+		return fmt.Errorf("reading states failed: %w", err)
 	}
 
-	// Unmarshal the id to serialized states map
-	var states map[string][]byte
-	if err := json.Unmarshal(in, &states); err != nil {
-		return fmt.Errorf("unmarshalling states failed: %w", err)
-	}
+	p.Lock()
+	defer p.Unlock()
 
-	// Get the initialized state as blueprint for unmarshalling
-	for id, serialized := range states {
+	for id, e := range states {
 		// Check if we have a plugin with that ID
 		plugin, found := p.register[id]
 		if !found {
 			continue
 		}
 
-		// Create a new empty state of the "state"-type. As we need a pointer
-		// of the state, we cannot dereference it here due to the unknown
-		// nature of the state-type.
-		nstate := reflect.New(reflect.TypeOf(plugin.GetState())).Interface()
-		if err := json.Unmarshal(serialized, &nstate); err != nil {
-			return fmt.Errorf("unmarshalling state for %q failed: %w", id, err)
-		}
-		state := reflect.ValueOf(nstate).Elem().Interface()
-
-		// Set the state in the plugin
-		if err := plugin.SetState(state); err != nil {
-			return fmt.Errorf("setting state of %q failed: %w", id, err)
+		if err := restore(plugin, e); err != nil {
+			log.Printf("W! [persister] Dropping state of plugin with ID %q: %v", id, err)
 		}
 	}
 
 	return nil
 }
 
+// Store persists the current states of all registered plugins. The states
+// are replaced atomically, so a failure does not corrupt previous states.
 func (p *Persister) Store() error {
-	states := make(map[string][]byte)
+	p.Lock()
+	defer p.Unlock()
 
 	// Collect the states and serialize the individual data chunks
-	// to later serialize all items in the id / serialized-states map
+	states := make(map[string]entry, len(p.register))
 	for id, plugin := range p.register {
 		state, err := json.Marshal(plugin.GetState())
 		if err != nil {
 			return fmt.Errorf("marshalling state for id %q failed: %w", id, err)
 		}
-		states[id] = state
+		states[id] = entry{Version: stateVersion(plugin), State: state}
 	}
 
-	// Serialize the states
-	serialized, err := json.Marshal(states)
-	if err != nil {
-		return fmt.Errorf("marshalling states failed: %w", err)
+	if err := p.backend.store(states); err != nil {
+		return fmt.Errorf("writing states failed: %w", err)
 	}
 
-	// Write the states to disk
-	f, err := os.Create(p.Filename)
-	if err != nil {
-		return fmt.Errorf("creating states file %q failed: %w", p.Filename, err)
+	return nil
+}
+
+// Close releases the resources of the backend.
+func (p *Persister) Close() error {
+	p.Lock()
+	defer p.Unlock()
+
+	if p.backend == nil {
+		return nil
 	}
-	defer f.Close()
+	return p.backend.close()
+}
 
-	if _, err := f.Write(serialized); err != nil {
-		return fmt.Errorf("writing states failed: %w", err)
+// restore sets the state of the plugin from the given entry.
+func restore(plugin telegraf.StatefulPlugin, e entry) error {
+	if version := stateVersion(plugin); e.Version != version {
+		return fmt.Errorf("incompatible state version %d, expected %d", e.Version, version)
+	}
+
+	// Create a new empty state of the "state"-type. As we need a pointer
+	// of the state, we cannot dereference it here due to the unknown
+	// nature of the state-type.
+	nstate := reflect.New(reflect.TypeOf(plugin.GetState())).Interface()
+	if err := json.Unmarshal(e.State, &nstate); err != nil {
+		return fmt.Errorf("unmarshalling state failed: %w", err)
+	}
+	state := reflect.ValueOf(nstate).Elem().Interface()
+
+	// Set the state in the plugin
+	if err := plugin.SetState(state); err != nil {
+		return fmt.Errorf("setting state failed: %w", err)
 	}
 
 	return nil
 }
+
+func stateVersion(plugin telegraf.StatefulPlugin) int {
+	if p, ok := plugin.(telegraf.StatefulPluginWithVersion); ok {
+		return p.StateVersion()
+	}
+	return 0
+}
@@ -0,0 +1,182 @@
+package persister
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type mockState struct {
+	Name   string `json:"name"`
+	Offset int    `json:"offset"`
+}
+
+type mockPlugin struct {
+	state mockState
+}
+
+func (m *mockPlugin) GetState() interface{} {
+	return m.state
+}
+
+func (m *mockPlugin) SetState(state interface{}) error {
+	m.state = state.(mockState)
+	return nil
+}
+
+type mockVersionedPlugin struct {
+	mockPlugin
+	version int
+}
+
+func (m *mockVersionedPlugin) StateVersion() int {
+	return m.version
+}
+
+func TestBackends(t *testing.T) {
+	for _, backend := range []string{"file", "directory", "kv"} {
+		t.Run(backend, func(t *testing.T) {
+			filename := filepath.Join(t.TempDir(), "states")
+
+			store := &Persister{Filename: filename, Backend: backend}
+			require.NoError(t, store.Init())
+			a := &mockPlugin{state: mockState{Name: "a", Offset: 1}}
+			b := &mockPlugin{state: mockState{Name: "b", Offset: 2}}
+			require.NoError(t, store.Register("a", a))
+			require.NoError(t, store.Register("b/buffer", b))
+			require.NoError(t, store.Store())
+
+			// Update a state and remove a plugin
+			a.state.Offset = 42
+			store.Unregister("b/buffer")
+			require.NoError(t, store.Store())
+			require.NoError(t, store.Close())
+
+			load := &Persister{Filename: filename, Backend: backend}
+			require.NoError(t, load.Init())
+			la := &mockPlugin{}
+			lb := &mockPlugin{}
+			require.NoError(t, load.Register("a", la))
+			require.NoError(t, load.Register("b/buffer", lb))
+			require.NoError(t, load.Load())
+			require.NoError(t, load.Close())
+
+			require.Equal(t, mockState{Name: "a", Offset: 42}, la.state)
+			require.Empty(t, lb.state)
+		})
+	}
+}
+
+func TestBackendNotExisting(t *testing.T) {
+	for _, backend := range []string{"file", "directory", "kv"} {
+		t.Run(backend, func(t *testing.T) {
+			p := &Persister{Filename: filepath.Join(t.TempDir(), "states"), Backend: backend}
+			require.NoError(t, p.Init())
+			require.ErrorIs(t, p.Load(), os.ErrNotExist)
+		})
+	}
+}
+
+func TestInvalidBackend(t *testing.T) {
+	p := &Persister{Filename: "states.json", Backend: "foo"}
+	require.ErrorContains(t, p.Init(), `invalid statefile backend "foo"`)
+}
+
+func TestStoreAtomic(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "states.json")
+
+	p := &Persister{Filename: filename}
+	require.NoError(t, p.Init())
+	require.NoError(t, p.Register("a", &mockPlugin{state: mockState{Name: "a"}}))
+	require.NoError(t, p.Store())
+	require.NoError(t, p.Store())
+
+	// No temporary files must be left behind
+	files, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	require.Equal(t, "states.json", files[0].Name())
+}
+
+func TestLoadLegacyFormat(t *testing.T) {
+	// States written by previous versions contain the state as byte-string
+	filename := filepath.Join(t.TempDir(), "states.json")
+	legacy := `{"a":"eyJuYW1lIjoiYSIsIm9mZnNldCI6NDJ9"}`
+	require.NoError(t, os.WriteFile(filename, []byte(legacy), 0600))
+
+	p := &Persister{Filename: filename}
+	require.NoError(t, p.Init())
+	plugin := &mockPlugin{}
+	require.NoError(t, p.Register("a", plugin))
+	require.NoError(t, p.Load())
+	require.Equal(t, mockState{Name: "a", Offset: 42}, plugin.state)
+}
+
+func TestLoadIncompatibleState(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "states.json")
+
+	store := &Persister{Filename: filename}
+	require.NoError(t, store.Init())
+	require.NoError(t, store.Register("a", &mockVersionedPlugin{mockPlugin: mockPlugin{state: mockState{Name: "a"}}, version: 1}))
+	require.NoError(t, store.Register("b", &mockPlugin{state: mockState{Name: "b"}}))
+	require.NoError(t, store.Store())
+
+	// A state of a different version is dropped without failing
+	load := &Persister{Filename: filename}
+	require.NoError(t, load.Init())
+	a := &mockVersionedPlugin{version: 2}
+	b := &mockPlugin{}
+	require.NoError(t, load.Register("a", a))
+	require.NoError(t, load.Register("b", b))
+	require.NoError(t, load.Load())
+	require.Empty(t, a.state)
+	require.Equal(t, mockState{Name: "b"}, b.state)
+}
+
+func TestKVBackendCompaction(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "states")
+
+	p := &Persister{Filename: filename, Backend: "kv"}
+	require.NoError(t, p.Init())
+	plugin := &mockPlugin{state: mockState{Name: "a"}}
+	require.NoError(t, p.Register("a", plugin))
+	for i := range 2 * kvCompactThreshold {
+		plugin.state.Offset = i
+		require.NoError(t, p.Store())
+	}
+
+	// Unchanged states must not be appended
+	require.NoError(t, p.Store())
+	kv := p.backend.(*kvBackend)
+	require.LessOrEqual(t, kv.records, kvCompactThreshold+1)
+	require.NoError(t, p.Close())
+
+	load := &Persister{Filename: filename, Backend: "kv"}
+	require.NoError(t, load.Init())
+	restored := &mockPlugin{}
+	require.NoError(t, load.Register("a", restored))
+	require.NoError(t, load.Load())
+	require.NoError(t, load.Close())
+	require.Equal(t, mockState{Name: "a", Offset: 2*kvCompactThreshold - 1}, restored.state)
+}
+
+func TestDirectoryBackendFiles(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "states")
+
+	p := &Persister{Filename: dir, Backend: "directory"}
+	require.NoError(t, p.Init())
+	for i := range 3 {
+		id := strconv.Itoa(i) + "/buffer"
+		require.NoError(t, p.Register(id, &mockPlugin{state: mockState{Name: id}}))
+	}
+	require.NoError(t, p.Store())
+
+	files, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, files, 3)
+	require.Equal(t, "0%2Fbuffer.json", files[0].Name())
+}
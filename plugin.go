@@ -49,7 +49,9 @@ type StatefulPlugin interface {
 	// serialized to JSON. The best choice is a structure defined in
 	// your plugin.
 	// Note: This function has to be callable directly after the
-	// plugin's Init() function if there is any!
+	// plugin's Init() function if there is any! When periodic
+	// checkpointing is enabled, the function is called concurrently
+	// to the other functions of the plugin.
 	GetState() interface{}
 
 	// SetState is called by the Persister once after loading and
@@ -57,6 +59,17 @@ type StatefulPlugin interface {
 	SetState(state interface{}) error
 }
 
+// StatefulPluginWithVersion is a StatefulPlugin versioning the format of its
+// state. Persisted states of a different version are dropped on loading
+// instead of being restored, so the version must be increased on incompatible
+// changes of the state.
+type StatefulPluginWithVersion interface {
+	StatefulPlugin
+
+	// StateVersion returns the version of the state format
+	StateVersion() int
+}
+
 // ProbePlugin is an interface that all input/output plugins need to
 // implement in order to support the `probe` value of `startup_error_behavior`
 type ProbePlugin interface {
@@ -0,0 +1,72 @@
+package backpressure
+
+import (
+	"context"
+	"sync"
+)
+
+// released is the channel returned by Ready while no backpressure is active
+var released = func() chan struct{} {
+	c := make(chan struct{})
+	close(c)
+	return c
+}()
+
+// Gate holds back consumers of service inputs while the agent signals
+// backpressure. The zero value is an open gate ready to use.
+type Gate struct {
+	// Channel closed when releasing the active backpressure, nil if no
+	// backpressure is active
+	resume chan struct{}
+	sync.Mutex
+}
+
+// Set activates or releases the backpressure, it is meant to be called by the
+// SetBackpressure function of the input
+func (g *Gate) Set(active bool) {
+	g.Lock()
+	defer g.Unlock()
+
+	if active && g.resume == nil {
+		g.resume = make(chan struct{})
+	} else if !active && g.resume != nil {
+		close(g.resume)
+		g.resume = nil
+	}
+}
+
+// Active returns true if backpressure is currently active
+func (g *Gate) Active() bool {
+	g.Lock()
+	defer g.Unlock()
+	return g.resume != nil
+}
+
+// Ready returns a channel that is closed while no backpressure is active or
+// when the current backpressure is released. Use this function instead of
+// Wait for waiting in a select statement.
+func (g *Gate) Ready() <-chan struct{} {
+	g.Lock()
+	defer g.Unlock()
+
+	if g.resume == nil {
+		return released
+	}
+	return g.resume
+}
+
+// Wait blocks while backpressure is active. An error is returned if the
+// context is done before the backpressure is released.
+func (g *Gate) Wait(ctx context.Context) error {
+	ready := g.Ready()
+	if ready == released {
+		return nil
+	}
+
+	select {
+	case <-ready:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,56 @@
+package backpressure
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGateOpen(t *testing.T) {
+	var g Gate
+	require.False(t, g.Active())
+	require.NoError(t, g.Wait(context.Background()))
+
+	// Releasing an open gate has no effect
+	g.Set(false)
+	require.False(t, g.Active())
+	require.NoError(t, g.Wait(context.Background()))
+}
+
+func TestGateBlocking(t *testing.T) {
+	var g Gate
+	g.Set(true)
+	g.Set(true)
+	require.True(t, g.Active())
+
+	done := make(chan error, 1)
+	go func() {
+		done <- g.Wait(context.Background())
+	}()
+
+	select {
+	case <-done:
+		require.Fail(t, "wait returned while backpressure is active")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	g.Set(false)
+	require.False(t, g.Active())
+	select {
+	case err := <-done:
+		require.NoError(t, err)
+	case <-time.After(time.Second):
+		require.Fail(t, "wait did not return after releasing the backpressure")
+	}
+}
+
+func TestGateContext(t *testing.T) {
+	var g Gate
+	g.Set(true)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	require.ErrorIs(t, g.Wait(ctx), context.DeadlineExceeded)
+}
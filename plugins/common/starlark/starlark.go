@@ -6,6 +6,7 @@ import (
 	"errors"
 	"fmt"
 	"strings"
+	"sync"
 
 	"go.starlark.net/lib/json"
 	"go.starlark.net/lib/math"
@@ -30,9 +31,16 @@ type Common struct {
 	functions  map[string]*starlark.Function
 	parameters map[string]starlark.Tuple
 	state      *starlark.Dict
+
+	// Protects the state from concurrent checkpointing while calling
+	// functions of the script
+	mu sync.Mutex
 }
 
 func (s *Common) GetState() interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
 	// Return the actual byte-type instead of nil allowing the persister
 	// to guess instantiate variable of the appropriate type
 	if s.state == nil {
@@ -244,6 +252,9 @@ func (s *Common) Call(name string) (starlark.Value, error) {
 	if !ok {
 		return nil, fmt.Errorf("params for function %q do not exist", name)
 	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	return starlark.Call(s.thread, fn, args, nil)
 }
 
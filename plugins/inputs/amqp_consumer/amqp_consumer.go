@@ -16,6 +16,7 @@ import (
 	"github.com/influxdata/telegraf"
 	"github.com/influxdata/telegraf/config"
 	"github.com/influxdata/telegraf/internal"
+	"github.com/influxdata/telegraf/plugins/common/backpressure"
 	"github.com/influxdata/telegraf/plugins/common/tls"
 	"github.com/influxdata/telegraf/plugins/inputs"
 )
@@ -62,6 +63,9 @@ type AMQPConsumer struct {
 	wg      *sync.WaitGroup
 	cancel  context.CancelFunc
 	decoder internal.ContentDecoder
+
+	// Gate for pausing the consumption on backpressure of the outputs
+	gate backpressure.Gate
 }
 
 func (*externalAuth) Mechanism() string {
@@ -193,6 +197,12 @@ func (a *AMQPConsumer) Stop() {
 	}
 }
 
+// SetBackpressure pauses or resumes consuming messages. The broker retains
+// the messages not consumed while paused.
+func (a *AMQPConsumer) SetBackpressure(active bool) {
+	a.gate.Set(active)
+}
+
 func (a *AMQPConsumer) createConfig() (*amqp.Config, error) {
 	// make new tls config
 	tlsCfg, err := a.ClientConfig.TLSConfig()
@@ -408,6 +418,19 @@ func (a *AMQPConsumer) process(ctx context.Context, msgs <-chan amqp.Delivery, a
 	sem := make(semaphore, a.MaxUndeliveredMessages)
 
 	for {
+		// Stop receiving new messages while the outputs are saturated but
+		// keep acknowledging the delivered ones
+		select {
+		case <-ctx.Done():
+			return
+		case track := <-acc.Delivered():
+			if a.onDelivery(track) {
+				<-sem
+			}
+			continue
+		case <-a.gate.Ready():
+		}
+
 		select {
 		case <-ctx.Done():
 			return
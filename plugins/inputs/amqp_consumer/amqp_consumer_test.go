@@ -67,6 +67,45 @@ func TestAutoEncoding(t *testing.T) {
 	acc.AssertContainsFields(t, "measurementName2", map[string]interface{}{"fieldKey": "identity"})
 }
 
+func TestBackpressure(t *testing.T) {
+	decoder, err := internal.NewContentDecoder("identity")
+	require.NoError(t, err)
+	plugin := &AMQPConsumer{
+		MaxUndeliveredMessages: 10,
+		decoder:                decoder,
+		Log:                    testutil.Logger{},
+	}
+
+	parser := &influx.Parser{}
+	require.NoError(t, parser.Init())
+	plugin.SetParser(parser)
+
+	msgs := make(chan amqp091.Delivery, 1)
+	msgs <- amqp091.Delivery{Body: []byte(`test value=42i 1556813561098000000`)}
+
+	plugin.SetBackpressure(true)
+
+	var acc testutil.Accumulator
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		plugin.process(ctx, msgs, &acc)
+	}()
+
+	// The message must not be received while paused
+	time.Sleep(100 * time.Millisecond)
+	require.Len(t, msgs, 1)
+	require.Zero(t, acc.NMetrics())
+
+	plugin.SetBackpressure(false)
+	acc.Wait(1)
+	require.Empty(t, msgs)
+
+	cancel()
+	<-done
+}
+
 func TestIntegration(t *testing.T) {
 	if testing.Short() {
 		t.Skip("Skipping integration test in short mode")
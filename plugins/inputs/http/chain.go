@@ -0,0 +1,138 @@
+package http
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"text/template"
+
+	"github.com/tidwall/gjson"
+
+	"github.com/influxdata/telegraf/config"
+)
+
+// ChainRequest is a request performed before querying the URLs to extract
+// values, e.g. an authentication token, from the response
+type ChainRequest struct {
+	URL     string                    `toml:"url"`
+	Method  string                    `toml:"method"`
+	Body    config.Secret             `toml:"body"`
+	Headers map[string]*config.Secret `toml:"headers"`
+	Extract map[string]string         `toml:"extract"`
+}
+
+func (r *ChainRequest) init() error {
+	if r.URL == "" {
+		return errors.New("'url' required for chained requests")
+	}
+	if r.Method == "" {
+		r.Method = "GET"
+	}
+	if len(r.Extract) == 0 {
+		return fmt.Errorf("no values to extract from %q", r.URL)
+	}
+	for name, path := range r.Extract {
+		if path == "" {
+			return fmt.Errorf("empty path for value %q", name)
+		}
+	}
+	return nil
+}
+
+// runChain performs the chained requests in order and returns the values
+// extracted from the responses
+func (h *HTTP) runChain() (map[string]string, error) {
+	values := make(map[string]string)
+	for _, r := range h.Chain {
+		address, err := expand(r.URL, values)
+		if err != nil {
+			return nil, fmt.Errorf("expanding url %q failed: %w", r.URL, err)
+		}
+
+		var body io.Reader
+		if !r.Body.Empty() {
+			secret, err := r.Body.Get()
+			if err != nil {
+				return nil, fmt.Errorf("getting body failed: %w", err)
+			}
+			content, err := expand(secret.String(), values)
+			secret.Destroy()
+			if err != nil {
+				return nil, fmt.Errorf("expanding body failed: %w", err)
+			}
+			body = strings.NewReader(content)
+		}
+
+		request, err := http.NewRequest(r.Method, address, body)
+		if err != nil {
+			return nil, err
+		}
+		if err := setHeaders(request, r.Headers, values); err != nil {
+			return nil, err
+		}
+
+		resp, err := h.client.Do(request)
+		if err != nil {
+			return nil, err
+		}
+		buf, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("reading body of %q failed: %w", address, err)
+		}
+		if err := h.checkStatusCode(resp); err != nil {
+			return nil, fmt.Errorf("request to %q failed: %w", address, err)
+		}
+
+		for name, path := range r.Extract {
+			result := gjson.GetBytes(buf, path)
+			if !result.Exists() {
+				return nil, fmt.Errorf("path %q of value %q not found in response of %q", path, name, address)
+			}
+			values[name] = result.String()
+		}
+	}
+
+	return values, nil
+}
+
+// expand replaces references to extracted values, e.g. '{{.token}}', in the
+// given text
+func expand(text string, values map[string]string) (string, error) {
+	if len(values) == 0 || !strings.Contains(text, "{{") {
+		return text, nil
+	}
+
+	tmpl, err := template.New("").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", err
+	}
+	var buf strings.Builder
+	if err := tmpl.Execute(&buf, values); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+func setHeaders(request *http.Request, headers map[string]*config.Secret, values map[string]string) error {
+	for k, v := range headers {
+		secret, err := v.Get()
+		if err != nil {
+			return err
+		}
+		headerVal, err := expand(secret.String(), values)
+		secret.Destroy()
+		if err != nil {
+			return fmt.Errorf("expanding header %q failed: %w", k, err)
+		}
+
+		if strings.EqualFold(k, "host") {
+			request.Host = headerVal
+		} else {
+			request.Header.Add(k, headerVal)
+		}
+	}
+	return nil
+}
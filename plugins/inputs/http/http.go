@@ -42,6 +42,8 @@ type HTTP struct {
 
 	Headers            map[string]*config.Secret `toml:"headers"`
 	SuccessStatusCodes []int                     `toml:"success_status_codes"`
+	Pagination         *Pagination               `toml:"pagination"`
+	Chain              []ChainRequest            `toml:"chain"`
 	Log                telegraf.Logger           `toml:"-"`
 
 	common_http.HTTPClientConfig
@@ -67,6 +69,18 @@ func (h *HTTP) Init() error {
 		return errors.New("either use 'token_file' or 'token' not both")
 	}
 
+	if h.Pagination != nil {
+		if err := h.Pagination.init(); err != nil {
+			return fmt.Errorf("invalid pagination settings: %w", err)
+		}
+	}
+
+	for i := range h.Chain {
+		if err := h.Chain[i].init(); err != nil {
+			return fmt.Errorf("invalid chained request %d: %w", i+1, err)
+		}
+	}
+
 	// Create the client
 	ctx := context.Background()
 	client, err := h.HTTPClientConfig.CreateClient(ctx, h.Log)
@@ -91,12 +105,18 @@ func (*HTTP) Start(telegraf.Accumulator) error {
 }
 
 func (h *HTTP) Gather(acc telegraf.Accumulator) error {
+	// Perform the chained requests once for all URLs
+	values, err := h.runChain()
+	if err != nil {
+		return fmt.Errorf("chained request failed: %w", err)
+	}
+
 	var wg sync.WaitGroup
 	for _, u := range h.URLs {
 		wg.Add(1)
 		go func(url string) {
 			defer wg.Done()
-			if err := h.gatherURL(acc, url); err != nil {
+			if err := h.gatherURL(acc, url, values); err != nil {
 				acc.AddError(fmt.Errorf("[url=%s]: %w", url, err))
 			}
 		}(u)
@@ -113,26 +133,62 @@ func (h *HTTP) Stop() {
 	}
 }
 
-// Gathers data from a particular URL
+// Gathers data from a particular URL following all pages of the response
 // Parameters:
 //
 //	acc    : The telegraf Accumulator to use
 //	url    : endpoint to send request to
+//	values : values extracted by the chained requests
 //
 // Returns:
 //
 //	error: Any error that may have occurred
-func (h *HTTP) gatherURL(acc telegraf.Accumulator, url string) error {
-	body := makeRequestBodyReader(h.ContentEncoding, h.Body)
-	request, err := http.NewRequest(h.Method, url, body)
+func (h *HTTP) gatherURL(acc telegraf.Accumulator, url string, values map[string]string) error {
+	address, err := expand(url, values)
+	if err != nil {
+		return fmt.Errorf("expanding url failed: %w", err)
+	}
+	body, err := expand(h.Body, values)
 	if err != nil {
+		return fmt.Errorf("expanding body failed: %w", err)
+	}
+
+	if h.Pagination == nil {
+		_, err := h.gatherPage(acc, url, address, body, values)
 		return err
 	}
 
+	address, err = h.Pagination.first(address)
+	if err != nil {
+		return err
+	}
+	for page := 1; address != ""; page++ {
+		next, err := h.gatherPage(acc, url, address, body, values)
+		if err != nil {
+			return fmt.Errorf("page %d: %w", page, err)
+		}
+		if next != "" && page >= h.Pagination.MaxPages {
+			h.Log.Warnf("Stopping pagination of %q after reaching the limit of %d pages", url, h.Pagination.MaxPages)
+			break
+		}
+		address = next
+	}
+
+	return nil
+}
+
+// gatherPage requests the given address, adds the received metrics tagged
+// with the configured URL and returns the address of the next page if any
+func (h *HTTP) gatherPage(acc telegraf.Accumulator, url, address, body string, values map[string]string) (string, error) {
+	request, err := http.NewRequest(h.Method, address, makeRequestBodyReader(h.ContentEncoding, body))
+	if err != nil {
+		return "", err
+	}
+
 	if !h.Token.Empty() {
 		token, err := h.Token.Get()
 		if err != nil {
-			return err
+			return "", err
 		}
 		bearer := "Bearer " + strings.TrimSpace(token.String())
 		token.Destroy()
@@ -140,7 +196,7 @@ func (h *HTTP) gatherURL(acc telegraf.Accumulator, url string) error {
 	} else if h.TokenFile != "" {
 		token, err := os.ReadFile(h.TokenFile)
 		if err != nil {
-			return err
+			return "", err
 		}
 		bearer := "Bearer " + strings.Trim(string(token), "\n")
 		request.Header.Set("Authorization", bearer)
@@ -150,60 +206,37 @@ func (h *HTTP) gatherURL(acc telegraf.Accumulator, url string) error {
 		request.Header.Set("Content-Encoding", "gzip")
 	}
 
-	for k, v := range h.Headers {
-		secret, err := v.Get()
-		if err != nil {
-			return err
-		}
-
-		headerVal := secret.String()
-		if strings.EqualFold(k, "host") {
-			request.Host = headerVal
-		} else {
-			request.Header.Add(k, headerVal)
-		}
-
-		secret.Destroy()
+	if err := setHeaders(request, h.Headers, values); err != nil {
+		return "", err
 	}
 
 	if err := h.setRequestAuth(request); err != nil {
-		return err
+		return "", err
 	}
 
 	resp, err := h.client.Do(request)
 	if err != nil {
-		return err
+		return "", err
 	}
 	defer resp.Body.Close()
 
-	responseHasSuccessCode := false
-	for _, statusCode := range h.SuccessStatusCodes {
-		if resp.StatusCode == statusCode {
-			responseHasSuccessCode = true
-			break
-		}
-	}
-
-	if !responseHasSuccessCode {
-		return fmt.Errorf("received status code %d (%s), expected any value out of %v",
-			resp.StatusCode,
-			http.StatusText(resp.StatusCode),
-			h.SuccessStatusCodes)
+	if err := h.checkStatusCode(resp); err != nil {
+		return "", err
 	}
 
 	b, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return fmt.Errorf("reading body failed: %w", err)
+		return "", fmt.Errorf("reading body failed: %w", err)
 	}
 
 	// Instantiate a new parser for the new data to avoid trouble with stateful parsers
 	parser, err := h.parserFunc()
 	if err != nil {
-		return fmt.Errorf("instantiating parser failed: %w", err)
+		return "", fmt.Errorf("instantiating parser failed: %w", err)
 	}
 	metrics, err := parser.Parse(b)
 	if err != nil {
-		return fmt.Errorf("parsing metrics failed: %w", err)
+		return "", fmt.Errorf("parsing metrics failed: %w", err)
 	}
 
 	if len(metrics) == 0 {
@@ -219,7 +252,23 @@ func (h *HTTP) gatherURL(acc telegraf.Accumulator, url string) error {
 		acc.AddFields(metric.Name(), metric.Fields(), metric.Tags(), metric.Time())
 	}
 
-	return nil
+	if h.Pagination == nil {
+		return "", nil
+	}
+	return h.Pagination.next(address, resp.Header, b, len(metrics))
+}
+
+func (h *HTTP) checkStatusCode(resp *http.Response) error {
+	for _, statusCode := range h.SuccessStatusCodes {
+		if resp.StatusCode == statusCode {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("received status code %d (%s), expected any value out of %v",
+		resp.StatusCode,
+		http.StatusText(resp.StatusCode),
+		h.SuccessStatusCodes)
 }
 
 func (h *HTTP) setRequestAuth(request *http.Request) error {
@@ -11,6 +11,7 @@ import (
 	"net/url"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"testing"
 	"time"
@@ -544,3 +545,299 @@ func TestConnectionOverUnixSocket(t *testing.T) {
 	require.NoError(t, acc.GatherError(plugin.Gather))
 	testutil.RequireMetricsEqual(t, expected, acc.GetTelegrafMetrics(), testutil.IgnoreTime())
 }
+
+func TestPaginationInitFail(t *testing.T) {
+	tests := []struct {
+		name       string
+		pagination *httpplugin.Pagination
+		chain      []httpplugin.ChainRequest
+		expected   string
+	}{
+		{
+			name:       "invalid type",
+			pagination: &httpplugin.Pagination{Type: "foo"},
+			expected:   "invalid pagination type",
+		},
+		{
+			name:       "missing next path",
+			pagination: &httpplugin.Pagination{Type: "body"},
+			expected:   "'next_path' required",
+		},
+		{
+			name:       "negative limit",
+			pagination: &httpplugin.Pagination{Type: "offset", Limit: -1},
+			expected:   "invalid 'limit' setting",
+		},
+		{
+			name:     "missing chain url",
+			chain:    []httpplugin.ChainRequest{{Extract: map[string]string{"token": "token"}}},
+			expected: "'url' required",
+		},
+		{
+			name:     "nothing to extract",
+			chain:    []httpplugin.ChainRequest{{URL: "http://localhost/login"}},
+			expected: "no values to extract",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plugin := &httpplugin.HTTP{
+				URLs:       []string{"http://localhost/items"},
+				Pagination: tt.pagination,
+				Chain:      tt.chain,
+				Log:        testutil.Logger{},
+			}
+			require.ErrorContains(t, plugin.Init(), tt.expected)
+		})
+	}
+}
+
+func TestPaginationLinkHeader(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Query().Get("page") {
+		case "":
+			w.Header().Set("Link", `</items?page=2>; rel="next", </items?page=3>; rel="last"`)
+			fmt.Fprint(w, `{"items": [{"id": 1}, {"id": 2}]}`)
+		case "2":
+			w.Header().Add("Link", `</items>; rel="prev first"`)
+			w.Header().Add("Link", `<http://`+r.Host+`/items?page=3>; rel="next last"`)
+			fmt.Fprint(w, `{"items": [{"id": 3}]}`)
+		case "3":
+			w.Header().Set("Link", `</items?page=2>; rel="prev"`)
+			fmt.Fprint(w, `{"items": [{"id": 4}]}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	address := server.URL + "/items"
+	plugin := &httpplugin.HTTP{
+		URLs:       []string{address},
+		Pagination: &httpplugin.Pagination{Type: "link_header"},
+		Log:        testutil.Logger{},
+	}
+	plugin.SetParserFunc(itemsParser)
+	require.NoError(t, plugin.Init())
+
+	var acc testutil.Accumulator
+	require.NoError(t, acc.GatherError(plugin.Gather))
+	testutil.RequireMetricsEqual(t, itemMetrics(address, 1, 2, 3, 4), acc.GetTelegrafMetrics(), testutil.IgnoreTime())
+}
+
+func TestPaginationBody(t *testing.T) {
+	pages := map[string]string{
+		"":    `{"items": [{"id": 1}], "next": "abc"}`,
+		"abc": `{"items": [{"id": 2}], "next": "def"}`,
+		"def": `{"items": [{"id": 3}], "next": null}`,
+	}
+
+	tests := []struct {
+		name      string
+		parameter string
+		handler   http.HandlerFunc
+	}{
+		{
+			name:      "cursor",
+			parameter: "cursor",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				fmt.Fprint(w, pages[r.URL.Query().Get("cursor")])
+			},
+		},
+		{
+			name: "url",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				page := pages[strings.TrimPrefix(r.URL.Path, "/items/")]
+				fmt.Fprint(w, strings.Replace(page, `"next": "`, `"next": "/items/`, 1))
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(tt.handler)
+			defer server.Close()
+
+			address := server.URL + "/items/"
+			plugin := &httpplugin.HTTP{
+				URLs: []string{address},
+				Pagination: &httpplugin.Pagination{
+					Type:          "body",
+					NextPath:      "next",
+					NextParameter: tt.parameter,
+				},
+				Log: testutil.Logger{},
+			}
+			plugin.SetParserFunc(itemsParser)
+			require.NoError(t, plugin.Init())
+
+			var acc testutil.Accumulator
+			require.NoError(t, acc.GatherError(plugin.Gather))
+			testutil.RequireMetricsEqual(t, itemMetrics(address, 1, 2, 3), acc.GetTelegrafMetrics(), testutil.IgnoreTime())
+		})
+	}
+}
+
+func TestPaginationOffset(t *testing.T) {
+	tests := []struct {
+		name      string
+		itemsPath string
+		expected  []string
+	}{
+		{
+			name:     "stop at empty page",
+			expected: []string{"0", "2", "4", "6"},
+		},
+		{
+			name:      "stop at incomplete page",
+			itemsPath: "items",
+			expected:  []string{"0", "2", "4"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var requested []string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				offset, err := strconv.Atoi(r.URL.Query().Get("start"))
+				if err != nil || r.URL.Query().Get("count") != "2" {
+					w.WriteHeader(http.StatusBadRequest)
+					return
+				}
+				requested = append(requested, r.URL.Query().Get("start"))
+
+				items := make([]string, 0, 2)
+				for id := offset + 1; id <= min(offset+2, 5); id++ {
+					items = append(items, fmt.Sprintf(`{"id": %d}`, id))
+				}
+				fmt.Fprintf(w, `{"items": [%s]}`, strings.Join(items, ","))
+			}))
+			defer server.Close()
+
+			address := server.URL + "/items"
+			plugin := &httpplugin.HTTP{
+				URLs: []string{address},
+				Pagination: &httpplugin.Pagination{
+					Type:            "offset",
+					OffsetParameter: "start",
+					LimitParameter:  "count",
+					Limit:           2,
+					ItemsPath:       tt.itemsPath,
+				},
+				Log: testutil.Logger{},
+			}
+			plugin.SetParserFunc(itemsParser)
+			require.NoError(t, plugin.Init())
+
+			var acc testutil.Accumulator
+			require.NoError(t, acc.GatherError(plugin.Gather))
+			testutil.RequireMetricsEqual(t, itemMetrics(address, 1, 2, 3, 4, 5), acc.GetTelegrafMetrics(), testutil.IgnoreTime())
+			require.Equal(t, tt.expected, requested)
+		})
+	}
+}
+
+func TestPaginationMaxPages(t *testing.T) {
+	var requests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		requests++
+		w.Header().Set("Link", fmt.Sprintf(`</items?page=%d>; rel="next"`, requests+1))
+		fmt.Fprintf(w, `{"items": [{"id": %d}]}`, requests)
+	}))
+	defer server.Close()
+
+	address := server.URL + "/items"
+	plugin := &httpplugin.HTTP{
+		URLs:       []string{address},
+		Pagination: &httpplugin.Pagination{Type: "link_header", MaxPages: 3},
+		Log:        testutil.Logger{},
+	}
+	plugin.SetParserFunc(itemsParser)
+	require.NoError(t, plugin.Init())
+
+	var acc testutil.Accumulator
+	require.NoError(t, acc.GatherError(plugin.Gather))
+	testutil.RequireMetricsEqual(t, itemMetrics(address, 1, 2, 3), acc.GetTelegrafMetrics(), testutil.IgnoreTime())
+	require.Equal(t, 3, requests)
+}
+
+func TestChainedRequests(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/login":
+			body, err := io.ReadAll(r.Body)
+			if err != nil || r.Method != http.MethodPost || string(body) != `{"user": "telegraf"}` {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			fmt.Fprint(w, `{"access_token": "secret-token", "sites": [{"id": "site-1"}]}`)
+		case "/sites/site-1":
+			if r.Header.Get("X-Site") != "site-1" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			fmt.Fprint(w, `{"devices": "devices-1"}`)
+		case "/sites/site-1/devices-1":
+			if r.Header.Get("Authorization") != "Bearer secret-token" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			fmt.Fprint(w, `{"items": [{"id": 1}]}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	body := config.NewSecret([]byte(`{"user": "telegraf"}`))
+	siteHeader := config.NewSecret([]byte("{{.site}}"))
+	authHeader := config.NewSecret([]byte("Bearer {{.token}}"))
+	address := server.URL + "/sites/{{.site}}/{{.devices}}"
+	plugin := &httpplugin.HTTP{
+		URLs:    []string{address},
+		Headers: map[string]*config.Secret{"Authorization": &authHeader},
+		Chain: []httpplugin.ChainRequest{
+			{
+				URL:     server.URL + "/login",
+				Method:  http.MethodPost,
+				Body:    body,
+				Extract: map[string]string{"token": "access_token", "site": "sites.0.id"},
+			},
+			{
+				URL:     server.URL + "/sites/{{.site}}",
+				Headers: map[string]*config.Secret{"X-Site": &siteHeader},
+				Extract: map[string]string{"devices": "devices"},
+			},
+		},
+		Log: testutil.Logger{},
+	}
+	plugin.SetParserFunc(itemsParser)
+	require.NoError(t, plugin.Init())
+
+	var acc testutil.Accumulator
+	require.NoError(t, acc.GatherError(plugin.Gather))
+	testutil.RequireMetricsEqual(t, itemMetrics(address, 1), acc.GetTelegrafMetrics(), testutil.IgnoreTime())
+
+	// Missing values cause the gather cycle to fail
+	plugin.Chain[1].Extract["devices"] = "foo"
+	acc.ClearMetrics()
+	require.ErrorContains(t, acc.GatherError(plugin.Gather), `path "foo" of value "devices" not found`)
+	require.Empty(t, acc.GetTelegrafMetrics())
+}
+
+func itemsParser() (telegraf.Parser, error) {
+	parser := &json.Parser{MetricName: "items", Query: "items"}
+	err := parser.Init()
+	return parser, err
+}
+
+func itemMetrics(address string, ids ...int) []telegraf.Metric {
+	metrics := make([]telegraf.Metric, 0, len(ids))
+	for _, id := range ids {
+		metrics = append(metrics, testutil.MustMetric(
+			"items",
+			map[string]string{"url": address},
+			map[string]interface{}{"id": float64(id)},
+			time.Unix(0, 0),
+		))
+	}
+	return metrics
+}
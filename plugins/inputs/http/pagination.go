@@ -0,0 +1,186 @@
+package http
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/tidwall/gjson"
+)
+
+// Pagination contains the settings for following paginated responses
+type Pagination struct {
+	Type            string `toml:"type"`
+	MaxPages        int    `toml:"max_pages"`
+	NextPath        string `toml:"next_path"`
+	NextParameter   string `toml:"next_parameter"`
+	OffsetParameter string `toml:"offset_parameter"`
+	LimitParameter  string `toml:"limit_parameter"`
+	Limit           int    `toml:"limit"`
+	ItemsPath       string `toml:"items_path"`
+}
+
+func (p *Pagination) init() error {
+	if p.MaxPages == 0 {
+		p.MaxPages = 100
+	}
+	if p.MaxPages < 0 {
+		return fmt.Errorf("invalid 'max_pages' setting %d", p.MaxPages)
+	}
+
+	switch p.Type {
+	case "link_header":
+	case "body":
+		if p.NextPath == "" {
+			return errors.New("'next_path' required for pagination type 'body'")
+		}
+	case "offset":
+		if p.OffsetParameter == "" {
+			p.OffsetParameter = "offset"
+		}
+		if p.LimitParameter == "" {
+			p.LimitParameter = "limit"
+		}
+		if p.Limit == 0 {
+			p.Limit = 100
+		}
+		if p.Limit < 0 {
+			return fmt.Errorf("invalid 'limit' setting %d", p.Limit)
+		}
+	default:
+		return fmt.Errorf("invalid pagination type %q", p.Type)
+	}
+
+	return nil
+}
+
+// first returns the address of the first page
+func (p *Pagination) first(address string) (string, error) {
+	if p.Type != "offset" {
+		return address, nil
+	}
+
+	// Keep the offset and limit if explicitly given in the address
+	u, err := url.Parse(address)
+	if err != nil {
+		return "", err
+	}
+	query := u.Query()
+	if !query.Has(p.OffsetParameter) {
+		query.Set(p.OffsetParameter, "0")
+	}
+	if !query.Has(p.LimitParameter) {
+		query.Set(p.LimitParameter, strconv.Itoa(p.Limit))
+	}
+	u.RawQuery = query.Encode()
+
+	return u.String(), nil
+}
+
+// next returns the address of the page following the current one or an
+// empty string if the current page is the last one
+func (p *Pagination) next(current string, header http.Header, body []byte, count int) (string, error) {
+	u, err := url.Parse(current)
+	if err != nil {
+		return "", err
+	}
+
+	var next string
+	switch p.Type {
+	case "link_header":
+		link := nextLink(header.Values("Link"))
+		if link == "" {
+			return "", nil
+		}
+		ref, err := url.Parse(link)
+		if err != nil {
+			return "", fmt.Errorf("parsing link %q failed: %w", link, err)
+		}
+		next = u.ResolveReference(ref).String()
+	case "body":
+		result := gjson.GetBytes(body, p.NextPath)
+		if !result.Exists() || result.String() == "" {
+			return "", nil
+		}
+		if p.NextParameter != "" {
+			query := u.Query()
+			query.Set(p.NextParameter, result.String())
+			u.RawQuery = query.Encode()
+			next = u.String()
+			break
+		}
+		ref, err := url.Parse(result.String())
+		if err != nil {
+			return "", fmt.Errorf("parsing next page %q failed: %w", result.String(), err)
+		}
+		next = u.ResolveReference(ref).String()
+	case "offset":
+		// Stop at an empty page or at a page with less items than requested
+		if count == 0 {
+			return "", nil
+		}
+		if p.ItemsPath != "" && len(gjson.GetBytes(body, p.ItemsPath).Array()) < p.Limit {
+			return "", nil
+		}
+
+		query := u.Query()
+		offset, err := strconv.Atoi(query.Get(p.OffsetParameter))
+		if err != nil {
+			return "", fmt.Errorf("parsing offset failed: %w", err)
+		}
+		limit, err := strconv.Atoi(query.Get(p.LimitParameter))
+		if err != nil {
+			return "", fmt.Errorf("parsing limit failed: %w", err)
+		}
+		query.Set(p.OffsetParameter, strconv.Itoa(offset+limit))
+		u.RawQuery = query.Encode()
+		next = u.String()
+	}
+
+	// Protect against servers pointing to the same page again
+	if next == current {
+		return "", nil
+	}
+	return next, nil
+}
+
+// nextLink extracts the target of the link with relation type "next" from
+// the given Link header values according to RFC 8288
+func nextLink(values []string) string {
+	for _, value := range values {
+		for value != "" {
+			start := strings.IndexByte(value, '<')
+			if start < 0 {
+				break
+			}
+			end := strings.IndexByte(value[start:], '>')
+			if end < 0 {
+				break
+			}
+			target := value[start+1 : start+end]
+			value = value[start+end+1:]
+
+			// The parameters end at the next link
+			params := value
+			if idx := strings.IndexByte(value, '<'); idx >= 0 {
+				params = value[:idx]
+			}
+			for _, param := range strings.Split(params, ";") {
+				k, v, found := strings.Cut(param, "=")
+				if !found || !strings.EqualFold(strings.TrimSpace(k), "rel") {
+					continue
+				}
+				v = strings.Trim(v, "\" ,\t")
+				for _, rel := range strings.Fields(v) {
+					if strings.EqualFold(rel, "next") {
+						return target
+					}
+				}
+			}
+		}
+	}
+	return ""
+}
@@ -0,0 +1,444 @@
+package journald
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/pierrec/lz4/v4"
+	"github.com/ulikunitz/xz"
+)
+
+// The reader implements the journal file format as described in
+// https://systemd.io/JOURNAL_FILE_FORMAT/. Entries are read sequentially
+// using the global entry array of the file so the hash tables are not used.
+
+var signature = []byte("LPKSHHRH")
+
+// Incompatible header flags
+const (
+	flagCompressedXZ   = 1 << 0
+	flagCompressedLZ4  = 1 << 1
+	flagKeyedHash      = 1 << 2
+	flagCompressedZSTD = 1 << 3
+	flagCompact        = 1 << 4
+
+	supportedFlags = flagCompressedXZ | flagCompressedLZ4 | flagKeyedHash | flagCompressedZSTD | flagCompact
+)
+
+// Object types and flags
+const (
+	objectData       = 1
+	objectEntry      = 3
+	objectEntryArray = 6
+
+	objectCompressedXZ   = 1 << 0
+	objectCompressedLZ4  = 1 << 1
+	objectCompressedZSTD = 1 << 2
+)
+
+const (
+	headerMinSize      = 208
+	objectHeaderSize   = 16
+	entryHeaderSize    = 64
+	arrayHeaderSize    = 24
+	dataHeaderSize     = 64
+	dataCompactSize    = 72
+	maxObjectSize      = 64 * 1024 * 1024
+	maxDataCacheSize   = 16384
+	maxUncompressedLen = 64 * 1024 * 1024
+)
+
+var zstdDecoder, _ = zstd.NewReader(nil, zstd.WithDecoderConcurrency(1), zstd.WithDecoderMaxMemory(maxUncompressedLen))
+
+type id128 [16]byte
+
+func (id id128) String() string {
+	return hex.EncodeToString(id[:])
+}
+
+// cursor identifies an entry in the same format as used by journalctl
+type cursor struct {
+	seqnumID  id128
+	seqnum    uint64
+	bootID    id128
+	monotonic uint64
+	realtime  uint64
+	xorHash   uint64
+}
+
+func (c *cursor) String() string {
+	return fmt.Sprintf("s=%s;i=%x;b=%s;m=%x;t=%x;x=%x", c.seqnumID, c.seqnum, c.bootID, c.monotonic, c.realtime, c.xorHash)
+}
+
+// less returns true if the entry identified by the cursor was written before
+// the one identified by the other cursor
+func (c *cursor) less(other *cursor) bool {
+	if c.seqnumID == other.seqnumID {
+		return c.seqnum < other.seqnum
+	}
+	return c.realtime < other.realtime
+}
+
+func parseCursor(s string) (*cursor, error) {
+	var c cursor
+	var seen int
+	for _, part := range strings.Split(s, ";") {
+		k, v, found := strings.Cut(part, "=")
+		if !found {
+			return nil, fmt.Errorf("invalid cursor element %q", part)
+		}
+		var err error
+		switch k {
+		case "s":
+			err = parseID(v, &c.seqnumID)
+			seen |= 1
+		case "i":
+			c.seqnum, err = strconv.ParseUint(v, 16, 64)
+			seen |= 2
+		case "b":
+			err = parseID(v, &c.bootID)
+		case "m":
+			c.monotonic, err = strconv.ParseUint(v, 16, 64)
+		case "t":
+			c.realtime, err = strconv.ParseUint(v, 16, 64)
+			seen |= 4
+		case "x":
+			c.xorHash, err = strconv.ParseUint(v, 16, 64)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid cursor element %q: %w", part, err)
+		}
+	}
+	if seen != 7 {
+		return nil, fmt.Errorf("incomplete cursor %q", s)
+	}
+	return &c, nil
+}
+
+func parseID(s string, id *id128) error {
+	buf, err := hex.DecodeString(s)
+	if err != nil {
+		return err
+	}
+	if len(buf) != len(id) {
+		return fmt.Errorf("invalid length %d", len(buf))
+	}
+	copy(id[:], buf)
+	return nil
+}
+
+// entry is a single journal entry
+type entry struct {
+	cursor
+	fields map[string]string
+}
+
+type entryArray struct {
+	offset uint64
+	first  uint64
+	items  uint64
+}
+
+// journalFile reads the entries of a single journal file
+type journalFile struct {
+	path string
+	file *os.File
+	size int64
+
+	fileID           id128
+	seqnumID         id128
+	incompatible     uint32
+	headerSize       uint64
+	nEntries         uint64
+	entryArrayOffset uint64
+
+	arrays []entryArray
+	cache  map[uint64][2]string
+}
+
+func openJournal(path string) (*journalFile, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	j := &journalFile{
+		path:  path,
+		file:  f,
+		cache: make(map[uint64][2]string),
+	}
+	if err := j.refresh(); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return j, nil
+}
+
+func (j *journalFile) close() error {
+	return j.file.Close()
+}
+
+func (j *journalFile) compact() bool {
+	return j.incompatible&flagCompact != 0
+}
+
+// refresh reads the header of the file to update the number of entries
+func (j *journalFile) refresh() error {
+	stat, err := j.file.Stat()
+	if err != nil {
+		return err
+	}
+	j.size = stat.Size()
+
+	buf := make([]byte, headerMinSize)
+	if _, err := j.file.ReadAt(buf, 0); err != nil {
+		return fmt.Errorf("reading header failed: %w", err)
+	}
+	if !bytes.Equal(buf[0:8], signature) {
+		return errors.New("invalid file signature")
+	}
+
+	incompatible := binary.LittleEndian.Uint32(buf[12:16])
+	if incompatible&^supportedFlags != 0 {
+		return fmt.Errorf("unsupported incompatible flags 0x%x", incompatible&^supportedFlags)
+	}
+
+	var fileID id128
+	copy(fileID[:], buf[24:40])
+	if j.fileID != (id128{}) && fileID != j.fileID {
+		return errors.New("file was replaced")
+	}
+	j.fileID = fileID
+	copy(j.seqnumID[:], buf[72:88])
+	j.incompatible = incompatible
+	j.headerSize = binary.LittleEndian.Uint64(buf[88:96])
+	j.nEntries = binary.LittleEndian.Uint64(buf[152:160])
+	j.entryArrayOffset = binary.LittleEndian.Uint64(buf[176:184])
+	if j.headerSize < headerMinSize {
+		return fmt.Errorf("invalid header size %d", j.headerSize)
+	}
+	return nil
+}
+
+// readObject reads the object of the given type at the given offset
+func (j *journalFile) readObject(offset uint64, objectType uint8, minSize uint64) ([]byte, error) {
+	if offset%8 != 0 || offset < j.headerSize || offset+objectHeaderSize > uint64(j.size) {
+		return nil, fmt.Errorf("invalid object offset %d", offset)
+	}
+
+	header := make([]byte, objectHeaderSize)
+	if _, err := j.file.ReadAt(header, int64(offset)); err != nil {
+		return nil, err
+	}
+	if header[0] != objectType {
+		return nil, fmt.Errorf("object at offset %d has type %d instead of %d", offset, header[0], objectType)
+	}
+	size := binary.LittleEndian.Uint64(header[8:16])
+	if size < minSize || size > maxObjectSize || offset+size > uint64(j.size) {
+		return nil, fmt.Errorf("invalid size %d of object at offset %d", size, offset)
+	}
+
+	buf := make([]byte, size)
+	if _, err := j.file.ReadAt(buf, int64(offset)); err != nil {
+		return nil, err
+	}
+	return buf, nil
+}
+
+// entryOffset returns the offset of the entry with the given index
+func (j *journalFile) entryOffset(idx uint64) (uint64, error) {
+	if idx >= j.nEntries {
+		return 0, io.EOF
+	}
+
+	itemSize := uint64(8)
+	if j.compact() {
+		itemSize = 4
+	}
+
+	// Extend the chain of entry arrays until the index is covered
+	for len(j.arrays) == 0 || idx >= j.arrays[len(j.arrays)-1].first+j.arrays[len(j.arrays)-1].items {
+		var offset, first uint64
+		if len(j.arrays) == 0 {
+			offset = j.entryArrayOffset
+		} else {
+			// The link to the next array is written after the array was
+			// filled, so read the last array again
+			last := j.arrays[len(j.arrays)-1]
+			buf, err := j.readObject(last.offset, objectEntryArray, arrayHeaderSize)
+			if err != nil {
+				return 0, err
+			}
+			offset = binary.LittleEndian.Uint64(buf[16:24])
+			first = last.first + last.items
+		}
+		if offset == 0 {
+			return 0, fmt.Errorf("entry %d not found in entry arrays", idx)
+		}
+
+		buf, err := j.readObject(offset, objectEntryArray, arrayHeaderSize)
+		if err != nil {
+			return 0, err
+		}
+		items := (uint64(len(buf)) - arrayHeaderSize) / itemSize
+		if items == 0 {
+			return 0, fmt.Errorf("empty entry array at offset %d", offset)
+		}
+		j.arrays = append(j.arrays, entryArray{offset: offset, first: first, items: items})
+	}
+
+	for _, a := range j.arrays {
+		if idx >= a.first+a.items {
+			continue
+		}
+		pos := a.offset + arrayHeaderSize + (idx-a.first)*itemSize
+		buf := make([]byte, itemSize)
+		if _, err := j.file.ReadAt(buf, int64(pos)); err != nil {
+			return 0, err
+		}
+		if j.compact() {
+			return uint64(binary.LittleEndian.Uint32(buf)), nil
+		}
+		return binary.LittleEndian.Uint64(buf), nil
+	}
+	return 0, fmt.Errorf("entry %d not found in entry arrays", idx)
+}
+
+// entry reads the entry with the given index optionally including its fields
+func (j *journalFile) entry(idx uint64, withFields bool) (*entry, error) {
+	offset, err := j.entryOffset(idx)
+	if err != nil {
+		return nil, err
+	}
+	buf, err := j.readObject(offset, objectEntry, entryHeaderSize)
+	if err != nil {
+		return nil, err
+	}
+
+	e := &entry{
+		cursor: cursor{
+			seqnumID:  j.seqnumID,
+			seqnum:    binary.LittleEndian.Uint64(buf[16:24]),
+			realtime:  binary.LittleEndian.Uint64(buf[24:32]),
+			monotonic: binary.LittleEndian.Uint64(buf[32:40]),
+			xorHash:   binary.LittleEndian.Uint64(buf[56:64]),
+		},
+	}
+	copy(e.bootID[:], buf[40:56])
+	if !withFields {
+		return e, nil
+	}
+
+	items := buf[entryHeaderSize:]
+	itemSize := 16
+	if j.compact() {
+		itemSize = 4
+	}
+	e.fields = make(map[string]string, len(items)/itemSize)
+	for i := 0; i+itemSize <= len(items); i += itemSize {
+		var dataOffset uint64
+		if j.compact() {
+			dataOffset = uint64(binary.LittleEndian.Uint32(items[i:]))
+		} else {
+			dataOffset = binary.LittleEndian.Uint64(items[i:])
+		}
+		name, value, err := j.data(dataOffset)
+		if err != nil {
+			return nil, fmt.Errorf("reading data of entry %d failed: %w", e.seqnum, err)
+		}
+		if _, found := e.fields[name]; !found {
+			e.fields[name] = value
+		}
+	}
+	return e, nil
+}
+
+// data returns the field name and value of the data object at the given
+// offset. Data objects are shared between entries, so keep them cached.
+func (j *journalFile) data(offset uint64) (name, value string, err error) {
+	if kv, found := j.cache[offset]; found {
+		return kv[0], kv[1], nil
+	}
+
+	headerSize := uint64(dataHeaderSize)
+	if j.compact() {
+		headerSize = dataCompactSize
+	}
+	buf, err := j.readObject(offset, objectData, headerSize)
+	if err != nil {
+		return "", "", err
+	}
+	payload, err := decompress(buf[1], buf[headerSize:])
+	if err != nil {
+		return "", "", fmt.Errorf("decompressing data object at offset %d failed: %w", offset, err)
+	}
+
+	k, v, found := bytes.Cut(payload, []byte("="))
+	if !found {
+		return "", "", fmt.Errorf("invalid data object at offset %d", offset)
+	}
+	name, value = string(k), string(v)
+
+	if len(j.cache) >= maxDataCacheSize {
+		clear(j.cache)
+	}
+	j.cache[offset] = [2]string{name, value}
+
+	return name, value, nil
+}
+
+func decompress(flags uint8, payload []byte) ([]byte, error) {
+	switch {
+	case flags&objectCompressedZSTD != 0:
+		return zstdDecoder.DecodeAll(payload, nil)
+	case flags&objectCompressedLZ4 != 0:
+		// The payload is prefixed with the uncompressed size
+		if len(payload) < 8 {
+			return nil, errors.New("missing uncompressed size")
+		}
+		size := binary.LittleEndian.Uint64(payload[:8])
+		if size > maxUncompressedLen {
+			return nil, fmt.Errorf("uncompressed size %d exceeds limit", size)
+		}
+		buf := make([]byte, size)
+		n, err := lz4.UncompressBlock(payload[8:], buf)
+		if err != nil {
+			return nil, err
+		}
+		return buf[:n], nil
+	case flags&objectCompressedXZ != 0:
+		r, err := xz.NewReader(bytes.NewReader(payload))
+		if err != nil {
+			return nil, err
+		}
+		return io.ReadAll(io.LimitReader(r, maxUncompressedLen))
+	}
+	return payload, nil
+}
+
+// seek returns the index of the first entry of the file written after the
+// entry identified by the given cursor
+func (j *journalFile) seek(c *cursor) (uint64, error) {
+	// Entries are ordered within a file so use a binary search
+	lower, upper := uint64(0), j.nEntries
+	for lower < upper {
+		mid := lower + (upper-lower)/2
+		e, err := j.entry(mid, false)
+		if err != nil {
+			return 0, err
+		}
+		if c.less(&e.cursor) {
+			upper = mid
+		} else {
+			lower = mid + 1
+		}
+	}
+	return lower, nil
+}
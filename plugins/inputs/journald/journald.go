@@ -0,0 +1,454 @@
+//go:generate ../../../tools/readme_config_includer/generator
+package journald
+
+import (
+	"context"
+	_ "embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/filter"
+	"github.com/influxdata/telegraf/plugins/common/backpressure"
+	"github.com/influxdata/telegraf/plugins/inputs"
+)
+
+//go:embed sample.conf
+var sampleConfig string
+
+// Number of entries processed before checking for cancellation and
+// backpressure
+const batchSize = 1000
+
+var priorities = map[string]int{
+	"emerg":   0,
+	"alert":   1,
+	"crit":    2,
+	"err":     3,
+	"warning": 4,
+	"notice":  5,
+	"info":    6,
+	"debug":   7,
+}
+
+type Journald struct {
+	Paths         []string        `toml:"paths"`
+	Units         []string        `toml:"units"`
+	Priority      string          `toml:"priority"`
+	Matches       []string        `toml:"matches"`
+	Tags          []string        `toml:"tags"`
+	Fields        []string        `toml:"fields"`
+	FromBeginning bool            `toml:"from_beginning"`
+	PollInterval  config.Duration `toml:"poll_interval"`
+	Log           telegraf.Logger `toml:"-"`
+
+	unitFilter  filter.Filter
+	maxPriority int
+	matches     map[string][]string
+	tagFilter   filter.Filter
+	fieldFilter filter.Filter
+
+	acc     telegraf.Accumulator
+	files   map[string]*trackedFile
+	ignored map[string]bool
+	started bool
+	gate    backpressure.Gate
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+
+	// Cursor of the last entry processed
+	last *cursor
+	sync.Mutex
+}
+
+// trackedFile is a journal file with the index of the next entry to process
+type trackedFile struct {
+	journal *journalFile
+	next    uint64
+	head    *entry
+}
+
+func (*Journald) SampleConfig() string {
+	return sampleConfig
+}
+
+func (j *Journald) Init() error {
+	if len(j.Paths) == 0 {
+		return errors.New("no paths configured")
+	}
+	if j.PollInterval <= 0 {
+		return fmt.Errorf("invalid 'poll_interval' setting %v", j.PollInterval)
+	}
+
+	// Units without type are services
+	units := make([]string, 0, len(j.Units))
+	for _, u := range j.Units {
+		if !strings.Contains(u, ".") && !strings.ContainsAny(u, "*?[") {
+			u += ".service"
+		}
+		units = append(units, u)
+	}
+	unitFilter, err := filter.Compile(units)
+	if err != nil {
+		return fmt.Errorf("creating unit filter failed: %w", err)
+	}
+	j.unitFilter = unitFilter
+
+	j.maxPriority = -1
+	if j.Priority != "" {
+		p, found := priorities[j.Priority]
+		if !found {
+			n, err := strconv.Atoi(j.Priority)
+			if err != nil || n < 0 || n > 7 {
+				return fmt.Errorf("invalid 'priority' setting %q", j.Priority)
+			}
+			p = n
+		}
+		j.maxPriority = p
+	}
+
+	j.matches = make(map[string][]string, len(j.Matches))
+	for _, m := range j.Matches {
+		k, v, found := strings.Cut(m, "=")
+		if !found || k == "" {
+			return fmt.Errorf("invalid match %q", m)
+		}
+		j.matches[k] = append(j.matches[k], v)
+	}
+
+	tagFilter, err := filter.Compile(j.Tags)
+	if err != nil {
+		return fmt.Errorf("creating tag filter failed: %w", err)
+	}
+	j.tagFilter = tagFilter
+
+	fieldFilter, err := filter.Compile(j.Fields)
+	if err != nil {
+		return fmt.Errorf("creating field filter failed: %w", err)
+	}
+	if fieldFilter == nil {
+		return errors.New("no fields configured")
+	}
+	j.fieldFilter = fieldFilter
+
+	return nil
+}
+
+func (j *Journald) GetState() interface{} {
+	j.Lock()
+	defer j.Unlock()
+
+	if j.last == nil {
+		return ""
+	}
+	return j.last.String()
+}
+
+func (j *Journald) SetState(state interface{}) error {
+	s, ok := state.(string)
+	if !ok {
+		return fmt.Errorf("state has wrong type %T", state)
+	}
+	if s == "" {
+		return nil
+	}
+
+	c, err := parseCursor(s)
+	if err != nil {
+		return err
+	}
+
+	j.Lock()
+	j.last = c
+	j.Unlock()
+
+	return nil
+}
+
+func (j *Journald) SetBackpressure(active bool) {
+	j.gate.Set(active)
+}
+
+func (j *Journald) Start(acc telegraf.Accumulator) error {
+	j.acc = acc
+	j.files = make(map[string]*trackedFile)
+	j.ignored = make(map[string]bool)
+	j.started = false
+
+	ctx, cancel := context.WithCancel(context.Background())
+	j.cancel = cancel
+
+	j.wg.Add(1)
+	go func() {
+		defer j.wg.Done()
+
+		ticker := time.NewTicker(time.Duration(j.PollInterval))
+		defer ticker.Stop()
+		for {
+			j.poll(ctx)
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	return nil
+}
+
+func (*Journald) Gather(telegraf.Accumulator) error {
+	return nil
+}
+
+func (j *Journald) Stop() {
+	if j.cancel != nil {
+		j.cancel()
+	}
+	j.wg.Wait()
+
+	for path, f := range j.files {
+		if err := f.journal.close(); err != nil {
+			j.Log.Debugf("Closing %q failed: %v", path, err)
+		}
+	}
+	j.files = nil
+}
+
+// poll processes all entries written since the last poll in the order of
+// writing across all journal files
+func (j *Journald) poll(ctx context.Context) {
+	if j.gate.Active() {
+		return
+	}
+	j.updateFiles()
+
+	broken := make(map[*trackedFile]bool)
+	for processed := 1; ; processed++ {
+		if processed%batchSize == 0 && (ctx.Err() != nil || j.gate.Active()) {
+			return
+		}
+
+		// Find the oldest entry not processed yet
+		var oldest *trackedFile
+		for path, f := range j.files {
+			if broken[f] {
+				continue
+			}
+			if f.head == nil {
+				if f.next >= f.journal.nEntries {
+					continue
+				}
+				e, err := f.journal.entry(f.next, true)
+				if err != nil {
+					j.acc.AddError(fmt.Errorf("reading entry %d of %q failed: %w", f.next, path, err))
+					broken[f] = true
+					continue
+				}
+				f.head = e
+			}
+			if oldest == nil || f.head.less(&oldest.head.cursor) {
+				oldest = f
+			}
+		}
+		if oldest == nil {
+			return
+		}
+
+		e := oldest.head
+		oldest.head = nil
+		oldest.next++
+
+		j.Lock()
+		j.last = &e.cursor
+		j.Unlock()
+
+		if j.match(e) {
+			j.addEntry(e)
+		}
+	}
+}
+
+// updateFiles opens new journal files, refreshes the known ones and removes
+// deleted files
+func (j *Journald) updateFiles() {
+	j.Lock()
+	last := j.last
+	j.Unlock()
+
+	paths := j.discover()
+	for path := range paths {
+		if f, found := j.files[path]; found {
+			err := f.journal.refresh()
+			if err == nil {
+				continue
+			}
+
+			// The file was replaced, e.g. on rotation, so open it again
+			j.Log.Debugf("Refreshing %q failed: %v", path, err)
+			if err := f.journal.close(); err != nil {
+				j.Log.Debugf("Closing %q failed: %v", path, err)
+			}
+			delete(j.files, path)
+		}
+
+		journal, err := openJournal(path)
+		if err != nil {
+			if !j.ignored[path] {
+				j.Log.Warnf("Ignoring journal file %q: %v", path, err)
+				j.ignored[path] = true
+			}
+			continue
+		}
+		delete(j.ignored, path)
+
+		// Continue after the last processed entry if any. When starting
+		// without state, skip the existing entries unless reading from the
+		// beginning.
+		var next uint64
+		switch {
+		case last != nil:
+			next, err = journal.seek(last)
+		case !j.started && !j.FromBeginning:
+			next = journal.nEntries
+		}
+		if err != nil {
+			j.acc.AddError(fmt.Errorf("seeking in %q failed: %w", path, err))
+			journal.close()
+			continue
+		}
+		j.files[path] = &trackedFile{journal: journal, next: next}
+	}
+
+	for path, f := range j.files {
+		if !paths[path] {
+			j.Log.Debugf("Journal file %q was removed", path)
+			f.journal.close()
+			delete(j.files, path)
+		}
+	}
+
+	// Remember the newest existing entry when skipping existing entries to
+	// not process them when they show up in other files, e.g. after rotation
+	if !j.started && !j.FromBeginning && last == nil {
+		for path, f := range j.files {
+			if f.next == 0 {
+				continue
+			}
+			e, err := f.journal.entry(f.next-1, false)
+			if err != nil {
+				j.acc.AddError(fmt.Errorf("reading entry %d of %q failed: %w", f.next-1, path, err))
+				continue
+			}
+			if last == nil || last.less(&e.cursor) {
+				last = &e.cursor
+			}
+		}
+		j.Lock()
+		j.last = last
+		j.Unlock()
+	}
+	j.started = true
+}
+
+// discover returns the journal files found in the configured paths
+func (j *Journald) discover() map[string]bool {
+	found := make(map[string]bool)
+	for _, pattern := range j.Paths {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			j.Log.Errorf("Invalid path %q: %v", pattern, err)
+			continue
+		}
+		for _, match := range matches {
+			err := filepath.WalkDir(match, func(path string, d fs.DirEntry, err error) error {
+				if err != nil {
+					if errors.Is(err, os.ErrNotExist) {
+						return nil
+					}
+					return err
+				}
+				if !d.IsDir() && (strings.HasSuffix(path, ".journal") || strings.HasSuffix(path, ".journal~")) {
+					found[path] = true
+				}
+				return nil
+			})
+			if err != nil && !j.ignored[match] {
+				j.Log.Warnf("Searching for journal files in %q failed: %v", match, err)
+				j.ignored[match] = true
+			}
+		}
+	}
+	return found
+}
+
+// match checks if the entry matches the configured units, priority and
+// field matches
+func (j *Journald) match(e *entry) bool {
+	if j.unitFilter != nil {
+		unit, found := e.fields["_SYSTEMD_UNIT"]
+		if !found || !j.unitFilter.Match(unit) {
+			// Also accept messages of the service manager about the unit
+			unit, found = e.fields["UNIT"]
+			if !found || e.fields["_PID"] != "1" || !j.unitFilter.Match(unit) {
+				return false
+			}
+		}
+	}
+
+	if j.maxPriority >= 0 {
+		p, err := strconv.Atoi(e.fields["PRIORITY"])
+		if err != nil || p > j.maxPriority {
+			return false
+		}
+	}
+
+	for k, values := range j.matches {
+		v, found := e.fields[k]
+		if !found || !slices.Contains(values, v) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (j *Journald) addEntry(e *entry) {
+	tags := make(map[string]string)
+	fields := make(map[string]interface{})
+	for k, v := range e.fields {
+		name := strings.ToLower(strings.TrimLeft(k, "_"))
+		v = strings.ToValidUTF8(v, "�")
+		if j.tagFilter != nil && j.tagFilter.Match(k) {
+			tags[name] = v
+		}
+		if j.fieldFilter.Match(k) {
+			fields[name] = v
+		}
+	}
+	if len(fields) == 0 {
+		return
+	}
+
+	j.acc.AddFields("journald", fields, tags, time.UnixMicro(int64(e.realtime)))
+}
+
+func init() {
+	inputs.Add("journald", func() telegraf.Input {
+		return &Journald{
+			Paths:        []string{"/var/log/journal", "/run/log/journal"},
+			Tags:         []string{"_SYSTEMD_UNIT", "SYSLOG_IDENTIFIER", "PRIORITY"},
+			Fields:       []string{"MESSAGE"},
+			PollInterval: config.Duration(time.Second),
+		}
+	})
+}
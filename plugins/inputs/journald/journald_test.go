@@ -0,0 +1,445 @@
+package journald
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/pierrec/lz4/v4"
+	"github.com/stretchr/testify/require"
+	"github.com/ulikunitz/xz"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/metric"
+	"github.com/influxdata/telegraf/plugins/inputs"
+	"github.com/influxdata/telegraf/testutil"
+)
+
+func TestInitFail(t *testing.T) {
+	tests := []struct {
+		name     string
+		modify   func(*Journald)
+		expected string
+	}{
+		{
+			name:     "no paths",
+			modify:   func(j *Journald) { j.Paths = nil },
+			expected: "no paths configured",
+		},
+		{
+			name:     "invalid priority",
+			modify:   func(j *Journald) { j.Priority = "important" },
+			expected: "invalid 'priority' setting",
+		},
+		{
+			name:     "priority out of range",
+			modify:   func(j *Journald) { j.Priority = "8" },
+			expected: "invalid 'priority' setting",
+		},
+		{
+			name:     "invalid match",
+			modify:   func(j *Journald) { j.Matches = []string{"_TRANSPORT"} },
+			expected: "invalid match",
+		},
+		{
+			name:     "no fields",
+			modify:   func(j *Journald) { j.Fields = nil },
+			expected: "no fields configured",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plugin := inputs.Inputs["journald"]().(*Journald)
+			tt.modify(plugin)
+			require.ErrorContains(t, plugin.Init(), tt.expected)
+		})
+	}
+}
+
+func TestReadJournal(t *testing.T) {
+	for _, name := range []string{"compact", "legacy"} {
+		t.Run(name, func(t *testing.T) {
+			// Compare with the entries exported using journalctl
+			expected := readExport(t, filepath.Join("testdata", name+".json"))
+
+			journal, err := openJournal(filepath.Join("testdata", name+".journal"))
+			require.NoError(t, err)
+			defer journal.close()
+			require.Equal(t, name == "compact", journal.compact())
+			require.Equal(t, uint64(len(expected)), journal.nEntries)
+
+			for i, fields := range expected {
+				e, err := journal.entry(uint64(i), true)
+				require.NoError(t, err)
+				require.Equal(t, fields["__CURSOR"], e.cursor.String())
+				require.Equal(t, fields["__REALTIME_TIMESTAMP"], strconv.FormatUint(e.realtime, 10))
+				require.Equal(t, fields["__MONOTONIC_TIMESTAMP"], strconv.FormatUint(e.monotonic, 10))
+
+				delete(fields, "__CURSOR")
+				delete(fields, "__REALTIME_TIMESTAMP")
+				delete(fields, "__MONOTONIC_TIMESTAMP")
+				require.Equal(t, fields, e.fields)
+			}
+			_, err = journal.entry(uint64(len(expected)), true)
+			require.Error(t, err)
+
+			// Seek after each entry
+			for i, fields := range readExport(t, filepath.Join("testdata", name+".json")) {
+				c, err := parseCursor(fields["__CURSOR"])
+				require.NoError(t, err)
+				idx, err := journal.seek(c)
+				require.NoError(t, err)
+				require.Equal(t, uint64(i+1), idx)
+			}
+		})
+	}
+}
+
+func TestInvalidFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "invalid.journal")
+	require.NoError(t, os.WriteFile(path, make([]byte, 512), 0600))
+	_, err := openJournal(path)
+	require.ErrorContains(t, err, "invalid file signature")
+
+	buf, err := os.ReadFile(filepath.Join("testdata", "compact.journal"))
+	require.NoError(t, err)
+	binary.LittleEndian.PutUint32(buf[12:16], 1<<10)
+	require.NoError(t, os.WriteFile(path, buf, 0600))
+	_, err = openJournal(path)
+	require.ErrorContains(t, err, "unsupported incompatible flags")
+}
+
+func TestDecompress(t *testing.T) {
+	payload := []byte("MESSAGE=" + string(make([]byte, 4096)))
+
+	// LZ4 payloads are prefixed with the uncompressed size
+	block := make([]byte, lz4.CompressBlockBound(len(payload)))
+	n, err := lz4.CompressBlock(payload, block, nil)
+	require.NoError(t, err)
+	compressed := binary.LittleEndian.AppendUint64(nil, uint64(len(payload)))
+	compressed = append(compressed, block[:n]...)
+	actual, err := decompress(objectCompressedLZ4, compressed)
+	require.NoError(t, err)
+	require.Equal(t, payload, actual)
+
+	var buf bytes.Buffer
+	w, err := xz.NewWriter(&buf)
+	require.NoError(t, err)
+	_, err = w.Write(payload)
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	actual, err = decompress(objectCompressedXZ, buf.Bytes())
+	require.NoError(t, err)
+	require.Equal(t, payload, actual)
+
+	actual, err = decompress(0, payload)
+	require.NoError(t, err)
+	require.Equal(t, payload, actual)
+}
+
+func TestParseCursor(t *testing.T) {
+	s := "s=0a079952d845405db2cd8e8df0f3a8b4;i=1f;b=8baa6cd92ae942d7af79eb5a0137eb37;m=39edee337;t=65e0327ddfa5c;x=5c270c8796f8210e"
+	c, err := parseCursor(s)
+	require.NoError(t, err)
+	require.Equal(t, uint64(0x1f), c.seqnum)
+	require.Equal(t, uint64(0x65e0327ddfa5c), c.realtime)
+	require.Equal(t, s, c.String())
+
+	_, err = parseCursor("s=0a079952d845405db2cd8e8df0f3a8b4;i=1f")
+	require.ErrorContains(t, err, "incomplete cursor")
+	_, err = parseCursor("s=foo;i=1f;t=1")
+	require.ErrorContains(t, err, "invalid cursor element")
+}
+
+func TestFromBeginning(t *testing.T) {
+	plugin := newPlugin(filepath.Join("testdata", "compact.journal"))
+	plugin.FromBeginning = true
+	require.NoError(t, plugin.Init())
+
+	var acc testutil.Accumulator
+	require.NoError(t, plugin.Start(&acc))
+	defer plugin.Stop()
+
+	export := readExport(t, filepath.Join("testdata", "compact.json"))
+	expected := []telegraf.Metric{
+		journalMetric("Journal started", "systemd-journald", "6", ""),
+		journalMetric(export[1]["MESSAGE"], "systemd-journald", "6", ""),
+		journalMetric("starting foo", "foo", "6", "foo.service"),
+		journalMetric("foo failed", "foo", "3", "foo.service"),
+		journalMetric("bar debug", "bar", "7", "bar.service"),
+		journalMetric("bar warning", "bar", "4", "bar.service"),
+		journalMetric("custom fields", "custom", "5", ""),
+		journalMetric("second custom", "custom", "6", ""),
+		journalMetric("Journal stopped", "systemd-journald", "6", ""),
+	}
+	require.Eventually(t, func() bool {
+		return acc.NMetrics() >= uint64(len(expected))
+	}, 3*time.Second, 100*time.Millisecond)
+	testutil.RequireMetricsEqual(t, expected, acc.GetTelegrafMetrics(), testutil.IgnoreTime())
+
+	// The metrics use the time of the entries and the state refers to the
+	// last entry
+	realtime, err := strconv.ParseInt(export[2]["__REALTIME_TIMESTAMP"], 10, 64)
+	require.NoError(t, err)
+	require.Equal(t, time.UnixMicro(realtime), acc.GetTelegrafMetrics()[2].Time())
+	require.Equal(t, export[len(export)-1]["__CURSOR"], plugin.GetState())
+}
+
+func TestFilter(t *testing.T) {
+	tests := []struct {
+		name     string
+		modify   func(*Journald)
+		expected []string
+	}{
+		{
+			name:     "units",
+			modify:   func(j *Journald) { j.Units = []string{"foo"} },
+			expected: []string{"starting foo", "foo failed"},
+		},
+		{
+			name:     "unit pattern",
+			modify:   func(j *Journald) { j.Units = []string{"*.service"} },
+			expected: []string{"starting foo", "foo failed", "bar debug", "bar warning"},
+		},
+		{
+			name:     "priority name",
+			modify:   func(j *Journald) { j.Priority = "warning" },
+			expected: []string{"foo failed", "bar warning"},
+		},
+		{
+			name:     "priority number",
+			modify:   func(j *Journald) { j.Priority = "5" },
+			expected: []string{"foo failed", "bar warning", "custom fields"},
+		},
+		{
+			name: "matches",
+			modify: func(j *Journald) {
+				j.Matches = []string{"SYSLOG_IDENTIFIER=custom", "SYSLOG_IDENTIFIER=bar", "PRIORITY=6"}
+			},
+			expected: []string{"second custom"},
+		},
+		{
+			name: "combined",
+			modify: func(j *Journald) {
+				j.Units = []string{"bar.service"}
+				j.Priority = "info"
+			},
+			expected: []string{"bar warning"},
+		},
+	}
+	export := readExport(t, filepath.Join("testdata", "compact.json"))
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plugin := newPlugin(filepath.Join("testdata", "compact.journal"))
+			plugin.FromBeginning = true
+			tt.modify(plugin)
+			require.NoError(t, plugin.Init())
+
+			var acc testutil.Accumulator
+			require.NoError(t, plugin.Start(&acc))
+			defer plugin.Stop()
+
+			// Wait for all entries to be processed
+			require.Eventually(t, func() bool {
+				return plugin.GetState() == export[len(export)-1]["__CURSOR"]
+			}, 3*time.Second, 100*time.Millisecond)
+
+			actual := make([]string, 0, len(tt.expected))
+			for _, m := range acc.GetTelegrafMetrics() {
+				actual = append(actual, m.Fields()["message"].(string))
+			}
+			require.Equal(t, tt.expected, actual)
+		})
+	}
+}
+
+func TestTagsAndFields(t *testing.T) {
+	plugin := newPlugin(filepath.Join("testdata", "compact.journal"))
+	plugin.FromBeginning = true
+	plugin.Matches = []string{"SYSLOG_IDENTIFIER=custom"}
+	plugin.Tags = []string{"_TRANSPORT", "SYSLOG_*"}
+	plugin.Fields = []string{"MESSAGE", "REQUEST_ID", "LARGE"}
+	require.NoError(t, plugin.Init())
+
+	var acc testutil.Accumulator
+	require.NoError(t, plugin.Start(&acc))
+	defer plugin.Stop()
+	require.Eventually(t, func() bool {
+		return acc.NMetrics() >= 2
+	}, 3*time.Second, 100*time.Millisecond)
+
+	large := make([]byte, 3000)
+	for i := range large {
+		large[i] = 'x'
+	}
+	expected := []telegraf.Metric{
+		metric.New(
+			"journald",
+			map[string]string{"transport": "journal", "syslog_identifier": "custom"},
+			map[string]interface{}{"message": "custom fields", "request_id": "42", "large": string(large)},
+			time.Unix(0, 0),
+		),
+		metric.New(
+			"journald",
+			map[string]string{"transport": "journal", "syslog_identifier": "custom"},
+			map[string]interface{}{"message": "second custom"},
+			time.Unix(0, 0),
+		),
+	}
+	testutil.RequireMetricsEqual(t, expected, acc.GetTelegrafMetrics(), testutil.IgnoreTime())
+}
+
+func TestTail(t *testing.T) {
+	dir := t.TempDir()
+	copyFile(t, filepath.Join("testdata", "compact.journal"), filepath.Join(dir, "system.journal"))
+
+	plugin := newPlugin(dir)
+	plugin.PollInterval = config.Duration(100 * time.Millisecond)
+	require.NoError(t, plugin.Init())
+
+	var acc testutil.Accumulator
+	require.NoError(t, plugin.Start(&acc))
+	defer plugin.Stop()
+
+	// Existing entries are skipped
+	export := readExport(t, filepath.Join("testdata", "compact.json"))
+	require.Eventually(t, func() bool {
+		return plugin.GetState() == export[len(export)-1]["__CURSOR"]
+	}, 3*time.Second, 100*time.Millisecond)
+	require.Empty(t, acc.GetTelegrafMetrics())
+
+	// Entries of new files are read
+	copyFile(t, filepath.Join("testdata", "legacy.journal"), filepath.Join(dir, "user-1000.journal"))
+	require.Eventually(t, func() bool {
+		return acc.NMetrics() >= 9
+	}, 3*time.Second, 100*time.Millisecond)
+	require.Equal(t, "Journal started", acc.GetTelegrafMetrics()[0].Fields()["message"])
+	require.Equal(t, "Journal stopped", acc.GetTelegrafMetrics()[8].Fields()["message"])
+
+	// Files being renamed, e.g. on rotation, are not read again
+	require.NoError(t, os.Rename(filepath.Join(dir, "system.journal"), filepath.Join(dir, "system@archived.journal")))
+	time.Sleep(300 * time.Millisecond)
+	require.Len(t, acc.GetTelegrafMetrics(), 9)
+}
+
+func TestMultipleFiles(t *testing.T) {
+	plugin := newPlugin("testdata")
+	plugin.FromBeginning = true
+	require.NoError(t, plugin.Init())
+
+	var acc testutil.Accumulator
+	require.NoError(t, plugin.Start(&acc))
+	defer plugin.Stop()
+	require.Eventually(t, func() bool {
+		return acc.NMetrics() >= 18
+	}, 3*time.Second, 100*time.Millisecond)
+
+	// Entries are processed in order across files
+	metrics := acc.GetTelegrafMetrics()
+	require.Len(t, metrics, 18)
+	for i := 1; i < len(metrics); i++ {
+		require.False(t, metrics[i].Time().Before(metrics[i-1].Time()))
+	}
+}
+
+func TestState(t *testing.T) {
+	export := readExport(t, filepath.Join("testdata", "compact.json"))
+
+	plugin := newPlugin(filepath.Join("testdata", "compact.journal"))
+	require.NoError(t, plugin.Init())
+	require.Empty(t, plugin.GetState())
+	require.NoError(t, plugin.SetState(export[5]["__CURSOR"]))
+	require.Equal(t, export[5]["__CURSOR"], plugin.GetState())
+
+	// Continue after the entry of the state although not reading from the
+	// beginning
+	var acc testutil.Accumulator
+	require.NoError(t, plugin.Start(&acc))
+	defer plugin.Stop()
+	require.Eventually(t, func() bool {
+		return acc.NMetrics() >= 3
+	}, 3*time.Second, 100*time.Millisecond)
+
+	expected := []telegraf.Metric{
+		journalMetric("custom fields", "custom", "5", ""),
+		journalMetric("second custom", "custom", "6", ""),
+		journalMetric("Journal stopped", "systemd-journald", "6", ""),
+	}
+	testutil.RequireMetricsEqual(t, expected, acc.GetTelegrafMetrics(), testutil.IgnoreTime())
+	require.Equal(t, export[8]["__CURSOR"], plugin.GetState())
+
+	require.ErrorContains(t, plugin.SetState(42), "state has wrong type")
+	require.ErrorContains(t, plugin.SetState("foo"), "invalid cursor element")
+}
+
+func TestBackpressure(t *testing.T) {
+	plugin := newPlugin(filepath.Join("testdata", "compact.journal"))
+	plugin.FromBeginning = true
+	plugin.PollInterval = config.Duration(100 * time.Millisecond)
+	require.NoError(t, plugin.Init())
+
+	plugin.SetBackpressure(true)
+	var acc testutil.Accumulator
+	require.NoError(t, plugin.Start(&acc))
+	defer plugin.Stop()
+
+	time.Sleep(300 * time.Millisecond)
+	require.Empty(t, acc.GetTelegrafMetrics())
+
+	plugin.SetBackpressure(false)
+	require.Eventually(t, func() bool {
+		return acc.NMetrics() >= 9
+	}, 3*time.Second, 100*time.Millisecond)
+}
+
+func newPlugin(paths ...string) *Journald {
+	plugin := inputs.Inputs["journald"]().(*Journald)
+	plugin.Paths = paths
+	plugin.Log = testutil.Logger{}
+	return plugin
+}
+
+func journalMetric(message, identifier, priority, unit string) telegraf.Metric {
+	tags := map[string]string{
+		"syslog_identifier": identifier,
+		"priority":          priority,
+	}
+	if unit != "" {
+		tags["systemd_unit"] = unit
+	}
+	return metric.New("journald", tags, map[string]interface{}{"message": message}, time.Unix(0, 0))
+}
+
+func readExport(t *testing.T, path string) []map[string]string {
+	t.Helper()
+
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+
+	var entries []map[string]string
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 1024*1024)
+	for scanner.Scan() {
+		var fields map[string]string
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &fields))
+		entries = append(entries, fields)
+	}
+	require.NoError(t, scanner.Err())
+	return entries
+}
+
+func copyFile(t *testing.T, src, dst string) {
+	t.Helper()
+
+	buf, err := os.ReadFile(src)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(dst, buf, 0600))
+}
@@ -17,6 +17,7 @@ import (
 	"github.com/influxdata/telegraf"
 	"github.com/influxdata/telegraf/config"
 	"github.com/influxdata/telegraf/internal"
+	"github.com/influxdata/telegraf/plugins/common/backpressure"
 	"github.com/influxdata/telegraf/plugins/common/kafka"
 	"github.com/influxdata/telegraf/plugins/inputs"
 )
@@ -67,6 +68,9 @@ type KafkaConsumer struct {
 	topicLock sync.Mutex
 	wg        sync.WaitGroup
 	cancel    context.CancelFunc
+
+	// Gate for pausing the consumption on backpressure of the outputs
+	gate backpressure.Gate
 }
 
 // consumerGroupHandler is a sarama.ConsumerGroupHandler implementation.
@@ -79,6 +83,7 @@ type consumerGroupHandler struct {
 
 	acc    telegraf.TrackingAccumulator
 	sem    semaphore
+	gate   *backpressure.Gate
 	parser telegraf.Parser
 	wg     sync.WaitGroup
 	cancel context.CancelFunc
@@ -262,6 +267,7 @@ func (k *KafkaConsumer) Start(acc telegraf.Accumulator) error {
 
 		for ctx.Err() == nil {
 			handler := newConsumerGroupHandler(acc, k.MaxUndeliveredMessages, k.parser, k.Log)
+			handler.gate = &k.gate
 			handler.maxMessageLen = k.MaxMessageLen
 			handler.topicTag = k.TopicTag
 			handler.msgHeaderToMetricName = k.MsgHeaderAsMetricName
@@ -315,6 +321,12 @@ func (k *KafkaConsumer) Stop() {
 	k.wg.Wait()
 }
 
+// SetBackpressure pauses or resumes consuming messages. Kafka retains the
+// messages not consumed while paused.
+func (k *KafkaConsumer) SetBackpressure(active bool) {
+	k.gate.Set(active)
+}
+
 func (k *KafkaConsumer) compileTopicRegexps() error {
 	// While we can add new topics matching extant regexps, we can't
 	// update that list on the fly.  We compile them once at startup.
@@ -450,6 +462,14 @@ func (h *consumerGroupHandler) ConsumeClaim(session sarama.ConsumerGroupSession,
 			return err
 		}
 
+		// Stop fetching new messages while the outputs are saturated
+		if h.gate != nil {
+			if err := h.gate.Wait(ctx); err != nil {
+				h.release()
+				return nil
+			}
+		}
+
 		select {
 		case <-ctx.Done():
 			return nil
@@ -385,6 +385,52 @@ func TestConsumerGroupHandlerConsumeClaim(t *testing.T) {
 	testutil.RequireMetricsEqual(t, expected, acc.GetTelegrafMetrics(), testutil.IgnoreTime())
 }
 
+func TestConsumerGroupHandlerBackpressure(t *testing.T) {
+	acc := &testutil.Accumulator{}
+	parser := value.Parser{
+		MetricName: "cpu",
+		DataType:   "int",
+	}
+	require.NoError(t, parser.Init())
+
+	plugin := &KafkaConsumer{}
+	plugin.SetBackpressure(true)
+
+	cg := newConsumerGroupHandler(acc, 1, &parser, testutil.Logger{})
+	cg.gate = &plugin.gate
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	session := &FakeConsumerGroupSession{ctx: ctx}
+	claim := &FakeConsumerGroupClaim{
+		messages: make(chan *sarama.ConsumerMessage, 1),
+	}
+	require.NoError(t, cg.Setup(session))
+
+	claim.messages <- &sarama.ConsumerMessage{
+		Topic: "telegraf",
+		Value: []byte("42"),
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- cg.ConsumeClaim(session, claim)
+	}()
+
+	// The message must not be consumed while paused
+	time.Sleep(100 * time.Millisecond)
+	require.Zero(t, acc.NMetrics())
+	require.Len(t, claim.messages, 1)
+
+	plugin.SetBackpressure(false)
+	acc.Wait(1)
+
+	cancel()
+	require.Eventually(t, func() bool { return len(done) > 0 }, time.Second, 10*time.Millisecond)
+	require.NoError(t, cg.Cleanup(session))
+}
+
 func TestConsumerGroupHandlerHandle(t *testing.T) {
 	tests := []struct {
 		name                string
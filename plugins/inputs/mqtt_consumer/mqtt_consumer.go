@@ -16,6 +16,7 @@ import (
 	"github.com/influxdata/telegraf"
 	"github.com/influxdata/telegraf/config"
 	"github.com/influxdata/telegraf/internal"
+	"github.com/influxdata/telegraf/plugins/common/backpressure"
 	"github.com/influxdata/telegraf/plugins/common/tls"
 	"github.com/influxdata/telegraf/plugins/inputs"
 	"github.com/influxdata/telegraf/selfstat"
@@ -64,6 +65,9 @@ type MQTTConsumer struct {
 	payloadSize   selfstat.Stat
 	messagesRecv  selfstat.Stat
 	wg            sync.WaitGroup
+
+	// Gate for pausing the consumption on backpressure of the outputs
+	gate backpressure.Gate
 }
 
 type client interface {
@@ -159,14 +163,22 @@ func (m *MQTTConsumer) Gather(_ telegraf.Accumulator) error {
 }
 
 func (m *MQTTConsumer) Stop() {
+	// Cancel first to release message handlers waiting due to backpressure
+	if m.cancel != nil {
+		m.cancel()
+	}
 	if m.client.IsConnected() {
 		m.Log.Debugf("Disconnecting %v", m.Servers)
 		m.client.Disconnect(200)
 		m.Log.Debugf("Disconnected %v", m.Servers)
 	}
-	if m.cancel != nil {
-		m.cancel()
-	}
+}
+
+// SetBackpressure pauses or resumes handling messages. The broker only retains
+// the messages not handled while paused for persistent sessions with a QoS
+// level above zero.
+func (m *MQTTConsumer) SetBackpressure(active bool) {
+	m.gate.Set(active)
 }
 
 func (m *MQTTConsumer) connect() error {
@@ -244,6 +256,12 @@ func (m *MQTTConsumer) onDelivered(track telegraf.DeliveryInfo) {
 }
 
 func (m *MQTTConsumer) onMessage(_ mqtt.Client, msg mqtt.Message) {
+	// Hold back the message while the outputs are saturated, the message is
+	// redelivered for persistent sessions if we are stopped in the meantime
+	if err := m.gate.Wait(m.ctx); err != nil {
+		return
+	}
+
 	m.sem <- empty{}
 
 	payloadBytes := len(msg.Payload())
@@ -569,6 +569,100 @@ func TestTopicTag(t *testing.T) {
 	}
 }
 
+func TestBackpressure(t *testing.T) {
+	var handler mqtt.MessageHandler
+	fClient := &fakeClient{
+		connectF: func() mqtt.Token {
+			return &fakeToken{}
+		},
+		addRouteF: func(callback mqtt.MessageHandler) {
+			handler = callback
+		},
+		subscribeMultipleF: func() mqtt.Token {
+			return &fakeToken{}
+		},
+		disconnectF: func() {
+		},
+	}
+
+	plugin := newMQTTConsumer(func(*mqtt.ClientOptions) client {
+		return fClient
+	})
+	plugin.Log = testutil.Logger{}
+	plugin.Topics = []string{"telegraf"}
+
+	parser := &influx.Parser{}
+	require.NoError(t, parser.Init())
+	plugin.SetParser(parser)
+	require.NoError(t, plugin.Init())
+
+	var acc testutil.Accumulator
+	require.NoError(t, plugin.Start(&acc))
+	defer plugin.Stop()
+
+	plugin.SetBackpressure(true)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		handler(nil, &message{topic: "telegraf"})
+	}()
+
+	// The message must be held back while paused
+	time.Sleep(100 * time.Millisecond)
+	require.Zero(t, acc.NMetrics())
+
+	plugin.SetBackpressure(false)
+	<-done
+	require.Equal(t, uint64(1), acc.NMetrics())
+}
+
+func TestBackpressureStop(t *testing.T) {
+	var handler mqtt.MessageHandler
+	fClient := &fakeClient{
+		connectF: func() mqtt.Token {
+			return &fakeToken{}
+		},
+		addRouteF: func(callback mqtt.MessageHandler) {
+			handler = callback
+		},
+		subscribeMultipleF: func() mqtt.Token {
+			return &fakeToken{}
+		},
+		disconnectF: func() {
+		},
+	}
+
+	plugin := newMQTTConsumer(func(*mqtt.ClientOptions) client {
+		return fClient
+	})
+	plugin.Log = testutil.Logger{}
+	plugin.Topics = []string{"telegraf"}
+
+	parser := &influx.Parser{}
+	require.NoError(t, parser.Init())
+	plugin.SetParser(parser)
+	require.NoError(t, plugin.Init())
+
+	var acc testutil.Accumulator
+	require.NoError(t, plugin.Start(&acc))
+
+	plugin.SetBackpressure(true)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		handler(nil, &message{topic: "telegraf"})
+	}()
+
+	// Stopping must release the waiting handler without handling the message
+	plugin.Stop()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		require.Fail(t, "message handler still blocked after stopping")
+	}
+	require.Zero(t, acc.NMetrics())
+}
+
 func TestAddRouteCalledForEachTopic(t *testing.T) {
 	fClient := &fakeClient{
 		connectF: func() mqtt.Token {
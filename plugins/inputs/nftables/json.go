@@ -0,0 +1,137 @@
+//go:build linux
+
+package nftables
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// Objects of the JSON output of "nft -j list ruleset", see libnftables-json(5)
+type jsonRuleset struct {
+	Nftables []map[string]json.RawMessage `json:"nftables"`
+}
+
+type jsonRule struct {
+	Family  string                       `json:"family"`
+	Table   string                       `json:"table"`
+	Chain   string                       `json:"chain"`
+	Handle  uint64                       `json:"handle"`
+	Comment string                       `json:"comment"`
+	Expr    []map[string]json.RawMessage `json:"expr"`
+}
+
+type jsonCounter struct {
+	Family  string `json:"family"`
+	Table   string `json:"table"`
+	Name    string `json:"name"`
+	Packets uint64 `json:"packets"`
+	Bytes   uint64 `json:"bytes"`
+}
+
+type jsonQuota struct {
+	Family string `json:"family"`
+	Table  string `json:"table"`
+	Name   string `json:"name"`
+	Bytes  uint64 `json:"bytes"`
+	Used   uint64 `json:"used"`
+}
+
+type jsonSet struct {
+	Family string            `json:"family"`
+	Table  string            `json:"table"`
+	Name   string            `json:"name"`
+	Elem   []json.RawMessage `json:"elem"`
+}
+
+func (n *Nftables) parseRuleset(buf []byte) (*ruleset, error) {
+	var data jsonRuleset
+	if err := json.Unmarshal(buf, &data); err != nil {
+		return nil, fmt.Errorf("parsing ruleset failed: %w", err)
+	}
+	if data.Nftables == nil {
+		return nil, errors.New("parsing ruleset failed: no 'nftables' element")
+	}
+
+	var rs ruleset
+	for _, obj := range data.Nftables {
+		for kind, raw := range obj {
+			switch kind {
+			case "rule":
+				var r jsonRule
+				if err := json.Unmarshal(raw, &r); err != nil {
+					return nil, fmt.Errorf("parsing rule failed: %w", err)
+				}
+				if !n.includeTable(r.Table) {
+					continue
+				}
+
+				// Only rules with an anonymous counter are of interest, a
+				// reference to a named counter is given as string.
+				for _, expr := range r.Expr {
+					var c jsonCounter
+					if raw, found := expr["counter"]; !found || json.Unmarshal(raw, &c) != nil {
+						continue
+					}
+					rs.rules = append(rs.rules, rule{
+						family:  r.Family,
+						table:   r.Table,
+						chain:   r.Chain,
+						handle:  r.Handle,
+						comment: r.Comment,
+						packets: c.Packets,
+						bytes:   c.Bytes,
+					})
+					break
+				}
+			case "counter":
+				var c jsonCounter
+				if err := json.Unmarshal(raw, &c); err != nil {
+					return nil, fmt.Errorf("parsing counter failed: %w", err)
+				}
+				if !n.includeTable(c.Table) {
+					continue
+				}
+				rs.counters = append(rs.counters, counter{
+					family:  c.Family,
+					table:   c.Table,
+					name:    c.Name,
+					packets: c.Packets,
+					bytes:   c.Bytes,
+				})
+			case "quota":
+				var q jsonQuota
+				if err := json.Unmarshal(raw, &q); err != nil {
+					return nil, fmt.Errorf("parsing quota failed: %w", err)
+				}
+				if !n.includeTable(q.Table) {
+					continue
+				}
+				rs.quotas = append(rs.quotas, quota{
+					family: q.Family,
+					table:  q.Table,
+					name:   q.Name,
+					limit:  q.Bytes,
+					used:   q.Used,
+				})
+			case "set", "map":
+				var s jsonSet
+				if err := json.Unmarshal(raw, &s); err != nil {
+					return nil, fmt.Errorf("parsing %s failed: %w", kind, err)
+				}
+				if !n.includeTable(s.Table) {
+					continue
+				}
+				rs.sets = append(rs.sets, set{
+					family:   s.Family,
+					table:    s.Table,
+					name:     s.Name,
+					elements: len(s.Elem),
+				})
+			}
+		}
+	}
+
+	return &rs, nil
+}
@@ -0,0 +1,143 @@
+//go:build linux
+
+package nftables
+
+import (
+	"fmt"
+
+	nftnl "github.com/google/nftables"
+	"github.com/google/nftables/expr"
+	"github.com/google/nftables/userdata"
+)
+
+// Family names as used by the nft tool
+var families = map[nftnl.TableFamily]string{
+	nftnl.TableFamilyINet:   "inet",
+	nftnl.TableFamilyIPv4:   "ip",
+	nftnl.TableFamilyIPv6:   "ip6",
+	nftnl.TableFamilyARP:    "arp",
+	nftnl.TableFamilyNetdev: "netdev",
+	nftnl.TableFamilyBridge: "bridge",
+}
+
+func familyName(f nftnl.TableFamily) string {
+	if name, found := families[f]; found {
+		return name
+	}
+	return fmt.Sprintf("unknown(%d)", f)
+}
+
+func (n *Nftables) readNetlink() (*ruleset, error) {
+	conn, err := nftnl.New(nftnl.AsLasting())
+	if err != nil {
+		return nil, fmt.Errorf("connecting to netlink failed: %w", err)
+	}
+	defer conn.CloseLasting()
+
+	tables, err := conn.ListTables()
+	if err != nil {
+		return nil, fmt.Errorf("listing tables failed: %w", err)
+	}
+
+	var rs ruleset
+	for _, t := range tables {
+		if !n.includeTable(t.Name) {
+			continue
+		}
+		family := familyName(t.Family)
+
+		objs, err := conn.GetNamedObjects(t)
+		if err != nil {
+			return nil, fmt.Errorf("listing objects of table %q failed: %w", t.Name, err)
+		}
+		for _, o := range objs {
+			obj, ok := o.(*nftnl.NamedObj)
+			if !ok {
+				continue
+			}
+			switch v := obj.Obj.(type) {
+			case *expr.Counter:
+				rs.counters = append(rs.counters, counter{
+					family:  family,
+					table:   t.Name,
+					name:    obj.Name,
+					packets: v.Packets,
+					bytes:   v.Bytes,
+				})
+			case *expr.Quota:
+				rs.quotas = append(rs.quotas, quota{
+					family: family,
+					table:  t.Name,
+					name:   obj.Name,
+					limit:  v.Bytes,
+					used:   v.Consumed,
+				})
+			}
+		}
+
+		sets, err := conn.GetSets(t)
+		if err != nil {
+			return nil, fmt.Errorf("listing sets of table %q failed: %w", t.Name, err)
+		}
+		for _, s := range sets {
+			if s.Anonymous {
+				continue
+			}
+			elements, err := conn.GetSetElements(s)
+			if err != nil {
+				return nil, fmt.Errorf("listing elements of set %q failed: %w", s.Name, err)
+			}
+
+			// Intervals are stored as start and end element, only count the
+			// start to match the elements shown by the nft tool
+			var count int
+			for _, e := range elements {
+				if !e.IntervalEnd {
+					count++
+				}
+			}
+			rs.sets = append(rs.sets, set{
+				family:   family,
+				table:    t.Name,
+				name:     s.Name,
+				elements: count,
+			})
+		}
+	}
+
+	chains, err := conn.ListChains()
+	if err != nil {
+		return nil, fmt.Errorf("listing chains failed: %w", err)
+	}
+	for _, c := range chains {
+		if !n.includeTable(c.Table.Name) {
+			continue
+		}
+
+		rules, err := conn.GetRules(c.Table, c)
+		if err != nil {
+			return nil, fmt.Errorf("listing rules of chain %q failed: %w", c.Name, err)
+		}
+		for _, r := range rules {
+			for _, e := range r.Exprs {
+				v, ok := e.(*expr.Counter)
+				if !ok {
+					continue
+				}
+				comment, _ := userdata.GetString(r.UserData, userdata.TypeComment)
+				rs.rules = append(rs.rules, rule{
+					family:  familyName(c.Table.Family),
+					table:   c.Table.Name,
+					chain:   c.Name,
+					handle:  r.Handle,
+					comment: comment,
+					packets: v.Packets,
+					bytes:   v.Bytes,
+				})
+				break
+			}
+		}
+	}
+
+	return &rs, nil
+}
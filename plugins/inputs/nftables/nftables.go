@@ -0,0 +1,227 @@
+//go:generate ../../../tools/readme_config_includer/generator
+//go:build linux
+
+package nftables
+
+import (
+	"bytes"
+	_ "embed"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/filter"
+	"github.com/influxdata/telegraf/internal"
+	"github.com/influxdata/telegraf/plugins/inputs"
+)
+
+//go:embed sample.conf
+var sampleConfig string
+
+type Nftables struct {
+	Method  string          `toml:"method"`
+	Tables  []string        `toml:"tables"`
+	Binary  string          `toml:"binary"`
+	UseSudo bool            `toml:"use_sudo"`
+	Timeout config.Duration `toml:"timeout"`
+	Log     telegraf.Logger `toml:"-"`
+
+	tableFilter filter.Filter
+	reader      func() (*ruleset, error)
+	lister      func() ([]byte, error)
+}
+
+// ruleset contains the statistics of the nftables ruleset
+type ruleset struct {
+	rules    []rule
+	counters []counter
+	quotas   []quota
+	sets     []set
+}
+
+// rule is a rule containing an anonymous counter
+type rule struct {
+	family  string
+	table   string
+	chain   string
+	handle  uint64
+	comment string
+	packets uint64
+	bytes   uint64
+}
+
+// counter is a named counter object
+type counter struct {
+	family  string
+	table   string
+	name    string
+	packets uint64
+	bytes   uint64
+}
+
+// quota is a named quota object
+type quota struct {
+	family string
+	table  string
+	name   string
+	limit  uint64
+	used   uint64
+}
+
+// set is a named set or map
+type set struct {
+	family   string
+	table    string
+	name     string
+	elements int
+}
+
+func (*Nftables) SampleConfig() string {
+	return sampleConfig
+}
+
+func (n *Nftables) Init() error {
+	switch n.Method {
+	case "", "netlink":
+		n.Method = "netlink"
+		n.reader = n.readNetlink
+	case "nft":
+		n.reader = n.readNft
+		n.lister = n.listRuleset
+	default:
+		return fmt.Errorf("invalid 'method' setting %q", n.Method)
+	}
+
+	if n.Binary == "" {
+		n.Binary = "nft"
+	}
+	if n.Timeout <= 0 {
+		return fmt.Errorf("invalid 'timeout' setting %v", n.Timeout)
+	}
+
+	tableFilter, err := filter.Compile(n.Tables)
+	if err != nil {
+		return fmt.Errorf("creating table filter failed: %w", err)
+	}
+	n.tableFilter = tableFilter
+
+	return nil
+}
+
+func (n *Nftables) Gather(acc telegraf.Accumulator) error {
+	rs, err := n.reader()
+	if err != nil {
+		return err
+	}
+
+	for _, r := range rs.rules {
+		tags := map[string]string{
+			"family": r.family,
+			"table":  r.table,
+			"chain":  r.chain,
+			"handle": strconv.FormatUint(r.handle, 10),
+		}
+		if r.comment != "" {
+			tags["comment"] = r.comment
+		}
+		fields := map[string]interface{}{
+			"packets": r.packets,
+			"bytes":   r.bytes,
+		}
+		acc.AddCounter("nftables_rule", fields, tags)
+	}
+
+	for _, c := range rs.counters {
+		tags := map[string]string{
+			"family": c.family,
+			"table":  c.table,
+			"name":   c.name,
+		}
+		fields := map[string]interface{}{
+			"packets": c.packets,
+			"bytes":   c.bytes,
+		}
+		acc.AddCounter("nftables_counter", fields, tags)
+	}
+
+	for _, q := range rs.quotas {
+		tags := map[string]string{
+			"family": q.family,
+			"table":  q.table,
+			"name":   q.name,
+		}
+		fields := map[string]interface{}{
+			"limit_bytes": q.limit,
+			"used_bytes":  q.used,
+		}
+		acc.AddGauge("nftables_quota", fields, tags)
+	}
+
+	for _, s := range rs.sets {
+		tags := map[string]string{
+			"family": s.family,
+			"table":  s.table,
+			"name":   s.name,
+		}
+		fields := map[string]interface{}{
+			"elements": s.elements,
+		}
+		acc.AddGauge("nftables_set", fields, tags)
+	}
+
+	return nil
+}
+
+// includeTable checks if the table with the given name should be collected
+func (n *Nftables) includeTable(name string) bool {
+	return n.tableFilter == nil || n.tableFilter.Match(name)
+}
+
+func (n *Nftables) readNft() (*ruleset, error) {
+	buf, err := n.lister()
+	if err != nil {
+		return nil, err
+	}
+	return n.parseRuleset(buf)
+}
+
+func (n *Nftables) listRuleset() ([]byte, error) {
+	binary, err := exec.LookPath(n.Binary)
+	if err != nil {
+		return nil, err
+	}
+	var args []string
+	name := binary
+	if n.UseSudo {
+		name = "sudo"
+		args = append(args, binary)
+	}
+	args = append(args, "-j", "list", "ruleset")
+
+	cmd := exec.Command(name, args...)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := internal.RunTimeout(cmd, time.Duration(n.Timeout)); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("running %q failed: %w: %s", binary, err, msg)
+		}
+		return nil, fmt.Errorf("running %q failed: %w", binary, err)
+	}
+
+	return stdout.Bytes(), nil
+}
+
+func init() {
+	inputs.Add("nftables", func() telegraf.Input {
+		return &Nftables{
+			Method:  "netlink",
+			Binary:  "nft",
+			Timeout: config.Duration(5 * time.Second),
+		}
+	})
+}
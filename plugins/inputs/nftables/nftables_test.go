@@ -0,0 +1,201 @@
+//go:build linux
+
+package nftables
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/testutil"
+)
+
+func TestInitFail(t *testing.T) {
+	tests := []struct {
+		name     string
+		plugin   *Nftables
+		expected string
+	}{
+		{
+			name:     "invalid method",
+			plugin:   &Nftables{Method: "foo", Timeout: config.Duration(time.Second)},
+			expected: `invalid 'method' setting "foo"`,
+		},
+		{
+			name:     "invalid timeout",
+			plugin:   &Nftables{Method: "nft"},
+			expected: "invalid 'timeout' setting",
+		},
+		{
+			name:     "invalid table pattern",
+			plugin:   &Nftables{Tables: []string{"[a"}, Timeout: config.Duration(time.Second)},
+			expected: "creating table filter failed",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.ErrorContains(t, tt.plugin.Init(), tt.expected)
+		})
+	}
+}
+
+func TestGatherNft(t *testing.T) {
+	expected := []telegraf.Metric{
+		metric("nftables_rule",
+			map[string]string{"family": "inet", "table": "filter", "chain": "input", "handle": "9", "comment": "ssh"},
+			map[string]interface{}{"packets": uint64(42), "bytes": uint64(2520)},
+			telegraf.Counter,
+		),
+		metric("nftables_rule",
+			map[string]string{"family": "inet", "table": "filter", "chain": "input", "handle": "11"},
+			map[string]interface{}{"packets": uint64(7), "bytes": uint64(420)},
+			telegraf.Counter,
+		),
+		metric("nftables_rule",
+			map[string]string{"family": "inet", "table": "filter", "chain": "forward", "handle": "12", "comment": "quota"},
+			map[string]interface{}{"packets": uint64(0), "bytes": uint64(0)},
+			telegraf.Counter,
+		),
+		metric("nftables_rule",
+			map[string]string{"family": "ip", "table": "nat", "chain": "postrouting", "handle": "2", "comment": "masquerade"},
+			map[string]interface{}{"packets": uint64(311), "bytes": uint64(20983)},
+			telegraf.Counter,
+		),
+		metric("nftables_counter",
+			map[string]string{"family": "inet", "table": "filter", "name": "http"},
+			map[string]interface{}{"packets": uint64(1250), "bytes": uint64(98720)},
+			telegraf.Counter,
+		),
+		metric("nftables_quota",
+			map[string]string{"family": "inet", "table": "filter", "name": "monthly"},
+			map[string]interface{}{"limit_bytes": uint64(10737418240), "used_bytes": uint64(52428800)},
+			telegraf.Gauge,
+		),
+		metric("nftables_set",
+			map[string]string{"family": "inet", "table": "filter", "name": "blocked"},
+			map[string]interface{}{"elements": 3},
+			telegraf.Gauge,
+		),
+		metric("nftables_set",
+			map[string]string{"family": "inet", "table": "filter", "name": "allowed"},
+			map[string]interface{}{"elements": 0},
+			telegraf.Gauge,
+		),
+		metric("nftables_set",
+			map[string]string{"family": "inet", "table": "filter", "name": "ports"},
+			map[string]interface{}{"elements": 2},
+			telegraf.Gauge,
+		),
+	}
+
+	plugin := newPlugin(t, "ruleset.json")
+
+	var acc testutil.Accumulator
+	require.NoError(t, plugin.Gather(&acc))
+	testutil.RequireMetricsEqual(t, expected, acc.GetTelegrafMetrics(), testutil.IgnoreTime(), testutil.SortMetrics())
+}
+
+func TestGatherNftTables(t *testing.T) {
+	expected := []telegraf.Metric{
+		metric("nftables_rule",
+			map[string]string{"family": "ip", "table": "nat", "chain": "postrouting", "handle": "2", "comment": "masquerade"},
+			map[string]interface{}{"packets": uint64(311), "bytes": uint64(20983)},
+			telegraf.Counter,
+		),
+	}
+
+	plugin := &Nftables{
+		Method:  "nft",
+		Tables:  []string{"n*"},
+		Timeout: config.Duration(time.Second),
+		Log:     testutil.Logger{},
+	}
+	require.NoError(t, plugin.Init())
+	plugin.lister = fixtureLister("ruleset.json")
+
+	var acc testutil.Accumulator
+	require.NoError(t, plugin.Gather(&acc))
+	testutil.RequireMetricsEqual(t, expected, acc.GetTelegrafMetrics(), testutil.IgnoreTime())
+}
+
+func TestGatherNftEmpty(t *testing.T) {
+	plugin := newPlugin(t, "empty.json")
+
+	var acc testutil.Accumulator
+	require.NoError(t, plugin.Gather(&acc))
+	require.Empty(t, acc.GetTelegrafMetrics())
+}
+
+func TestGatherNftFail(t *testing.T) {
+	tests := []struct {
+		name     string
+		lister   func() ([]byte, error)
+		expected string
+	}{
+		{
+			name:     "command failed",
+			lister:   func() ([]byte, error) { return nil, errors.New("permission denied") },
+			expected: "permission denied",
+		},
+		{
+			name:     "invalid json",
+			lister:   func() ([]byte, error) { return []byte("{"), nil },
+			expected: "parsing ruleset failed",
+		},
+		{
+			name:     "unexpected json",
+			lister:   func() ([]byte, error) { return []byte(`{"foo": []}`), nil },
+			expected: "no 'nftables' element",
+		},
+		{
+			name: "invalid rule",
+			lister: func() ([]byte, error) {
+				return []byte(`{"nftables": [{"rule": {"family": "inet", "handle": "foo"}}]}`), nil
+			},
+			expected: "parsing rule failed",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plugin := &Nftables{
+				Method:  "nft",
+				Timeout: config.Duration(time.Second),
+				Log:     testutil.Logger{},
+			}
+			require.NoError(t, plugin.Init())
+			plugin.lister = tt.lister
+
+			var acc testutil.Accumulator
+			require.ErrorContains(t, plugin.Gather(&acc), tt.expected)
+		})
+	}
+}
+
+func newPlugin(t *testing.T, fixture string) *Nftables {
+	plugin := &Nftables{
+		Method:  "nft",
+		Timeout: config.Duration(time.Second),
+		Log:     testutil.Logger{},
+	}
+	require.NoError(t, plugin.Init())
+	plugin.lister = fixtureLister(fixture)
+	return plugin
+}
+
+func fixtureLister(fixture string) func() ([]byte, error) {
+	return func() ([]byte, error) {
+		return os.ReadFile(filepath.Join("testdata", fixture))
+	}
+}
+
+func metric(name string, tags map[string]string, fields map[string]interface{}, tp telegraf.ValueType) telegraf.Metric {
+	return testutil.MustMetric(name, tags, fields, time.Unix(0, 0), tp)
+}
@@ -0,0 +1,167 @@
+package opentelemetry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+
+	"go.opentelemetry.io/collector/pdata/plog/plogotlp"
+	"go.opentelemetry.io/collector/pdata/pmetric/pmetricotlp"
+	"go.opentelemetry.io/collector/pdata/ptrace/ptraceotlp"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/internal"
+)
+
+const (
+	contentTypeProtobuf = "application/x-protobuf"
+	contentTypeJSON     = "application/json"
+
+	// Default maximum request size matching the gRPC default
+	defaultMaxHTTPBodySize = 4 * 1024 * 1024
+)
+
+// otlpRequest is implemented by the export requests of all signals
+type otlpRequest interface {
+	UnmarshalProto(data []byte) error
+	UnmarshalJSON(data []byte) error
+}
+
+// otlpResponse is implemented by the export responses of all signals
+type otlpResponse interface {
+	MarshalProto() ([]byte, error)
+	MarshalJSON() ([]byte, error)
+}
+
+// httpService serves the OTLP/HTTP endpoints by passing the decoded requests
+// to the same services used for gRPC
+type httpService struct {
+	traces      *traceService
+	metrics     *metricsService
+	logs        *logsService
+	maxBodySize int64
+	log         telegraf.Logger
+}
+
+func (s *httpService) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /v1/traces", func(res http.ResponseWriter, req *http.Request) {
+		request := ptraceotlp.NewExportRequest()
+		s.serve(res, req, request, func(ctx context.Context) (otlpResponse, error) {
+			return s.traces.Export(ctx, request)
+		})
+	})
+	mux.HandleFunc("POST /v1/metrics", func(res http.ResponseWriter, req *http.Request) {
+		request := pmetricotlp.NewExportRequest()
+		s.serve(res, req, request, func(ctx context.Context) (otlpResponse, error) {
+			return s.metrics.Export(ctx, request)
+		})
+	})
+	mux.HandleFunc("POST /v1/logs", func(res http.ResponseWriter, req *http.Request) {
+		request := plogotlp.NewExportRequest()
+		s.serve(res, req, request, func(ctx context.Context) (otlpResponse, error) {
+			return s.logs.Export(ctx, request)
+		})
+	})
+	return mux
+}
+
+func (s *httpService) serve(res http.ResponseWriter, req *http.Request, request otlpRequest, export func(context.Context) (otlpResponse, error)) {
+	contentType, _, err := mime.ParseMediaType(req.Header.Get("Content-Type"))
+	if err != nil || (contentType != contentTypeProtobuf && contentType != contentTypeJSON) {
+		s.writeError(res, contentTypeJSON, http.StatusUnsupportedMediaType, fmt.Sprintf("unsupported content type %q", req.Header.Get("Content-Type")))
+		return
+	}
+
+	// Limit the size of the compressed and the decompressed body
+	body := http.MaxBytesReader(res, req.Body, s.maxBodySize)
+	defer body.Close()
+	reader, err := internal.NewStreamContentDecoder(req.Header.Get("Content-Encoding"), body)
+	if err != nil {
+		s.writeError(res, contentType, http.StatusBadRequest, fmt.Sprintf("decoding body failed: %v", err))
+		return
+	}
+	buf, err := io.ReadAll(io.LimitReader(reader, s.maxBodySize+1))
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			s.writeError(res, contentType, http.StatusRequestEntityTooLarge, "request body too large")
+			return
+		}
+		s.writeError(res, contentType, http.StatusBadRequest, fmt.Sprintf("reading body failed: %v", err))
+		return
+	}
+	if int64(len(buf)) > s.maxBodySize {
+		s.writeError(res, contentType, http.StatusRequestEntityTooLarge, "request body too large")
+		return
+	}
+
+	if contentType == contentTypeJSON {
+		err = request.UnmarshalJSON(buf)
+	} else {
+		err = request.UnmarshalProto(buf)
+	}
+	if err != nil {
+		s.writeError(res, contentType, http.StatusBadRequest, fmt.Sprintf("parsing request failed: %v", err))
+		return
+	}
+
+	response, err := export(req.Context())
+	if err != nil {
+		s.log.Errorf("Processing request to %q failed: %v", req.URL.Path, err)
+		s.writeError(res, contentType, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	var out []byte
+	if contentType == contentTypeJSON {
+		out, err = response.MarshalJSON()
+	} else {
+		out, err = response.MarshalProto()
+	}
+	if err != nil {
+		s.log.Errorf("Encoding response failed: %v", err)
+		res.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	res.Header().Set("Content-Type", contentType)
+	res.WriteHeader(http.StatusOK)
+	if _, err := res.Write(out); err != nil {
+		s.log.Debugf("Writing response failed: %v", err)
+	}
+}
+
+// writeError responds with a Status message as required by the OTLP
+// specification for failed requests
+func (s *httpService) writeError(res http.ResponseWriter, contentType string, code int, msg string) {
+	grpcCode := codes.InvalidArgument
+	if code >= http.StatusInternalServerError {
+		grpcCode = codes.Internal
+	}
+	st := status.New(grpcCode, msg).Proto()
+
+	var out []byte
+	var err error
+	if contentType == contentTypeProtobuf {
+		out, err = proto.Marshal(st)
+	} else {
+		out, err = protojson.Marshal(st)
+	}
+	if err != nil {
+		s.log.Errorf("Encoding error response failed: %v", err)
+		res.WriteHeader(code)
+		return
+	}
+	res.Header().Set("Content-Type", contentType)
+	res.WriteHeader(code)
+	if _, err := res.Write(out); err != nil {
+		s.log.Debugf("Writing response failed: %v", err)
+	}
+}
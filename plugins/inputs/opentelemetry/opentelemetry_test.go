@@ -1,8 +1,12 @@
 package opentelemetry
 
 import (
+	"bytes"
+	"compress/gzip"
 	"context"
+	"io"
 	"net"
+	"net/http"
 	"os"
 	"path/filepath"
 	"runtime"
@@ -14,6 +18,9 @@ import (
 	"github.com/google/go-cmp/cmp"
 	"github.com/influxdata/influxdb-observability/otel2influx"
 	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.opentelemetry.io/collector/pdata/pmetric/pmetricotlp"
 	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetricgrpc"
 	"go.opentelemetry.io/otel/sdk/metric"
 	"go.opentelemetry.io/otel/sdk/metric/metricdata"
@@ -21,9 +28,11 @@ import (
 	otlpmetrics "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
 	otlpprofiles "go.opentelemetry.io/proto/otlp/collector/profiles/v1experimental"
 	otlptrace "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	spb "google.golang.org/genproto/googleapis/rpc/status"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials/insecure"
 	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
 
 	"github.com/influxdata/telegraf"
 	"github.com/influxdata/telegraf/config"
@@ -203,6 +212,10 @@ func TestCases(t *testing.T) {
 			require.NoError(t, err)
 			defer grpcClient.Close()
 			for msgtype, messages := range inputs {
+				if plugin.httpListener != nil {
+					sendHTTP(t, plugin.httpListener.Addr().String(), msgtype, messages)
+					continue
+				}
 				switch msgtype {
 				case "logs":
 					client := otlplogs.NewLogsServiceClient(grpcClient)
@@ -254,3 +267,294 @@ func TestCases(t *testing.T) {
 		})
 	}
 }
+
+func sendHTTP(t *testing.T, addr, msgtype string, messages [][]byte) {
+	t.Helper()
+
+	for _, buf := range messages {
+		resp, err := http.Post("http://"+addr+"/v1/"+msgtype, "application/json", bytes.NewReader(buf))
+		require.NoError(t, err)
+		resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+	}
+}
+
+func TestOpenTelemetryHTTP(t *testing.T) {
+	// Setup the metrics to send
+	ts := time.Unix(1729152000, 0)
+	md := pmetric.NewMetrics()
+	rm := md.ResourceMetrics().AppendEmpty()
+	rm.Resource().Attributes().PutStr("service.name", "checkout")
+	m := rm.ScopeMetrics().AppendEmpty().Metrics().AppendEmpty()
+	m.SetName("queue_length")
+	dp := m.SetEmptyGauge().DataPoints().AppendEmpty()
+	dp.Attributes().PutStr("queue", "orders")
+	dp.SetTimestamp(pcommon.NewTimestampFromTime(ts))
+	dp.SetIntValue(12)
+	request := pmetricotlp.NewExportRequestFromMetrics(md)
+
+	protobuf, err := request.MarshalProto()
+	require.NoError(t, err)
+	jsonBody, err := request.MarshalJSON()
+	require.NoError(t, err)
+
+	expected := []telegraf.Metric{
+		testutil.MustMetric(
+			"queue_length",
+			map[string]string{
+				"queue":        "orders",
+				"service.name": "checkout",
+			},
+			map[string]interface{}{
+				"gauge": int64(12),
+			},
+			ts,
+			telegraf.Gauge,
+		),
+	}
+
+	tests := []struct {
+		name        string
+		contentType string
+		encoding    string
+		body        []byte
+	}{
+		{
+			name:        "protobuf",
+			contentType: "application/x-protobuf",
+			body:        protobuf,
+		},
+		{
+			name:        "json",
+			contentType: "application/json",
+			body:        jsonBody,
+		},
+		{
+			name:        "protobuf gzip",
+			contentType: "application/x-protobuf",
+			encoding:    "gzip",
+			body:        protobuf,
+		},
+		{
+			name:        "json gzip",
+			contentType: "application/json; charset=utf-8",
+			encoding:    "gzip",
+			body:        jsonBody,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plugin := &OpenTelemetry{
+				ServiceAddress:     "127.0.0.1:0",
+				HTTPServiceAddress: "127.0.0.1:0",
+				Log:                testutil.Logger{},
+			}
+			require.NoError(t, plugin.Init())
+
+			var acc testutil.Accumulator
+			require.NoError(t, plugin.Start(&acc))
+			defer plugin.Stop()
+
+			body := tt.body
+			if tt.encoding == "gzip" {
+				var buf bytes.Buffer
+				w := gzip.NewWriter(&buf)
+				_, err := w.Write(body)
+				require.NoError(t, err)
+				require.NoError(t, w.Close())
+				body = buf.Bytes()
+			}
+
+			addr := "http://" + plugin.httpListener.Addr().String() + "/v1/metrics"
+			req, err := http.NewRequest("POST", addr, bytes.NewReader(body))
+			require.NoError(t, err)
+			req.Header.Set("Content-Type", tt.contentType)
+			req.Header.Set("Content-Encoding", tt.encoding)
+			resp, err := http.DefaultClient.Do(req)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+			require.Equal(t, http.StatusOK, resp.StatusCode)
+
+			// The response must use the encoding of the request
+			buf, err := io.ReadAll(resp.Body)
+			require.NoError(t, err)
+			response := pmetricotlp.NewExportResponse()
+			if strings.HasPrefix(tt.contentType, "application/json") {
+				require.Equal(t, "application/json", resp.Header.Get("Content-Type"))
+				require.NoError(t, response.UnmarshalJSON(buf))
+			} else {
+				require.Equal(t, "application/x-protobuf", resp.Header.Get("Content-Type"))
+				require.NoError(t, response.UnmarshalProto(buf))
+			}
+
+			require.Empty(t, acc.Errors)
+			testutil.RequireMetricsEqual(t, expected, acc.GetTelegrafMetrics())
+		})
+	}
+}
+
+func TestOpenTelemetryHTTPTLS(t *testing.T) {
+	pki := testutil.NewPKI("../../../testutil/pki")
+	plugin := &OpenTelemetry{
+		ServiceAddress:     "127.0.0.1:0",
+		HTTPServiceAddress: "127.0.0.1:0",
+		ServerConfig:       *pki.TLSServerConfig(),
+		Log:                testutil.Logger{},
+	}
+	require.NoError(t, plugin.Init())
+
+	var acc testutil.Accumulator
+	require.NoError(t, plugin.Start(&acc))
+	defer plugin.Stop()
+
+	tlsConfig, err := pki.TLSClientConfig().TLSConfig()
+	require.NoError(t, err)
+	client := &http.Client{
+		Transport: &http.Transport{
+			TLSClientConfig: tlsConfig,
+		},
+	}
+
+	body := `{"resourceLogs":[{"scopeLogs":[{"logRecords":[{"timeUnixNano":"1729152000000000000","body":{"stringValue":"hello"}}]}]}]}`
+	addr := "https://" + plugin.httpListener.Addr().String() + "/v1/logs"
+	resp, err := client.Post(addr, "application/json", strings.NewReader(body))
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	// Plain HTTP must not be accepted
+	resp, err = http.Post("http://"+plugin.httpListener.Addr().String()+"/v1/logs", "application/json", strings.NewReader(body))
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	expected := []telegraf.Metric{
+		testutil.MustMetric(
+			"logs",
+			map[string]string{},
+			map[string]interface{}{"body": "hello"},
+			time.Unix(1729152000, 0),
+		),
+	}
+	require.Empty(t, acc.Errors)
+	testutil.RequireMetricsEqual(t, expected, acc.GetTelegrafMetrics())
+}
+
+func TestOpenTelemetryHTTPFail(t *testing.T) {
+	tests := []struct {
+		name        string
+		method      string
+		path        string
+		contentType string
+		encoding    string
+		body        string
+		expected    int
+	}{
+		{
+			name:        "unknown path",
+			method:      "POST",
+			path:        "/v1/foo",
+			contentType: "application/json",
+			body:        "{}",
+			expected:    http.StatusNotFound,
+		},
+		{
+			name:        "invalid method",
+			method:      "GET",
+			path:        "/v1/metrics",
+			contentType: "application/json",
+			expected:    http.StatusMethodNotAllowed,
+		},
+		{
+			name:        "unsupported content type",
+			method:      "POST",
+			path:        "/v1/metrics",
+			contentType: "text/plain",
+			body:        "{}",
+			expected:    http.StatusUnsupportedMediaType,
+		},
+		{
+			name:        "unsupported encoding",
+			method:      "POST",
+			path:        "/v1/metrics",
+			contentType: "application/json",
+			encoding:    "br",
+			body:        "{}",
+			expected:    http.StatusBadRequest,
+		},
+		{
+			name:        "invalid gzip",
+			method:      "POST",
+			path:        "/v1/metrics",
+			contentType: "application/json",
+			encoding:    "gzip",
+			body:        "{}",
+			expected:    http.StatusBadRequest,
+		},
+		{
+			name:        "invalid json",
+			method:      "POST",
+			path:        "/v1/traces",
+			contentType: "application/json",
+			body:        `{"resourceSpans": 1`,
+			expected:    http.StatusBadRequest,
+		},
+		{
+			name:        "invalid protobuf",
+			method:      "POST",
+			path:        "/v1/logs",
+			contentType: "application/x-protobuf",
+			body:        "\xff\xff\xff",
+			expected:    http.StatusBadRequest,
+		},
+		{
+			name:        "too large",
+			method:      "POST",
+			path:        "/v1/logs",
+			contentType: "application/json",
+			body:        `{"resourceLogs":[{"scopeLogs":[{"logRecords":[{"body":{"stringValue":"` + strings.Repeat("a", 1024) + `"}}]}]}]}`,
+			expected:    http.StatusRequestEntityTooLarge,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plugin := &OpenTelemetry{
+				ServiceAddress:     "127.0.0.1:0",
+				HTTPServiceAddress: "127.0.0.1:0",
+				MaxMsgSize:         config.Size(1024),
+				Log:                testutil.Logger{},
+			}
+			require.NoError(t, plugin.Init())
+
+			var acc testutil.Accumulator
+			require.NoError(t, plugin.Start(&acc))
+			defer plugin.Stop()
+
+			addr := "http://" + plugin.httpListener.Addr().String() + tt.path
+			req, err := http.NewRequest(tt.method, addr, strings.NewReader(tt.body))
+			require.NoError(t, err)
+			req.Header.Set("Content-Type", tt.contentType)
+			req.Header.Set("Content-Encoding", tt.encoding)
+			resp, err := http.DefaultClient.Do(req)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+			require.Equal(t, tt.expected, resp.StatusCode)
+
+			// Errors of the OTLP endpoints must contain a status message
+			if tt.expected != http.StatusNotFound && tt.expected != http.StatusMethodNotAllowed {
+				buf, err := io.ReadAll(resp.Body)
+				require.NoError(t, err)
+				var st spb.Status
+				if resp.Header.Get("Content-Type") == "application/x-protobuf" {
+					require.NoError(t, proto.Unmarshal(buf, &st))
+				} else {
+					require.NoError(t, protojson.Unmarshal(buf, &st))
+				}
+				require.NotEmpty(t, st.Message)
+			}
+			require.Empty(t, acc.GetTelegrafMetrics())
+		})
+	}
+}
@@ -0,0 +1,203 @@
+package sql
+
+import (
+	"cmp"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/influxdata/telegraf/internal"
+)
+
+// Layouts tried in order for converting text column values to time cursors,
+// e.g. for MySQL returning DATETIME columns as text
+var cursorTimeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02 15:04:05.999999999Z07:00",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02T15:04:05.999999999",
+	time.DateOnly,
+}
+
+// cursor keeps the latest value of the cursor column of a query to be bound
+// as parameter in the next execution of the query
+type cursor struct {
+	value interface{}
+	sync.Mutex
+}
+
+// cursorState is the serialized value of a cursor, keeping the value type
+// across restarts
+type cursorState struct {
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+func (c *cursor) get() interface{} {
+	c.Lock()
+	defer c.Unlock()
+	return c.value
+}
+
+func (c *cursor) set(v interface{}) {
+	c.Lock()
+	c.value = v
+	c.Unlock()
+}
+
+func (c *cursor) state() cursorState {
+	c.Lock()
+	defer c.Unlock()
+
+	switch v := c.value.(type) {
+	case int64:
+		return cursorState{Type: "int", Value: strconv.FormatInt(v, 10)}
+	case uint64:
+		return cursorState{Type: "uint", Value: strconv.FormatUint(v, 10)}
+	case float64:
+		return cursorState{Type: "float", Value: strconv.FormatFloat(v, 'g', -1, 64)}
+	case time.Time:
+		return cursorState{Type: "time", Value: v.Format(time.RFC3339Nano)}
+	case string:
+		return cursorState{Type: "string", Value: v}
+	}
+	return cursorState{}
+}
+
+func (c *cursor) restore(s cursorState) error {
+	var v interface{}
+	var err error
+	switch s.Type {
+	case "int":
+		v, err = strconv.ParseInt(s.Value, 10, 64)
+	case "uint":
+		v, err = strconv.ParseUint(s.Value, 10, 64)
+	case "float":
+		v, err = strconv.ParseFloat(s.Value, 64)
+	case "time":
+		v, err = time.Parse(time.RFC3339Nano, s.Value)
+	case "string":
+		v = s.Value
+	default:
+		return fmt.Errorf("unknown cursor type %q", s.Type)
+	}
+	if err != nil {
+		return fmt.Errorf("parsing cursor value %q failed: %w", s.Value, err)
+	}
+	c.set(v)
+	return nil
+}
+
+// normalizeCursor converts the value of a cursor column or the initial cursor
+// value to one of the types supported for cursors
+func normalizeCursor(raw interface{}) (interface{}, error) {
+	switch v := raw.(type) {
+	case int:
+		return int64(v), nil
+	case int8:
+		return int64(v), nil
+	case int16:
+		return int64(v), nil
+	case int32:
+		return int64(v), nil
+	case int64:
+		return v, nil
+	case uint:
+		return uint64(v), nil
+	case uint8:
+		return uint64(v), nil
+	case uint16:
+		return uint64(v), nil
+	case uint32:
+		return uint64(v), nil
+	case uint64:
+		return v, nil
+	case float32:
+		return float64(v), nil
+	case float64:
+		return v, nil
+	case time.Time:
+		return v, nil
+	case string:
+		return v, nil
+	case []byte:
+		return string(v), nil
+	case fmt.Stringer:
+		return v.String(), nil
+	}
+	return nil, fmt.Errorf("type \"%T\" unsupported", raw)
+}
+
+// convertCursor converts the value of a cursor column to the type of the
+// normalized reference value, usually the initial cursor value. This is
+// required as drivers might return the column e.g. as text.
+func convertCursor(raw, reference interface{}) (interface{}, error) {
+	switch reference.(type) {
+	case int64:
+		return internal.ToInt64(raw)
+	case uint64:
+		return internal.ToUint64(raw)
+	case float64:
+		return internal.ToFloat64(raw)
+	case time.Time:
+		if v, ok := raw.(time.Time); ok {
+			return v, nil
+		}
+		s, err := internal.ToString(raw)
+		if err != nil {
+			return nil, err
+		}
+		for _, layout := range cursorTimeLayouts {
+			if v, err := time.Parse(layout, s); err == nil {
+				return v, nil
+			}
+		}
+		return nil, fmt.Errorf("cannot parse %q as time", s)
+	case string:
+		return internal.ToString(raw)
+	}
+	return normalizeCursor(raw)
+}
+
+// laterCursor returns the later one of the two normalized cursor values. In
+// case of mismatching types the candidate is assumed to be later.
+func laterCursor(current, candidate interface{}) interface{} {
+	var c int
+	switch v := candidate.(type) {
+	case int64:
+		cur, ok := current.(int64)
+		if !ok {
+			return candidate
+		}
+		c = cmp.Compare(v, cur)
+	case uint64:
+		cur, ok := current.(uint64)
+		if !ok {
+			return candidate
+		}
+		c = cmp.Compare(v, cur)
+	case float64:
+		cur, ok := current.(float64)
+		if !ok {
+			return candidate
+		}
+		c = cmp.Compare(v, cur)
+	case time.Time:
+		cur, ok := current.(time.Time)
+		if !ok {
+			return candidate
+		}
+		c = v.Compare(cur)
+	case string:
+		cur, ok := current.(string)
+		if !ok {
+			return candidate
+		}
+		c = cmp.Compare(v, cur)
+	}
+	if c > 0 {
+		return candidate
+	}
+	return current
+}
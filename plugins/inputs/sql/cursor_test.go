@@ -0,0 +1,232 @@
+//go:build !mips && !mipsle && !mips64 && !ppc64 && !riscv64 && !loong64 && !mips64le && !(windows && (386 || arm))
+
+package sql
+
+import (
+	dbsql "database/sql"
+	"encoding/json"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/metric"
+	"github.com/influxdata/telegraf/testutil"
+)
+
+func TestCursorInitFail(t *testing.T) {
+	tests := []struct {
+		name     string
+		query    query
+		expected string
+	}{
+		{
+			name:     "missing initial value",
+			query:    query{Query: "SELECT * FROM foo WHERE id > ?", CursorColumn: "id"},
+			expected: "'cursor_initial' required",
+		},
+		{
+			name:     "unsupported initial value",
+			query:    query{Query: "SELECT * FROM foo WHERE id > ?", CursorColumn: "id", CursorInitial: true},
+			expected: "invalid 'cursor_initial' setting",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plugin := &SQL{
+				Driver:  "sqlite",
+				Dsn:     config.NewSecret([]byte("file::memory:")),
+				Queries: []query{tt.query},
+				Log:     testutil.Logger{},
+			}
+			require.ErrorContains(t, plugin.Init(), tt.expected)
+		})
+	}
+}
+
+func TestCursorStateRoundtrip(t *testing.T) {
+	values := []interface{}{
+		int64(-42),
+		uint64(18446744073709551615),
+		float64(3.14),
+		"2024-01-01 12:00:00",
+		time.Date(2024, 1, 1, 12, 0, 0, 123456789, time.UTC),
+	}
+	for _, v := range values {
+		c := &cursor{value: v}
+		buf, err := json.Marshal(c.state())
+		require.NoError(t, err)
+
+		var s cursorState
+		require.NoError(t, json.Unmarshal(buf, &s))
+		restored := &cursor{}
+		require.NoError(t, restored.restore(s))
+		require.Equal(t, v, restored.get())
+	}
+
+	c := &cursor{}
+	require.ErrorContains(t, c.restore(cursorState{Type: "foo"}), "unknown cursor type")
+	require.ErrorContains(t, c.restore(cursorState{Type: "int", Value: "foo"}), "parsing cursor value")
+}
+
+func TestCursorConvert(t *testing.T) {
+	tests := []struct {
+		name      string
+		raw       interface{}
+		reference interface{}
+		expected  interface{}
+	}{
+		{
+			name:      "int from text",
+			raw:       []byte("42"),
+			reference: int64(0),
+			expected:  int64(42),
+		},
+		{
+			name:      "int from uint",
+			raw:       uint32(42),
+			reference: int64(0),
+			expected:  int64(42),
+		},
+		{
+			name:      "uint from text",
+			raw:       []byte("18446744073709551615"),
+			reference: uint64(0),
+			expected:  uint64(18446744073709551615),
+		},
+		{
+			name:      "float from text",
+			raw:       []byte("3.14"),
+			reference: float64(0),
+			expected:  float64(3.14),
+		},
+		{
+			name:      "float from int",
+			raw:       int64(3),
+			reference: float64(0),
+			expected:  float64(3),
+		},
+		{
+			name:      "time from text",
+			raw:       []byte("2024-01-01 12:00:00.123456"),
+			reference: time.Time{},
+			expected:  time.Date(2024, 1, 1, 12, 0, 0, 123456000, time.UTC),
+		},
+		{
+			name:      "time from RFC3339 text",
+			raw:       "2024-01-01T12:00:00+02:00",
+			reference: time.Time{},
+			expected:  time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC),
+		},
+		{
+			name:      "time",
+			raw:       time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC),
+			reference: time.Time{},
+			expected:  time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC),
+		},
+		{
+			name:      "string from int",
+			raw:       int64(42),
+			reference: "",
+			expected:  "42",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			actual, err := convertCursor(tt.raw, tt.reference)
+			require.NoError(t, err)
+			if expected, ok := tt.expected.(time.Time); ok {
+				require.True(t, expected.Equal(actual.(time.Time)), "expected %v but got %v", expected, actual)
+				return
+			}
+			require.Equal(t, tt.expected, actual)
+		})
+	}
+
+	_, err := convertCursor([]byte("foo"), int64(0))
+	require.Error(t, err)
+	_, err = convertCursor([]byte("foo"), time.Time{})
+	require.ErrorContains(t, err, "cannot parse")
+}
+
+func TestCursorSQLite(t *testing.T) {
+	dsn := filepath.Join(t.TempDir(), "events.db")
+	db, err := dbsql.Open("sqlite", dsn)
+	require.NoError(t, err)
+	defer db.Close()
+
+	_, err = db.Exec("CREATE TABLE events (id INTEGER PRIMARY KEY, name TEXT, value INTEGER)")
+	require.NoError(t, err)
+	insert := func(ids ...int) {
+		for _, id := range ids {
+			_, err := db.Exec("INSERT INTO events (id, name, value) VALUES (?, ?, ?)", id, "login", 10*id)
+			require.NoError(t, err)
+		}
+	}
+	expected := func(ids ...int) []telegraf.Metric {
+		metrics := make([]telegraf.Metric, 0, len(ids))
+		for _, id := range ids {
+			metrics = append(metrics, metric.New(
+				"sql",
+				map[string]string{"name": "login"},
+				map[string]interface{}{"id": int64(id), "value": int64(10 * id)},
+				time.Unix(0, 0),
+			))
+		}
+		return metrics
+	}
+
+	newPlugin := func() *SQL {
+		plugin := &SQL{
+			Driver: "sqlite",
+			Dsn:    config.NewSecret([]byte(dsn)),
+			Queries: []query{
+				{
+					Query:               "SELECT id, name, value FROM events WHERE id > ? ORDER BY id",
+					TagColumnsInclude:   []string{"name"},
+					FieldColumnsExclude: []string{"name"},
+					CursorColumn:        "id",
+					CursorInitial:       int64(1),
+				},
+			},
+			Log: testutil.Logger{},
+		}
+		require.NoError(t, plugin.Init())
+		return plugin
+	}
+
+	plugin := newPlugin()
+	var acc testutil.Accumulator
+	require.NoError(t, plugin.Start(&acc))
+
+	// The initial cursor value is used in the first query
+	insert(1, 2, 3)
+	require.NoError(t, plugin.Gather(&acc))
+	testutil.RequireMetricsEqual(t, expected(2, 3), acc.GetTelegrafMetrics(), testutil.IgnoreTime())
+
+	// No new rows
+	acc.ClearMetrics()
+	require.NoError(t, plugin.Gather(&acc))
+	require.Empty(t, acc.GetTelegrafMetrics())
+
+	// Only new rows are returned
+	insert(4, 5)
+	require.NoError(t, plugin.Gather(&acc))
+	testutil.RequireMetricsEqual(t, expected(4, 5), acc.GetTelegrafMetrics(), testutil.IgnoreTime())
+	require.Equal(t, map[string]cursorState{plugin.Queries[0].Query: {Type: "int", Value: "5"}}, plugin.GetState())
+	plugin.Stop()
+
+	// Resume at the persisted cursor after restart
+	restarted := newPlugin()
+	require.NoError(t, restarted.SetState(plugin.GetState()))
+	require.NoError(t, restarted.Start(&acc))
+	defer restarted.Stop()
+
+	acc.ClearMetrics()
+	insert(6)
+	require.NoError(t, restarted.Gather(&acc))
+	testutil.RequireMetricsEqual(t, expected(6), acc.GetTelegrafMetrics(), testutil.IgnoreTime())
+}
@@ -62,6 +62,10 @@ type query struct {
 	FieldColumnsBool    []string `toml:"field_columns_bool"`
 	FieldColumnsString  []string `toml:"field_columns_string"`
 
+	CursorColumn  string      `toml:"cursor_column"`
+	CursorInitial interface{} `toml:"cursor_initial"`
+
+	cursor            *cursor
 	statement         *dbsql.Stmt
 	tagFilter         filter.Filter
 	fieldFilter       filter.Filter
@@ -166,6 +170,18 @@ func (s *SQL) Init() error {
 		if q.Measurement == "" {
 			s.Queries[i].Measurement = "sql"
 		}
+
+		// Setup the cursor for incremental queries
+		if q.CursorColumn != "" {
+			if q.CursorInitial == nil {
+				return fmt.Errorf("'cursor_initial' required for cursor column %q", q.CursorColumn)
+			}
+			initial, err := normalizeCursor(q.CursorInitial)
+			if err != nil {
+				return fmt.Errorf("invalid 'cursor_initial' setting: %w", err)
+			}
+			s.Queries[i].cursor = &cursor{value: initial}
+		}
 	}
 
 	// Derive the sql-framework driver name from our config name. This abstracts the actual driver
@@ -264,6 +280,37 @@ func (s *SQL) Gather(acc telegraf.Accumulator) error {
 	return nil
 }
 
+func (s *SQL) GetState() interface{} {
+	state := make(map[string]cursorState)
+	for _, q := range s.Queries {
+		if q.cursor != nil {
+			state[q.Query] = q.cursor.state()
+		}
+	}
+	return state
+}
+
+func (s *SQL) SetState(state interface{}) error {
+	cursors, ok := state.(map[string]cursorState)
+	if !ok {
+		return fmt.Errorf("state has wrong type %T", state)
+	}
+
+	// Cursors are identified by the query, so changing the query starts
+	// over at the initial cursor value
+	for _, q := range s.Queries {
+		if q.cursor == nil {
+			continue
+		}
+		if c, found := cursors[q.Query]; found {
+			if err := q.cursor.restore(c); err != nil {
+				return fmt.Errorf("restoring cursor of query %q failed: %w", q.Query, err)
+			}
+		}
+	}
+	return nil
+}
+
 func (s *SQL) Stop() {
 	// Free the statements
 	for _, q := range s.Queries {
@@ -340,19 +387,25 @@ func (s *SQL) prepareStatements() {
 }
 
 func (s *SQL) executeQuery(ctx context.Context, acc telegraf.Accumulator, q query, tquery time.Time) error {
+	// Bind the current cursor value for incremental queries
+	var args []interface{}
+	if q.cursor != nil {
+		args = append(args, q.cursor.get())
+	}
+
 	// Execute the query either prepared or unprepared
 	var rows *dbsql.Rows
 	if q.statement != nil {
 		// Use the previously prepared query
 		var err error
-		rows, err = q.statement.QueryContext(ctx)
+		rows, err = q.statement.QueryContext(ctx, args...)
 		if err != nil {
 			return err
 		}
 	} else {
 		// Fallback to unprepared query
 		var err error
-		rows, err = s.db.Query(q.Query)
+		rows, err = s.db.Query(q.Query, args...)
 		if err != nil {
 			return err
 		}
@@ -391,6 +444,11 @@ func (q *query) parse(acc telegraf.Accumulator, rows *dbsql.Rows, t time.Time, l
 		columnDataPtr[i] = &columnData[i]
 	}
 
+	// Cursor column values are converted to the type of the current cursor
+	var latest, reference interface{}
+	if q.cursor != nil {
+		reference = q.cursor.get()
+	}
 	rowCount := 0
 	for rows.Next() {
 		measurement := q.Measurement
@@ -404,6 +462,18 @@ func (q *query) parse(acc telegraf.Accumulator, rows *dbsql.Rows, t time.Time, l
 		}
 
 		for i, name := range columnNames {
+			if q.cursor != nil && name == q.CursorColumn && columnData[i] != nil {
+				v, err := convertCursor(columnData[i], reference)
+				if err != nil {
+					return 0, fmt.Errorf("cursor column %q: %w", name, err)
+				}
+				if latest == nil {
+					latest = v
+				} else {
+					latest = laterCursor(latest, v)
+				}
+			}
+
 			if q.MeasurementColumn != "" && name == q.MeasurementColumn {
 				switch raw := columnData[i].(type) {
 				case string:
@@ -533,6 +603,12 @@ func (q *query) parse(acc telegraf.Accumulator, rows *dbsql.Rows, t time.Time, l
 		return rowCount, err
 	}
 
+	// Only advance the cursor if all rows were processed to not miss any
+	// rows in case of errors
+	if q.cursor != nil && latest != nil {
+		q.cursor.set(latest)
+	}
+
 	return rowCount, nil
 }
 
@@ -51,6 +51,7 @@ type Tail struct {
 	Log        telegraf.Logger `toml:"-"`
 	tailers    map[string]*tail.Tail
 	offsets    map[string]int64
+	mu         sync.Mutex // protects tailers and offsets
 	parserFunc telegraf.ParserFunc
 	wg         sync.WaitGroup
 
@@ -128,7 +129,9 @@ func (t *Tail) Start(acc telegraf.Accumulator) error {
 		return err
 	}
 
+	t.mu.Lock()
 	t.tailers = make(map[string]*tail.Tail)
+	t.mu.Unlock()
 
 	err = t.tailNewFiles()
 	if err != nil {
@@ -169,7 +172,23 @@ func (t *Tail) getSeekInfo(file string) (*tail.SeekInfo, error) {
 }
 
 func (t *Tail) GetState() interface{} {
-	return t.offsets
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	// Use the current offsets of the running tailers to allow checkpointing
+	// the state while running
+	state := make(map[string]int64, len(t.offsets)+len(t.tailers))
+	for k, v := range t.offsets {
+		state[k] = v
+	}
+	if !t.Pipe {
+		for _, tailer := range t.tailers {
+			if offset, err := tailer.Tell(); err == nil {
+				state[tailer.Filename] = offset
+			}
+		}
+	}
+	return state
 }
 
 func (t *Tail) SetState(state interface{}) error {
@@ -177,6 +196,9 @@ func (t *Tail) SetState(state interface{}) error {
 	if !ok {
 		return errors.New("state has to be of type 'map[string]int64'")
 	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
 	for k, v := range offsetsState {
 		t.offsets[k] = v
 	}
@@ -188,6 +210,7 @@ func (t *Tail) Gather(_ telegraf.Accumulator) error {
 }
 
 func (t *Tail) Stop() {
+	t.mu.Lock()
 	for _, tailer := range t.tailers {
 		if !t.Pipe {
 			// store offset for resume
@@ -204,6 +227,8 @@ func (t *Tail) Stop() {
 			t.Log.Errorf("Stopping tail on %q: %s", tailer.Filename, err.Error())
 		}
 	}
+	t.tailers = make(map[string]*tail.Tail)
+	t.mu.Unlock()
 
 	t.cancel()
 	t.wg.Wait()
@@ -229,7 +254,10 @@ func (t *Tail) tailNewFiles() error {
 			t.Log.Errorf("Glob %q failed to compile: %s", filepath, err.Error())
 		}
 		for _, file := range g.Match() {
-			if _, ok := t.tailers[file]; ok {
+			t.mu.Lock()
+			_, ok := t.tailers[file]
+			t.mu.Unlock()
+			if ok {
 				// we're already tailing this file
 				continue
 			}
@@ -279,14 +307,18 @@ func (t *Tail) tailNewFiles() error {
 				if err := tailer.Err(); err != nil {
 					if strings.HasSuffix(err.Error(), "permission denied") {
 						t.Log.Errorf("Deleting tailer for %q due to: %v", tailer.Filename, err)
+						t.mu.Lock()
 						delete(t.tailers, tailer.Filename)
+						t.mu.Unlock()
 					} else {
 						t.Log.Errorf("Tailing %q: %s", tailer.Filename, err.Error())
 					}
 				}
 			}()
 
+			t.mu.Lock()
 			t.tailers[tailer.Filename] = tailer
+			t.mu.Unlock()
 		}
 	}
 	return nil
@@ -12,6 +12,7 @@ import (
 	"fmt"
 	"reflect"
 	"strings"
+	"sync"
 	"syscall"
 	"time"
 
@@ -47,6 +48,7 @@ type WinEventLog struct {
 	subscription     evtHandle
 	subscriptionFlag evtSubscribeFlag
 	bookmark         evtHandle
+	mu               sync.Mutex // protects the bookmark from concurrent checkpointing
 	tagFilter        filter.Filter
 	fieldFilter      filter.Filter
 	fieldEmptyFilter filter.Filter
@@ -104,6 +106,9 @@ func (w *WinEventLog) Start(telegraf.Accumulator) error {
 }
 
 func (w *WinEventLog) GetState() interface{} {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
 	bookmarkXML, err := renderBookmark(w.bookmark)
 	if err != nil {
 		w.Log.Errorf("State-persistence failed, cannot render bookmark: %v", err)
@@ -127,6 +132,9 @@ func (w *WinEventLog) SetState(state interface{}) error {
 	if err != nil {
 		return fmt.Errorf("creating bookmark failed: %w", err)
 	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
 	w.bookmark = bookmark
 	w.subscriptionFlag = evtSubscribeStartAfterBookmark
 
@@ -324,9 +332,11 @@ func (w *WinEventLog) evtSubscribe() (evtHandle, error) {
 	}
 
 	var bookmark evtHandle
+	w.mu.Lock()
 	if w.subscriptionFlag == evtSubscribeStartAfterBookmark {
 		bookmark = w.bookmark
 	}
+	w.mu.Unlock()
 	subsHandle, err := evtSubscribe(0, uintptr(sigEvent), logNamePtr, xqueryPtr, bookmark, 0, 0, w.subscriptionFlag)
 	if err != nil {
 		return 0, err
@@ -365,7 +375,10 @@ func (w *WinEventLog) fetchEvents(subsHandle evtHandle) ([]event, error) {
 		if event, err := w.renderEvent(eventHandle); err == nil {
 			events = append(events, event)
 		}
-		if err := evtUpdateBookmark(w.bookmark, eventHandle); err != nil && evterr == nil {
+		w.mu.Lock()
+		err := evtUpdateBookmark(w.bookmark, eventHandle)
+		w.mu.Unlock()
+		if err != nil && evterr == nil {
 			evterr = err
 		}
 
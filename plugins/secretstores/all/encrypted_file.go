@@ -0,0 +1,5 @@
+//go:build !custom || secretstores || secretstores.encrypted_file
+
+package all
+
+import _ "github.com/influxdata/telegraf/plugins/secretstores/encrypted_file" // register plugin
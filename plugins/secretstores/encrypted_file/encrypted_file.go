@@ -0,0 +1,319 @@
+//go:generate ../../../tools/readme_config_includer/generator
+package encrypted_file
+
+import (
+	"bytes"
+	_ "embed"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"filippo.io/age"
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"gopkg.in/yaml.v2"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/internal/orderedjson"
+	"github.com/influxdata/telegraf/plugins/secretstores"
+)
+
+//go:embed sample.conf
+var sampleConfig string
+
+type EncryptedFile struct {
+	Path          string          `toml:"path"`
+	Encryption    string          `toml:"encryption"`
+	FileFormat    string          `toml:"file_format"`
+	AgeKey        config.Secret   `toml:"age_key"`
+	AgeKeyFile    string          `toml:"age_key_file"`
+	AgeRecipients []string        `toml:"age_recipients"`
+	PGPKey        config.Secret   `toml:"pgp_key"`
+	PGPKeyFile    string          `toml:"pgp_key_file"`
+	PGPPassphrase config.Secret   `toml:"pgp_passphrase"`
+	Dynamic       bool            `toml:"dynamic"`
+	Log           telegraf.Logger `toml:"-"`
+
+	ageIdentities []age.Identity
+	ageRecipients []age.Recipient
+	pgpKeyring    openpgp.EntityList
+	pgpRecipients openpgp.EntityList
+
+	// Decrypted content of the file and the modification time of the file
+	// when reading it
+	data    yaml.MapSlice
+	sops    *sopsState
+	modTime time.Time
+	loaded  bool
+	sync.Mutex
+}
+
+func (*EncryptedFile) SampleConfig() string {
+	return sampleConfig
+}
+
+// Init initializes all internals of the secret-store
+func (e *EncryptedFile) Init() error {
+	defer e.PGPPassphrase.Destroy()
+
+	if e.Path == "" {
+		return errors.New("'path' required")
+	}
+
+	switch e.Encryption {
+	case "":
+		e.Encryption = "sops"
+	case "sops", "age":
+	default:
+		return fmt.Errorf("invalid 'encryption' %q", e.Encryption)
+	}
+
+	switch e.FileFormat {
+	case "":
+		name := strings.TrimSuffix(strings.ToLower(e.Path), ".age")
+		if filepath.Ext(name) == ".json" {
+			e.FileFormat = "json"
+		} else {
+			e.FileFormat = "yaml"
+		}
+	case "json", "yaml":
+	default:
+		return fmt.Errorf("invalid 'file_format' %q", e.FileFormat)
+	}
+
+	if err := e.setupAge(); err != nil {
+		return err
+	}
+	if e.Encryption == "age" && len(e.ageIdentities) == 0 {
+		return errors.New("age key required")
+	}
+	if e.Encryption == "sops" {
+		if err := e.setupPGP(); err != nil {
+			return err
+		}
+		if len(e.ageIdentities) == 0 && len(e.pgpKeyring) == 0 {
+			return errors.New("age or PGP key required")
+		}
+	}
+
+	return nil
+}
+
+// Get searches for the given key and return the secret
+func (e *EncryptedFile) Get(key string) ([]byte, error) {
+	e.Lock()
+	defer e.Unlock()
+
+	if err := e.load(); err != nil {
+		return nil, err
+	}
+	for _, item := range e.data {
+		if item.Key != key {
+			continue
+		}
+		if v, ok := secretValue(item.Value); ok {
+			return []byte(v), nil
+		}
+		return nil, fmt.Errorf("value of %q is not a scalar", key)
+	}
+	return nil, errors.New("not found")
+}
+
+// Set sets the given secret for the given key
+func (e *EncryptedFile) Set(key, value string) error {
+	e.Lock()
+	defer e.Unlock()
+
+	if err := e.load(); err != nil {
+		return err
+	}
+
+	data := make(yaml.MapSlice, len(e.data))
+	copy(data, e.data)
+	data = setItem(data, key, value)
+	if err := e.store(data); err != nil {
+		return err
+	}
+	e.data = data
+
+	return nil
+}
+
+// List lists all known secret keys
+func (e *EncryptedFile) List() ([]string, error) {
+	e.Lock()
+	defer e.Unlock()
+
+	if err := e.load(); err != nil {
+		return nil, err
+	}
+	keys := make([]string, 0, len(e.data))
+	for _, item := range e.data {
+		if _, ok := secretValue(item.Value); ok {
+			keys = append(keys, item.Key.(string))
+		}
+	}
+	return keys, nil
+}
+
+// GetResolver returns a function to resolve the given key.
+func (e *EncryptedFile) GetResolver(key string) (telegraf.ResolveFunc, error) {
+	resolver := func() ([]byte, bool, error) {
+		s, err := e.Get(key)
+		return s, e.Dynamic, err
+	}
+	return resolver, nil
+}
+
+// load reads and decrypts the file if it was not read yet or was modified
+// since. A missing file is treated as empty to allow creating the file via
+// setting secrets. The caller must hold the lock.
+func (e *EncryptedFile) load() error {
+	stat, err := os.Stat(e.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		e.data, e.sops, e.modTime, e.loaded = nil, nil, time.Time{}, true
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("accessing file failed: %w", err)
+	}
+	if e.loaded && stat.ModTime().Equal(e.modTime) {
+		return nil
+	}
+
+	content, err := os.ReadFile(e.Path)
+	if err != nil {
+		return fmt.Errorf("reading file failed: %w", err)
+	}
+
+	var data yaml.MapSlice
+	var state *sopsState
+	switch e.Encryption {
+	case "age":
+		plaintext, err := decryptAge(content, e.ageIdentities)
+		if err != nil {
+			return fmt.Errorf("decrypting file failed: %w", err)
+		}
+		if err := yaml.Unmarshal(plaintext, &data); err != nil {
+			return fmt.Errorf("decoding file failed: %w", err)
+		}
+	case "sops":
+		var doc yaml.MapSlice
+		if err := yaml.Unmarshal(content, &doc); err != nil {
+			return fmt.Errorf("decoding file failed: %w", err)
+		}
+		data, state, err = e.decryptSOPS(doc)
+		if err != nil {
+			return fmt.Errorf("decrypting file failed: %w", err)
+		}
+	}
+
+	// Only string keys can be used for secrets
+	for _, item := range data {
+		if _, ok := item.Key.(string); !ok {
+			return fmt.Errorf("non-string key %v not supported", item.Key)
+		}
+	}
+
+	e.data, e.sops, e.modTime, e.loaded = data, state, stat.ModTime(), true
+	return nil
+}
+
+// store encrypts and writes the given data to the file. The caller must hold
+// the lock.
+func (e *EncryptedFile) store(data yaml.MapSlice) error {
+	var content []byte
+	switch e.Encryption {
+	case "age":
+		if len(e.ageRecipients) == 0 {
+			return errors.New("no age recipients for encrypting the file")
+		}
+		plaintext, err := encodeDocument(data, e.FileFormat)
+		if err != nil {
+			return err
+		}
+		var buf bytes.Buffer
+		if err := encryptAge(&buf, plaintext, e.ageRecipients...); err != nil {
+			return fmt.Errorf("encrypting file failed: %w", err)
+		}
+		content = buf.Bytes()
+	case "sops":
+		doc, err := e.encryptSOPS(data, e.sops)
+		if err != nil {
+			return fmt.Errorf("encrypting file failed: %w", err)
+		}
+		if content, err = encodeDocument(doc, e.FileFormat); err != nil {
+			return err
+		}
+	}
+
+	// Write the file atomically keeping the permissions of an existing file
+	mode := os.FileMode(0600)
+	if stat, err := os.Stat(e.Path); err == nil {
+		mode = stat.Mode().Perm()
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(e.Path), "."+filepath.Base(e.Path)+".*")
+	if err != nil {
+		return fmt.Errorf("creating temporary file failed: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+		return fmt.Errorf("writing file failed: %w", err)
+	}
+	if err := tmp.Chmod(mode); err != nil {
+		tmp.Close()
+		return fmt.Errorf("setting file permissions failed: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("writing file failed: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), e.Path); err != nil {
+		return fmt.Errorf("replacing file failed: %w", err)
+	}
+
+	// Force reading the file again to get the new state for sops
+	e.loaded = false
+	return e.load()
+}
+
+// secretValue returns the string representation of scalar values
+func secretValue(value interface{}) (string, bool) {
+	switch v := value.(type) {
+	case string:
+		return v, true
+	case int:
+		return strconv.Itoa(v), true
+	case int64:
+		return strconv.FormatInt(v, 10), true
+	case uint64:
+		return strconv.FormatUint(v, 10), true
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), true
+	case bool:
+		return strconv.FormatBool(v), true
+	case time.Time:
+		return v.Format(time.RFC3339Nano), true
+	}
+	return "", false
+}
+
+// encodeDocument serializes the document in the given format
+func encodeDocument(doc yaml.MapSlice, format string) ([]byte, error) {
+	if format == "yaml" {
+		return yaml.Marshal(doc)
+	}
+	return orderedjson.MarshalIndent(doc, "\t")
+}
+
+// Register the secret-store on load.
+func init() {
+	secretstores.Add("encrypted_file", func(string) telegraf.SecretStore {
+		return &EncryptedFile{}
+	})
+}
@@ -0,0 +1,363 @@
+package encrypted_file
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"filippo.io/age"
+	"github.com/stretchr/testify/require"
+
+	"github.com/influxdata/telegraf/config"
+)
+
+func TestSampleConfig(t *testing.T) {
+	plugin := &EncryptedFile{}
+	require.NotEmpty(t, plugin.SampleConfig())
+}
+
+func TestInitFail(t *testing.T) {
+	t.Setenv("SOPS_AGE_KEY", "")
+	t.Setenv("SOPS_AGE_KEY_FILE", "")
+
+	tests := []struct {
+		name     string
+		plugin   *EncryptedFile
+		expected string
+	}{
+		{
+			name:     "missing path",
+			plugin:   &EncryptedFile{},
+			expected: "'path' required",
+		},
+		{
+			name: "invalid encryption",
+			plugin: &EncryptedFile{
+				Path:       "secrets.yaml",
+				Encryption: "foo",
+			},
+			expected: "invalid 'encryption'",
+		},
+		{
+			name: "invalid file format",
+			plugin: &EncryptedFile{
+				Path:       "secrets.yaml",
+				FileFormat: "toml",
+			},
+			expected: "invalid 'file_format'",
+		},
+		{
+			name: "missing key for sops",
+			plugin: &EncryptedFile{
+				Path: "secrets.yaml",
+			},
+			expected: "age or PGP key required",
+		},
+		{
+			name: "missing key for age",
+			plugin: &EncryptedFile{
+				Path:       "secrets.yaml",
+				Encryption: "age",
+			},
+			expected: "age key required",
+		},
+		{
+			name: "invalid age key",
+			plugin: &EncryptedFile{
+				Path:   "secrets.yaml",
+				AgeKey: config.NewSecret([]byte("AGE-SECRET-KEY-INVALID")),
+			},
+			expected: "age",
+		},
+		{
+			name: "invalid age recipient",
+			plugin: &EncryptedFile{
+				Path:          "secrets.yaml",
+				AgeKeyFile:    filepath.Join("testdata", "age.key"),
+				AgeRecipients: []string{"age1invalid"},
+			},
+			expected: "recipient",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.ErrorContains(t, tt.plugin.Init(), tt.expected)
+		})
+	}
+}
+
+func TestSOPSAge(t *testing.T) {
+	for _, fn := range []string{"secrets.sops.yaml", "secrets.sops.json"} {
+		t.Run(fn, func(t *testing.T) {
+			plugin := &EncryptedFile{
+				Path:       filepath.Join("testdata", fn),
+				AgeKeyFile: filepath.Join("testdata", "age.key"),
+			}
+			require.NoError(t, plugin.Init())
+
+			secret, err := plugin.Get("user")
+			require.NoError(t, err)
+			require.Equal(t, "telegraf", string(secret))
+
+			secret, err = plugin.Get("password")
+			require.NoError(t, err)
+			require.Equal(t, "pa$$word", string(secret))
+
+			secret, err = plugin.Get("port")
+			require.NoError(t, err)
+			require.Equal(t, "8086", string(secret))
+
+			_, err = plugin.Get("nested")
+			require.ErrorContains(t, err, "not a scalar")
+
+			_, err = plugin.Get("foo")
+			require.ErrorContains(t, err, "not found")
+		})
+	}
+}
+
+func TestSOPSValueTypes(t *testing.T) {
+	plugin := &EncryptedFile{
+		Path:       filepath.Join("testdata", "secrets.sops.yaml"),
+		AgeKeyFile: filepath.Join("testdata", "age.key"),
+	}
+	require.NoError(t, plugin.Init())
+
+	expected := map[string]string{
+		"user":                    "telegraf",
+		"password":                "pa$$word",
+		"port":                    "8086",
+		"ratio":                   "0.5",
+		"enabled":                 "true",
+		"description_unencrypted": "plain text",
+		"empty":                   "",
+	}
+	keys, err := plugin.List()
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{"user", "password", "port", "ratio", "enabled", "description_unencrypted", "empty"}, keys)
+
+	for key, value := range expected {
+		resolver, err := plugin.GetResolver(key)
+		require.NoError(t, err)
+		secret, dynamic, err := resolver()
+		require.NoError(t, err)
+		require.False(t, dynamic)
+		require.Equal(t, value, string(secret), key)
+	}
+}
+
+func TestSOPSPGP(t *testing.T) {
+	plugin := &EncryptedFile{
+		Path:       filepath.Join("testdata", "pgp.sops.yaml"),
+		PGPKeyFile: filepath.Join("testdata", "pgp.asc"),
+	}
+	require.NoError(t, plugin.Init())
+
+	secret, err := plugin.Get("password")
+	require.NoError(t, err)
+	require.Equal(t, "pgp-secret", string(secret))
+}
+
+func TestSOPSAgeKeyEnvironment(t *testing.T) {
+	t.Setenv("SOPS_AGE_KEY", "")
+	t.Setenv("SOPS_AGE_KEY_FILE", filepath.Join("testdata", "age.key"))
+
+	plugin := &EncryptedFile{Path: filepath.Join("testdata", "secrets.sops.yaml")}
+	require.NoError(t, plugin.Init())
+
+	secret, err := plugin.Get("user")
+	require.NoError(t, err)
+	require.Equal(t, "telegraf", string(secret))
+}
+
+func TestSOPSWrongKey(t *testing.T) {
+	// Use a valid age identity not being a recipient of the file
+	identity, err := age.GenerateX25519Identity()
+	require.NoError(t, err)
+
+	plugin := &EncryptedFile{
+		Path:   filepath.Join("testdata", "secrets.sops.yaml"),
+		AgeKey: config.NewSecret([]byte(identity.String())),
+	}
+	require.NoError(t, plugin.Init())
+
+	_, err = plugin.Get("user")
+	require.ErrorContains(t, err, "decrypting file failed")
+}
+
+func TestSOPSModified(t *testing.T) {
+	content, err := os.ReadFile(filepath.Join("testdata", "secrets.sops.yaml"))
+	require.NoError(t, err)
+	content = []byte(strings.Replace(string(content), "plain text", "modified text", 1))
+
+	fn := filepath.Join(t.TempDir(), "secrets.sops.yaml")
+	require.NoError(t, os.WriteFile(fn, content, 0600))
+
+	plugin := &EncryptedFile{
+		Path:       fn,
+		AgeKeyFile: filepath.Join("testdata", "age.key"),
+	}
+	require.NoError(t, plugin.Init())
+
+	_, err = plugin.Get("user")
+	require.ErrorContains(t, err, "MAC mismatch")
+}
+
+func TestSOPSSet(t *testing.T) {
+	for _, fn := range []string{"secrets.sops.yaml", "secrets.sops.json"} {
+		t.Run(fn, func(t *testing.T) {
+			content, err := os.ReadFile(filepath.Join("testdata", fn))
+			require.NoError(t, err)
+			path := filepath.Join(t.TempDir(), fn)
+			require.NoError(t, os.WriteFile(path, content, 0640))
+
+			plugin := &EncryptedFile{
+				Path:       path,
+				AgeKeyFile: filepath.Join("testdata", "age.key"),
+			}
+			require.NoError(t, plugin.Init())
+			require.NoError(t, plugin.Set("password", "new secret"))
+			require.NoError(t, plugin.Set("added", "another secret"))
+
+			// Unchanged values must keep their ciphertext and the new values
+			// must be encrypted
+			modified, err := os.ReadFile(path)
+			require.NoError(t, err)
+			require.NotContains(t, string(modified), "new secret")
+			require.NotContains(t, string(modified), "another secret")
+			user := strings.SplitN(strings.SplitN(string(content), `ENC[AES256_GCM,data:`, 2)[1], ",", 2)[0]
+			require.Contains(t, string(modified), user)
+
+			// The file permissions must be kept
+			stat, err := os.Stat(path)
+			require.NoError(t, err)
+			require.Equal(t, os.FileMode(0640), stat.Mode().Perm())
+
+			// Read the file with a new instance
+			reader := &EncryptedFile{
+				Path:       path,
+				AgeKeyFile: filepath.Join("testdata", "age.key"),
+			}
+			require.NoError(t, reader.Init())
+			for key, value := range map[string]string{
+				"user":     "telegraf",
+				"password": "new secret",
+				"added":    "another secret",
+			} {
+				secret, err := reader.Get(key)
+				require.NoError(t, err)
+				require.Equal(t, value, string(secret), key)
+			}
+		})
+	}
+}
+
+func TestSOPSCreate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "secrets.sops.yaml")
+
+	plugin := &EncryptedFile{
+		Path:       path,
+		AgeKeyFile: filepath.Join("testdata", "age.key"),
+		PGPKeyFile: filepath.Join("testdata", "pgp.asc"),
+	}
+	require.NoError(t, plugin.Init())
+
+	keys, err := plugin.List()
+	require.NoError(t, err)
+	require.Empty(t, keys)
+
+	require.NoError(t, plugin.Set("user", "telegraf"))
+	require.NoError(t, plugin.Set("password", "secret"))
+
+	stat, err := os.Stat(path)
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0600), stat.Mode().Perm())
+
+	// The file must be decryptable with each of the keys
+	for _, reader := range []*EncryptedFile{
+		{Path: path, AgeKeyFile: filepath.Join("testdata", "age.key")},
+		{Path: path, PGPKeyFile: filepath.Join("testdata", "pgp.asc")},
+	} {
+		require.NoError(t, reader.Init())
+		keys, err := reader.List()
+		require.NoError(t, err)
+		require.Equal(t, []string{"user", "password"}, keys)
+
+		secret, err := reader.Get("password")
+		require.NoError(t, err)
+		require.Equal(t, "secret", string(secret))
+	}
+}
+
+func TestAge(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "secrets.json.age")
+
+	plugin := &EncryptedFile{
+		Path:       path,
+		Encryption: "age",
+		AgeKeyFile: filepath.Join("testdata", "age.key"),
+	}
+	require.NoError(t, plugin.Init())
+	require.Equal(t, "json", plugin.FileFormat)
+	require.NoError(t, plugin.Set("user", "telegraf"))
+	require.NoError(t, plugin.Set("password", "secret"))
+
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.Contains(t, string(content), "-----BEGIN AGE ENCRYPTED FILE-----")
+	require.NotContains(t, string(content), "telegraf")
+
+	reader := &EncryptedFile{
+		Path:       path,
+		Encryption: "age",
+		AgeKeyFile: filepath.Join("testdata", "age.key"),
+	}
+	require.NoError(t, reader.Init())
+	keys, err := reader.List()
+	require.NoError(t, err)
+	require.Equal(t, []string{"user", "password"}, keys)
+
+	secret, err := reader.Get("password")
+	require.NoError(t, err)
+	require.Equal(t, "secret", string(secret))
+}
+
+func TestDynamic(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "secrets.sops.yaml")
+
+	plugin := &EncryptedFile{
+		Path:       path,
+		AgeKeyFile: filepath.Join("testdata", "age.key"),
+		Dynamic:    true,
+	}
+	require.NoError(t, plugin.Init())
+	require.NoError(t, plugin.Set("password", "old"))
+
+	resolver, err := plugin.GetResolver("password")
+	require.NoError(t, err)
+	secret, dynamic, err := resolver()
+	require.NoError(t, err)
+	require.True(t, dynamic)
+	require.Equal(t, "old", string(secret))
+
+	// Modify the file with another instance
+	writer := &EncryptedFile{
+		Path:       path,
+		AgeKeyFile: filepath.Join("testdata", "age.key"),
+	}
+	require.NoError(t, writer.Init())
+	require.NoError(t, writer.Set("password", "new"))
+
+	// Make sure the modification time differs on filesystems with a coarse
+	// timestamp resolution
+	stat, err := os.Stat(path)
+	require.NoError(t, err)
+	mtime := stat.ModTime().Add(time.Second)
+	require.NoError(t, os.Chtimes(path, mtime, mtime))
+
+	secret, _, err = resolver()
+	require.NoError(t, err)
+	require.Equal(t, "new", string(secret))
+}
@@ -0,0 +1,161 @@
+package encrypted_file
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"filippo.io/age"
+	"filippo.io/age/armor"
+	"github.com/ProtonMail/go-crypto/openpgp"
+	pgparmor "github.com/ProtonMail/go-crypto/openpgp/armor"
+
+	"github.com/influxdata/telegraf/config"
+)
+
+// readKey returns the key given directly or from the given file, falling back
+// to the given environment variables for key and file
+func readKey(key config.Secret, file, keyEnv, fileEnv string) ([]byte, error) {
+	if !key.Empty() {
+		secret, err := key.Get()
+		if err != nil {
+			return nil, err
+		}
+		defer secret.Destroy()
+		return bytes.Clone(secret.Bytes()), nil
+	}
+	if file != "" {
+		return os.ReadFile(file)
+	}
+	if keyEnv != "" {
+		if v := os.Getenv(keyEnv); v != "" {
+			return []byte(v), nil
+		}
+	}
+	if fileEnv != "" {
+		if fn := os.Getenv(fileEnv); fn != "" {
+			return os.ReadFile(fn)
+		}
+	}
+	return nil, nil
+}
+
+// setupAge parses the age identities and recipients
+func (e *EncryptedFile) setupAge() error {
+	buf, err := readKey(e.AgeKey, e.AgeKeyFile, "SOPS_AGE_KEY", "SOPS_AGE_KEY_FILE")
+	if err != nil {
+		return fmt.Errorf("reading age key failed: %w", err)
+	}
+	if len(buf) > 0 {
+		identities, err := age.ParseIdentities(bytes.NewReader(buf))
+		if err != nil {
+			return fmt.Errorf("parsing age key failed: %w", err)
+		}
+		e.ageIdentities = identities
+	}
+
+	// Use the given recipients for encryption or the ones of the identities
+	if len(e.AgeRecipients) > 0 {
+		recipients, err := age.ParseRecipients(strings.NewReader(strings.Join(e.AgeRecipients, "\n")))
+		if err != nil {
+			return fmt.Errorf("parsing age recipients failed: %w", err)
+		}
+		e.ageRecipients = recipients
+		return nil
+	}
+	for _, identity := range e.ageIdentities {
+		if id, ok := identity.(*age.X25519Identity); ok {
+			e.ageRecipients = append(e.ageRecipients, id.Recipient())
+		}
+	}
+	return nil
+}
+
+// setupPGP reads the PGP keyring and decrypts the private keys
+func (e *EncryptedFile) setupPGP() error {
+	buf, err := readKey(e.PGPKey, e.PGPKeyFile, "", "")
+	if err != nil {
+		return fmt.Errorf("reading PGP key failed: %w", err)
+	}
+	if len(buf) == 0 {
+		return nil
+	}
+
+	keyring, err := openpgp.ReadArmoredKeyRing(bytes.NewReader(buf))
+	if err != nil {
+		return fmt.Errorf("parsing PGP key failed: %w", err)
+	}
+	if !e.PGPPassphrase.Empty() {
+		passphrase, err := e.PGPPassphrase.Get()
+		if err != nil {
+			return fmt.Errorf("getting PGP passphrase failed: %w", err)
+		}
+		defer passphrase.Destroy()
+		for _, entity := range keyring {
+			if err := entity.DecryptPrivateKeys(passphrase.Bytes()); err != nil {
+				return fmt.Errorf("decrypting PGP key failed: %w", err)
+			}
+		}
+	}
+	e.pgpKeyring = keyring
+	e.pgpRecipients = keyring
+	return nil
+}
+
+// decryptAge decrypts the given armored or binary age data
+func decryptAge(data []byte, identities []age.Identity) ([]byte, error) {
+	if len(identities) == 0 {
+		return nil, errors.New("no age key")
+	}
+	var src io.Reader = bytes.NewReader(data)
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte(armor.Header)) {
+		src = armor.NewReader(bytes.NewReader(bytes.TrimSpace(data)))
+	}
+	r, err := age.Decrypt(src, identities...)
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(r)
+}
+
+// encryptAge writes the armored encryption of the data for the recipients
+func encryptAge(w io.Writer, data []byte, recipients ...age.Recipient) error {
+	aw := armor.NewWriter(w)
+	ew, err := age.Encrypt(aw, recipients...)
+	if err != nil {
+		return err
+	}
+	if _, err := ew.Write(data); err != nil {
+		return err
+	}
+	if err := ew.Close(); err != nil {
+		return err
+	}
+	return aw.Close()
+}
+
+// encryptPGP returns the armored PGP message of the data for the entity
+func encryptPGP(data []byte, entity *openpgp.Entity) (string, error) {
+	var buf bytes.Buffer
+	aw, err := pgparmor.Encode(&buf, "PGP MESSAGE", nil)
+	if err != nil {
+		return "", err
+	}
+	pw, err := openpgp.Encrypt(aw, []*openpgp.Entity{entity}, nil, &openpgp.FileHints{IsBinary: true}, nil)
+	if err != nil {
+		return "", err
+	}
+	if _, err := pw.Write(data); err != nil {
+		return "", err
+	}
+	if err := pw.Close(); err != nil {
+		return "", err
+	}
+	if err := aw.Close(); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
@@ -0,0 +1,552 @@
+package encrypted_file
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"filippo.io/age"
+	"filippo.io/age/armor"
+	"github.com/ProtonMail/go-crypto/openpgp"
+	pgparmor "github.com/ProtonMail/go-crypto/openpgp/armor"
+	"gopkg.in/yaml.v2"
+)
+
+// Key of the metadata section in sops files
+const sopsMetadataKey = "sops"
+
+// Version written to newly created sops files
+const sopsVersion = "3.9.0"
+
+// Nonce size used by sops for encrypting values
+const sopsNonceSize = 32
+
+// Initialization of the MAC hash if only encrypted values are authenticated
+var sopsMACOnlyEncryptedInit = []byte{
+	0x8a, 0x3f, 0xd2, 0xad, 0x54, 0xce, 0x66, 0x52, 0x7b, 0x10, 0x34, 0xf3, 0xd1, 0x47, 0xbe, 0x0b,
+	0x0b, 0x97, 0x5b, 0x3b, 0xf4, 0x4f, 0x72, 0xc6, 0xfd, 0xad, 0xec, 0x81, 0x76, 0xf2, 0x7d, 0x69,
+}
+
+var sopsValuePattern = regexp.MustCompile(`^ENC\[AES256_GCM,data:(.+),iv:(.+),tag:(.+),type:(.+)\]`)
+
+// sopsMetadata is the part of the sops metadata required for decrypting and
+// encrypting the file
+type sopsMetadata struct {
+	KeyGroups         []interface{} `yaml:"key_groups"`
+	Age               []sopsAgeKey  `yaml:"age"`
+	PGP               []sopsPGPKey  `yaml:"pgp"`
+	LastModified      string        `yaml:"lastmodified"`
+	MAC               string        `yaml:"mac"`
+	UnencryptedSuffix string        `yaml:"unencrypted_suffix"`
+	EncryptedSuffix   string        `yaml:"encrypted_suffix"`
+	UnencryptedRegex  string        `yaml:"unencrypted_regex"`
+	EncryptedRegex    string        `yaml:"encrypted_regex"`
+	MACOnlyEncrypted  bool          `yaml:"mac_only_encrypted"`
+}
+
+type sopsAgeKey struct {
+	Recipient string `yaml:"recipient"`
+	Enc       string `yaml:"enc"`
+}
+
+type sopsPGPKey struct {
+	CreatedAt   string `yaml:"created_at"`
+	Enc         string `yaml:"enc"`
+	Fingerprint string `yaml:"fp"`
+}
+
+// sopsState is the state of a decrypted sops file required to encrypt the
+// file again
+type sopsState struct {
+	dataKey  []byte
+	metadata yaml.MapSlice
+	settings sopsMetadata
+
+	// Encrypted values by their path and plaintext to keep the ciphertext of
+	// unchanged values and thus to minimize differences of the file
+	stash map[string]string
+
+	unencryptedRe *regexp.Regexp
+	encryptedRe   *regexp.Regexp
+}
+
+// shouldEncrypt returns true if the value at the given path is encrypted
+// following the rules of sops
+func (s *sopsState) shouldEncrypt(path []string) bool {
+	encrypted := true
+	if suffix := s.settings.UnencryptedSuffix; suffix != "" {
+		for _, p := range path {
+			if strings.HasSuffix(p, suffix) {
+				encrypted = false
+				break
+			}
+		}
+	}
+	if suffix := s.settings.EncryptedSuffix; suffix != "" {
+		encrypted = false
+		for _, p := range path {
+			if strings.HasSuffix(p, suffix) {
+				encrypted = true
+				break
+			}
+		}
+	}
+	if s.unencryptedRe != nil {
+		for _, p := range path {
+			if s.unencryptedRe.MatchString(p) {
+				encrypted = false
+				break
+			}
+		}
+	}
+	if s.encryptedRe != nil {
+		encrypted = false
+		for _, p := range path {
+			if s.encryptedRe.MatchString(p) {
+				encrypted = true
+				break
+			}
+		}
+	}
+	return encrypted
+}
+
+// newMACHash returns the hash for computing the message authentication code
+func (s *sopsState) newMACHash() hash.Hash {
+	h := sha512.New()
+	if s.settings.MACOnlyEncrypted {
+		h.Write(sopsMACOnlyEncryptedInit)
+	}
+	return h
+}
+
+// decryptSOPS decrypts the values of the given sops document and verifies
+// the message authentication code
+func (e *EncryptedFile) decryptSOPS(doc yaml.MapSlice) (yaml.MapSlice, *sopsState, error) {
+	// Separate the data from the metadata
+	data := make(yaml.MapSlice, 0, len(doc))
+	state := &sopsState{stash: make(map[string]string)}
+	for _, item := range doc {
+		if item.Key == sopsMetadataKey {
+			metadata, ok := item.Value.(yaml.MapSlice)
+			if !ok {
+				return nil, nil, errors.New("invalid sops metadata")
+			}
+			state.metadata = metadata
+			continue
+		}
+		data = append(data, item)
+	}
+	if state.metadata == nil {
+		return nil, nil, errors.New("sops metadata not found")
+	}
+	buf, err := yaml.Marshal(state.metadata)
+	if err != nil {
+		return nil, nil, fmt.Errorf("encoding sops metadata failed: %w", err)
+	}
+	if err := yaml.Unmarshal(buf, &state.settings); err != nil {
+		return nil, nil, fmt.Errorf("decoding sops metadata failed: %w", err)
+	}
+	if err := state.compileRegexes(); err != nil {
+		return nil, nil, err
+	}
+	if len(state.settings.KeyGroups) > 0 {
+		return nil, nil, errors.New("sops key groups are not supported")
+	}
+
+	// Get the data key
+	state.dataKey, err = e.sopsDataKey(&state.settings)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Decrypt the values and compute the MAC of the plaintext values
+	h := state.newMACHash()
+	decrypted, err := walkTree(data, nil, func(value interface{}, path []string) (interface{}, error) {
+		encrypted := state.shouldEncrypt(path)
+		if encrypted {
+			ciphertext, ok := value.(string)
+			if !ok {
+				return nil, fmt.Errorf("expected encrypted value at %q but got %T", strings.Join(path, "."), value)
+			}
+			aad := strings.Join(path, ":") + ":"
+			plaintext, err := decryptSOPSValue(ciphertext, state.dataKey, aad)
+			if err != nil {
+				return nil, fmt.Errorf("decrypting value at %q failed: %w", strings.Join(path, "."), err)
+			}
+			if stashKey, err := sopsStashKey(plaintext, aad); err == nil {
+				state.stash[stashKey] = ciphertext
+			}
+			value = plaintext
+		}
+		if !state.settings.MACOnlyEncrypted || encrypted {
+			buf, err := sopsValueBytes(value)
+			if err != nil {
+				return nil, err
+			}
+			h.Write(buf)
+		}
+		return value, nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Verify the integrity of the data
+	mac, err := decryptSOPSValue(state.settings.MAC, state.dataKey, state.settings.LastModified)
+	if err != nil {
+		return nil, nil, fmt.Errorf("decrypting MAC failed: %w", err)
+	}
+	if expected := strings.ToUpper(hex.EncodeToString(h.Sum(nil))); mac != expected {
+		return nil, nil, errors.New("MAC mismatch, the file was modified")
+	}
+
+	return decrypted.(yaml.MapSlice), state, nil
+}
+
+// encryptSOPS encrypts the values of the given data and adds the metadata
+// including the message authentication code
+func (e *EncryptedFile) encryptSOPS(data yaml.MapSlice, state *sopsState) (yaml.MapSlice, error) {
+	if state == nil {
+		var err error
+		if state, err = e.newSOPSState(); err != nil {
+			return nil, err
+		}
+	}
+
+	h := state.newMACHash()
+	encrypted, err := walkTree(data, nil, func(value interface{}, path []string) (interface{}, error) {
+		encrypt := state.shouldEncrypt(path)
+		if !state.settings.MACOnlyEncrypted || encrypt {
+			buf, err := sopsValueBytes(value)
+			if err != nil {
+				return nil, err
+			}
+			h.Write(buf)
+		}
+		if !encrypt {
+			return value, nil
+		}
+		aad := strings.Join(path, ":") + ":"
+		if stashKey, err := sopsStashKey(value, aad); err == nil {
+			if ciphertext, found := state.stash[stashKey]; found {
+				return ciphertext, nil
+			}
+		}
+		return encryptSOPSValue(value, state.dataKey, aad)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Authenticate the data
+	lastModified := time.Now().UTC().Format(time.RFC3339)
+	macValue := strings.ToUpper(hex.EncodeToString(h.Sum(nil)))
+	mac, err := encryptSOPSValue(macValue, state.dataKey, lastModified)
+	if err != nil {
+		return nil, fmt.Errorf("encrypting MAC failed: %w", err)
+	}
+	metadata := setItem(state.metadata, "lastmodified", lastModified)
+	metadata = setItem(metadata, "mac", mac)
+
+	doc := encrypted.(yaml.MapSlice)
+	return append(doc, yaml.MapItem{Key: sopsMetadataKey, Value: metadata}), nil
+}
+
+// newSOPSState creates the state for a new sops file by generating a data key
+// and encrypting it for the configured recipients
+func (e *EncryptedFile) newSOPSState() (*sopsState, error) {
+	state := &sopsState{
+		dataKey:  make([]byte, 32),
+		settings: sopsMetadata{UnencryptedSuffix: "_unencrypted"},
+		stash:    make(map[string]string),
+	}
+	if _, err := rand.Read(state.dataKey); err != nil {
+		return nil, fmt.Errorf("generating data key failed: %w", err)
+	}
+
+	var ageKeys, pgpKeys []interface{}
+	for _, recipient := range e.ageRecipients {
+		var buf bytes.Buffer
+		if err := encryptAge(&buf, state.dataKey, recipient); err != nil {
+			return nil, fmt.Errorf("encrypting data key for %q failed: %w", recipient, err)
+		}
+		ageKeys = append(ageKeys, yaml.MapSlice{
+			{Key: "recipient", Value: fmt.Sprint(recipient)},
+			{Key: "enc", Value: buf.String()},
+		})
+	}
+	for _, entity := range e.pgpRecipients {
+		enc, err := encryptPGP(state.dataKey, entity)
+		if err != nil {
+			return nil, fmt.Errorf("encrypting data key for PGP key failed: %w", err)
+		}
+		pgpKeys = append(pgpKeys, yaml.MapSlice{
+			{Key: "created_at", Value: time.Now().UTC().Format(time.RFC3339)},
+			{Key: "enc", Value: enc},
+			{Key: "fp", Value: strings.ToUpper(hex.EncodeToString(entity.PrimaryKey.Fingerprint))},
+		})
+	}
+	if len(ageKeys) == 0 && len(pgpKeys) == 0 {
+		return nil, errors.New("no recipients for encrypting the file")
+	}
+
+	if len(ageKeys) > 0 {
+		state.metadata = append(state.metadata, yaml.MapItem{Key: "age", Value: ageKeys})
+	}
+	state.metadata = append(state.metadata, yaml.MapItem{Key: "lastmodified", Value: ""}, yaml.MapItem{Key: "mac", Value: ""})
+	if len(pgpKeys) > 0 {
+		state.metadata = append(state.metadata, yaml.MapItem{Key: "pgp", Value: pgpKeys})
+	}
+	state.metadata = append(state.metadata,
+		yaml.MapItem{Key: "unencrypted_suffix", Value: state.settings.UnencryptedSuffix},
+		yaml.MapItem{Key: "version", Value: sopsVersion},
+	)
+	return state, nil
+}
+
+func (s *sopsState) compileRegexes() error {
+	if s.settings.UnencryptedRegex != "" {
+		re, err := regexp.Compile(s.settings.UnencryptedRegex)
+		if err != nil {
+			return fmt.Errorf("compiling unencrypted_regex failed: %w", err)
+		}
+		s.unencryptedRe = re
+	}
+	if s.settings.EncryptedRegex != "" {
+		re, err := regexp.Compile(s.settings.EncryptedRegex)
+		if err != nil {
+			return fmt.Errorf("compiling encrypted_regex failed: %w", err)
+		}
+		s.encryptedRe = re
+	}
+	return nil
+}
+
+// sopsDataKey decrypts the data key using the first matching age identity or
+// PGP key
+func (e *EncryptedFile) sopsDataKey(metadata *sopsMetadata) ([]byte, error) {
+	var errs []error
+	if len(e.ageIdentities) > 0 {
+		for _, key := range metadata.Age {
+			r, err := age.Decrypt(armor.NewReader(strings.NewReader(key.Enc)), e.ageIdentities...)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("age recipient %q: %w", key.Recipient, err))
+				continue
+			}
+			return io.ReadAll(r)
+		}
+	}
+	if len(e.pgpKeyring) > 0 {
+		for _, key := range metadata.PGP {
+			block, err := pgparmor.Decode(strings.NewReader(key.Enc))
+			if err != nil {
+				errs = append(errs, fmt.Errorf("PGP key %q: %w", key.Fingerprint, err))
+				continue
+			}
+			md, err := openpgp.ReadMessage(block.Body, e.pgpKeyring, nil, nil)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("PGP key %q: %w", key.Fingerprint, err))
+				continue
+			}
+			return io.ReadAll(md.UnverifiedBody)
+		}
+	}
+	if len(errs) == 0 {
+		return nil, errors.New("no matching key for decrypting the data key")
+	}
+	return nil, fmt.Errorf("decrypting data key failed: %w", errors.Join(errs...))
+}
+
+// decryptSOPSValue decrypts a sops-encrypted value using the additional data
+func decryptSOPSValue(ciphertext string, key []byte, aad string) (interface{}, error) {
+	if ciphertext == "" {
+		return "", nil
+	}
+	matches := sopsValuePattern.FindStringSubmatch(ciphertext)
+	if matches == nil {
+		return nil, errors.New("invalid encrypted value")
+	}
+	data, err := base64.StdEncoding.DecodeString(matches[1])
+	if err != nil {
+		return nil, fmt.Errorf("decoding data failed: %w", err)
+	}
+	iv, err := base64.StdEncoding.DecodeString(matches[2])
+	if err != nil {
+		return nil, fmt.Errorf("decoding IV failed: %w", err)
+	}
+	tag, err := base64.StdEncoding.DecodeString(matches[3])
+	if err != nil {
+		return nil, fmt.Errorf("decoding tag failed: %w", err)
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCMWithNonceSize(block, len(iv))
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := gcm.Open(nil, iv, append(data, tag...), []byte(aad))
+	if err != nil {
+		return nil, err
+	}
+
+	switch matches[4] {
+	case "str", "bytes":
+		return string(plaintext), nil
+	case "int":
+		return strconv.Atoi(string(plaintext))
+	case "float":
+		return strconv.ParseFloat(string(plaintext), 64)
+	case "bool":
+		return strconv.ParseBool(string(plaintext))
+	case "time":
+		var t time.Time
+		err := t.UnmarshalText(plaintext)
+		return t, err
+	}
+	return nil, fmt.Errorf("unsupported type %q", matches[4])
+}
+
+// encryptSOPSValue encrypts a value in the sops format using the additional
+// data
+func encryptSOPSValue(value interface{}, key []byte, aad string) (string, error) {
+	if s, ok := value.(string); ok && s == "" {
+		return "", nil
+	}
+
+	var typ string
+	switch value.(type) {
+	case string:
+		typ = "str"
+	case int, int64, uint64:
+		typ = "int"
+	case float64:
+		typ = "float"
+	case bool:
+		typ = "bool"
+	case time.Time:
+		typ = "time"
+	default:
+		return "", fmt.Errorf("unsupported value type %T", value)
+	}
+	plaintext, err := sopsValueBytes(value)
+	if err != nil {
+		return "", err
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return "", err
+	}
+	gcm, err := cipher.NewGCMWithNonceSize(block, sopsNonceSize)
+	if err != nil {
+		return "", err
+	}
+	iv := make([]byte, sopsNonceSize)
+	if _, err := rand.Read(iv); err != nil {
+		return "", fmt.Errorf("generating IV failed: %w", err)
+	}
+	out := gcm.Seal(nil, iv, plaintext, []byte(aad))
+	tagStart := len(out) - gcm.Overhead()
+
+	return fmt.Sprintf("ENC[AES256_GCM,data:%s,iv:%s,tag:%s,type:%s]",
+		base64.StdEncoding.EncodeToString(out[:tagStart]),
+		base64.StdEncoding.EncodeToString(iv),
+		base64.StdEncoding.EncodeToString(out[tagStart:]),
+		typ,
+	), nil
+}
+
+// sopsValueBytes returns the representation of the value used by sops for
+// encryption and authentication
+func sopsValueBytes(value interface{}) ([]byte, error) {
+	switch v := value.(type) {
+	case string:
+		return []byte(v), nil
+	case int:
+		return []byte(strconv.Itoa(v)), nil
+	case int64:
+		return []byte(strconv.FormatInt(v, 10)), nil
+	case uint64:
+		return []byte(strconv.FormatUint(v, 10)), nil
+	case float64:
+		return []byte(strconv.FormatFloat(v, 'f', -1, 64)), nil
+	case bool:
+		if v {
+			return []byte("True"), nil
+		}
+		return []byte("False"), nil
+	case time.Time:
+		return v.MarshalText()
+	}
+	return nil, fmt.Errorf("unsupported value type %T", value)
+}
+
+func sopsStashKey(value interface{}, aad string) (string, error) {
+	buf, err := sopsValueBytes(value)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s\x00%T\x00%s", aad, value, buf), nil
+}
+
+// walkTree calls the function for all leaf values of the tree, replacing
+// the values by the result. Values of lists share the path of the list as in
+// sops.
+func walkTree(value interface{}, path []string, fn func(interface{}, []string) (interface{}, error)) (interface{}, error) {
+	switch v := value.(type) {
+	case yaml.MapSlice:
+		out := make(yaml.MapSlice, 0, len(v))
+		for _, item := range v {
+			key, ok := item.Key.(string)
+			if !ok {
+				return nil, fmt.Errorf("non-string key %v not supported", item.Key)
+			}
+			child, err := walkTree(item.Value, append(path[:len(path):len(path)], key), fn)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, yaml.MapItem{Key: key, Value: child})
+		}
+		return out, nil
+	case []interface{}:
+		out := make([]interface{}, 0, len(v))
+		for _, element := range v {
+			child, err := walkTree(element, path, fn)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, child)
+		}
+		return out, nil
+	case nil:
+		return nil, nil
+	}
+	return fn(value, path)
+}
+
+// setItem sets the value of the given key, appending the key if not present
+func setItem(m yaml.MapSlice, key string, value interface{}) yaml.MapSlice {
+	for i, item := range m {
+		if item.Key == key {
+			m[i].Value = value
+			return m
+		}
+	}
+	return append(m, yaml.MapItem{Key: key, Value: value})
+}
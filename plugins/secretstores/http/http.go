@@ -10,7 +10,9 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/blues/jsonata-go"
@@ -34,6 +36,7 @@ type HTTP struct {
 	Token              config.Secret     `toml:"token"`
 	SuccessStatusCodes []int             `toml:"success_status_codes"`
 	Transformation     string            `toml:"transformation"`
+	RotationCheck      config.Duration   `toml:"rotation_check_interval"`
 	Log                telegraf.Logger   `toml:"-"`
 	common_http.HTTPClientConfig
 	DecryptionConfig
@@ -42,6 +45,7 @@ type HTTP struct {
 	transformer *jsonata.Expr
 	cache       map[string]string
 	decrypter   Decrypter
+	sync.RWMutex
 }
 
 func (*HTTP) SampleConfig() string {
@@ -87,7 +91,9 @@ func (h *HTTP) Init() error {
 
 // Get searches for the given key and return the secret
 func (h *HTTP) Get(key string) ([]byte, error) {
+	h.RLock()
 	v, found := h.cache[key]
+	h.RUnlock()
 	if !found {
 		return nil, errors.New("not found")
 	}
@@ -112,6 +118,9 @@ func (*HTTP) Set(_, _ string) error {
 
 // List lists all known secret keys
 func (h *HTTP) List() ([]string, error) {
+	h.RLock()
+	defer h.RUnlock()
+
 	keys := make([]string, 0, len(h.cache))
 	for k := range h.cache {
 		keys = append(keys, k)
@@ -126,13 +135,69 @@ func (h *HTTP) GetResolver(key string) (telegraf.ResolveFunc, error) {
 		return nil, err
 	}
 
+	// Secrets need to be resolved dynamically if they are checked for
+	// rotation as the cached values might change.
+	dynamic := h.RotationCheck > 0
 	resolver := func() ([]byte, bool, error) {
 		s, err := h.Get(key)
-		return s, false, err
+		return s, dynamic, err
 	}
 	return resolver, nil
 }
 
+// Watch periodically downloads the secrets and notifies about the keys of
+// changed, added or removed secrets
+func (h *HTTP) Watch(ctx context.Context, notify func(keys []string)) error {
+	if h.RotationCheck <= 0 {
+		return nil
+	}
+
+	ticker := time.NewTicker(time.Duration(h.RotationCheck))
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+
+		h.RLock()
+		previous := h.cache
+		h.RUnlock()
+
+		if err := h.download(); err != nil {
+			h.Log.Warnf("Checking for rotated secrets failed: %v", err)
+			continue
+		}
+
+		h.RLock()
+		current := h.cache
+		h.RUnlock()
+
+		if changed := changedKeys(previous, current); len(changed) > 0 {
+			notify(changed)
+		}
+	}
+}
+
+// changedKeys returns the sorted keys with different values in the given
+// caches including keys only present in one of them
+func changedKeys(previous, current map[string]string) []string {
+	var keys []string
+	for k, v := range current {
+		if old, found := previous[k]; !found || old != v {
+			keys = append(keys, k)
+		}
+	}
+	for k := range previous {
+		if _, found := current[k]; !found {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
 func (h *HTTP) download() error {
 	// Get the raw data form the URL
 	data, err := h.query()
@@ -150,7 +215,8 @@ func (h *HTTP) download() error {
 	}
 
 	// Extract the data from the resulting data
-	if err := json.Unmarshal(data, &h.cache); err != nil {
+	var cache map[string]string
+	if err := json.Unmarshal(data, &cache); err != nil {
 		var terr *json.UnmarshalTypeError
 		if errors.As(err, &terr) {
 			return fmt.Errorf("%w; maybe missing or wrong data transformation", err)
@@ -158,6 +224,10 @@ func (h *HTTP) download() error {
 		return err
 	}
 
+	h.Lock()
+	h.cache = cache
+	h.Unlock()
+
 	return nil
 }
 
@@ -1,6 +1,7 @@
 package http
 
 import (
+	"context"
 	"encoding/json"
 	"net"
 	"net/http"
@@ -8,6 +9,7 @@ import (
 	"net/url"
 	"os"
 	"path/filepath"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -431,3 +433,54 @@ func TestAuthenticationToken(t *testing.T) {
 	require.NotEmpty(t, auth)
 	require.Equal(t, "Bearer "+token, auth)
 }
+
+func TestWatchRotation(t *testing.T) {
+	var secrets atomic.Value
+	secrets.Store(`{"user": "admin", "password": "secret-A", "obsolete": "x"}`)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		if _, err := w.Write([]byte(secrets.Load().(string))); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			t.Error(err)
+			return
+		}
+	}))
+	defer server.Close()
+
+	plugin := &HTTP{
+		URL:           server.URL,
+		RotationCheck: config.Duration(50 * time.Millisecond),
+		Log:           testutil.Logger{},
+	}
+	plugin.Timeout = config.Duration(200 * time.Millisecond)
+	require.NoError(t, plugin.Init())
+
+	resolver, err := plugin.GetResolver("password")
+	require.NoError(t, err)
+	s, dynamic, err := resolver()
+	require.NoError(t, err)
+	require.True(t, dynamic)
+	require.Equal(t, "secret-A", string(s))
+
+	// Rotate the password, add a new secret and remove another
+	secrets.Store(`{"user": "admin", "password": "secret-B", "token": "abc"}`)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	rotated := make(chan []string, 10)
+	go func() {
+		if err := plugin.Watch(ctx, func(keys []string) { rotated <- keys }); err != nil {
+			t.Error(err)
+		}
+	}()
+
+	select {
+	case keys := <-rotated:
+		require.Equal(t, []string{"obsolete", "password", "token"}, keys)
+	case <-time.After(5 * time.Second):
+		require.Fail(t, "no rotation reported")
+	}
+
+	s, _, err = resolver()
+	require.NoError(t, err)
+	require.Equal(t, "secret-B", string(s))
+}
@@ -4,9 +4,14 @@
 package os
 
 import (
+	"context"
+	"crypto/sha256"
 	_ "embed"
 	"errors"
 	"fmt"
+	"sort"
+	"sync"
+	"time"
 
 	"github.com/99designs/keyring"
 
@@ -19,13 +24,19 @@ import (
 var sampleConfig string
 
 type OS struct {
-	ID         string        `toml:"id"`
-	Keyring    string        `toml:"keyring"`
-	Collection string        `toml:"collection"`
-	Dynamic    bool          `toml:"dynamic"`
-	Password   config.Secret `toml:"password"`
+	ID            string          `toml:"id"`
+	Keyring       string          `toml:"keyring"`
+	Collection    string          `toml:"collection"`
+	Dynamic       bool            `toml:"dynamic"`
+	Password      config.Secret   `toml:"password"`
+	RotationCheck config.Duration `toml:"rotation_check_interval"`
+	Log           telegraf.Logger `toml:"-"`
 
 	ring keyring.Keyring
+
+	// Hashes of the values of the resolved keys for detecting rotation
+	hashes map[string][sha256.Size]byte
+	sync.Mutex
 }
 
 func (*OS) SampleConfig() string {
@@ -86,13 +97,71 @@ func (o *OS) List() ([]string, error) {
 
 // GetResolver returns a function to resolve the given key.
 func (o *OS) GetResolver(key string) (telegraf.ResolveFunc, error) {
+	// Remember the current value of the key to check it for rotation
+	if o.RotationCheck > 0 {
+		o.Lock()
+		if o.hashes == nil {
+			o.hashes = make(map[string][sha256.Size]byte)
+		}
+		if _, found := o.hashes[key]; !found {
+			o.hashes[key] = o.hash(key)
+		}
+		o.Unlock()
+	}
+
+	// Secrets checked for rotation need to be resolved dynamically to pick
+	// up the new values.
+	dynamic := o.Dynamic || o.RotationCheck > 0
 	resolver := func() ([]byte, bool, error) {
 		s, err := o.Get(key)
-		return s, o.Dynamic, err
+		return s, dynamic, err
 	}
 	return resolver, nil
 }
 
+// Watch periodically checks the resolved secrets and notifies about the keys
+// of changed secrets
+func (o *OS) Watch(ctx context.Context, notify func(keys []string)) error {
+	if o.RotationCheck <= 0 {
+		return nil
+	}
+
+	ticker := time.NewTicker(time.Duration(o.RotationCheck))
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+
+		var changed []string
+		o.Lock()
+		for key, previous := range o.hashes {
+			if current := o.hash(key); current != previous {
+				o.hashes[key] = current
+				changed = append(changed, key)
+			}
+		}
+		o.Unlock()
+
+		if len(changed) > 0 {
+			sort.Strings(changed)
+			notify(changed)
+		}
+	}
+}
+
+// hash returns the hash of the current value of the given key, missing keys
+// result in the hash of an empty value
+func (o *OS) hash(key string) [sha256.Size]byte {
+	value, err := o.Get(key)
+	if err != nil {
+		o.Log.Debugf("Getting secret %q for rotation check failed: %v", key, err)
+	}
+	return sha256.Sum256(value)
+}
+
 // Register the secret-store on load.
 func init() {
 	secretstores.Add("os", func(id string) telegraf.SecretStore {
@@ -3,10 +3,16 @@
 package os
 
 import (
+	"context"
 	"testing"
+	"time"
 
-	"github.com/influxdata/telegraf/internal/choice"
+	"github.com/99designs/keyring"
 	"github.com/stretchr/testify/require"
+
+	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/internal/choice"
+	"github.com/influxdata/telegraf/testutil"
 )
 
 // In docker, access to the keyring is disabled by default see
@@ -86,3 +92,45 @@ func TestGetNonExisting(t *testing.T) {
 	_, err = plugin.Get(testKey)
 	require.EqualError(t, err, "The specified item could not be found in the keyring")
 }
+
+func TestWatchRotation(t *testing.T) {
+	plugin := &OS{
+		ID:            "test",
+		RotationCheck: config.Duration(50 * time.Millisecond),
+		Log:           testutil.Logger{},
+		ring:          keyring.NewArrayKeyring(nil),
+	}
+	require.NoError(t, plugin.Set("password", "secret-A"))
+	require.NoError(t, plugin.Set("unused", "foo"))
+
+	resolver, err := plugin.GetResolver("password")
+	require.NoError(t, err)
+	s, dynamic, err := resolver()
+	require.NoError(t, err)
+	require.True(t, dynamic)
+	require.Equal(t, "secret-A", string(s))
+
+	// Rotate the used secret and change a secret not used by any plugin
+	require.NoError(t, plugin.Set("password", "secret-B"))
+	require.NoError(t, plugin.Set("unused", "bar"))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	rotated := make(chan []string, 10)
+	go func() {
+		if err := plugin.Watch(ctx, func(keys []string) { rotated <- keys }); err != nil {
+			t.Error(err)
+		}
+	}()
+
+	select {
+	case keys := <-rotated:
+		require.Equal(t, []string{"password"}, keys)
+	case <-time.After(5 * time.Second):
+		require.Fail(t, "no rotation reported")
+	}
+
+	s, _, err = resolver()
+	require.NoError(t, err)
+	require.Equal(t, "secret-B", string(s))
+}
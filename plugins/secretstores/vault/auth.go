@@ -0,0 +1,202 @@
+package vault
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/influxdata/telegraf/config"
+)
+
+const defaultKubernetesTokenFile = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+
+// AppRoleConfig contains the settings for the AppRole authentication method
+type AppRoleConfig struct {
+	Mount    string        `toml:"mount"`
+	RoleID   config.Secret `toml:"role_id"`
+	SecretID config.Secret `toml:"secret_id"`
+}
+
+// KubernetesConfig contains the settings for the Kubernetes authentication
+// method using the service-account token of the pod
+type KubernetesConfig struct {
+	Mount     string `toml:"mount"`
+	Role      string `toml:"role"`
+	TokenFile string `toml:"token_file"`
+}
+
+// authInfo is the authentication part of Vault API responses
+type authInfo struct {
+	ClientToken   string `json:"client_token"`
+	LeaseDuration int64  `json:"lease_duration"`
+	Renewable     bool   `json:"renewable"`
+}
+
+// token is an authentication token together with its lifetime, a zero
+// expiry time denotes a token without expiry
+type token struct {
+	value     string
+	expires   time.Time
+	renewable bool
+}
+
+func newToken(value string, ttl int64, renewable bool) *token {
+	t := &token{value: value, renewable: renewable}
+	if ttl > 0 {
+		t.expires = time.Now().Add(time.Duration(ttl) * time.Second)
+	}
+	return t
+}
+
+// expiresWithin returns true if the token expires in the given duration
+func (t *token) expiresWithin(d time.Duration) bool {
+	return !t.expires.IsZero() && time.Until(t.expires) <= d
+}
+
+func (v *Vault) initAuth() error {
+	switch v.AuthMethod {
+	case "token":
+		if v.Token.Empty() {
+			return errors.New("'token' required for authentication method \"token\"")
+		}
+	case "approle":
+		if v.AppRole.Mount == "" {
+			v.AppRole.Mount = "approle"
+		}
+		if v.AppRole.RoleID.Empty() {
+			return errors.New("'role_id' required for authentication method \"approle\"")
+		}
+	case "kubernetes":
+		if v.Kubernetes.Mount == "" {
+			v.Kubernetes.Mount = "kubernetes"
+		}
+		if v.Kubernetes.TokenFile == "" {
+			v.Kubernetes.TokenFile = defaultKubernetesTokenFile
+		}
+		if v.Kubernetes.Role == "" {
+			return errors.New("'role' required for authentication method \"kubernetes\"")
+		}
+	default:
+		return fmt.Errorf("invalid 'auth_method' %q", v.AuthMethod)
+	}
+	return nil
+}
+
+// currentToken returns a valid token, renewing the current token when it is
+// about to expire or logging in again if renewal is not possible. The caller
+// must hold the refresh lock.
+func (v *Vault) currentToken() (string, error) {
+	margin := time.Duration(v.ExpiryMargin)
+	if v.token != nil && !v.token.expiresWithin(margin) {
+		return v.token.value, nil
+	}
+
+	if v.token != nil && v.token.renewable && !v.token.expiresWithin(0) {
+		t, err := v.renew(v.token.value)
+		if err == nil {
+			v.Log.Debugf("Renewed token, expiring at %v", t.expires)
+			v.token = t
+			return t.value, nil
+		}
+		v.Log.Warnf("Renewing token failed, logging in again: %v", err)
+	}
+
+	t, err := v.login()
+	if err != nil {
+		return "", fmt.Errorf("authentication failed: %w", err)
+	}
+	v.Log.Debugf("Logged in using authentication method %q", v.AuthMethod)
+	v.token = t
+	return t.value, nil
+}
+
+// login gets a new token using the configured authentication method
+func (v *Vault) login() (*token, error) {
+	switch v.AuthMethod {
+	case "token":
+		secret, err := v.Token.Get()
+		if err != nil {
+			return nil, fmt.Errorf("getting token failed: %w", err)
+		}
+		value := strings.TrimSpace(secret.String())
+		secret.Destroy()
+		return v.lookup(value)
+	case "approle":
+		roleID, err := v.AppRole.RoleID.Get()
+		if err != nil {
+			return nil, fmt.Errorf("getting role ID failed: %w", err)
+		}
+		defer roleID.Destroy()
+		body := map[string]string{"role_id": roleID.String()}
+		if !v.AppRole.SecretID.Empty() {
+			secretID, err := v.AppRole.SecretID.Get()
+			if err != nil {
+				return nil, fmt.Errorf("getting secret ID failed: %w", err)
+			}
+			defer secretID.Destroy()
+			body["secret_id"] = secretID.String()
+		}
+		return v.authenticate("auth/"+strings.Trim(v.AppRole.Mount, "/")+"/login", body)
+	case "kubernetes":
+		// Read the token on every login as the service-account tokens are
+		// rotated by Kubernetes
+		jwt, err := os.ReadFile(v.Kubernetes.TokenFile)
+		if err != nil {
+			return nil, fmt.Errorf("reading service-account token failed: %w", err)
+		}
+		body := map[string]string{
+			"role": v.Kubernetes.Role,
+			"jwt":  strings.TrimSpace(string(jwt)),
+		}
+		return v.authenticate("auth/"+strings.Trim(v.Kubernetes.Mount, "/")+"/login", body)
+	}
+	return nil, fmt.Errorf("invalid authentication method %q", v.AuthMethod)
+}
+
+// authenticate sends the login request and returns the issued token
+func (v *Vault) authenticate(endpoint string, body interface{}) (*token, error) {
+	resp, err := v.request(http.MethodPost, endpoint, "", body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.Auth == nil || resp.Auth.ClientToken == "" {
+		return nil, errors.New("no token in response")
+	}
+	return newToken(resp.Auth.ClientToken, resp.Auth.LeaseDuration, resp.Auth.Renewable), nil
+}
+
+// lookup determines the lifetime of the given static token
+func (v *Vault) lookup(value string) (*token, error) {
+	resp, err := v.request(http.MethodGet, "auth/token/lookup-self", value, nil)
+	if err != nil {
+		return nil, fmt.Errorf("looking up token failed: %w", err)
+	}
+	var data struct {
+		TTL       int64 `json:"ttl"`
+		Renewable bool  `json:"renewable"`
+	}
+	if err := json.Unmarshal(resp.Data, &data); err != nil {
+		return nil, fmt.Errorf("decoding token information failed: %w", err)
+	}
+	return newToken(value, data.TTL, data.Renewable), nil
+}
+
+// renew extends the lease of the given token
+func (v *Vault) renew(value string) (*token, error) {
+	resp, err := v.request(http.MethodPost, "auth/token/renew-self", value, map[string]string{})
+	if err != nil {
+		return nil, err
+	}
+	if resp.Auth == nil {
+		return nil, errors.New("no token information in response")
+	}
+	t := newToken(value, resp.Auth.LeaseDuration, resp.Auth.Renewable)
+	if t.expiresWithin(time.Duration(v.ExpiryMargin)) {
+		return nil, errors.New("token reached its maximum lifetime")
+	}
+	return t, nil
+}
@@ -0,0 +1,435 @@
+//go:generate ../../../tools/readme_config_includer/generator
+package vault
+
+import (
+	"bytes"
+	"context"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/plugins/common/proxy"
+	common_tls "github.com/influxdata/telegraf/plugins/common/tls"
+	"github.com/influxdata/telegraf/plugins/secretstores"
+)
+
+//go:embed sample.conf
+var sampleConfig string
+
+type Vault struct {
+	Address      string           `toml:"address"`
+	Namespace    string           `toml:"namespace"`
+	Mount        string           `toml:"mount"`
+	KVVersion    int              `toml:"kv_version"`
+	Paths        []string         `toml:"paths"`
+	AuthMethod   string           `toml:"auth_method"`
+	Token        config.Secret    `toml:"token"`
+	AppRole      AppRoleConfig    `toml:"approle"`
+	Kubernetes   KubernetesConfig `toml:"kubernetes"`
+	CacheTTL     config.Duration  `toml:"cache_ttl"`
+	ExpiryMargin config.Duration  `toml:"token_expiry_margin"`
+	Timeout      config.Duration  `toml:"timeout"`
+	Log          telegraf.Logger  `toml:"-"`
+	proxy.HTTPProxy
+	common_tls.ClientConfig
+
+	client *http.Client
+
+	// Current authentication token, guarded by the refresh lock
+	token *token
+
+	// Serializes reading the secrets from the server
+	refreshLock sync.Mutex
+
+	// Cached secrets, their expiry time and the keys of secrets changed
+	// since the last notification. The cache is replaced on refresh and
+	// never modified.
+	cache   map[string]string
+	expires time.Time
+	rotated map[string]bool
+	sync.Mutex
+}
+
+// response is the common envelope of Vault API responses
+type response struct {
+	Auth          *authInfo       `json:"auth"`
+	Data          json.RawMessage `json:"data"`
+	LeaseDuration int64           `json:"lease_duration"`
+	Errors        []string        `json:"errors"`
+}
+
+func (*Vault) SampleConfig() string {
+	return sampleConfig
+}
+
+// Init initializes all internals of the secret-store
+func (v *Vault) Init() error {
+	if v.Address == "" {
+		return errors.New("'address' required")
+	}
+	if _, err := url.Parse(v.Address); err != nil {
+		return fmt.Errorf("parsing address failed: %w", err)
+	}
+	v.Address = strings.TrimSuffix(v.Address, "/")
+
+	// Set defaults
+	if v.Mount == "" {
+		v.Mount = "secret"
+	}
+	v.Mount = strings.Trim(v.Mount, "/")
+	if v.KVVersion == 0 {
+		v.KVVersion = 2
+	}
+	if v.AuthMethod == "" {
+		v.AuthMethod = "token"
+	}
+	if v.Timeout <= 0 {
+		v.Timeout = config.Duration(5 * time.Second)
+	}
+
+	// Check the settings
+	if v.KVVersion != 1 && v.KVVersion != 2 {
+		return fmt.Errorf("invalid 'kv_version' %d, only 1 and 2 are supported", v.KVVersion)
+	}
+	if len(v.Paths) == 0 {
+		return errors.New("'paths' required")
+	}
+	for i, p := range v.Paths {
+		v.Paths[i] = strings.Trim(p, "/")
+		if v.Paths[i] == "" {
+			return errors.New("empty path in 'paths'")
+		}
+	}
+	if err := v.initAuth(); err != nil {
+		return err
+	}
+
+	// Setup the HTTP client
+	tlsCfg, err := v.ClientConfig.TLSConfig()
+	if err != nil {
+		return fmt.Errorf("setting up TLS failed: %w", err)
+	}
+	prox, err := v.HTTPProxy.Proxy()
+	if err != nil {
+		return fmt.Errorf("setting up proxy failed: %w", err)
+	}
+	v.client = &http.Client{
+		Transport: &http.Transport{
+			TLSClientConfig: tlsCfg,
+			Proxy:           prox,
+		},
+		Timeout: time.Duration(v.Timeout),
+	}
+
+	return nil
+}
+
+// Get searches for the given key and return the secret
+func (v *Vault) Get(key string) ([]byte, error) {
+	cache, err := v.secrets()
+	if err != nil {
+		return nil, err
+	}
+
+	value, found := cache[key]
+	if !found {
+		return nil, errors.New("not found")
+	}
+	return []byte(value), nil
+}
+
+// Set sets the given secret for the given key
+func (*Vault) Set(_, _ string) error {
+	return errors.New("setting secrets not supported")
+}
+
+// List lists all known secret keys
+func (v *Vault) List() ([]string, error) {
+	cache, err := v.secrets()
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make([]string, 0, len(cache))
+	for k := range cache {
+		keys = append(keys, k)
+	}
+	return keys, nil
+}
+
+// GetResolver returns a function to resolve the given key.
+func (v *Vault) GetResolver(key string) (telegraf.ResolveFunc, error) {
+	// Read the secrets to detect connection and authentication issues early
+	if _, err := v.secrets(); err != nil {
+		return nil, err
+	}
+
+	// Secrets need to be resolved dynamically if they are re-read as the
+	// values might change.
+	dynamic := v.CacheTTL > 0
+	resolver := func() ([]byte, bool, error) {
+		s, err := v.Get(key)
+		return s, dynamic, err
+	}
+	return resolver, nil
+}
+
+// Watch re-reads the secrets once the cache expired, keeping the token alive,
+// and notifies about the keys of changed, added or removed secrets. If reading
+// fails, the cached secrets are kept and reading is retried after the TTL.
+func (v *Vault) Watch(ctx context.Context, notify func(keys []string)) error {
+	if v.CacheTTL <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(v.untilExpiry())
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-timer.C:
+		}
+
+		v.refreshLock.Lock()
+		_, err := v.refresh()
+		v.refreshLock.Unlock()
+		if err != nil {
+			v.Log.Warnf("Reading secrets failed, keeping the cached secrets: %v", err)
+		}
+
+		v.Lock()
+		keys := make([]string, 0, len(v.rotated))
+		for k := range v.rotated {
+			keys = append(keys, k)
+		}
+		v.rotated = nil
+		v.Unlock()
+
+		if len(keys) > 0 {
+			sort.Strings(keys)
+			notify(keys)
+		}
+		timer.Reset(v.untilExpiry())
+	}
+}
+
+// untilExpiry returns the time until the cache expires or the cache TTL if
+// the secrets were not read successfully yet or the last refresh failed
+func (v *Vault) untilExpiry() time.Duration {
+	v.Lock()
+	defer v.Unlock()
+
+	if d := time.Until(v.expires); d > 0 {
+		return d
+	}
+	return time.Duration(v.CacheTTL)
+}
+
+// secrets returns the cached secrets, reading them if not cached yet
+func (v *Vault) secrets() (map[string]string, error) {
+	v.Lock()
+	cache := v.cache
+	v.Unlock()
+	if cache != nil {
+		return cache, nil
+	}
+
+	v.refreshLock.Lock()
+	defer v.refreshLock.Unlock()
+
+	// The secrets might have been read while waiting for the lock
+	v.Lock()
+	cache = v.cache
+	v.Unlock()
+	if cache != nil {
+		return cache, nil
+	}
+	return v.refresh()
+}
+
+// refresh reads the secrets, replaces the cache and records the changed
+// secrets. The caller must hold the refresh lock.
+func (v *Vault) refresh() (map[string]string, error) {
+	cache := make(map[string]string)
+	var lease time.Duration
+	for _, p := range v.Paths {
+		secrets, duration, err := v.read(p)
+		if err != nil {
+			return nil, fmt.Errorf("reading secrets at %q failed: %w", p, err)
+		}
+		for k, value := range secrets {
+			if _, found := cache[k]; found {
+				return nil, fmt.Errorf("secret %q at %q already defined by another path", k, p)
+			}
+			cache[k] = value
+		}
+		if duration > 0 && (lease == 0 || duration < lease) {
+			lease = duration
+		}
+	}
+
+	v.Lock()
+	defer v.Unlock()
+
+	// Remember the changed secrets to notify about rotation
+	if v.cache != nil {
+		for k, value := range cache {
+			if old, found := v.cache[k]; !found || old != value {
+				v.markRotated(k)
+			}
+		}
+		for k := range v.cache {
+			if _, found := cache[k]; !found {
+				v.markRotated(k)
+			}
+		}
+	}
+	v.cache = cache
+
+	// Expire the cache after the configured TTL or the lease duration of the
+	// secrets, whatever is shorter. A zero TTL disables re-reading.
+	v.expires = time.Time{}
+	if v.CacheTTL > 0 {
+		ttl := time.Duration(v.CacheTTL)
+		if lease > 0 && lease < ttl {
+			ttl = lease
+		}
+		v.expires = time.Now().Add(ttl)
+	}
+
+	return cache, nil
+}
+
+func (v *Vault) markRotated(key string) {
+	if v.rotated == nil {
+		v.rotated = make(map[string]bool)
+	}
+	v.rotated[key] = true
+}
+
+// read returns the secrets stored at the given path of the key-value engine
+// and the lease duration of the secrets if any
+func (v *Vault) read(p string) (map[string]string, time.Duration, error) {
+	token, err := v.currentToken()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	endpoint := v.Mount + "/" + p
+	if v.KVVersion == 2 {
+		endpoint = v.Mount + "/data/" + p
+	}
+	resp, err := v.request(http.MethodGet, endpoint, token, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	data := resp.Data
+	if v.KVVersion == 2 {
+		var versioned struct {
+			Data json.RawMessage `json:"data"`
+		}
+		if err := json.Unmarshal(resp.Data, &versioned); err != nil {
+			return nil, 0, fmt.Errorf("decoding data failed: %w", err)
+		}
+		data = versioned.Data
+	}
+
+	var raw map[string]interface{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, 0, fmt.Errorf("decoding secrets failed: %w", err)
+	}
+	if raw == nil {
+		return nil, 0, errors.New("no data, maybe the secret was deleted")
+	}
+
+	// Use the JSON representation for non-string secrets
+	secrets := make(map[string]string, len(raw))
+	for k, value := range raw {
+		if s, ok := value.(string); ok {
+			secrets[k] = s
+			continue
+		}
+		buf, err := json.Marshal(value)
+		if err != nil {
+			return nil, 0, fmt.Errorf("encoding secret %q failed: %w", k, err)
+		}
+		secrets[k] = string(buf)
+	}
+
+	return secrets, time.Duration(resp.LeaseDuration) * time.Second, nil
+}
+
+// request sends a request to the given API endpoint and decodes the response
+func (v *Vault) request(method, endpoint, token string, body interface{}) (*response, error) {
+	var reader io.Reader
+	if body != nil {
+		buf, err := json.Marshal(body)
+		if err != nil {
+			return nil, fmt.Errorf("encoding request failed: %w", err)
+		}
+		reader = bytes.NewReader(buf)
+	}
+
+	request, err := http.NewRequest(method, v.Address+"/v1/"+endpoint, reader)
+	if err != nil {
+		return nil, fmt.Errorf("creating request failed: %w", err)
+	}
+	request.Header.Set("X-Vault-Request", "true")
+	if token != "" {
+		request.Header.Set("X-Vault-Token", token)
+	}
+	if v.Namespace != "" {
+		request.Header.Set("X-Vault-Namespace", v.Namespace)
+	}
+	if body != nil {
+		request.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := v.client.Do(request)
+	if err != nil {
+		return nil, fmt.Errorf("executing request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	buf, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("reading response failed: %w", err)
+	}
+
+	var r response
+	if len(buf) > 0 {
+		if err := json.Unmarshal(buf, &r); err != nil && resp.StatusCode < 300 {
+			return nil, fmt.Errorf("decoding response failed: %w", err)
+		}
+	}
+	if resp.StatusCode >= 300 {
+		if len(r.Errors) > 0 {
+			return nil, fmt.Errorf("received status code %d (%s): %s", resp.StatusCode, http.StatusText(resp.StatusCode), strings.Join(r.Errors, "; "))
+		}
+		return nil, fmt.Errorf("received status code %d (%s)", resp.StatusCode, http.StatusText(resp.StatusCode))
+	}
+
+	return &r, nil
+}
+
+// Register the secret-store on load.
+func init() {
+	secretstores.Add("vault", func(string) telegraf.SecretStore {
+		return &Vault{
+			CacheTTL:     config.Duration(5 * time.Minute),
+			ExpiryMargin: config.Duration(time.Minute),
+		}
+	})
+}
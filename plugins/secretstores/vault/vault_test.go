@@ -0,0 +1,465 @@
+package vault
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/testutil"
+)
+
+// vaultServer is a minimal stand-in for the Vault API
+type vaultServer struct {
+	token     string
+	ttl       int64
+	namespace string
+	secrets   map[string]map[string]interface{}
+
+	unavailable bool
+	logins      int
+	renewals    int
+	requests    int
+	sync.Mutex
+}
+
+func (s *vaultServer) setUnavailable(unavailable bool) {
+	s.Lock()
+	defer s.Unlock()
+	s.unavailable = unavailable
+}
+
+func (s *vaultServer) requestCount() int {
+	s.Lock()
+	defer s.Unlock()
+	return s.requests
+}
+
+func (s *vaultServer) setSecret(path string, data map[string]interface{}) {
+	s.Lock()
+	defer s.Unlock()
+	s.secrets[path] = data
+}
+
+func (s *vaultServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.Lock()
+	defer s.Unlock()
+
+	reply := func(code int, v interface{}) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(code)
+		if err := json.NewEncoder(w).Encode(v); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}
+	deny := func() {
+		reply(http.StatusForbidden, map[string]interface{}{"errors": []string{"permission denied"}})
+	}
+
+	s.requests++
+	if s.unavailable {
+		reply(http.StatusServiceUnavailable, map[string]interface{}{"errors": []string{"Vault is sealed"}})
+		return
+	}
+	issue := func() {
+		s.logins++
+		reply(http.StatusOK, map[string]interface{}{
+			"auth": map[string]interface{}{"client_token": s.token, "lease_duration": s.ttl, "renewable": true},
+		})
+	}
+
+	if r.Header.Get("X-Vault-Namespace") != s.namespace {
+		deny()
+		return
+	}
+
+	var body map[string]string
+	if r.Method == http.MethodPost {
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			reply(http.StatusBadRequest, map[string]interface{}{"errors": []string{err.Error()}})
+			return
+		}
+	}
+
+	switch r.URL.Path {
+	case "/v1/auth/approle/login":
+		if body["role_id"] != "telegraf-role" || body["secret_id"] != "s3cr3t" {
+			deny()
+			return
+		}
+		issue()
+		return
+	case "/v1/auth/kubernetes/login":
+		if body["role"] != "telegraf" || body["jwt"] != "service-account-jwt" {
+			deny()
+			return
+		}
+		issue()
+		return
+	}
+
+	if r.Header.Get("X-Vault-Token") != s.token {
+		deny()
+		return
+	}
+
+	switch {
+	case r.URL.Path == "/v1/auth/token/lookup-self":
+		reply(http.StatusOK, map[string]interface{}{
+			"data": map[string]interface{}{"ttl": s.ttl, "renewable": true},
+		})
+	case r.URL.Path == "/v1/auth/token/renew-self":
+		s.renewals++
+		reply(http.StatusOK, map[string]interface{}{
+			"auth": map[string]interface{}{"client_token": s.token, "lease_duration": 3600, "renewable": true},
+		})
+	case strings.HasPrefix(r.URL.Path, "/v1/secret/data/"):
+		data, found := s.secrets[strings.TrimPrefix(r.URL.Path, "/v1/secret/data/")]
+		if !found {
+			reply(http.StatusNotFound, map[string]interface{}{"errors": []string{}})
+			return
+		}
+		reply(http.StatusOK, map[string]interface{}{
+			"data": map[string]interface{}{"data": data, "metadata": map[string]interface{}{"version": 1}},
+		})
+	case strings.HasPrefix(r.URL.Path, "/v1/kv/"):
+		data, found := s.secrets[strings.TrimPrefix(r.URL.Path, "/v1/kv/")]
+		if !found {
+			reply(http.StatusNotFound, map[string]interface{}{"errors": []string{}})
+			return
+		}
+		reply(http.StatusOK, map[string]interface{}{"lease_duration": 2764800, "data": data})
+	default:
+		reply(http.StatusNotFound, map[string]interface{}{"errors": []string{}})
+	}
+}
+
+func newVaultServer(t *testing.T) (*vaultServer, string) {
+	s := &vaultServer{
+		token: "hvs.token",
+		secrets: map[string]map[string]interface{}{
+			"telegraf/db":  {"user": "admin", "password": "secret-A"},
+			"telegraf/api": {"api_key": "abc", "port": 8086},
+		},
+	}
+	server := httptest.NewServer(s)
+	t.Cleanup(server.Close)
+	return s, server.URL
+}
+
+func TestSampleConfig(t *testing.T) {
+	plugin := &Vault{}
+	require.NotEmpty(t, plugin.SampleConfig())
+}
+
+func TestInitFail(t *testing.T) {
+	tests := []struct {
+		name     string
+		plugin   *Vault
+		expected string
+	}{
+		{
+			name:     "no address",
+			plugin:   &Vault{},
+			expected: "'address' required",
+		},
+		{
+			name:     "no paths",
+			plugin:   &Vault{Address: "http://localhost:8200"},
+			expected: "'paths' required",
+		},
+		{
+			name:     "invalid kv version",
+			plugin:   &Vault{Address: "http://localhost:8200", KVVersion: 3, Paths: []string{"telegraf"}},
+			expected: "invalid 'kv_version' 3",
+		},
+		{
+			name:     "empty path",
+			plugin:   &Vault{Address: "http://localhost:8200", Paths: []string{"/"}},
+			expected: "empty path in 'paths'",
+		},
+		{
+			name:     "invalid auth method",
+			plugin:   &Vault{Address: "http://localhost:8200", Paths: []string{"telegraf"}, AuthMethod: "ldap"},
+			expected: `invalid 'auth_method' "ldap"`,
+		},
+		{
+			name:     "no token",
+			plugin:   &Vault{Address: "http://localhost:8200", Paths: []string{"telegraf"}},
+			expected: "'token' required",
+		},
+		{
+			name:     "no role id",
+			plugin:   &Vault{Address: "http://localhost:8200", Paths: []string{"telegraf"}, AuthMethod: "approle"},
+			expected: "'role_id' required",
+		},
+		{
+			name:     "no kubernetes role",
+			plugin:   &Vault{Address: "http://localhost:8200", Paths: []string{"telegraf"}, AuthMethod: "kubernetes"},
+			expected: "'role' required",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.ErrorContains(t, tt.plugin.Init(), tt.expected)
+		})
+	}
+}
+
+func TestTokenKVv2(t *testing.T) {
+	server, addr := newVaultServer(t)
+	server.namespace = "team"
+
+	plugin := &Vault{
+		Address:   addr,
+		Namespace: "team",
+		Paths:     []string{"telegraf/db", "/telegraf/api/"},
+		Token:     config.NewSecret([]byte("hvs.token")),
+		Log:       testutil.Logger{},
+	}
+	require.NoError(t, plugin.Init())
+
+	keys, err := plugin.List()
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{"user", "password", "api_key", "port"}, keys)
+
+	resolver, err := plugin.GetResolver("password")
+	require.NoError(t, err)
+	s, dynamic, err := resolver()
+	require.NoError(t, err)
+	require.False(t, dynamic)
+	require.Equal(t, "secret-A", string(s))
+
+	s, err = plugin.Get("port")
+	require.NoError(t, err)
+	require.Equal(t, "8086", string(s))
+
+	_, err = plugin.Get("foo")
+	require.ErrorContains(t, err, "not found")
+}
+
+func TestTokenInvalid(t *testing.T) {
+	_, addr := newVaultServer(t)
+
+	plugin := &Vault{
+		Address: addr,
+		Paths:   []string{"telegraf/db"},
+		Token:   config.NewSecret([]byte("invalid")),
+		Log:     testutil.Logger{},
+	}
+	require.NoError(t, plugin.Init())
+
+	_, err := plugin.GetResolver("password")
+	require.ErrorContains(t, err, "received status code 403 (Forbidden): permission denied")
+}
+
+func TestAppRoleKVv1(t *testing.T) {
+	server, addr := newVaultServer(t)
+
+	plugin := &Vault{
+		Address:    addr,
+		Mount:      "kv",
+		KVVersion:  1,
+		Paths:      []string{"telegraf/db"},
+		AuthMethod: "approle",
+		AppRole: AppRoleConfig{
+			RoleID:   config.NewSecret([]byte("telegraf-role")),
+			SecretID: config.NewSecret([]byte("s3cr3t")),
+		},
+		CacheTTL: config.Duration(time.Hour),
+		Log:      testutil.Logger{},
+	}
+	require.NoError(t, plugin.Init())
+
+	resolver, err := plugin.GetResolver("user")
+	require.NoError(t, err)
+	s, dynamic, err := resolver()
+	require.NoError(t, err)
+	require.True(t, dynamic)
+	require.Equal(t, "admin", string(s))
+	require.Equal(t, 1, server.logins)
+}
+
+func TestKubernetes(t *testing.T) {
+	server, addr := newVaultServer(t)
+
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	require.NoError(t, os.WriteFile(tokenFile, []byte("service-account-jwt\n"), 0600))
+
+	plugin := &Vault{
+		Address:    addr,
+		Paths:      []string{"telegraf/api"},
+		AuthMethod: "kubernetes",
+		Kubernetes: KubernetesConfig{
+			Role:      "telegraf",
+			TokenFile: tokenFile,
+		},
+		Log: testutil.Logger{},
+	}
+	require.NoError(t, plugin.Init())
+
+	s, err := plugin.Get("api_key")
+	require.NoError(t, err)
+	require.Equal(t, "abc", string(s))
+	require.Equal(t, 1, server.logins)
+}
+
+func TestTokenRenewal(t *testing.T) {
+	server, addr := newVaultServer(t)
+	server.ttl = 30
+
+	plugin := &Vault{
+		Address:      addr,
+		Paths:        []string{"telegraf/db"},
+		AuthMethod:   "approle",
+		AppRole:      AppRoleConfig{RoleID: config.NewSecret([]byte("telegraf-role")), SecretID: config.NewSecret([]byte("s3cr3t"))},
+		ExpiryMargin: config.Duration(time.Minute),
+		Log:          testutil.Logger{},
+	}
+	require.NoError(t, plugin.Init())
+
+	// The issued token expires within the margin so it must be renewed on
+	// the next use instead of logging in again
+	plugin.refreshLock.Lock()
+	defer plugin.refreshLock.Unlock()
+	_, err := plugin.currentToken()
+	require.NoError(t, err)
+	require.Equal(t, 1, server.logins)
+	require.Equal(t, 0, server.renewals)
+
+	_, err = plugin.currentToken()
+	require.NoError(t, err)
+	require.Equal(t, 1, server.logins)
+	require.Equal(t, 1, server.renewals)
+
+	// The renewed token is valid for long enough
+	_, err = plugin.currentToken()
+	require.NoError(t, err)
+	require.Equal(t, 1, server.logins)
+	require.Equal(t, 1, server.renewals)
+}
+
+func TestWatchRotation(t *testing.T) {
+	server, addr := newVaultServer(t)
+
+	plugin := &Vault{
+		Address:  addr,
+		Paths:    []string{"telegraf/db"},
+		Token:    config.NewSecret([]byte("hvs.token")),
+		CacheTTL: config.Duration(50 * time.Millisecond),
+		Log:      testutil.Logger{},
+	}
+	require.NoError(t, plugin.Init())
+
+	resolver, err := plugin.GetResolver("password")
+	require.NoError(t, err)
+	s, _, err := resolver()
+	require.NoError(t, err)
+	require.Equal(t, "secret-A", string(s))
+
+	// Rotate the password
+	server.setSecret("telegraf/db", map[string]interface{}{"user": "admin", "password": "secret-B"})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	rotated := make(chan []string, 10)
+	go func() {
+		if err := plugin.Watch(ctx, func(keys []string) { rotated <- keys }); err != nil {
+			t.Error(err)
+		}
+	}()
+
+	select {
+	case keys := <-rotated:
+		require.Equal(t, []string{"password"}, keys)
+	case <-time.After(5 * time.Second):
+		require.Fail(t, "no rotation reported")
+	}
+
+	s, _, err = resolver()
+	require.NoError(t, err)
+	require.Equal(t, "secret-B", string(s))
+}
+
+func TestCachedSecretsOnFailure(t *testing.T) {
+	server, addr := newVaultServer(t)
+
+	logger := &testutil.CaptureLogger{}
+	plugin := &Vault{
+		Address:  addr,
+		Paths:    []string{"telegraf/db"},
+		Token:    config.NewSecret([]byte("hvs.token")),
+		CacheTTL: config.Duration(50 * time.Millisecond),
+		Log:      logger,
+	}
+	require.NoError(t, plugin.Init())
+
+	resolver, err := plugin.GetResolver("password")
+	require.NoError(t, err)
+	requests := server.requestCount()
+
+	// Resolving the secret is served from the cache even after it expired
+	// and the server is unavailable
+	server.setUnavailable(true)
+	time.Sleep(100 * time.Millisecond)
+	s, _, err := resolver()
+	require.NoError(t, err)
+	require.Equal(t, "secret-A", string(s))
+	require.Equal(t, requests, server.requestCount())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	rotated := make(chan []string, 10)
+	go func() {
+		if err := plugin.Watch(ctx, func(keys []string) { rotated <- keys }); err != nil {
+			t.Error(err)
+		}
+	}()
+
+	// Failing to refresh keeps the cached secrets
+	require.Eventually(t, func() bool {
+		return len(logger.Warnings()) > 0
+	}, 5*time.Second, 10*time.Millisecond)
+	require.Contains(t, logger.Warnings()[0], "keeping the cached secrets")
+	s, _, err = resolver()
+	require.NoError(t, err)
+	require.Equal(t, "secret-A", string(s))
+
+	// Secrets are picked up once the server is available again
+	server.setSecret("telegraf/db", map[string]interface{}{"user": "admin", "password": "secret-B"})
+	server.setUnavailable(false)
+	select {
+	case keys := <-rotated:
+		require.Equal(t, []string{"password"}, keys)
+	case <-time.After(5 * time.Second):
+		require.Fail(t, "no rotation reported")
+	}
+	s, _, err = resolver()
+	require.NoError(t, err)
+	require.Equal(t, "secret-B", string(s))
+}
+
+func TestDuplicateKeys(t *testing.T) {
+	server, addr := newVaultServer(t)
+	server.setSecret("other", map[string]interface{}{"password": "foo"})
+
+	plugin := &Vault{
+		Address: addr,
+		Paths:   []string{"telegraf/db", "other"},
+		Token:   config.NewSecret([]byte("hvs.token")),
+		Log:     testutil.Logger{},
+	}
+	require.NoError(t, plugin.Init())
+
+	_, err := plugin.Get("password")
+	require.ErrorContains(t, err, `secret "password" at "other" already defined by another path`)
+}
@@ -1,5 +1,7 @@
 package telegraf
 
+import "context"
+
 // SecretStore is an interface defining functions that a secret-store plugin must satisfy.
 type SecretStore interface {
 	Initializer
@@ -23,3 +25,12 @@ type SecretStore interface {
 // the secret will not change over time, or dynamic (true) to handle
 // secrets that change over time (e.g. TOTP).
 type ResolveFunc func() ([]byte, bool, error)
+
+// SecretStoreWatcher is an optional interface for secret-stores able to detect
+// changed secrets, e.g. due to credential rotation. Plugins using the changed
+// secrets are reconnected to pick up the new credentials.
+type SecretStoreWatcher interface {
+	// Watch checks for changed secrets until the context is cancelled and
+	// calls the notify function with the keys of the changed secrets.
+	Watch(ctx context.Context, notify func(keys []string)) error
+}
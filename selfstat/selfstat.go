@@ -75,6 +75,13 @@ func RegisterTiming(measurement, field string, tags map[string]string) Stat {
 	return registry.registerTiming("internal_"+measurement, field, tags)
 }
 
+// Unregister removes all stats registered for the given measurement and tags
+// from the selfstat registry. Stats previously returned by Register keep
+// working but are no longer returned by Metrics().
+func Unregister(measurement string, tags map[string]string) {
+	registry.unregister("internal_"+measurement, tags)
+}
+
 // Metrics returns all registered stats as telegraf metrics.
 func Metrics() []telegraf.Metric {
 	registry.mu.Lock()
@@ -153,6 +160,13 @@ func (r *Registry) registerTiming(measurement, field string, tags map[string]str
 	return s
 }
 
+func (r *Registry) unregister(measurement string, tags map[string]string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.stats, key(measurement, tags))
+}
+
 func (r *Registry) get(key uint64, field string) (Stat, bool) {
 	if _, ok := r.stats[key]; !ok {
 		return nil, false
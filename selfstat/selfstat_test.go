@@ -211,3 +211,26 @@ func TestRegisterCopy(t *testing.T) {
 	tags["new"] = "value"
 	require.NotEqual(t, tags, stat.Tags())
 }
+
+func TestUnregister(t *testing.T) {
+	testLock.Lock()
+	defer testCleanup()
+	registry = &Registry{
+		stats: make(map[uint64]map[string]Stat),
+	}
+
+	s := Register("test", "test_field1", map[string]string{"test": "foo"})
+	Register("test", "test_field2", map[string]string{"test": "foo"})
+	Register("test", "test_field1", map[string]string{"test": "bar"})
+	require.Len(t, Metrics(), 2)
+
+	Unregister("test", map[string]string{"test": "foo"})
+	metrics := Metrics()
+	require.Len(t, metrics, 1)
+	require.Equal(t, map[string]string{"test": "bar"}, metrics[0].Tags())
+
+	// The stat is still usable and registering it again creates a new one
+	s.Incr(1)
+	require.Equal(t, int64(1), s.Get())
+	require.Zero(t, Register("test", "test_field1", map[string]string{"test": "foo"}).Get())
+}